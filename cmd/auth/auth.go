@@ -1,28 +1,35 @@
 package main
 
 import (
-	//"context"
+	"context"
 	"flag"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 
-	//"os"
-
-	//"github.com/bloqs-sites/bloqsenjin/internal/auth"
+	"github.com/bloqs-sites/bloqsenjin/internal/auth"
+	dbh "github.com/bloqs-sites/bloqsenjin/internal/db"
+	"github.com/bloqs-sites/bloqsenjin/pkg/audit"
+	auth_server "github.com/bloqs-sites/bloqsenjin/pkg/auth"
 	auth_http "github.com/bloqs-sites/bloqsenjin/pkg/auth/http"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/pkg/email"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+	"github.com/redis/go-redis/v9"
 	"github.com/santhosh-tekuri/jsonschema/v5"
-	//dbh "github.com/bloqs-sites/bloqsenjin/internal/db"
-	//auth_server "github.com/bloqs-sites/bloqsenjin/pkg/auth"
-	//"github.com/bloqs-sites/bloqsenjin/proto"
-	//"google.golang.org/grpc"
+	"google.golang.org/grpc"
+
+	_ "github.com/joho/godotenv/autoload"
 )
 
 var (
 	httpPort = flag.Int("HTTPPort", 3000, "The HTTP server port")
-	//gRPCPort = flag.Int("gRPCPort", 50051, "The gRPC server port")
+	gRPCPort = flag.Int("gRPCPort", 50051, "The gRPC server port")
 
-	//s *grpc.Server
+	s *grpc.Server
 )
 
 func main() {
@@ -36,49 +43,77 @@ func main() {
 
 	ch := make(chan error)
 
+	ctx := context.Background()
+
 	// TODO: How can I make it that you can specify which implementation of the interfaces you want to use?
-	//creds := dbh.NewMySQL(os.Getenv("DSN"))
-	//secrets := dbh.NewKeyDB(dbh.NewRedisCreds("localhost", 6379, "", 0))
+	creds, err := dbh.NewMySQL(ctx, strings.TrimSpace(os.Getenv("BLOQS_AUTH_MYSQL_DSN")))
+	if err != nil {
+		panic(fmt.Errorf("error creating DB instance of type `%T`:\t%s", creds, err))
+	}
 
-	//auther := auth.NewBloqsAuther(context.Background(), &creds)
-	//tokener := auth.NewBloqsTokener(secrets)
+	opt, err := redis.ParseURL(strings.TrimSpace(os.Getenv("BLOQS_TOKENS_REDIS_DSN")))
+	if err != nil {
+		panic(fmt.Errorf("could not parse the `BLOQS_TOKENS_REDIS_DSN` to create the credentials to connect to the DB:\t%s", err))
+	}
 
-	//go startGRPCServer(ch, auther, tokener)
+	secrets, err := dbh.NewKeyDB(ctx, opt)
+	if err != nil {
+		panic(fmt.Errorf("error creating DB instance of type `%T`:\t%s", secrets, err))
+	}
+
+	auther, err := auth.NewBloqsAuther(ctx, creds)
+	if err != nil {
+		panic(err)
+	}
+	tokener := auth.NewBloqsTokener(secrets)
+
+	// the audit log is kept with the credentials without its own DB
+	logs := creds
+	if dsn, ok := os.LookupEnv("BLOQS_AUDIT_MYSQL_DSN"); ok {
+		if logs, err = dbh.NewMySQL(ctx, strings.TrimSpace(dsn)); err != nil {
+			panic(fmt.Errorf("error creating DB instance of type `%T`:\t%s", logs, err))
+		}
+	}
+
+	auditor, err := audit.NewSinkFromConf(ctx, logs)
+	if err != nil {
+		panic(err)
+	}
+
+	s = grpc.NewServer(grpc.UnaryInterceptor(auth_server.HttpErrorInterceptor))
+	var mailer auth_server.Mailer
+	queue, err := email.NewQueueFromConf()
+	if err != nil {
+		panic(err)
+	}
+	if queue != nil {
+		defer queue.Close()
+		mailer = queue
+	}
+
+	proto.RegisterAuthServer(s, auth_server.NewAuthServer(auther, tokener, mailer, auditor))
+
+	go startGRPCServer(ch)
 	go startHTTPServer(ch)
 
 	for i := range ch {
 		if i != nil {
+			s.GracefulStop()
 			panic(i)
 		}
 	}
 }
 
-//func startGRPCServer(ch chan error, a auth_server.Auther, t auth_server.Tokener) {
-//	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *gRPCPort))
-//	if err != nil {
-//		ch <- err
-//	}
-//
-//	s = grpc.NewServer()
-//
-//	as := auth_server.NewAuthServer(a, t)
-//
-//	proto.RegisterAuthServer(s, as)
-//	log.Printf("server listening at %v", lis.Addr())
-//	if err := s.Serve(lis); err != nil {
-//		ch <- err
-//	}
-//}
-
-//func createGRPCClient(ch chan error) (proto.AuthClient, func()) {
-//	conn, err := grpc.Dial(net.JoinHostPort("localhost", fmt.Sprint(*gRPCPort)), grpc.WithTransportCredentials(insecure.NewCredentials()))
-//	if err != nil {
-//		ch <- err
-//	}
-//	return proto.NewAuthClient(conn), func() {
-//		conn.Close()
-//	}
-//}
+func startGRPCServer(ch chan error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *gRPCPort))
+	if err != nil {
+		ch <- err
+		return
+	}
+
+	log.Printf("Auth gRPC server listening at %v", lis.Addr())
+	ch <- s.Serve(lis)
+}
 
 func startHTTPServer(ch chan error) {
 	fmt.Printf("Auth HTTP server port:\t %d\n", *httpPort)
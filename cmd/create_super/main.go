@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	internal_auth "github.com/bloqs-sites/bloqsenjin/internal/auth"
 	"github.com/bloqs-sites/bloqsenjin/internal/db"
+	"github.com/bloqs-sites/bloqsenjin/pkg/audit"
 	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
 	"github.com/bloqs-sites/bloqsenjin/proto"
@@ -42,7 +44,7 @@ func init() {
 
 func main() {
 	ctx := context.Background()
-	a, err := authSrv(ctx)
+	a, l, err := authSrv(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -59,17 +61,51 @@ func main() {
 		panic(err)
 	}
 
-	if err = a.GrantSuper(ctx, creds); err != nil {
+	err = a.GrantSuper(ctx, creds)
+
+	e := &audit.Event{
+		Actor:  "create_super",
+		Target: *email,
+		Action: audit.GRANT_SUPER,
+		Result: audit.SUCCESS,
+		At:     time.Now(),
+	}
+	if err != nil {
+		e.Result, e.Detail = audit.FAILURE, err.Error()
+	}
+	if err := l.Record(ctx, e); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	if err != nil {
 		a.SignOutBasic(ctx, user)
 		panic(err)
 	}
 }
 
-func authSrv(ctx context.Context) (auth.Auther, error) {
+func authSrv(ctx context.Context) (auth.Auther, audit.Sink, error) {
 	creds, err := db.NewMySQL(ctx, strings.TrimSpace(os.Getenv("BLOQS_AUTH_MYSQL_DSN")))
 	if err != nil {
-		return nil, fmt.Errorf("error creating DB instance of type `%T`:\t%s", creds, err)
+		return nil, nil, fmt.Errorf("error creating DB instance of type `%T`:\t%s", creds, err)
+	}
+
+	a, err := internal_auth.NewBloqsAuther(ctx, creds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the audit log is kept with the credentials without its own DB
+	logs := creds
+	if dsn, ok := os.LookupEnv("BLOQS_AUDIT_MYSQL_DSN"); ok {
+		if logs, err = db.NewMySQL(ctx, strings.TrimSpace(dsn)); err != nil {
+			return nil, nil, fmt.Errorf("error creating DB instance of type `%T`:\t%s", logs, err)
+		}
+	}
+
+	l, err := audit.NewSinkFromConf(ctx, logs)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return internal_auth.NewBloqsAuther(ctx, creds)
+	return a, l, nil
 }
@@ -0,0 +1,201 @@
+// mock_oidc is an OpenID provider to try the OpenID Connect log in locally.
+// Every authorization is granted right away to the identity given by the
+// flags, or by the `email` and `sub` query parameters of the authorization
+// request. Configure it as a provider with:
+//
+//	"mock": {"issuer": "http://localhost:9000", "client_id": "bloqs"}
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	port     = flag.Int("port", 9000, "The HTTP server port")
+	issuer   = flag.String("issuer", "", "The issuer, defaults to http://localhost:<port>")
+	email    = flag.String("email", "mock@localhost", "The email of the identity")
+	subject  = flag.String("sub", "mock", "The subject of the identity")
+	verified = flag.Bool("verified", true, "If the email of the identity is verified")
+)
+
+type grant struct {
+	client    string
+	redirect  string
+	challenge string
+	nonce     string
+	email     string
+	subject   string
+}
+
+var (
+	priv ed25519.PrivateKey
+	pub  ed25519.PublicKey
+
+	grants    = map[string]grant{}
+	grants_mu sync.Mutex
+)
+
+func main() {
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = fmt.Sprintf("http://localhost:%d", *port)
+	}
+
+	var err error
+	if pub, priv, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		panic(err)
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", discovery)
+	http.HandleFunc("/authorize", authorize)
+	http.HandleFunc("/token", token)
+	http.HandleFunc("/jwks", jwks)
+
+	log.Printf("Mock OpenID provider `%s` listening on port %d", *issuer, *port)
+	panic(http.ListenAndServe(fmt.Sprintf(":%d", *port), nil))
+}
+
+func discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                *issuer,
+		"authorization_endpoint":                *issuer + "/authorize",
+		"token_endpoint":                        *issuer + "/token",
+		"jwks_uri":                              *issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"EdDSA"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid `redirect_uri`", http.StatusBadRequest)
+		return
+	}
+
+	if q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "only the `S256` PKCE challenges are supported", http.StatusBadRequest)
+		return
+	}
+
+	code, err := auth.RandomString(16)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	g := grant{
+		client:    q.Get("client_id"),
+		redirect:  q.Get("redirect_uri"),
+		challenge: q.Get("code_challenge"),
+		nonce:     q.Get("nonce"),
+		email:     *email,
+		subject:   *subject,
+	}
+	if v := q.Get("email"); v != "" {
+		g.email = v
+	}
+	if v := q.Get("sub"); v != "" {
+		g.subject = v
+	}
+
+	grants_mu.Lock()
+	grants[code] = g
+	grants_mu.Unlock()
+
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func token(w http.ResponseWriter, r *http.Request) {
+	tokenError := func(e string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": e})
+	}
+
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.FormValue("grant_type") != "authorization_code" {
+		tokenError("invalid_request")
+		return
+	}
+
+	code := r.FormValue("code")
+
+	grants_mu.Lock()
+	g, ok := grants[code]
+	delete(grants, code)
+	grants_mu.Unlock()
+
+	client := r.FormValue("client_id")
+	if user, _, ok := r.BasicAuth(); ok {
+		client, _ = url.QueryUnescape(user)
+	}
+
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || g.client != client || g.redirect != r.FormValue("redirect_uri") || g.challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		tokenError("invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	id := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"iss":            *issuer,
+		"sub":            g.subject,
+		"aud":            g.client,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          g.nonce,
+		"email":          g.email,
+		"email_verified": *verified,
+	})
+	id.Header["kid"] = "mock"
+
+	str, err := id.SignedString(priv)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": code,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     str,
+	})
+}
+
+func jwks(w http.ResponseWriter, r *http.Request) {
+	jwk, err := auth.NewJWK("mock", pub)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{*jwk}})
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/google/uuid"
+)
+
+// Account merges all the credentials of identifier, it's super or verified if
+// any of them is.
+func (a *BloqsAuther) Account(ctx context.Context, identifier string) (*auth.Account, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{
+			"account":  new(string),
+			"is_super": new(bool),
+			"verified": new(bool),
+		}
+	}, []db.Condition{{Column: "identifier", Value: identifier}})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) == 0 {
+		return nil, &mux.HttpError{
+			Body:   "credentials do not exist",
+			Status: http.StatusNotFound,
+		}
+	}
+
+	acc := &auth.Account{Identifier: identifier}
+	for _, i := range res.Rows {
+		if acc.ID == "" {
+			acc.ID = *i["account"].(*string)
+		}
+		acc.Super = acc.Super || *i["is_super"].(*bool)
+		acc.Verified = acc.Verified || *i["verified"].(*bool)
+	}
+
+	if acc.ID == "" {
+		acc.ID = identifier
+	}
+
+	if acc.Roles, err = a.Roles(ctx, identifier); err != nil {
+		return nil, err
+	}
+
+	return acc, nil
+}
+
+// accountFor is the account of new credentials for identifier. It's the one
+// of the credentials it already has, or the identifier itself, which is what
+// the accounts were before they could change their email, unless it already
+// is the account of credentials that changed to another email.
+func (a *BloqsAuther) accountFor(ctx context.Context, identifier string) (string, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"account": new(string)}
+	}, []db.Condition{{Column: "identifier", Value: identifier}})
+	if err != nil {
+		return "", &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	for _, i := range res.Rows {
+		if account := *i["account"].(*string); account != "" {
+			return account, nil
+		}
+	}
+
+	res, err = a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "account", Value: identifier}})
+	if err != nil {
+		return "", &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) > 0 {
+		return uuid.NewString(), nil
+	}
+
+	return identifier, nil
+}
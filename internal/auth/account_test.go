@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+)
+
+// accounts are the credentials of user@example.com, which changed from the
+// email old@example.com, and the ones from before there were accounts.
+func accounts() *BloqsAuther {
+	return &BloqsAuther{creds: &credsTables{tables: map[string][]map[string]any{
+		table: {
+			{"id": int64(1), "identifier": "user@example.com", "account": "old@example.com", "is_super": false, "verified": false},
+			{"id": int64(2), "identifier": "user@example.com", "account": "old@example.com", "is_super": true, "verified": true},
+			{"id": int64(3), "identifier": "legacy@example.com", "account": "", "is_super": false, "verified": false},
+		},
+		roles_table: {
+			{"identifier": "user@example.com", "role": auth.ROLE_SELLER},
+		},
+	}}}
+}
+
+func TestAccount(t *testing.T) {
+	ctx := context.Background()
+	a := accounts()
+
+	acc, err := a.Account(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &auth.Account{ID: "old@example.com", Identifier: "user@example.com", Super: true, Verified: true, Roles: []string{auth.ROLE_SELLER}}
+	if !reflect.DeepEqual(acc, want) {
+		t.Errorf("Account() = %+v, want %+v", acc, want)
+	}
+
+	if acc, err := a.Account(ctx, "legacy@example.com"); err != nil || acc.ID != "legacy@example.com" {
+		t.Errorf("Account() from before there were accounts = (%+v, %v), want it by the identifier", acc, err)
+	}
+
+	if _, err := a.Account(ctx, "nobody@example.com"); httpStatus(err) != http.StatusNotFound {
+		t.Errorf("Account() without credentials error = %v, want a 404", err)
+	}
+}
+
+func TestAccountFor(t *testing.T) {
+	a := accounts()
+
+	tests := []struct {
+		name       string
+		identifier string
+		want       string
+	}{
+		{"the one it has", "user@example.com", "old@example.com"},
+		{"a new one", "new@example.com", "new@example.com"},
+		{"the email of an account that changed it", "old@example.com", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := a.accountFor(context.Background(), tt.identifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tt.want == "" {
+			if got == tt.identifier || got == "old@example.com" {
+				t.Errorf("accountFor() %s = %q, want another account", tt.name, got)
+			}
+		} else if got != tt.want {
+			t.Errorf("accountFor() %s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// apiKeyColumns are the columns of a key, without its hash.
+func apiKeyColumns() map[string]any {
+	return map[string]any{
+		"prefix":      new(string),
+		"identifier":  new(string),
+		"label":       new(string),
+		"permissions": new(string),
+		"created_at":  new(int64),
+		"expires_at":  new(int64),
+		"last_used":   new(int64),
+	}
+}
+
+func apiKeyFromRow(row map[string]any) (auth.APIKey, error) {
+	k := auth.APIKey{
+		Prefix:     *row["prefix"].(*string),
+		Identifier: *row["identifier"].(*string),
+		Label:      *row["label"].(*string),
+		CreatedAt:  time.Unix(*row["created_at"].(*int64), 0),
+	}
+
+	if err := json.Unmarshal([]byte(*row["permissions"].(*string)), &k.Permissions); err != nil {
+		return k, err
+	}
+
+	if at := *row["expires_at"].(*int64); at != 0 {
+		k.ExpiresAt = time.Unix(at, 0)
+	}
+
+	if at := *row["last_used"].(*int64); at != 0 {
+		k.LastUsed = time.Unix(at, 0)
+	}
+
+	return k, nil
+}
+
+func (a *BloqsAuther) CreateAPIKey(ctx context.Context, key *auth.APIKey, hash string) error {
+	permissions, err := json.Marshal(key.Permissions)
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	var expires int64
+	if !key.ExpiresAt.IsZero() {
+		expires = key.ExpiresAt.Unix()
+	}
+
+	if _, err := a.creds.Insert(ctx, api_keys_table, []map[string]any{
+		{
+			"prefix":      key.Prefix,
+			"hash":        hash,
+			"identifier":  key.Identifier,
+			"label":       key.Label,
+			"permissions": string(permissions),
+			"created_at":  key.CreatedAt.Unix(),
+			"expires_at":  expires,
+		},
+	}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return nil
+}
+
+func (a *BloqsAuther) APIKeys(ctx context.Context, identifier string) ([]auth.APIKey, error) {
+	res, err := a.creds.Select(ctx, api_keys_table, apiKeyColumns, []db.Condition{
+		{Column: "identifier", Value: identifier},
+	})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	keys := make([]auth.APIKey, 0, len(res.Rows))
+	for _, i := range res.Rows {
+		k, err := apiKeyFromRow(i)
+		if err != nil {
+			return nil, &mux.HttpError{
+				Body:   err.Error(),
+				Status: http.StatusInternalServerError,
+			}
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (a *BloqsAuther) RevokeAPIKey(ctx context.Context, identifier string, prefix string) error {
+	res, err := a.creds.Select(ctx, api_keys_table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "prefix", Value: prefix},
+	})
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) == 0 {
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("there's no API key `%s`", prefix),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if err := a.creds.Delete(ctx, api_keys_table, map[string]any{
+		"identifier": identifier,
+		"prefix":     prefix,
+	}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return nil
+}
+
+func (a *BloqsAuther) CheckAPIKey(ctx context.Context, key string) (*auth.APIKey, error) {
+	invalid := &mux.HttpError{
+		Body:   "invalid API key",
+		Status: http.StatusUnauthorized,
+	}
+
+	prefix, err := auth.ParseAPIKey(key)
+	if err != nil {
+		return nil, invalid
+	}
+
+	res, err := a.creds.Select(ctx, api_keys_table, func() map[string]any {
+		columns := apiKeyColumns()
+		columns["hash"] = new(string)
+		return columns
+	}, []db.Condition{{Column: "prefix", Value: prefix}})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) != 1 {
+		return nil, invalid
+	}
+
+	row := res.Rows[0]
+	if subtle.ConstantTimeCompare([]byte(*row["hash"].(*string)), []byte(auth.HashAPIKey(key))) != 1 {
+		return nil, invalid
+	}
+
+	k, err := apiKeyFromRow(row)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if k.Expired() {
+		return nil, &mux.HttpError{
+			Body:   "the API key expired",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	// the key can't have more than its credentials have now, they could have
+	// lost roles or been deleted since it was made
+	acc, err := a.Account(ctx, k.Identifier)
+	if err != nil {
+		if err, ok := err.(*mux.HttpError); ok && err.Status == http.StatusNotFound {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	k.Permissions = auth.CapPermissions(k.Permissions, acc.Super, acc.Verified, acc.Roles)
+	k.Account = acc.ID
+
+	// a key can be used many times a second, once a minute is precise enough
+	if now := time.Now(); now.Sub(k.LastUsed) > time.Minute {
+		if err := a.creds.Update(ctx, api_keys_table, map[string]any{
+			"last_used": now.Unix(),
+		}, map[string]any{"prefix": prefix}); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+		k.LastUsed = now
+	}
+
+	return &k, nil
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// credsTables keeps the tables of the credentials in memory, it selects,
+// updates and deletes the rows equal to the conditions, numbers the rows it
+// inserts and counts the updates.
+type credsTables struct {
+	db.DataManipulater
+	tables  map[string][]map[string]any
+	ids     int64
+	updates int
+}
+
+func equalTo(row map[string]any, conditions map[string]any) bool {
+	for k, v := range conditions {
+		if fmt.Sprint(row[k]) != fmt.Sprint(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *credsTables) Select(ctx context.Context, table string, columns func() map[string]any, where []db.Condition) (db.Result, error) {
+	conditions := map[string]any{}
+	for _, i := range where {
+		conditions[i.Column] = i.Value
+	}
+
+	res := db.Result{Rows: []db.JSON{}}
+	for _, row := range c.tables[table] {
+		if !equalTo(row, conditions) {
+			continue
+		}
+
+		selected := db.JSON{}
+		for k, v := range columns() {
+			reflect.ValueOf(v).Elem().Set(reflect.ValueOf(row[k]))
+			selected[k] = v
+		}
+		res.Rows = append(res.Rows, selected)
+	}
+
+	return res, nil
+}
+
+func (c *credsTables) Insert(ctx context.Context, table string, rows []map[string]any) (db.Result, error) {
+	for _, row := range rows {
+		c.ids++
+		row["id"] = c.ids
+		c.tables[table] = append(c.tables[table], row)
+	}
+	return db.Result{}, nil
+}
+
+func (c *credsTables) Update(ctx context.Context, table string, assignments map[string]any, conditions map[string]any) error {
+	c.updates++
+	for _, row := range c.tables[table] {
+		if equalTo(row, conditions) {
+			for k, v := range assignments {
+				row[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+func (c *credsTables) Delete(ctx context.Context, table string, conditions map[string]any) error {
+	kept := []map[string]any{}
+	for _, row := range c.tables[table] {
+		if !equalTo(row, conditions) {
+			kept = append(kept, row)
+		}
+	}
+	c.tables[table] = kept
+	return nil
+}
+
+// apiKeyCreds are the credentials of user@example.com, a verified user, with
+// the key made by CreateAPIKey.
+func apiKeyCreds(t *testing.T, k *auth.APIKey) (*BloqsAuther, *credsTables, string) {
+	t.Helper()
+
+	c := &credsTables{tables: map[string][]map[string]any{
+		table: {
+			{"identifier": "user@example.com", "account": "account", "is_super": false, "verified": true},
+		},
+		roles_table: {},
+	}}
+	a := &BloqsAuther{creds: c}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.Prefix = prefix
+
+	if err := a.CreateAPIKey(context.Background(), k, hash); err != nil {
+		t.Fatal(err)
+	}
+	// the column has no default in memory
+	for _, row := range c.tables[api_keys_table] {
+		row["last_used"] = int64(0)
+	}
+
+	return a, c, key
+}
+
+func testAPIKey() *auth.APIKey {
+	return &auth.APIKey{
+		Identifier:  "user@example.com",
+		Label:       "deploys",
+		Permissions: auth.Union(auth.CREATE_BLOQ, auth.READ_PROFILE),
+		CreatedAt:   time.Now(),
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare changes the key before it's made, after changes what's
+		// stored and can replace the key that's checked
+		prepare func(k *auth.APIKey)
+		after   func(c *credsTables, key string) string
+		status  uint16
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:    "expires later",
+			prepare: func(k *auth.APIKey) { k.ExpiresAt = time.Now().Add(time.Hour) },
+		},
+		{
+			name:    "expired",
+			prepare: func(k *auth.APIKey) { k.ExpiresAt = time.Now().Add(-time.Second) },
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name: "wrong secret",
+			after: func(c *credsTables, key string) string {
+				return key[:len(key)-1] + "x"
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown prefix",
+			after: func(c *credsTables, key string) string {
+				return "bloqs_zzzzzzzz_c2VjcmV0"
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "not a key",
+			after: func(c *credsTables, key string) string {
+				return "eyJhbGciOiJFZERTQSJ9.e30.c2ln"
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "credentials gone",
+			after: func(c *credsTables, key string) string {
+				c.tables[table] = nil
+				return key
+			},
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := testAPIKey()
+			if tt.prepare != nil {
+				tt.prepare(k)
+			}
+			a, c, key := apiKeyCreds(t, k)
+			if tt.after != nil {
+				key = tt.after(c, key)
+			}
+
+			got, err := a.CheckAPIKey(context.Background(), key)
+			if tt.status != 0 {
+				var e *mux.HttpError
+				if !errors.As(err, &e) || e.Status != tt.status {
+					t.Errorf("CheckAPIKey() error = %v, want a %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckAPIKey() error = %v", err)
+			}
+
+			if got.Prefix != k.Prefix || got.Identifier != k.Identifier || got.Label != k.Label || got.Account != "account" {
+				t.Errorf("CheckAPIKey() = %+v, want the key %+v", got, k)
+			}
+			if got.Permissions != k.Permissions {
+				t.Errorf("CheckAPIKey() permissions = %v, want %v", got.Permissions, k.Permissions)
+			}
+		})
+	}
+}
+
+func TestCheckAPIKeyCapped(t *testing.T) {
+	k := testAPIKey()
+	k.Permissions = auth.Union(auth.CREATE_BLOQ, auth.GRANT_SUPER)
+	a, _, key := apiKeyCreds(t, k)
+
+	// the credentials aren't super anymore
+	got, err := a.CheckAPIKey(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Permissions.Has(auth.GRANT_SUPER) || !got.Permissions.Has(auth.CREATE_BLOQ) {
+		t.Errorf("CheckAPIKey() permissions = %v, want them capped to the credentials", got.Permissions)
+	}
+}
+
+func TestCheckAPIKeyLastUsed(t *testing.T) {
+	ctx := context.Background()
+	a, c, key := apiKeyCreds(t, testAPIKey())
+
+	got, err := a.CheckAPIKey(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.updates != 1 || got.LastUsed.IsZero() {
+		t.Fatalf("CheckAPIKey() made %d updates, used at %v, want the first use recorded", c.updates, got.LastUsed)
+	}
+	used := c.tables[api_keys_table][0]["last_used"].(int64)
+
+	// right after it's not recorded again
+	if _, err := a.CheckAPIKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if c.updates != 1 {
+		t.Errorf("CheckAPIKey() made %d updates, want the use right after not recorded", c.updates)
+	}
+
+	// a while after it is
+	c.tables[api_keys_table][0]["last_used"] = used - 120
+	if _, err := a.CheckAPIKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if c.updates != 2 || c.tables[api_keys_table][0]["last_used"].(int64) < used {
+		t.Errorf("CheckAPIKey() made %d updates, want the use a while after recorded", c.updates)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	ctx := context.Background()
+	k := testAPIKey()
+	a, _, key := apiKeyCreds(t, k)
+
+	// only the credentials of the key can revoke it
+	var e *mux.HttpError
+	if err := a.RevokeAPIKey(ctx, "other@example.com", k.Prefix); !errors.As(err, &e) || e.Status != http.StatusNotFound {
+		t.Errorf("RevokeAPIKey() of other credentials error = %v, want a 404", err)
+	}
+	if _, err := a.CheckAPIKey(ctx, key); err != nil {
+		t.Fatalf("CheckAPIKey() error = %v", err)
+	}
+
+	if err := a.RevokeAPIKey(ctx, k.Identifier, k.Prefix); err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+	if _, err := a.CheckAPIKey(ctx, key); !errors.As(err, &e) || e.Status != http.StatusUnauthorized {
+		t.Errorf("CheckAPIKey() of a revoked key error = %v, want a 401", err)
+	}
+
+	keys, err := a.APIKeys(ctx, k.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("APIKeys() = %+v, want none", keys)
+	}
+
+	if err := a.RevokeAPIKey(ctx, k.Identifier, k.Prefix); !errors.As(err, &e) || e.Status != http.StatusNotFound {
+		t.Errorf("RevokeAPIKey() again error = %v, want a 404", err)
+	}
+}
@@ -2,7 +2,7 @@ package auth
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,14 +13,19 @@ import (
 	"github.com/bloqs-sites/bloqsenjin/pkg/email"
 	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
 	"github.com/bloqs-sites/bloqsenjin/proto"
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	jwt_prefix    = "token:jwt:%s"
-	table         = "credentials"
-	id_type_table = "id-type"
-	failed_table  = "failed"
+	jwt_prefix         = "token:jwt:%s"
+	revoked_prefix     = "token:revoked:%s"
+	revoked_sub_prefix = "token:revoked:sub:%s"
+	table              = "credentials"
+	id_type_table      = "id-type"
+	failed_table       = "failed"
+	challenges_table   = "webauthn_challenges"
+	recovery_table     = "recovery_codes"
+	roles_table        = "credential_roles"
+	api_keys_table     = "api_keys"
 )
 
 type BloqsAuther struct {
@@ -40,30 +45,151 @@ func NewBloqsAuther(ctx context.Context, creds db.DataManipulater) (*BloqsAuther
 				"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"`modified_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"`last_log_in` TIMESTAMP",
-				"UNIQUE (`identifier`, `type`)",
+				"`external_id` VARCHAR(320) NOT NULL DEFAULT ''",
+				"`sign_count` BIGINT UNSIGNED NOT NULL DEFAULT 0",
+				"`totp_secret` VARCHAR(64) NOT NULL DEFAULT ''",
+				"`totp_enabled` BOOLEAN NOT NULL DEFAULT 0",
+				"`totp_last` BIGINT NOT NULL DEFAULT 0",
+				"`verified` BOOLEAN NOT NULL DEFAULT 0",
+				// the identifier can change, the account is what stays
+				"`account` VARCHAR(320) NOT NULL DEFAULT ''",
+				"INDEX (`account`)",
+				// the same identifier can have more than one authenticator
+				"UNIQUE `credential` (`identifier`, `type`, `external_id`)",
 			},
 		},
 		{
-			Name: "failed",
+			Name: failed_table,
 			Columns: []string{
 				"`id` INTEGER PRIMARY KEY AUTO_INCREMENT",
 				"`credential` INTEGER NOT NULL",
 				"`timestamp` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
+				"`ip` VARCHAR(45) NOT NULL DEFAULT ''",
+				"`at` BIGINT NOT NULL DEFAULT 0",
+				"INDEX (`credential`, `at`)",
+				"INDEX (`ip`, `at`)",
 				//fmt.Sprintf("FOREIGN KEY (`credential`) REFERENCES `%s`(`id`)", table),
 			},
 		},
+		{
+			Name: recovery_table,
+			Columns: []string{
+				"`id` INTEGER PRIMARY KEY AUTO_INCREMENT",
+				"`credential` INTEGER NOT NULL",
+				"`hash` CHAR(64) NOT NULL",
+				"UNIQUE (`credential`, `hash`)",
+			},
+		},
+		{
+			Name: roles_table,
+			Columns: []string{
+				"`id` INTEGER PRIMARY KEY AUTO_INCREMENT",
+				"`identifier` VARCHAR(320) NOT NULL",
+				"`role` VARCHAR(64) NOT NULL",
+				"`granted_by` VARCHAR(320) NOT NULL DEFAULT ''",
+				"`granted_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
+				"UNIQUE (`identifier`, `role`)",
+			},
+		},
+		{
+			Name: api_keys_table,
+			Columns: []string{
+				"`id` INTEGER PRIMARY KEY AUTO_INCREMENT",
+				"`prefix` CHAR(8) NOT NULL",
+				"`hash` CHAR(64) NOT NULL",
+				"`identifier` VARCHAR(320) NOT NULL",
+				"`label` VARCHAR(80) NOT NULL DEFAULT ''",
+				"`permissions` TEXT NOT NULL",
+				"`created_at` BIGINT NOT NULL",
+				"`expires_at` BIGINT NOT NULL DEFAULT 0",
+				"`last_used` BIGINT NOT NULL DEFAULT 0",
+				"UNIQUE (`prefix`)",
+				"INDEX (`identifier`)",
+			},
+		},
+		{
+			Name: challenges_table,
+			Columns: []string{
+				"`challenge` VARCHAR(64) PRIMARY KEY",
+				"`identifier` VARCHAR(320) NOT NULL DEFAULT ''",
+				"`ceremony` VARCHAR(16) NOT NULL",
+				"`expires` BIGINT NOT NULL",
+			},
+		},
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err := creds.Migrate(ctx, migrations); err != nil {
+		return nil, err
+	}
+
 	return &BloqsAuther{creds}, nil
 }
 
-func (a *BloqsAuther) SignInBasic(ctx context.Context, c *proto.Credentials_Basic) error {
-	u := time.Now()
-	if err := email.VerifyEmail(ctx, c.Basic.Email); err != nil {
+// migrations bring the tables made by older versions up to date.
+var migrations = []db.Migration{
+	{
+		ID: "credentials_external_id",
+		Statements: []string{
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `external_id` VARCHAR(320) NOT NULL DEFAULT '';", table),
+			// it could be null at first
+			fmt.Sprintf("UPDATE `%s` SET `external_id` = '' WHERE `external_id` IS NULL;", table),
+			fmt.Sprintf("ALTER TABLE `%s` MODIFY COLUMN `external_id` VARCHAR(320) NOT NULL DEFAULT '';", table),
+		},
+	},
+	{
+		ID: "credentials_webauthn",
+		Statements: []string{
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `sign_count` BIGINT UNSIGNED NOT NULL DEFAULT 0;", table),
+			// the key was on `identifier` and `type` only, the new one is
+			// added before the old one is dropped so they're always unique
+			fmt.Sprintf("ALTER TABLE `%s` ADD UNIQUE `credential` (`identifier`, `type`, `external_id`);", table),
+			fmt.Sprintf("ALTER TABLE `%s` DROP INDEX `identifier`;", table),
+		},
+	},
+	{
+		ID: "credentials_totp",
+		Statements: []string{
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `totp_secret` VARCHAR(64) NOT NULL DEFAULT '';", table),
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `totp_enabled` BOOLEAN NOT NULL DEFAULT 0;", table),
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `totp_last` BIGINT NOT NULL DEFAULT 0;", table),
+		},
+	},
+	{
+		ID: "failed_ip",
+		Statements: []string{
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `ip` VARCHAR(45) NOT NULL DEFAULT '';", failed_table),
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `at` BIGINT NOT NULL DEFAULT 0;", failed_table),
+			fmt.Sprintf("UPDATE `%s` SET `at` = UNIX_TIMESTAMP(`timestamp`) WHERE `at` = 0;", failed_table),
+			fmt.Sprintf("ALTER TABLE `%s` ADD INDEX `credential` (`credential`, `at`);", failed_table),
+			fmt.Sprintf("ALTER TABLE `%s` ADD INDEX `ip` (`ip`, `at`);", failed_table),
+		},
+	},
+	{
+		ID: "credentials_verified",
+		Statements: []string{
+			// the credentials from before the verification can't be told to
+			// verify, they're kept as they were
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `verified` BOOLEAN NOT NULL DEFAULT 1;", table),
+			fmt.Sprintf("ALTER TABLE `%s` MODIFY COLUMN `verified` BOOLEAN NOT NULL DEFAULT 0;", table),
+		},
+	},
+	{
+		ID: "credentials_account",
+		Statements: []string{
+			fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `account` VARCHAR(320) NOT NULL DEFAULT '';", table),
+			fmt.Sprintf("ALTER TABLE `%s` ADD INDEX `account` (`account`);", table),
+			// the REST data was kept by the identifier until now
+			fmt.Sprintf("UPDATE `%s` SET `account` = `identifier` WHERE `account` = '';", table),
+		},
+	},
+}
+
+func verifyEmail(ctx context.Context, address string) error {
+	if err := email.VerifyEmail(ctx, address); err != nil {
 		status := uint16(http.StatusInternalServerError)
 
 		switch err := err.(type) {
@@ -79,15 +205,16 @@ func (a *BloqsAuther) SignInBasic(ctx context.Context, c *proto.Credentials_Basi
 		}
 	}
 
-	log.Printf("%s took %v", "VerifyEmail", time.Since(u))
-
-	pass := c.Basic.Password
+	return nil
+}
 
-	if len(pass) > 72 { // bcrypt says that "GenerateFromPassword does not accept passwords longer than 72 bytes"
-		return errors.New("the password provided it's too long (bigger than 72 bytes)")
+func (a *BloqsAuther) SignInBasic(ctx context.Context, c *proto.Credentials_Basic) error {
+	u := time.Now()
+	if err := verifyEmail(ctx, c.Basic.Email); err != nil {
+		return err
 	}
 
-	// TODO: test password entropy
+	log.Printf("%s took %v", "VerifyEmail", time.Since(u))
 
 	u = time.Now()
 	exists, err := a.creds.Select(ctx, table, func() map[string]any {
@@ -113,18 +240,24 @@ func (a *BloqsAuther) SignInBasic(ctx context.Context, c *proto.Credentials_Basi
 	}
 
 	u = time.Now()
-	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	hash, err := hashPassword(c.Basic.Password, c.Basic.Email)
 	if err != nil {
 		return err
 	}
 	log.Printf("%s took %v", "GenerateFromPassword", time.Since(u))
 
+	account, err := a.accountFor(ctx, c.Basic.Email)
+	if err != nil {
+		return err
+	}
+
 	u = time.Now()
 	if _, err := a.creds.Insert(ctx, table, []map[string]any{
 		{
 			"identifier": c.Basic.Email,
 			"type":       auth.BASIC_EMAIL,
 			"secret":     hash,
+			"account":    account,
 		},
 	}); err != nil {
 		return err
@@ -149,6 +282,81 @@ func (a *BloqsAuther) SignOutBasic(ctx context.Context, c *proto.Credentials_Bas
 	return nil
 }
 
+func (a *BloqsAuther) SignOut(ctx context.Context, identifier string, typ auth.AuthType) error {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{
+			"id": new(int64),
+		}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "type", Value: strconv.Itoa(int(typ))},
+	})
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) != 1 {
+		return &mux.HttpError{
+			Body:   "credentials do not exist",
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if err := a.creds.Delete(ctx, table, map[string]any{
+		"identifier": identifier,
+		"type":       strconv.Itoa(int(typ)),
+	}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if err := a.creds.Delete(ctx, failed_table, map[string]any{
+		"credential": *res.Rows[0]["id"].(*int64),
+	}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	// the API keys were made with tokens of the credentials, so they can't
+	// outlive them
+	if err := a.creds.Delete(ctx, api_keys_table, map[string]any{"identifier": identifier}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	// the roles are of the account, so they go with the last credentials, or
+	// whoever signs in with the identifier next would have them
+	res, err = a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "identifier", Value: identifier}})
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) == 0 {
+		if err := a.creds.Delete(ctx, roles_table, map[string]any{"identifier": identifier}); err != nil {
+			return &mux.HttpError{
+				Body:   err.Error(),
+				Status: http.StatusInternalServerError,
+			}
+		}
+	}
+
+	return nil
+}
+
 func (a *BloqsAuther) GrantSuper(ctx context.Context, creds *proto.Credentials) error {
 	return a.super(ctx, creds, true)
 }
@@ -204,22 +412,6 @@ func (a *BloqsAuther) super(ctx context.Context, creds *proto.Credentials, super
 }
 
 func (a *BloqsAuther) IsSuperBasic(ctx context.Context, creds *proto.Credentials_Basic) (super bool, err error) {
-	var ok bool
-	super = false
-
-	if err = a.CheckAccessBasic(ctx, creds); err != nil {
-		if err, ok = err.(*mux.HttpError); ok {
-			return
-		}
-
-		var status uint32 = http.StatusInternalServerError
-		err = &mux.HttpError{
-			Body:   "",
-			Status: uint16(status),
-		}
-		return
-	}
-
 	res, err := a.creds.Select(ctx, table, func() map[string]any {
 		return map[string]any{
 			"is_super": new(bool),
@@ -253,7 +445,8 @@ func (a *BloqsAuther) IsSuperBasic(ctx context.Context, creds *proto.Credentials
 func (a *BloqsAuther) CheckAccessBasic(ctx context.Context, c *proto.Credentials_Basic) error {
 	res, err := a.creds.Select(ctx, table, func() map[string]any {
 		return map[string]any{
-			"secret": new([]byte),
+			"id":     new(int64),
+			"secret": new(string),
 		}
 	}, []db.Condition{
 		{Column: "identifier", Value: c.Basic.Email},
@@ -267,20 +460,176 @@ func (a *BloqsAuther) CheckAccessBasic(ctx context.Context, c *proto.Credentials
 		}
 	}
 
+	var id int64
+	if len(res.Rows) == 1 {
+		id = *res.Rows[0]["id"].(*int64)
+	}
+
+	if err := a.checkThrottle(ctx, id); err != nil {
+		return err
+	}
+
+	hashing := auth.PasswordHashingConf()
+
 	if len(res.Rows) != 1 {
+		// hashing takes as long as checking a password would, so how long it
+		// takes doesn't tell if the credentials exist
+		if _, err := hashing.Hash(c.Basic.GetPassword()); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+
+		a.failed(ctx, 0)
 		return &mux.HttpError{
 			Body:   "wrong credentials",
 			Status: http.StatusUnauthorized,
 		}
 	}
 
-	secret := res.Rows[0]["secret"].(*[]byte)
-	if err := bcrypt.CompareHashAndPassword(*secret, []byte(c.Basic.GetPassword())); err != nil {
+	secret := *res.Rows[0]["secret"].(*string)
+	ok, rehash, err := hashing.Verify(secret, c.Basic.GetPassword())
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+	if !ok {
+		a.failed(ctx, id)
 		return &mux.HttpError{
 			Body:   "wrong credentials",
 			Status: http.StatusUnauthorized,
 		}
 	}
 
+	a.succeeded(ctx, id)
+
+	// the password is only known now, so it's when hashes from older
+	// algorithms or parameters can be upgraded
+	if rehash {
+		if hash, err := hashing.Hash(c.Basic.GetPassword()); err != nil {
+			fmt.Printf("%v\n", err)
+		} else if err := a.creds.Update(ctx, table, map[string]any{
+			"secret": hash,
+		}, map[string]any{"id": id}); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	}
+
 	return nil
 }
+
+// LogInOIDC uses the credentials linked to the identity. On the first log in
+// they are created with the email of the identity as identifier, which links
+// them to the `BASIC_EMAIL` credentials with the same email, if there are any,
+// so both log in to the same account. That's only done for emails the
+// provider has verified, and only to credentials that verified it too, or
+// anyone could sign in with the email first and own who logs in with it.
+func (a *BloqsAuther) LogInOIDC(ctx context.Context, i *auth.OIDCIdentity) (identifier string, super bool, err error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{
+			"identifier": new(string),
+			"is_super":   new(bool),
+		}
+	}, []db.Condition{
+		{Column: "external_id", Value: i.ExternalID()},
+		{Column: "type", Value: strconv.Itoa(int(auth.OIDC))},
+	})
+	if err != nil {
+		return "", false, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) == 1 {
+		identifier = *res.Rows[0]["identifier"].(*string)
+		super = *res.Rows[0]["is_super"].(*bool)
+	} else {
+		if i.Email == "" || !i.EmailVerified {
+			return "", false, &mux.HttpError{
+				Body:   fmt.Sprintf("the OpenID provider `%s` did not verify your email", i.Provider),
+				Status: http.StatusForbidden,
+			}
+		}
+
+		identifier = i.Email
+
+		if res, err = a.creds.Select(ctx, table, func() map[string]any {
+			return map[string]any{
+				"type":     new(int),
+				"verified": new(bool),
+			}
+		}, []db.Condition{
+			{Column: "identifier", Value: identifier},
+		}); err != nil {
+			return "", false, &mux.HttpError{
+				Body:   err.Error(),
+				Status: http.StatusInternalServerError,
+			}
+		}
+
+		for _, row := range res.Rows {
+			if auth.AuthType(*row["type"].(*int)) == auth.OIDC {
+				return "", false, &mux.HttpError{
+					Body:   fmt.Sprintf("`%s` is already linked to another OpenID provider", identifier),
+					Status: http.StatusConflict,
+				}
+			}
+
+			if !*row["verified"].(*bool) {
+				return "", false, &mux.HttpError{
+					Body:   fmt.Sprintf("there are credentials for `%s` that didn't verify it, verify it with them before logging in with `%s`", identifier, i.Provider),
+					Status: http.StatusConflict,
+				}
+			}
+		}
+
+		var account string
+		if account, err = a.accountFor(ctx, identifier); err != nil {
+			return "", false, err
+		}
+
+		if _, err = a.creds.Insert(ctx, table, []map[string]any{
+			{
+				"identifier":  identifier,
+				"type":        auth.OIDC,
+				"secret":      "",
+				"external_id": i.ExternalID(),
+				"verified":    true,
+				"account":     account,
+			},
+		}); err != nil {
+			return "", false, &mux.HttpError{
+				Body:   err.Error(),
+				Status: http.StatusInternalServerError,
+			}
+		}
+	}
+
+	if !super {
+		if super, err = a.linkedSuper(ctx, identifier); err != nil {
+			return "", false, err
+		}
+	}
+
+	return identifier, super, nil
+}
+
+// linkedSuper is if the `BASIC_EMAIL` credentials of identifier are super.
+// The other types of credentials linked to them share it.
+func (a *BloqsAuther) linkedSuper(ctx context.Context, identifier string) (bool, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"is_super": new(bool)}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "type", Value: strconv.Itoa(int(auth.BASIC_EMAIL))},
+	})
+	if err != nil {
+		return false, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return len(res.Rows) == 1 && *res.Rows[0]["is_super"].(*bool), nil
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+func (a *BloqsAuther) ChangePassword(ctx context.Context, c *proto.Credentials_Basic, password string) error {
+	if err := a.CheckAccessBasic(ctx, c); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password, c.Basic.Email)
+	if err != nil {
+		return err
+	}
+
+	if err := a.creds.Update(ctx, table, map[string]any{
+		"secret":      hash,
+		"modified_at": time.Now(),
+	}, map[string]any{
+		"identifier": c.Basic.Email,
+		"type":       strconv.Itoa(int(auth.BASIC_EMAIL)),
+	}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return nil
+}
+
+func (a *BloqsAuther) inUse(ctx context.Context, identifier string) (bool, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "identifier", Value: identifier}})
+	if err != nil {
+		return false, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return len(res.Rows) > 0, nil
+}
+
+func (a *BloqsAuther) CheckNewEmail(ctx context.Context, identifier string, email string) error {
+	if email == identifier {
+		return &mux.HttpError{
+			Body:   "the new email is the same as the current one",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	if exists, err := a.inUse(ctx, identifier); err != nil {
+		return err
+	} else if !exists {
+		return &mux.HttpError{
+			Body:   "credentials do not exist",
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if err := verifyEmail(ctx, email); err != nil {
+		return err
+	}
+
+	if used, err := a.inUse(ctx, email); err != nil {
+		return err
+	} else if used {
+		return &mux.HttpError{
+			Body:   "credentials already in use",
+			Status: http.StatusConflict,
+		}
+	}
+
+	return nil
+}
+
+// ChangeEmail changes the identifier of all the credentials linked by it.
+// The new email was confirmed to get here, so it's verified.
+func (a *BloqsAuther) ChangeEmail(ctx context.Context, identifier string, email string) error {
+	// it could have been taken since it was checked
+	if used, err := a.inUse(ctx, email); err != nil {
+		return err
+	} else if used {
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("`%s` is already in use", email),
+			Status: http.StatusConflict,
+		}
+	}
+
+	if err := a.creds.Update(ctx, table, map[string]any{
+		"identifier":  email,
+		"verified":    true,
+		"modified_at": time.Now(),
+	}, map[string]any{"identifier": identifier}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	for _, t := range []string{roles_table, api_keys_table} {
+		if err := a.creds.Update(ctx, t, map[string]any{
+			"identifier": email,
+		}, map[string]any{"identifier": identifier}); err != nil {
+			return &mux.HttpError{
+				Body:   err.Error(),
+				Status: http.StatusInternalServerError,
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+func httpStatus(err error) uint16 {
+	var e *mux.HttpError
+	if errors.As(err, &e) {
+		return e.Status
+	}
+	return 0
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	const old, password = "an old and long passphrase", "correct horse battery staple"
+
+	hash, err := auth.PasswordHashingConf().Hash(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basic := func(pass string) *proto.Credentials_Basic {
+		return &proto.Credentials_Basic{Basic: &proto.Credentials_BasicCredentials{Email: "user@example.com", Password: pass}}
+	}
+
+	tests := []struct {
+		name     string
+		old      string
+		password string
+		status   uint16
+	}{
+		{"wrong password", "wrong", password, http.StatusUnauthorized},
+		{"weak password", old, "short", http.StatusUnprocessableEntity},
+		{"changes", old, password, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &credsTables{tables: map[string][]map[string]any{
+				table: {
+					{"id": int64(1), "identifier": "user@example.com", "type": int(auth.BASIC_EMAIL), "secret": hash},
+				},
+			}, ids: 1}
+			a := &BloqsAuther{creds: c}
+
+			err := a.ChangePassword(ctx, basic(tt.old), tt.password)
+			if httpStatus(err) != tt.status || (tt.status == 0) != (err == nil) {
+				t.Fatalf("ChangePassword() error = %v, want a %d", err, tt.status)
+			}
+
+			want := old
+			if tt.status == 0 {
+				want = password
+			}
+			if ok, _, err := auth.PasswordHashingConf().Verify(c.tables[table][0]["secret"].(string), want); err != nil || !ok {
+				t.Errorf("ChangePassword() didn't leave the password as %q: %v", want, err)
+			}
+		})
+	}
+}
+
+func TestCheckNewEmail(t *testing.T) {
+	a, _ := emailCreds()
+
+	tests := []struct {
+		name       string
+		identifier string
+		email      string
+		status     uint16
+	}{
+		{"the same", "user@example.com", "user@example.com", http.StatusUnprocessableEntity},
+		{"no credentials", "nobody@example.com", "new@example.com", http.StatusNotFound},
+		{"not an email", "user@example.com", "not an email", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		if err := a.CheckNewEmail(context.Background(), tt.identifier, tt.email); httpStatus(err) != tt.status {
+			t.Errorf("CheckNewEmail() %s error = %v, want a %d", tt.name, err, tt.status)
+		}
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	ctx := context.Background()
+
+	a, c := emailCreds()
+	c.tables[table] = append(c.tables[table],
+		map[string]any{"id": int64(3), "identifier": "user@example.com", "type": int(auth.OIDC), "verified": false},
+	)
+	c.tables[roles_table] = []map[string]any{
+		{"identifier": "user@example.com", "role": auth.ROLE_SELLER},
+		{"identifier": "oidc@example.com", "role": auth.ROLE_MODERATOR},
+	}
+	c.tables[api_keys_table] = []map[string]any{
+		{"identifier": "user@example.com", "prefix": "abcdefgh"},
+	}
+
+	// it could have been taken after it was checked
+	if err := a.ChangeEmail(ctx, "user@example.com", "oidc@example.com"); httpStatus(err) != http.StatusConflict {
+		t.Errorf("ChangeEmail() to one in use error = %v, want a 409", err)
+	}
+	if c.updates != 0 {
+		t.Fatalf("ChangeEmail() to one in use changed the credentials")
+	}
+
+	if err := a.ChangeEmail(ctx, "user@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// all the credentials linked by it change, and the email is verified
+	for _, row := range c.tables[table] {
+		if row["id"] == int64(2) {
+			if row["identifier"] != "oidc@example.com" {
+				t.Errorf("ChangeEmail() changed the credentials of others")
+			}
+			continue
+		}
+		if row["identifier"] != "new@example.com" || row["verified"] != true {
+			t.Errorf("ChangeEmail() left the credentials %v", row)
+		}
+	}
+
+	for _, name := range []string{roles_table, api_keys_table} {
+		if row := c.tables[name][0]; row["identifier"] != "new@example.com" {
+			t.Errorf("ChangeEmail() left %v in `%s`", row, name)
+		}
+	}
+	if row := c.tables[roles_table][1]; row["identifier"] != "oidc@example.com" {
+		t.Errorf("ChangeEmail() changed the roles of others")
+	}
+}
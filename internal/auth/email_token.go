@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+const email_prefix = "token:email:%s"
+
+// emailToken is what gets stored for the tokens sent by email, like the
+// refresh tokens only their hash is stored and each one can be used once.
+type emailToken struct {
+	Identifier string            `json:"identifier"`
+	Purpose    auth.EmailPurpose `json:"purpose"`
+	Data       string            `json:"data,omitempty"`
+}
+
+func hashEmailToken(tk auth.Token) string {
+	sum := sha256.Sum256([]byte(tk))
+	return fmt.Sprintf(email_prefix, hex.EncodeToString(sum[:]))
+}
+
+func (t *BloqsTokener) GenEmailToken(ctx context.Context, identifier string, purpose auth.EmailPurpose, data string) (auth.Token, error) {
+	str, err := auth.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	tk := auth.Token(str)
+
+	value, err := json.Marshal(&emailToken{
+		Identifier: identifier,
+		Purpose:    purpose,
+		Data:       data,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.secrets.Put(ctx, map[string][]byte{
+		hashEmailToken(tk): value,
+	}, purpose.Exp()); err != nil {
+		return "", err
+	}
+
+	return tk, nil
+}
+
+func (t *BloqsTokener) ConsumeEmailToken(ctx context.Context, tk auth.Token, purpose auth.EmailPurpose) (string, string, error) {
+	key := hashEmailToken(tk)
+
+	values, err := t.secrets.Get(ctx, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	var stored emailToken
+	if len(values[key]) == 0 || json.Unmarshal(values[key], &stored) != nil || stored.Purpose != purpose {
+		return "", "", &mux.HttpError{
+			Body:   "the token provided it's invalid, expired or was already used",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	if err := t.secrets.Delete(ctx, key); err != nil {
+		return "", "", err
+	}
+
+	return stored.Identifier, stored.Data, nil
+}
+
+func (t *BloqsTokener) RevokeSubject(ctx context.Context, sub string) error {
+	return t.revokeSubject(ctx, sub)
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+func TestEmailToken(t *testing.T) {
+	ctx := context.Background()
+	kv := newMemKV()
+	tk := NewBloqsTokener(kv)
+
+	invalid := func(err error) bool {
+		var e *mux.HttpError
+		return errors.As(err, &e) && e.Status == http.StatusUnauthorized
+	}
+
+	email, err := tk.GenEmailToken(ctx, "user@example.com", auth.CHANGE_EMAIL, "new@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only its hash is stored
+	for k, v := range kv.entries {
+		if strings.Contains(k, string(email)) || strings.Contains(string(v), string(email)) {
+			t.Errorf("the token is stored as is in %q", k)
+		}
+	}
+
+	// it's for a purpose only
+	if _, _, err := tk.ConsumeEmailToken(ctx, email, auth.RESET_PASSWORD); !invalid(err) {
+		t.Errorf("ConsumeEmailToken() for another purpose error = %v, want a 401", err)
+	}
+
+	identifier, data, err := tk.ConsumeEmailToken(ctx, email, auth.CHANGE_EMAIL)
+	if err != nil || identifier != "user@example.com" || data != "new@example.com" {
+		t.Errorf("ConsumeEmailToken() = (%q, %q, %v), want (%q, %q)", identifier, data, err, "user@example.com", "new@example.com")
+	}
+
+	// and can be used once
+	if _, _, err := tk.ConsumeEmailToken(ctx, email, auth.CHANGE_EMAIL); !invalid(err) {
+		t.Errorf("ConsumeEmailToken() of a used token error = %v, want a 401", err)
+	}
+	if len(kv.entries) != 0 {
+		t.Errorf("the used token is still stored")
+	}
+
+	if _, _, err := tk.ConsumeEmailToken(ctx, "forged", auth.VERIFY_EMAIL); !invalid(err) {
+		t.Errorf("ConsumeEmailToken() of a forged token error = %v, want a 401", err)
+	}
+
+	// each token is another one
+	a, err := tk.GenEmailToken(ctx, "user@example.com", auth.VERIFY_EMAIL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tk.GenEmailToken(ctx, "user@example.com", auth.VERIFY_EMAIL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Errorf("GenEmailToken() made %q twice", a)
+	}
+	if identifier, _, err := tk.ConsumeEmailToken(ctx, b, auth.VERIFY_EMAIL); err != nil || identifier != "user@example.com" {
+		t.Errorf("ConsumeEmailToken() = (%q, %v), want %q", identifier, err, "user@example.com")
+	}
+}
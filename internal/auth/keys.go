@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	key_prefix  = "token:key:"
+	current_key = "token:signing"
+	// keys_index is the sorted set of the kids of the keys that can still be
+	// published, by when they were made, so they're never looked for with a
+	// scan
+	keys_index = "token:keys"
+)
+
+// signingKey is what gets stored for each key tokens are signed with. A new
+// key is made every `auth.token.rotation` milliseconds and the old ones are
+// kept, and published, until the last token they signed expires.
+type signingKey struct {
+	Alg     string `json:"alg"`
+	Private []byte `json:"private"`
+	Created int64  `json:"created"`
+}
+
+func keyRotation() time.Duration {
+	return time.Duration(conf.MustGetConfOrDefault[float64](604800000, "auth", "token", "rotation")) * time.Millisecond
+}
+
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA, nil
+	case "ES256":
+		return jwt.SigningMethodES256, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm `%s`", alg)
+	}
+}
+
+func (k *signingKey) signer() (crypto.Signer, error) {
+	priv, err := x509.ParsePKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type `%T`", priv)
+	}
+
+	return signer, nil
+}
+
+func (t *BloqsTokener) getKey(ctx context.Context, kid string) (*signingKey, error) {
+	key := key_prefix + kid
+	values, err := t.secrets.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values[key]) == 0 {
+		return nil, nil
+	}
+
+	k := new(signingKey)
+	if err := json.Unmarshal(values[key], k); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (t *BloqsTokener) newKey(ctx context.Context) (string, *signingKey, error) {
+	alg := conf.MustGetConfOrDefault("EdDSA", "auth", "token", "alg")
+
+	var (
+		priv any
+		err  error
+	)
+	switch alg {
+	case "EdDSA":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case "ES256":
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		_, err = signingMethod(alg)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", nil, err
+	}
+
+	k := &signingKey{
+		Alg:     alg,
+		Private: der,
+		Created: time.Now().Unix(),
+	}
+
+	value, err := json.Marshal(k)
+	if err != nil {
+		return "", nil, err
+	}
+
+	kid := uuid.NewString()
+	rotation := keyRotation()
+
+	if err := t.secrets.Put(ctx, map[string][]byte{
+		key_prefix + kid: value,
+	}, rotation+auth.AccessTokenExp()); err != nil {
+		return "", nil, err
+	}
+
+	// only one of the rotations at the same time becomes the current key, the
+	// others sign with it and throw theirs away
+	claimed, err := t.secrets.PutIfAbsent(ctx, current_key, []byte(kid), rotation)
+	if err != nil {
+		return "", nil, err
+	}
+	if !claimed {
+		if err := t.secrets.Delete(ctx, key_prefix+kid); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+
+		return t.storedCurrentKey(ctx)
+	}
+
+	if err := t.secrets.ZAdd(ctx, []string{keys_index}, db.ZMember{
+		Score:  float64(k.Created),
+		Member: []byte(kid),
+	}, 0, rotation+auth.AccessTokenExp()); err != nil {
+		return "", nil, err
+	}
+
+	return kid, k, nil
+}
+
+// storedCurrentKey returns the key at current_key, nil if there's none or it
+// expired.
+func (t *BloqsTokener) storedCurrentKey(ctx context.Context) (string, *signingKey, error) {
+	values, err := t.secrets.Get(ctx, current_key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	kid := string(values[current_key])
+	if kid == "" {
+		return "", nil, nil
+	}
+
+	k, err := t.getKey(ctx, kid)
+	if err != nil || k == nil {
+		return "", nil, err
+	}
+
+	return kid, k, nil
+}
+
+// currentKey returns the key new tokens should be signed with, making a new
+// one when the last one is due to be rotated.
+func (t *BloqsTokener) currentKey(ctx context.Context) (string, *signingKey, error) {
+	kid, k, err := t.storedCurrentKey(ctx)
+	if err != nil || k != nil {
+		return kid, k, err
+	}
+
+	if kid, k, err = t.newKey(ctx); err == nil && k == nil {
+		err = errors.New("the signing key was rotated but it can't be found")
+	}
+
+	return kid, k, err
+}
+
+func (t *BloqsTokener) publicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k, err := t.getKey(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if k == nil {
+		return nil, fmt.Errorf("unknown signing key `%s`", kid)
+	}
+
+	signer, err := k.signer()
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.Public(), nil
+}
+
+func (t *BloqsTokener) JWKS(ctx context.Context) (*auth.JWKS, error) {
+	// so there's always a key to publish before the first token is signed
+	current, k, err := t.currentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kids, err := t.secrets.ZRevRange(ctx, keys_index, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key could be from before there was an index
+	indexed := false
+	for _, i := range kids {
+		indexed = indexed || string(i.Member) == current
+	}
+	if !indexed {
+		m := db.ZMember{Score: float64(k.Created), Member: []byte(current)}
+		if err := t.secrets.ZAdd(ctx, []string{keys_index}, m, 0, keyRotation()+auth.AccessTokenExp()); err != nil {
+			return nil, err
+		}
+		kids = append([]db.ZMember{m}, kids...)
+	}
+
+	set := &auth.JWKS{
+		Keys: make([]auth.JWK, 0, len(kids)),
+	}
+	expired := [][]byte{}
+	for _, i := range kids {
+		kid := string(i.Member)
+
+		k, err := t.getKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			expired = append(expired, i.Member)
+			continue
+		}
+
+		signer, err := k.signer()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+
+		jwk, err := auth.NewJWK(kid, signer.Public())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+
+		set.Keys = append(set.Keys, *jwk)
+	}
+
+	if err := t.secrets.ZRem(ctx, keys_index, expired...); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	return set, nil
+}
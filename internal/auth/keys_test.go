@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestCurrentKeyAtOnce(t *testing.T) {
+	ctx := context.Background()
+	kv := newMemKV()
+	tr := NewBloqsTokener(kv)
+
+	const n = 16
+	kids := make([]string, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			kid, _, err := tr.currentKey(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			kids[i] = kid
+		}(i)
+	}
+	wg.Wait()
+
+	for _, kid := range kids {
+		if kid != kids[0] {
+			t.Fatalf("currentKey() at once made the keys %v, want one", kids)
+		}
+	}
+
+	index, _ := kv.ZRevRange(ctx, keys_index, nil, 0)
+	if len(index) != 1 || string(index[0].Member) != kids[0] {
+		t.Errorf("the index of the keys is %v, want only %q", index, kids[0])
+	}
+	for k := range kv.entries {
+		if len(k) > len(key_prefix) && k[:len(key_prefix)] == key_prefix && k != key_prefix+kids[0] {
+			t.Errorf("the key %q that lost the rotation was kept", k)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	ctx := context.Background()
+	kv := newMemKV()
+	tr := NewBloqsTokener(kv)
+
+	old, _, err := tr.currentKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rotation of the old key, which is still published until its tokens
+	// expire
+	delete(kv.entries, current_key)
+	current, _, err := tr.currentKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := tr.JWKS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(set.Keys))
+	}
+
+	published := map[string]bool{}
+	for _, i := range set.Keys {
+		published[i.Kid] = true
+	}
+	if !published[old] || !published[current] {
+		t.Errorf("JWKS() published %v, want %q and %q", published, old, current)
+	}
+
+	// once the old key expires it leaves the index
+	delete(kv.entries, key_prefix+old)
+	if set, err = tr.JWKS(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != current {
+		t.Errorf("JWKS() = %+v, want only %q", set.Keys, current)
+	}
+	if index, _ := kv.ZRevRange(ctx, keys_index, nil, 0); len(index) != 1 {
+		t.Errorf("the index of the keys has %d keys, want 1", len(index))
+	}
+
+	// a key from before the index is published too
+	kv.Delete(ctx, keys_index)
+	if set, err = tr.JWKS(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != current {
+		t.Errorf("JWKS() = %+v, want only %q", set.Keys, current)
+	}
+}
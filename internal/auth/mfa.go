@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+const mfa_prefix = "token:mfa:%s"
+
+// mfaChallenge is what's remembered between the first step of a log in, the
+// password, and the second one, the code. The payload is the one the tokens
+// will be issued with once the second step passes. The wrong codes are
+// counted apart, at `<key>:attempts`, so tries at the same time all count.
+type mfaChallenge struct {
+	Payload auth.Payload `json:"payload"`
+	Expires int64        `json:"expires"`
+}
+
+func hashMFAChallenge(tk auth.Token) string {
+	sum := sha256.Sum256([]byte(tk))
+	return fmt.Sprintf(mfa_prefix, hex.EncodeToString(sum[:]))
+}
+
+func mfaChallengeExp() time.Duration {
+	return time.Duration(conf.MustGetConfOrDefault[float64](300000, "auth", "totp", "challenge", "exp")) * time.Millisecond
+}
+
+func (t *BloqsTokener) GenMFAChallenge(ctx context.Context, p *auth.Payload) (auth.Token, error) {
+	str, err := auth.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	tk := auth.Token(str)
+
+	exp := mfaChallengeExp()
+	value, err := json.Marshal(&mfaChallenge{
+		Payload: *p,
+		Expires: time.Now().Add(exp).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.secrets.Put(ctx, map[string][]byte{
+		hashMFAChallenge(tk): value,
+	}, exp); err != nil {
+		return "", err
+	}
+
+	return tk, nil
+}
+
+func (t *BloqsTokener) CheckMFAChallenge(ctx context.Context, tk auth.Token, check func(*auth.Payload) error) (*auth.Payload, error) {
+	key := hashMFAChallenge(tk)
+
+	values, err := t.secrets.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored mfaChallenge
+	if len(values[key]) == 0 || json.Unmarshal(values[key], &stored) != nil || time.Now().Unix() > stored.Expires {
+		return nil, &mux.HttpError{
+			Body:   "the log in expired or was never started, log in again",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	attempts := key + ":attempts"
+
+	if err := check(&stored.Payload); err != nil {
+		n, ierr := t.secrets.Incr(ctx, attempts, time.Until(time.Unix(stored.Expires, 0)))
+		if ierr != nil {
+			return nil, ierr
+		}
+
+		// after too many wrong codes the password has to be given again
+		max := int64(conf.MustGetConfOrDefault[float64](5, "auth", "totp", "challenge", "attempts"))
+		if n >= max {
+			if derr := t.secrets.Delete(ctx, key, attempts); derr != nil {
+				return nil, derr
+			}
+		}
+
+		return nil, err
+	}
+
+	if err := t.secrets.Delete(ctx, key, attempts); err != nil {
+		return nil, err
+	}
+
+	return &stored.Payload, nil
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/google/uuid"
+)
+
+const (
+	refresh_prefix = "token:refresh:%s"
+	family_prefix  = "token:family:%s"
+)
+
+// refreshToken is what gets stored for each refresh token. Tokens are only
+// stored hashed and each one can be used once, using it again means it was
+// stolen and the whole family of tokens that came from the same log in is
+// revoked. A token is claimed by setting `<key>:used` only if it isn't set,
+// so two refreshes with the same token at once can't both get new tokens.
+type refreshToken struct {
+	Family  string       `json:"family"`
+	Payload auth.Payload `json:"payload"`
+	// Used is how tokens were claimed before, it's still checked for the
+	// ones stored then.
+	Used bool `json:"used,omitempty"`
+}
+
+func hashRefreshToken(tk auth.Token) string {
+	sum := sha256.Sum256([]byte(tk))
+	return fmt.Sprintf(refresh_prefix, hex.EncodeToString(sum[:]))
+}
+
+func (t *BloqsTokener) GenRefreshToken(ctx context.Context, p *auth.Payload) (auth.Token, error) {
+	family := p.Session
+	if family == "" {
+		family = uuid.NewString()
+	}
+
+	if err := t.secrets.Put(ctx, map[string][]byte{
+		fmt.Sprintf(family_prefix, family): formatInstant(time.Now()),
+	}, auth.RefreshTokenExp()); err != nil {
+		return "", err
+	}
+
+	return t.putRefreshToken(ctx, &refreshToken{
+		Family:  family,
+		Payload: *p,
+	})
+}
+
+func (t *BloqsTokener) putRefreshToken(ctx context.Context, r *refreshToken) (auth.Token, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	tk := auth.Token(base64.RawURLEncoding.EncodeToString(buf))
+
+	value, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.secrets.Put(ctx, map[string][]byte{
+		hashRefreshToken(tk): value,
+	}, auth.RefreshTokenExp()); err != nil {
+		return "", err
+	}
+
+	return tk, nil
+}
+
+func (t *BloqsTokener) Refresh(ctx context.Context, tk auth.Token, update func(*auth.Payload) error) (access auth.Token, refresh auth.Token, err error) {
+	key := hashRefreshToken(tk)
+
+	values, err := t.secrets.Get(ctx, key)
+	if err != nil {
+		return
+	}
+
+	value := values[key]
+	if len(value) == 0 {
+		err = &mux.HttpError{
+			Body:   "the refresh token provided it's invalid or expired",
+			Status: http.StatusUnauthorized,
+		}
+		return
+	}
+
+	var stored refreshToken
+	if err = json.Unmarshal(value, &stored); err != nil {
+		return
+	}
+
+	family := fmt.Sprintf(family_prefix, stored.Family)
+
+	if stored.Used {
+		err = t.reused(ctx, stored.Family)
+		return
+	}
+
+	values, err = t.secrets.Get(ctx, family)
+	if err != nil {
+		return
+	}
+
+	created, _ := parseInstant(values[family])
+	since, err := t.revokedSince(ctx, stored.Payload.Client)
+	if err != nil {
+		return
+	}
+
+	if len(values[family]) == 0 || (since != nil && !created.After(*since)) {
+		err = &mux.HttpError{
+			Body:   "the session of the refresh token provided was revoked",
+			Status: http.StatusUnauthorized,
+		}
+		return
+	}
+
+	claimed, err := t.secrets.PutIfAbsent(ctx, key+":used", []byte{1}, auth.RefreshTokenExp())
+	if err != nil {
+		return
+	}
+	if !claimed {
+		err = t.reused(ctx, stored.Family)
+		return
+	}
+
+	if update != nil {
+		if err = update(&stored.Payload); err != nil {
+			return
+		}
+	}
+
+	// sliding sessions, every refresh keeps the session alive for longer
+	if err = t.secrets.Put(ctx, map[string][]byte{
+		family: values[family],
+	}, auth.RefreshTokenExp()); err != nil {
+		return
+	}
+
+	if refresh, err = t.putRefreshToken(ctx, &stored); err != nil {
+		return
+	}
+
+	if access, err = t.GenToken(ctx, &stored.Payload); err != nil {
+		return
+	}
+
+	if err := t.touchSession(ctx, stored.Payload.Client, stored.Family, access); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	return
+}
+
+// reused revokes the family of a refresh token that was used again and
+// returns the error for it.
+func (t *BloqsTokener) reused(ctx context.Context, family string) error {
+	if err := t.revokeFamily(ctx, family); err != nil {
+		return err
+	}
+
+	return &mux.HttpError{
+		Body:   "the refresh token provided was already used, all the tokens of this session were revoked",
+		Status: http.StatusUnauthorized,
+	}
+}
+
+// revokeFamily ends the session of a family of refresh tokens, with it all
+// the access tokens issued for the session stop being valid too.
+func (t *BloqsTokener) revokeFamily(ctx context.Context, family string) error {
+	return t.secrets.Delete(ctx, fmt.Sprintf(family_prefix, family))
+}
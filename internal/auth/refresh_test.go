@@ -0,0 +1,394 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// memKV is a KVDBer in memory, the entries never expire.
+type memKV struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	sets    map[string][]db.ZMember
+}
+
+func newMemKV() *memKV {
+	return &memKV{entries: map[string][]byte{}, sets: map[string][]db.ZMember{}}
+}
+
+func (kv *memKV) Get(ctx context.Context, key ...string) (map[string][]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	res := make(map[string][]byte, len(key))
+	for _, i := range key {
+		res[i] = kv.entries[i]
+	}
+	return res, nil
+}
+
+func (kv *memKV) Put(ctx context.Context, entries map[string][]byte, ttl time.Duration) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	for k, v := range entries {
+		kv.entries[k] = v
+	}
+	return nil
+}
+
+func (kv *memKV) Delete(ctx context.Context, key ...string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	for _, i := range key {
+		delete(kv.entries, i)
+		delete(kv.sets, i)
+	}
+	return nil
+}
+
+func (kv *memKV) List(ctx context.Context, prefix *string, limit *uint) ([]string, uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	keys := []string{}
+	for k := range kv.entries {
+		if prefix == nil || strings.HasPrefix(k, *prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	if limit != nil && uint(len(keys)) > *limit {
+		keys = keys[:*limit]
+	}
+	return keys, 0, nil
+}
+
+func (kv *memKV) DeleteAll(ctx context.Context) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.entries = map[string][]byte{}
+	kv.sets = map[string][]db.ZMember{}
+	return nil
+}
+
+func (kv *memKV) Head(ctx context.Context, key ...string) (bool, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	for _, i := range key {
+		if _, ok := kv.entries[i]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (kv *memKV) PutIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if _, ok := kv.entries[key]; ok {
+		return false, nil
+	}
+	kv.entries[key] = value
+	return true, nil
+}
+
+func (kv *memKV) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	var n int64
+	if v, ok := kv.entries[key]; ok {
+		if err := json.Unmarshal(v, &n); err != nil {
+			return 0, err
+		}
+	}
+	n++
+
+	v, _ := json.Marshal(n)
+	kv.entries[key] = v
+	return n, nil
+}
+
+func (kv *memKV) ZAdd(ctx context.Context, keys []string, m db.ZMember, keep int64, ttl time.Duration) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	for _, key := range keys {
+		set := kv.sets[key]
+		for i := range set {
+			if string(set[i].Member) == string(m.Member) {
+				set = append(set[:i], set[i+1:]...)
+				break
+			}
+		}
+		set = append(set, m)
+
+		sort.Slice(set, func(i, j int) bool { return set[i].Score > set[j].Score })
+		if keep > 0 && int64(len(set)) > keep {
+			set = set[:keep]
+		}
+		kv.sets[key] = set
+	}
+	return nil
+}
+
+func (kv *memKV) ZRevRange(ctx context.Context, key string, before *float64, limit int64) ([]db.ZMember, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	members := []db.ZMember{}
+	for _, i := range kv.sets[key] {
+		if before != nil && i.Score >= *before {
+			continue
+		}
+		if limit > 0 && int64(len(members)) >= limit {
+			break
+		}
+		members = append(members, i)
+	}
+	return members, nil
+}
+
+func (kv *memKV) ZRem(ctx context.Context, key string, members ...[]byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	set := []db.ZMember{}
+	for _, i := range kv.sets[key] {
+		removed := false
+		for _, m := range members {
+			if string(i.Member) == string(m) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			set = append(set, i)
+		}
+	}
+	kv.sets[key] = set
+	return nil
+}
+
+func (kv *memKV) Close() error {
+	return nil
+}
+
+func testPayload() *auth.Payload {
+	return &auth.Payload{
+		Client:      "user@example.com",
+		Permissions: auth.Union(auth.CREATE_BLOQ, auth.UPDATE_BLOQ),
+		Type:        auth.BASIC_EMAIL,
+	}
+}
+
+func isUnauthorized(err error) bool {
+	var e *mux.HttpError
+	return errors.As(err, &e) && e.Status == http.StatusUnauthorized
+}
+
+func TestRefresh(t *testing.T) {
+	errUpdate := errors.New("the credentials are gone")
+
+	tests := []struct {
+		name string
+		// prepare changes what's stored before the token is refreshed, and
+		// can replace the token
+		prepare func(ctx context.Context, tr *BloqsTokener, kv *memKV, tk auth.Token) auth.Token
+		update  func(*auth.Payload) error
+		err     func(error) bool
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "permissions recomputed",
+			update: func(p *auth.Payload) error {
+				p.Permissions = auth.CREATE_BLOQ
+				return nil
+			},
+		},
+		{
+			name: "unknown token",
+			prepare: func(ctx context.Context, tr *BloqsTokener, kv *memKV, tk auth.Token) auth.Token {
+				return "unknown"
+			},
+			err: isUnauthorized,
+		},
+		{
+			name: "subject revoked",
+			prepare: func(ctx context.Context, tr *BloqsTokener, kv *memKV, tk auth.Token) auth.Token {
+				// the revocation has to be at least a millisecond after the
+				// log in to tell them apart
+				time.Sleep(2 * time.Millisecond)
+				if err := tr.RevokeSubject(ctx, "user@example.com"); err != nil {
+					t.Fatal(err)
+				}
+				return tk
+			},
+			err: isUnauthorized,
+		},
+		{
+			name: "family revoked",
+			prepare: func(ctx context.Context, tr *BloqsTokener, kv *memKV, tk auth.Token) auth.Token {
+				var stored refreshToken
+				json.Unmarshal(kv.entries[hashRefreshToken(tk)], &stored)
+				if err := tr.revokeFamily(ctx, stored.Family); err != nil {
+					t.Fatal(err)
+				}
+				return tk
+			},
+			err: isUnauthorized,
+		},
+		{
+			name: "used before the claims",
+			prepare: func(ctx context.Context, tr *BloqsTokener, kv *memKV, tk auth.Token) auth.Token {
+				var stored refreshToken
+				json.Unmarshal(kv.entries[hashRefreshToken(tk)], &stored)
+				stored.Used = true
+				kv.entries[hashRefreshToken(tk)], _ = json.Marshal(stored)
+				return tk
+			},
+			err: isUnauthorized,
+		},
+		{
+			name: "credentials gone",
+			update: func(p *auth.Payload) error {
+				return errUpdate
+			},
+			err: func(err error) bool { return errors.Is(err, errUpdate) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kv := newMemKV()
+			tr := NewBloqsTokener(kv)
+
+			tk, err := tr.GenRefreshToken(ctx, testPayload())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.prepare != nil {
+				tk = tt.prepare(ctx, tr, kv, tk)
+			}
+
+			access, refresh, err := tr.Refresh(ctx, tk, tt.update)
+			if tt.err != nil {
+				if !tt.err(err) {
+					t.Errorf("Refresh() error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			if refresh == "" || refresh == tk {
+				t.Errorf("Refresh() didn't rotate the refresh token")
+			}
+
+			claims, err := tr.GetClaims(ctx, access)
+			if err != nil {
+				t.Fatalf("GetClaims() error = %v", err)
+			}
+
+			want := testPayload()
+			if tt.update != nil {
+				tt.update(want)
+			}
+			if claims.Client != want.Client || claims.Permissions != want.Permissions {
+				t.Errorf("Refresh() issued %+v, want %+v", claims.Payload, *want)
+			}
+		})
+	}
+}
+
+func TestRefreshReuse(t *testing.T) {
+	ctx := context.Background()
+	tr := NewBloqsTokener(newMemKV())
+
+	first, err := tr.GenRefreshToken(ctx, testPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, second, err := tr.Refresh(ctx, first, nil)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	// the first token was stolen and the thief uses it after the user
+	if _, _, err := tr.Refresh(ctx, first, nil); !isUnauthorized(err) {
+		t.Fatalf("Refresh() error = %v with a used token, want it refused", err)
+	}
+
+	// so the whole session is gone, even the token the user has
+	if _, _, err := tr.Refresh(ctx, second, nil); !isUnauthorized(err) {
+		t.Errorf("Refresh() error = %v after a reuse, want the session revoked", err)
+	}
+
+	// other sessions are left alone
+	other, err := tr.GenRefreshToken(ctx, testPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tr.Refresh(ctx, other, nil); err != nil {
+		t.Errorf("Refresh() error = %v in another session", err)
+	}
+}
+
+func TestRefreshAtOnce(t *testing.T) {
+	ctx := context.Background()
+	tr := NewBloqsTokener(newMemKV())
+
+	tk, err := tr.GenRefreshToken(ctx, testPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 8
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		refreshed int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, _, err := tr.Refresh(ctx, tk, nil); err == nil {
+				mu.Lock()
+				refreshed++
+				mu.Unlock()
+			} else if !isUnauthorized(err) {
+				t.Errorf("Refresh() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if refreshed != 1 {
+		t.Errorf("%d of %d refreshes with the same token got new tokens, want 1", refreshed, n)
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// Roles are the roles assigned to identifier, they're shared by all its
+// credentials.
+func (a *BloqsAuther) Roles(ctx context.Context, identifier string) ([]string, error) {
+	res, err := a.creds.Select(ctx, roles_table, func() map[string]any {
+		return map[string]any{"role": new(string)}
+	}, []db.Condition{{Column: "identifier", Value: identifier}})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	roles := make([]string, 0, len(res.Rows))
+	for _, i := range res.Rows {
+		roles = append(roles, *i["role"].(*string))
+	}
+
+	return roles, nil
+}
+
+func (a *BloqsAuther) GrantRole(ctx context.Context, identifier string, role string, by string) error {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "identifier", Value: identifier}})
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) == 0 {
+		return &mux.HttpError{
+			Body:   "credentials do not exist",
+			Status: http.StatusNotFound,
+		}
+	}
+
+	roles, err := a.Roles(ctx, identifier)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range roles {
+		if i == role {
+			return nil
+		}
+	}
+
+	if _, err := a.creds.Insert(ctx, roles_table, []map[string]any{
+		{
+			"identifier": identifier,
+			"role":       role,
+			"granted_by": by,
+			"granted_at": time.Now(),
+		},
+	}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return nil
+}
+
+func (a *BloqsAuther) RevokeRole(ctx context.Context, identifier string, role string) error {
+	if err := a.creds.Delete(ctx, roles_table, map[string]any{
+		"identifier": identifier,
+		"role":       role,
+	}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+)
+
+func TestRoleAssignment(t *testing.T) {
+	ctx := context.Background()
+	a, c := emailCreds()
+
+	roles := func(identifier string) []string {
+		t.Helper()
+		roles, err := a.Roles(ctx, identifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return roles
+	}
+
+	if got := roles("user@example.com"); len(got) != 0 {
+		t.Errorf("Roles() = %v, want none", got)
+	}
+
+	if err := a.GrantRole(ctx, "nobody@example.com", auth.ROLE_SELLER, "admin@example.com"); httpStatus(err) != http.StatusNotFound {
+		t.Errorf("GrantRole() without credentials error = %v, want a 404", err)
+	}
+
+	// granting it again keeps one
+	for i := 0; i < 2; i++ {
+		if err := a.GrantRole(ctx, "user@example.com", auth.ROLE_SELLER, "admin@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.GrantRole(ctx, "user@example.com", auth.ROLE_MODERATOR, "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := roles("user@example.com"), []string{auth.ROLE_SELLER, auth.ROLE_MODERATOR}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Roles() = %v, want %v", got, want)
+	}
+	if got := roles("oidc@example.com"); len(got) != 0 {
+		t.Errorf("Roles() of others = %v, want none", got)
+	}
+	if by := c.tables[roles_table][0]["granted_by"]; by != "admin@example.com" {
+		t.Errorf("the role was granted by %v, want %q", by, "admin@example.com")
+	}
+
+	if err := a.RevokeRole(ctx, "user@example.com", auth.ROLE_SELLER); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := roles("user@example.com"), []string{auth.ROLE_MODERATOR}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Roles() after RevokeRole() = %v, want %v", got, want)
+	}
+}
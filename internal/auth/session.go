@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+const (
+	session_prefix = "token:session:%s:%s"
+	// sessions_prefix is the sorted set of the ids of the sessions of a
+	// subject, by when they were last used, so they're never looked for with a
+	// pattern made from the subject
+	sessions_prefix = "token:sessions:%s"
+)
+
+func (t *BloqsTokener) PutSession(ctx context.Context, sub string, s *auth.Session) error {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	// it lasts as long as the family of its refresh tokens
+	if err := t.secrets.Put(ctx, map[string][]byte{
+		fmt.Sprintf(session_prefix, sub, s.ID): value,
+	}, auth.RefreshTokenExp()); err != nil {
+		return err
+	}
+
+	return t.secrets.ZAdd(ctx, []string{fmt.Sprintf(sessions_prefix, sub)}, db.ZMember{
+		Score:  float64(s.LastUsed.UnixMilli()),
+		Member: []byte(s.ID),
+	}, 0, auth.RefreshTokenExp())
+}
+
+func (t *BloqsTokener) Sessions(ctx context.Context, sub string) ([]auth.Session, error) {
+	ids, err := t.secrets.ZRevRange(ctx, fmt.Sprintf(sessions_prefix, sub), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]auth.Session, 0, len(ids))
+	if len(ids) == 0 {
+		return sessions, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, i := range ids {
+		keys = append(keys, fmt.Sprintf(session_prefix, sub, i.Member))
+	}
+
+	values, err := t.secrets.Get(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	since, err := t.revokedSince(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	dead := [][]byte{}
+	for i, key := range keys {
+		var s auth.Session
+		if err := json.Unmarshal(values[key], &s); err != nil {
+			// the session expired before its id left the index
+			dead = append(dead, ids[i].Member)
+			continue
+		}
+
+		// sessions can end without going through RevokeSession, by the reuse
+		// of a refresh token or by logging out everywhere
+		alive, err := t.secrets.Head(ctx, fmt.Sprintf(family_prefix, s.ID))
+		if err != nil {
+			return nil, err
+		}
+
+		if !alive || (since != nil && !s.IssuedAt.After(*since)) {
+			if err := t.secrets.Delete(ctx, key); err != nil {
+				fmt.Printf("%v\n", err)
+			}
+			dead = append(dead, ids[i].Member)
+			continue
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	if err := t.secrets.ZRem(ctx, fmt.Sprintf(sessions_prefix, sub), dead...); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	return sessions, nil
+}
+
+func (t *BloqsTokener) RevokeSession(ctx context.Context, sub string, id string) error {
+	key := fmt.Sprintf(session_prefix, sub, id)
+	if exists, err := t.secrets.Head(ctx, key); err != nil {
+		return err
+	} else if !exists {
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("there's no session `%s`", id),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if err := t.revokeFamily(ctx, id); err != nil {
+		return err
+	}
+
+	return t.deleteSession(ctx, sub, id)
+}
+
+// deleteSession forgets the session id of sub, and takes it out of the index.
+func (t *BloqsTokener) deleteSession(ctx context.Context, sub string, id string) error {
+	if err := t.secrets.Delete(ctx, fmt.Sprintf(session_prefix, sub, id)); err != nil {
+		return err
+	}
+
+	return t.secrets.ZRem(ctx, fmt.Sprintf(sessions_prefix, sub), []byte(id))
+}
+
+// touchSession records that the session was refreshed into access, sessions
+// from before they were recorded are left alone.
+func (t *BloqsTokener) touchSession(ctx context.Context, sub string, id string, access auth.Token) error {
+	key := fmt.Sprintf(session_prefix, sub, id)
+	values, err := t.secrets.Get(ctx, key)
+	if err != nil || len(values[key]) == 0 {
+		return err
+	}
+
+	var s auth.Session
+	if err := json.Unmarshal(values[key], &s); err != nil {
+		return err
+	}
+
+	claims, err := t.GetClaims(ctx, access)
+	if err != nil {
+		return err
+	}
+
+	s.TokenID = claims.ID
+	s.LastUsed = time.Now()
+
+	return t.PutSession(ctx, sub, &s)
+}
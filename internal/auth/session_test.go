@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// putSession stores a session of sub with a living family, last used at ago
+// before now.
+func putSession(t *testing.T, tr *BloqsTokener, kv *memKV, sub, id string, ago time.Duration) {
+	t.Helper()
+
+	now := time.Now()
+	kv.entries[fmt.Sprintf(family_prefix, id)] = formatInstant(now)
+	if err := tr.PutSession(context.Background(), sub, &auth.Session{
+		ID:       id,
+		IssuedAt: now.Add(-ago),
+		LastUsed: now.Add(-ago),
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sessionIDs(sessions []auth.Session) []string {
+	ids := make([]string, 0, len(sessions))
+	for _, i := range sessions {
+		ids = append(ids, i.ID)
+	}
+	return ids
+}
+
+func TestSessions(t *testing.T) {
+	// the subjects with glob characters would match the others if they were
+	// looked for with a pattern
+	subjects := map[string][]string{
+		"a*@example.com": {"star-1", "star-2"},
+		"a?@example.com": {"question"},
+		"ab@example.com": {"plain-1", "plain-2"},
+		"*":              {"any"},
+	}
+
+	tests := []struct {
+		name    string
+		sub     string
+		prepare func(ctx context.Context, tr *BloqsTokener, kv *memKV)
+		want    []string
+	}{
+		{
+			name: "star",
+			sub:  "a*@example.com",
+			want: []string{"star-1", "star-2"},
+		},
+		{
+			name: "question mark",
+			sub:  "a?@example.com",
+			want: []string{"question"},
+		},
+		{
+			name: "only a star",
+			sub:  "*",
+			want: []string{"any"},
+		},
+		{
+			name: "brackets",
+			sub:  "a[b*]@example.com",
+			want: []string{},
+		},
+		{
+			name: "family revoked",
+			sub:  "ab@example.com",
+			prepare: func(ctx context.Context, tr *BloqsTokener, kv *memKV) {
+				if err := tr.revokeFamily(ctx, "plain-1"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"plain-2"},
+		},
+		{
+			name: "expired",
+			sub:  "ab@example.com",
+			prepare: func(ctx context.Context, tr *BloqsTokener, kv *memKV) {
+				delete(kv.entries, fmt.Sprintf(session_prefix, "ab@example.com", "plain-2"))
+			},
+			want: []string{"plain-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			kv := newMemKV()
+			tr := NewBloqsTokener(kv)
+
+			for sub, ids := range subjects {
+				for i, id := range ids {
+					putSession(t, tr, kv, sub, id, time.Duration(i)*time.Minute)
+				}
+			}
+			if tt.prepare != nil {
+				tt.prepare(ctx, tr, kv)
+			}
+
+			sessions, err := tr.Sessions(ctx, tt.sub)
+			if err != nil {
+				t.Fatalf("Sessions() error = %v", err)
+			}
+			if got := sessionIDs(sessions); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Sessions() = %v, want %v", got, tt.want)
+			}
+
+			// the sessions that ended left the index of the subject, and the
+			// other subjects kept all of theirs
+			index, _ := kv.ZRevRange(ctx, fmt.Sprintf(sessions_prefix, tt.sub), nil, 0)
+			if len(index) != len(tt.want) {
+				t.Errorf("the index of %q has %d sessions, want %d", tt.sub, len(index), len(tt.want))
+			}
+			for sub, ids := range subjects {
+				if sub == tt.sub {
+					continue
+				}
+				for _, id := range ids {
+					if _, ok := kv.entries[fmt.Sprintf(session_prefix, sub, id)]; !ok {
+						t.Errorf("the session %q of %q was deleted", id, sub)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSessionsLastUsed(t *testing.T) {
+	ctx := context.Background()
+	kv := newMemKV()
+	tr := NewBloqsTokener(kv)
+
+	putSession(t, tr, kv, "user@example.com", "old", time.Hour)
+	putSession(t, tr, kv, "user@example.com", "new", time.Minute)
+
+	sessions, err := tr.Sessions(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(sessions); fmt.Sprint(got) != "[new old]" {
+		t.Errorf("Sessions() = %v, want [new old]", got)
+	}
+
+	// using the old one again puts it first
+	s := sessions[1]
+	s.LastUsed = time.Now()
+	if err := tr.PutSession(ctx, "user@example.com", &s); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err = tr.Sessions(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(sessions); fmt.Sprint(got) != "[old new]" {
+		t.Errorf("Sessions() = %v, want [old new]", got)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	kv := newMemKV()
+	tr := NewBloqsTokener(kv)
+
+	putSession(t, tr, kv, "a*@example.com", "star", 0)
+	putSession(t, tr, kv, "ab@example.com", "plain", 0)
+
+	// a session is only revoked by its own subject
+	var e *mux.HttpError
+	if err := tr.RevokeSession(ctx, "a*@example.com", "plain"); !errors.As(err, &e) || e.Status != http.StatusNotFound {
+		t.Errorf("RevokeSession() of another subject error = %v, want a 404", err)
+	}
+
+	if err := tr.RevokeSession(ctx, "a*@example.com", "star"); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if alive, _ := kv.Head(ctx, fmt.Sprintf(family_prefix, "star")); alive {
+		t.Errorf("RevokeSession() left the family of the session")
+	}
+	if index, _ := kv.ZRevRange(ctx, fmt.Sprintf(sessions_prefix, "a*@example.com"), nil, 0); len(index) != 0 {
+		t.Errorf("RevokeSession() left %d sessions in the index", len(index))
+	}
+
+	sessions, err := tr.Sessions(ctx, "ab@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(sessions); fmt.Sprint(got) != "[plain]" {
+		t.Errorf("Sessions() = %v, want [plain]", got)
+	}
+}
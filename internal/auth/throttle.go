@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// throttle is how failed log ins slow down the next ones, defined at
+// `auth.throttle`. After `free` failures each one doubles the wait, starting
+// at `delay` milliseconds, until `max` failures lock the log in for `lockout`
+// milliseconds. Only failures in the last `window` milliseconds count, and
+// each address has its own, more lenient, `ip` limits.
+type throttle struct {
+	free    int
+	max     int
+	delay   time.Duration
+	lockout time.Duration
+	window  time.Duration
+}
+
+func throttleConf(free, max float64, keys ...string) throttle {
+	get := func(def float64, key string) float64 {
+		path := append(append([]string{"auth", "throttle"}, keys...), key)
+		return conf.MustGetConfOrDefault(def, path...)
+	}
+
+	return throttle{
+		free:    int(get(free, "free")),
+		max:     int(get(max, "max")),
+		delay:   time.Duration(get(1000, "delay")) * time.Millisecond,
+		lockout: time.Duration(get(900000, "lockout")) * time.Millisecond,
+		window:  time.Duration(get(900000, "window")) * time.Millisecond,
+	}
+}
+
+// wait is for how long, since the last of them, failures lock the log in.
+func (t throttle) wait(failures int) time.Duration {
+	if failures < t.free {
+		return 0
+	}
+
+	if failures >= t.max {
+		return t.lockout
+	}
+
+	wait := t.delay
+	for i := t.free; i < failures && wait < t.lockout; i++ {
+		wait *= 2
+	}
+
+	if wait > t.lockout {
+		return t.lockout
+	}
+
+	return wait
+}
+
+// retryAfter counts the recent failures that match the column and returns for
+// how long the log in is still locked.
+func (a *BloqsAuther) retryAfter(ctx context.Context, t throttle, column string, value any) (time.Duration, error) {
+	now := time.Now()
+
+	res, err := a.creds.Select(ctx, failed_table, func() map[string]any {
+		return map[string]any{"at": new(int64)}
+	}, []db.Condition{
+		{Column: column, Value: value},
+		{Column: "at", Op: db.GT, Value: now.Add(-t.window).Unix()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var last int64
+	for _, i := range res.Rows {
+		if at := *i["at"].(*int64); at > last {
+			last = at
+		}
+	}
+
+	return time.Until(time.Unix(last, 0).Add(t.wait(len(res.Rows)))), nil
+}
+
+// checkThrottle refuses the log in while the credentials, or the address
+// trying them, failed too many times.
+func (a *BloqsAuther) checkThrottle(ctx context.Context, credential int64) (err error) {
+	var wait time.Duration
+	if credential != 0 {
+		wait, err = a.retryAfter(ctx, throttleConf(3, 10), "credential", credential)
+	}
+	if ip := auth.ClientIP(ctx); err == nil && ip != "" {
+		var wait_ip time.Duration
+		if wait_ip, err = a.retryAfter(ctx, throttleConf(20, 100, "ip"), "ip", ip); wait_ip > wait {
+			wait = wait_ip
+		}
+	}
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if wait <= 0 {
+		return nil
+	}
+
+	seconds := uint32(wait.Round(time.Second) / time.Second)
+	if seconds == 0 {
+		seconds = 1
+	}
+
+	return &mux.HttpError{
+		Body:       fmt.Sprintf("too many failed log ins, try again in %d seconds", seconds),
+		Status:     http.StatusTooManyRequests,
+		RetryAfter: seconds,
+	}
+}
+
+// failed records a failed log in. The credential is 0 when there are no
+// credentials with the identifier used, so it's only counted for the address.
+func (a *BloqsAuther) failed(ctx context.Context, credential int64) {
+	if _, err := a.creds.Insert(ctx, failed_table, []map[string]any{
+		{
+			"credential": credential,
+			"ip":         auth.ClientIP(ctx),
+			"at":         time.Now().Unix(),
+		},
+	}); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// succeeded forgets the failed log ins of the credentials.
+func (a *BloqsAuther) succeeded(ctx context.Context, credential int64) {
+	if err := a.creds.Delete(ctx, failed_table, map[string]any{"credential": credential}); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	if err := a.creds.Update(ctx, table, map[string]any{
+		"last_log_in": time.Now(),
+	}, map[string]any{"id": credential}); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
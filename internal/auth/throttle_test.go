@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+func TestThrottleWait(t *testing.T) {
+	th := throttle{free: 3, max: 10, delay: time.Second, lockout: 15 * time.Minute}
+	short := throttle{free: 1, max: 10, delay: time.Second, lockout: 3 * time.Second}
+
+	tests := []struct {
+		throttle throttle
+		failures int
+		want     time.Duration
+	}{
+		{th, 0, 0},
+		{th, 2, 0},
+		{th, 3, time.Second},
+		{th, 4, 2 * time.Second},
+		{th, 5, 4 * time.Second},
+		{th, 9, 64 * time.Second},
+		{th, 10, 15 * time.Minute},
+		{th, 100, 15 * time.Minute},
+		{short, 1, time.Second},
+		{short, 2, 2 * time.Second},
+		{short, 3, 3 * time.Second},
+		{short, 9, 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := tt.throttle.wait(tt.failures); got != tt.want {
+			t.Errorf("%+v.wait(%d) = %v, want %v", tt.throttle, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleConf(t *testing.T) {
+	tests := []struct {
+		got  throttle
+		want throttle
+	}{
+		{throttleConf(3, 10), throttle{free: 3, max: 10, delay: time.Second, lockout: 15 * time.Minute, window: 15 * time.Minute}},
+		{throttleConf(20, 100, "ip"), throttle{free: 20, max: 100, delay: time.Second, lockout: 15 * time.Minute, window: 15 * time.Minute}},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("throttleConf() = %+v, want %+v", tt.got, tt.want)
+		}
+	}
+}
+
+// failedLogIns keeps the failed table in memory, the other tables aren't
+// there.
+type failedLogIns struct {
+	db.DataManipulater
+	rows []map[string]any
+}
+
+func (f *failedLogIns) Select(ctx context.Context, table string, columns func() map[string]any, where []db.Condition) (db.Result, error) {
+	res := db.Result{Rows: []db.JSON{}}
+
+rows:
+	for _, row := range f.rows {
+		for _, c := range where {
+			switch c.Op {
+			case db.EQ:
+				if row[c.Column] != c.Value {
+					continue rows
+				}
+			case db.GT:
+				if row[c.Column].(int64) <= c.Value.(int64) {
+					continue rows
+				}
+			}
+		}
+
+		selected := db.JSON{}
+		for k, v := range columns() {
+			*v.(*int64) = row[k].(int64)
+			selected[k] = v
+		}
+		res.Rows = append(res.Rows, selected)
+	}
+
+	return res, nil
+}
+
+func (f *failedLogIns) Insert(ctx context.Context, table string, rows []map[string]any) (db.Result, error) {
+	f.rows = append(f.rows, rows...)
+	return db.Result{}, nil
+}
+
+func (f *failedLogIns) Delete(ctx context.Context, table string, conditions map[string]any) error {
+	kept := f.rows[:0]
+	for _, row := range f.rows {
+		if row["credential"] != conditions["credential"] {
+			kept = append(kept, row)
+		}
+	}
+	f.rows = kept
+
+	return nil
+}
+
+func (f *failedLogIns) Update(ctx context.Context, table string, assignments map[string]any, conditions map[string]any) error {
+	return nil
+}
+
+func TestCheckThrottle(t *testing.T) {
+	now := time.Now().Unix()
+	failures := func(n int, credential int64, ip string, at int64) []map[string]any {
+		rows := make([]map[string]any, 0, n)
+		for i := 0; i < n; i++ {
+			rows = append(rows, map[string]any{"credential": credential, "ip": ip, "at": at})
+		}
+		return rows
+	}
+
+	tests := []struct {
+		name       string
+		rows       []map[string]any
+		credential int64
+		ip         string
+		retry      uint32
+	}{
+		{"no failures", nil, 1, "192.0.2.1", 0},
+		{"free failures", failures(2, 1, "192.0.2.1", now), 1, "192.0.2.1", 0},
+		{"throttled credential", failures(4, 1, "192.0.2.1", now), 1, "192.0.2.2", 2},
+		{"locked credential", failures(10, 1, "192.0.2.1", now), 1, "192.0.2.2", 900},
+		{"other credential", failures(10, 2, "192.0.2.1", now), 1, "192.0.2.2", 0},
+		{"failures out of the window", failures(10, 1, "192.0.2.1", now-901), 1, "192.0.2.1", 0},
+		{"throttled address", failures(21, 0, "192.0.2.1", now), 1, "192.0.2.1", 2},
+		{"throttled address of unknown identifiers", failures(21, 0, "192.0.2.1", now), 0, "192.0.2.1", 2},
+		{"other address", failures(21, 0, "192.0.2.1", now), 1, "192.0.2.2", 0},
+		{"the longest wait", append(failures(5, 1, "192.0.2.1", now), failures(20, 2, "192.0.2.1", now)...), 1, "192.0.2.1", 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &BloqsAuther{creds: &failedLogIns{rows: tt.rows}}
+			ctx := auth.WithClientIP(context.Background(), tt.ip)
+
+			err := a.checkThrottle(ctx, tt.credential)
+			if tt.retry == 0 {
+				if err != nil {
+					t.Errorf("checkThrottle() error = %v", err)
+				}
+				return
+			}
+
+			// the second it's checked in may have just ended
+			err2, ok := err.(*mux.HttpError)
+			if !ok || err2.Status != http.StatusTooManyRequests || err2.RetryAfter < tt.retry-1 || err2.RetryAfter > tt.retry {
+				t.Errorf("checkThrottle() error = %v, want to retry after %d seconds", err, tt.retry)
+			}
+		})
+	}
+}
+
+func TestSucceededForgetsFailures(t *testing.T) {
+	f := &failedLogIns{}
+	a := &BloqsAuther{creds: f}
+	ctx := auth.WithClientIP(context.Background(), "192.0.2.1")
+
+	for i := 0; i < 10; i++ {
+		a.failed(ctx, 1)
+	}
+	a.failed(ctx, 2)
+
+	if err := a.checkThrottle(ctx, 1); err == nil {
+		t.Fatal("checkThrottle() didn't throttle after 10 failures")
+	}
+
+	a.succeeded(ctx, 1)
+
+	if err := a.checkThrottle(ctx, 1); err != nil {
+		t.Errorf("checkThrottle() error = %v after the log in succeeded", err)
+	}
+	if len(f.rows) != 1 || f.rows[0]["credential"] != int64(2) {
+		t.Errorf("succeeded() left %v, want only the failure of the other credential", f.rows)
+	}
+}
@@ -2,10 +2,10 @@ package auth
 
 import (
 	"context"
-	"crypto/rand"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
@@ -27,49 +27,49 @@ func NewBloqsTokener(secrets db.KVDBer) *BloqsTokener {
 func (t *BloqsTokener) GenToken(ctx context.Context, p *auth.Payload) (tokenstr auth.Token, err error) {
 	tokenstr = ""
 
-	key := fmt.Sprintf(jwt_prefix, p.Client)
-
-	var secrets map[string][]byte
-	secrets, err = t.secrets.Get(ctx, key)
+	kid, key, err := t.currentKey(ctx)
 	if err != nil {
 		return
 	}
 
-	secret, ok := secrets[key]
+	method, err := signingMethod(key.Alg)
+	if err != nil {
+		return
+	}
 
-	if !ok || (len(secret) == 0) { // create a new secret
-		secret = make([]byte, 32)
+	signer, err := key.signer()
+	if err != nil {
+		return
+	}
 
-		if _, err = rand.Read(secret); err != nil {
-			return
-		}
+	exp := auth.AccessTokenExp()
 
-		puts := make(map[string][]byte, 1)
-		puts[key] = secret
-		if err = t.secrets.Put(ctx, puts, 7*time.Minute); err != nil {
-			return
-		}
-	}
+	payload := *p
+	payload.Asked = nil
 
 	var (
 		str      string
+		now      = time.Now()
 		auth_api = conf.MustGetConfOrDefault("", "auth", "domain")
 		rest_api = conf.MustGetConfOrDefault("", "REST", "domain")
-		token    = jwt.NewWithClaims(jwt.SigningMethodHS512, auth.Claims{
-			Payload: *p,
+		token    = jwt.NewWithClaims(method, auth.Claims{
+			Payload: payload,
 			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * time.Minute)),
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-				NotBefore: jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(now.Add(exp)),
+				IssuedAt:  jwt.NewNumericDate(now),
+				NotBefore: jwt.NewNumericDate(now),
 				Issuer:    auth_api,
 				Subject:   p.Client,
 				Audience:  []string{auth_api, rest_api},
 				ID:        uuid.NewString(),
 			},
+			IssuedAtMilli: now.UnixMilli(),
 		})
 	)
 
-	str, err = token.SignedString(secret)
+	token.Header["kid"] = kid
+
+	str, err = token.SignedString(signer)
 	tokenstr = auth.Token(str)
 
 	return
@@ -86,7 +86,7 @@ func (t *BloqsTokener) VerifyToken(ctx context.Context, tk auth.Token, p auth.Pe
 	}
 
 	if claims, ok := token.Claims.(*auth.Claims); ok && token.Valid {
-		if (claims.Payload.Permissions & p) == p {
+		if claims.Payload.Permissions.Has(p) {
 			return true, nil
 		} else {
 			return false, auth.NoPermissionsError{
@@ -113,38 +113,155 @@ func (t *BloqsTokener) VerifyToken(ctx context.Context, tk auth.Token, p auth.Pe
 }
 
 func (t *BloqsTokener) RevokeToken(ctx context.Context, tk auth.Token) error {
-	token, err := t.ParseToken(ctx, tk)
+	claims, err := t.GetClaims(ctx, tk)
+	if err != nil {
+		return err
+	}
+
+	// it only needs to be remembered while the token would still be valid
+	ttl := time.Minute
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time) + 5*time.Second
+	}
+
+	if err := t.secrets.Put(ctx, map[string][]byte{
+		fmt.Sprintf(revoked_prefix, claims.ID): {1},
+	}, ttl); err != nil {
+		return err
+	}
+
+	if claims.Session != "" {
+		if err := t.revokeFamily(ctx, claims.Session); err != nil {
+			return err
+		}
+
+		return t.deleteSession(ctx, claims.Subject, claims.Session)
+	}
+
+	return nil
+}
 
+func (t *BloqsTokener) RevokeAllTokens(ctx context.Context, tk auth.Token) error {
+	claims, err := t.GetClaims(ctx, tk)
 	if err != nil {
 		return err
 	}
 
-	if claims, ok := token.Claims.(*auth.Claims); ok && token.Valid {
-		sub := claims.Subject
-		key := fmt.Sprintf(jwt_prefix, sub)
-		return t.secrets.Delete(ctx, key)
-	} else {
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return fmt.Errorf("that's not even a token:\t%v", err)
-		} else if errors.Is(err, jwt.ErrTokenSignatureInvalid) {
-			return fmt.Errorf("invalid signature:\t%v", err)
-		} else if errors.Is(err, jwt.ErrTokenExpired) || errors.Is(err, jwt.ErrTokenNotValidYet) {
-			return fmt.Errorf("timing is everything:\t%v", err)
-		} else {
-			return fmt.Errorf("couldn't handle this token:\t%v", err)
+	return t.revokeSubject(ctx, claims.Subject)
+}
+
+// revokeSubject marks every token and session of sub issued until now as
+// revoked. The mark has to last as long as the longest lived token.
+func (t *BloqsTokener) revokeSubject(ctx context.Context, sub string) error {
+	return t.secrets.Put(ctx, map[string][]byte{
+		fmt.Sprintf(revoked_sub_prefix, sub): formatInstant(time.Now()),
+	}, auth.RefreshTokenExp())
+}
+
+func (t *BloqsTokener) RevokedSince(ctx context.Context, sub string) (*time.Time, error) {
+	return t.revokedSince(ctx, sub)
+}
+
+// revokedSince returns when all the tokens of sub were revoked or nil if they
+// never were.
+func (t *BloqsTokener) revokedSince(ctx context.Context, sub string) (*time.Time, error) {
+	key := fmt.Sprintf(revoked_sub_prefix, sub)
+	values, err := t.secrets.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values[key]) == 0 {
+		return nil, nil
+	}
+
+	since, err := parseInstant(values[key])
+	if err != nil {
+		return nil, err
+	}
+
+	return &since, nil
+}
+
+// formatInstant is how the instants compared with the ones tokens are issued
+// at are stored, with nanoseconds so the ones in the same second can be
+// ordered.
+func formatInstant(t time.Time) []byte {
+	return []byte(t.UTC().Format(time.RFC3339Nano))
+}
+
+// parseInstant reads what formatInstant stored, or the Unix seconds that
+// were stored before.
+func parseInstant(v []byte) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, string(v)); err == nil {
+		return t, nil
+	}
+
+	unix, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(unix, 0), nil
+}
+
+func (t *BloqsTokener) isRevoked(ctx context.Context, claims *auth.Claims) (bool, error) {
+	if revoked, err := t.secrets.Head(ctx, fmt.Sprintf(revoked_prefix, claims.ID)); err != nil || revoked {
+		return revoked, err
+	}
+
+	// the session of the token ended, by logging out or by the reuse of one
+	// of its refresh tokens
+	if claims.Session != "" {
+		if alive, err := t.secrets.Head(ctx, fmt.Sprintf(family_prefix, claims.Session)); err != nil || !alive {
+			return !alive, err
 		}
 	}
+
+	since, err := t.revokedSince(ctx, claims.Subject)
+	if err != nil || since == nil {
+		return false, err
+	}
+
+	return issuedBefore(claims, *since), nil
+}
+
+// issuedBefore is if the token was issued until since. The tokens issued
+// before `iat_ms` only have `iat` in seconds, they're revoked when issued in
+// the same second.
+func issuedBefore(claims *auth.Claims, since time.Time) bool {
+	if claims.IssuedAtMilli != 0 {
+		return claims.IssuedAtMilli <= since.UnixMilli()
+	}
+
+	return claims.IssuedAt == nil || claims.IssuedAt.Unix() <= since.Unix()
+}
+
+func (t *BloqsTokener) GetClaims(ctx context.Context, tk auth.Token) (*auth.Claims, error) {
+	token, err := t.ParseToken(ctx, tk)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	claims, ok := token.Claims.(*auth.Claims)
+	if !ok || !token.Valid {
+		return nil, &mux.HttpError{
+			Body:   "couldn't handle this token",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	return claims, nil
 }
 
 func (t *BloqsTokener) ParseToken(ctx context.Context, tk auth.Token) (*jwt.Token, error) {
 	auth_api := conf.MustGetConf("auth", "domain").(string)
 	rest_api := conf.MustGetConf("REST", "domain").(string)
 
-	token, err := jwt.ParseWithClaims(string(tk), &auth.Claims{}, t.keyfunc(ctx), jwt.WithValidMethods([]string{
-		"HS256",
-		"HS384",
-		"HS512",
-	}), jwt.WithJSONNumber(), jwt.WithLeeway(5*time.Second))
+	token, err := jwt.ParseWithClaims(string(tk), &auth.Claims{}, t.keyfunc(ctx), jwt.WithValidMethods(auth.ValidMethods), jwt.WithJSONNumber(), jwt.WithLeeway(5*time.Second))
 
 	if err != nil {
 		return nil, err
@@ -159,11 +276,28 @@ func (t *BloqsTokener) ParseToken(ctx context.Context, tk auth.Token) (*jwt.Toke
 		return nil, errors.New("token has invalid claims: token has invalid issuer")
 	}
 
+	if claims, ok := token.Claims.(*auth.Claims); ok {
+		revoked, err := t.isRevoked(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+
+		if revoked {
+			return nil, errors.New("token has been revoked")
+		}
+	}
+
 	return token, err
 }
 
 func (t *BloqsTokener) keyfunc(ctx context.Context) jwt.Keyfunc {
 	return func(tk *jwt.Token) (any, error) {
+		if kid, ok := tk.Header["kid"].(string); ok {
+			return t.publicKey(ctx, kid)
+		}
+
+		// tokens signed before the asymmetric keys were signed with a secret
+		// of their subject
 		if _, ok := tk.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", tk.Header["alg"])
 		}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIssuedBefore(t *testing.T) {
+	since := time.Date(2024, 1, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+
+	tests := []struct {
+		name   string
+		claims auth.Claims
+		want   bool
+	}{
+		{
+			name:   "milliseconds before",
+			claims: auth.Claims{IssuedAtMilli: since.Add(-time.Millisecond).UnixMilli()},
+			want:   true,
+		},
+		{
+			name:   "milliseconds at",
+			claims: auth.Claims{IssuedAtMilli: since.UnixMilli()},
+			want:   true,
+		},
+		{
+			name:   "milliseconds after in the same second",
+			claims: auth.Claims{IssuedAtMilli: since.Add(time.Millisecond).UnixMilli()},
+			want:   false,
+		},
+		{
+			name: "seconds in the same second",
+			claims: auth.Claims{RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt: jwt.NewNumericDate(since.Add(400 * time.Millisecond)),
+			}},
+			want: true,
+		},
+		{
+			name: "seconds after",
+			claims: auth.Claims{RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt: jwt.NewNumericDate(since.Add(time.Second)),
+			}},
+			want: false,
+		},
+		{
+			name: "never issued",
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issuedBefore(&tt.claims, since); got != tt.want {
+				t.Errorf("issuedBefore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeSubject(t *testing.T) {
+	ctx := context.Background()
+	tr := NewBloqsTokener(newMemKV())
+
+	before, err := tr.GenToken(ctx, testPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if err := tr.RevokeSubject(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	// most likely in the same second as the revocation
+	after, err := tr.GenToken(ctx, testPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tr.GetClaims(ctx, before); !isUnauthorized(err) {
+		t.Errorf("GetClaims() of a token from before error = %v, want a 401", err)
+	}
+	if _, err := tr.GetClaims(ctx, after); err != nil {
+		t.Errorf("GetClaims() of a token from after error = %v", err)
+	}
+}
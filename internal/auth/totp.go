@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+type totpState struct {
+	id      int64
+	secret  string
+	enabled bool
+	last    int64
+}
+
+// totp gets the TOTP state of the `BASIC_EMAIL` credentials of identifier,
+// the only ones that have a password to go with it.
+func (a *BloqsAuther) totp(ctx context.Context, identifier string) (*totpState, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{
+			"id":           new(int64),
+			"totp_secret":  new(string),
+			"totp_enabled": new(bool),
+			"totp_last":    new(int64),
+		}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "type", Value: strconv.Itoa(int(auth.BASIC_EMAIL))},
+	})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) != 1 {
+		return nil, &mux.HttpError{
+			Body:   "two-factor authentication is only available for credentials with a password",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	row := res.Rows[0]
+	return &totpState{
+		id:      *row["id"].(*int64),
+		secret:  *row["totp_secret"].(*string),
+		enabled: *row["totp_enabled"].(*bool),
+		last:    *row["totp_last"].(*int64),
+	}, nil
+}
+
+func (a *BloqsAuther) updateTOTP(ctx context.Context, id int64, values map[string]any) error {
+	if err := a.creds.Update(ctx, table, values, map[string]any{"id": id}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return nil
+}
+
+func (a *BloqsAuther) HasTOTP(ctx context.Context, identifier string) (bool, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"totp_enabled": new(bool)}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "type", Value: strconv.Itoa(int(auth.BASIC_EMAIL))},
+	})
+	if err != nil {
+		return false, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return len(res.Rows) == 1 && *res.Rows[0]["totp_enabled"].(*bool), nil
+}
+
+func (a *BloqsAuther) EnrolTOTP(ctx context.Context, identifier string) (string, error) {
+	state, err := a.totp(ctx, identifier)
+	if err != nil {
+		return "", err
+	}
+
+	if state.enabled {
+		return "", &mux.HttpError{
+			Body:   "two-factor authentication is already enabled",
+			Status: http.StatusConflict,
+		}
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+
+	return secret, a.updateTOTP(ctx, state.id, map[string]any{
+		"totp_secret": secret,
+		"totp_last":   0,
+	})
+}
+
+func (a *BloqsAuther) ConfirmTOTP(ctx context.Context, identifier string, code string) ([]string, error) {
+	state, err := a.totp(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.enabled || state.secret == "" {
+		return nil, &mux.HttpError{
+			Body:   "there's no two-factor authentication enrolment to confirm",
+			Status: http.StatusConflict,
+		}
+	}
+
+	step, ok := auth.VerifyTOTP(state.secret, code, time.Now(), state.last)
+	if !ok {
+		return nil, &mux.HttpError{
+			Body:   "wrong code",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.creds.Delete(ctx, recovery_table, map[string]any{"credential": state.id}); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	rows := make([]map[string]any, 0, len(codes))
+	for _, i := range codes {
+		rows = append(rows, map[string]any{
+			"credential": state.id,
+			"hash":       auth.HashRecoveryCode(i),
+		})
+	}
+	if _, err := a.creds.Insert(ctx, recovery_table, rows); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return codes, a.updateTOTP(ctx, state.id, map[string]any{
+		"totp_enabled": true,
+		"totp_last":    step,
+	})
+}
+
+func (a *BloqsAuther) DisableTOTP(ctx context.Context, identifier string, code string) error {
+	if err := a.CheckTOTP(ctx, identifier, code); err != nil {
+		return err
+	}
+
+	state, err := a.totp(ctx, identifier)
+	if err != nil {
+		return err
+	}
+
+	if err := a.creds.Delete(ctx, recovery_table, map[string]any{"credential": state.id}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return a.updateTOTP(ctx, state.id, map[string]any{
+		"totp_secret":  "",
+		"totp_enabled": false,
+		"totp_last":    0,
+	})
+}
+
+func (a *BloqsAuther) CheckTOTP(ctx context.Context, identifier string, code string) error {
+	state, err := a.totp(ctx, identifier)
+	if err != nil {
+		return err
+	}
+
+	if !state.enabled {
+		return &mux.HttpError{
+			Body:   "two-factor authentication is not enabled",
+			Status: http.StatusConflict,
+		}
+	}
+
+	// the codes are short, guessing them is slowed down like the passwords
+	if err := a.checkThrottle(ctx, state.id); err != nil {
+		return err
+	}
+
+	if step, ok := auth.VerifyTOTP(state.secret, code, time.Now(), state.last); ok {
+		return a.updateTOTP(ctx, state.id, map[string]any{"totp_last": step})
+	}
+
+	hash := auth.HashRecoveryCode(code)
+	res, err := a.creds.Select(ctx, recovery_table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "credential", Value: state.id},
+		{Column: "hash", Value: hash},
+	})
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) != 1 {
+		a.failed(ctx, state.id)
+		return &mux.HttpError{
+			Body:   "wrong code",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	if err := a.creds.Delete(ctx, recovery_table, map[string]any{"id": *res.Rows[0]["id"].(*int64)}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// hashPassword hashes the new password of identifier if it follows the
+// password policy.
+func hashPassword(pass string, identifier string) (string, error) {
+	hashing := auth.PasswordHashingConf()
+
+	if max := hashing.MaxLength(); max > 0 && len(pass) > max {
+		return "", &mux.HttpError{
+			Body:   fmt.Sprintf("the password provided it's too long (bigger than %d bytes)", max),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	if err := auth.CheckPassword(pass, identifier); err != nil {
+		return "", err
+	}
+
+	hash, err := hashing.Hash(pass)
+	if err != nil {
+		return "", &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return hash, nil
+}
+
+func (a *BloqsAuther) HasPassword(ctx context.Context, identifier string) (bool, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "type", Value: strconv.Itoa(int(auth.BASIC_EMAIL))},
+	})
+	if err != nil {
+		return false, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return len(res.Rows) == 1, nil
+}
+
+// IsVerified is if any of the credentials of identifier has its email
+// verified, the ones from OpenID providers always have.
+func (a *BloqsAuther) IsVerified(ctx context.Context, identifier string) (bool, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "verified", Value: true},
+	})
+	if err != nil {
+		return false, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return len(res.Rows) > 0, nil
+}
+
+func (a *BloqsAuther) VerifyEmail(ctx context.Context, identifier string) error {
+	if err := a.creds.Update(ctx, table, map[string]any{
+		"verified": true,
+	}, map[string]any{"identifier": identifier}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password to the `BASIC_EMAIL` credentials of
+// identifier. Being able to reset it proves the email is theirs, so it's
+// verified too.
+func (a *BloqsAuther) ResetPassword(ctx context.Context, identifier string, password string) error {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "type", Value: strconv.Itoa(int(auth.BASIC_EMAIL))},
+	})
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) != 1 {
+		return &mux.HttpError{
+			Body:   "credentials do not exist",
+			Status: http.StatusNotFound,
+		}
+	}
+	id := *res.Rows[0]["id"].(*int64)
+
+	hash, err := hashPassword(password, identifier)
+	if err != nil {
+		return err
+	}
+
+	if err := a.creds.Update(ctx, table, map[string]any{
+		"secret":   hash,
+		"verified": true,
+	}, map[string]any{"id": id}); err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	// the failed log ins were probably why the password had to be reset
+	if err := a.creds.Delete(ctx, failed_table, map[string]any{"credential": id}); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+)
+
+// emailCreds are the unverified credentials of user@example.com, with a
+// password and a failed log in, and the ones of a user of an OpenID provider.
+func emailCreds() (*BloqsAuther, *credsTables) {
+	c := &credsTables{tables: map[string][]map[string]any{
+		table: {
+			{"id": int64(1), "identifier": "user@example.com", "type": int(auth.BASIC_EMAIL), "secret": "old", "verified": false},
+			{"id": int64(2), "identifier": "oidc@example.com", "type": int(auth.OIDC), "secret": "", "verified": true},
+		},
+		failed_table: {
+			{"credential": int64(1)},
+			{"credential": int64(2)},
+		},
+	}, ids: 2}
+
+	return &BloqsAuther{creds: c}, c
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	a, _ := emailCreds()
+
+	for identifier, want := range map[string]bool{"user@example.com": false, "oidc@example.com": true, "nobody@example.com": false} {
+		if got, err := a.IsVerified(ctx, identifier); err != nil || got != want {
+			t.Errorf("IsVerified(%q) = (%v, %v), want %v", identifier, got, err, want)
+		}
+	}
+
+	if err := a.VerifyEmail(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.IsVerified(ctx, "user@example.com"); err != nil || !got {
+		t.Errorf("IsVerified() after VerifyEmail() = (%v, %v), want it verified", got, err)
+	}
+
+	for identifier, want := range map[string]bool{"user@example.com": true, "oidc@example.com": false, "nobody@example.com": false} {
+		if got, err := a.HasPassword(ctx, identifier); err != nil || got != want {
+			t.Errorf("HasPassword(%q) = (%v, %v), want %v", identifier, got, err, want)
+		}
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	const password = "correct horse battery staple"
+
+	a, c := emailCreds()
+	if err := a.ResetPassword(ctx, "oidc@example.com", password); httpStatus(err) != http.StatusNotFound {
+		t.Errorf("ResetPassword() without a password error = %v, want a 404", err)
+	}
+	if err := a.ResetPassword(ctx, "nobody@example.com", password); httpStatus(err) != http.StatusNotFound {
+		t.Errorf("ResetPassword() without credentials error = %v, want a 404", err)
+	}
+	if err := a.ResetPassword(ctx, "user@example.com", "short"); httpStatus(err) != http.StatusUnprocessableEntity {
+		t.Errorf("ResetPassword() with a weak password error = %v, want a 422", err)
+	}
+	if c.updates != 0 || len(c.tables[failed_table]) != 2 {
+		t.Fatalf("ResetPassword() changed the credentials when it failed")
+	}
+
+	if err := a.ResetPassword(ctx, "user@example.com", password); err != nil {
+		t.Fatal(err)
+	}
+
+	row := c.tables[table][0]
+	if ok, _, err := auth.PasswordHashingConf().Verify(row["secret"].(string), password); err != nil || !ok {
+		t.Errorf("ResetPassword() didn't set the new password: %v", err)
+	}
+	if row["verified"] != true {
+		t.Errorf("ResetPassword() didn't verify the email")
+	}
+	if failed := c.tables[failed_table]; len(failed) != 1 || failed[0]["credential"] != int64(2) {
+		t.Errorf("ResetPassword() left the failed log ins %v, want the ones of the others", failed)
+	}
+}
@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+const (
+	ceremony_register = "register"
+	ceremony_login    = "login"
+)
+
+func (a *BloqsAuther) webAuthnCredentials(ctx context.Context, identifier string) ([]string, error) {
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"external_id": new(string)}
+	}, []db.Condition{
+		{Column: "identifier", Value: identifier},
+		{Column: "type", Value: strconv.Itoa(int(auth.WEBAUTHN))},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(res.Rows))
+	for _, i := range res.Rows {
+		ids = append(ids, *i["external_id"].(*string))
+	}
+
+	return ids, nil
+}
+
+func (a *BloqsAuther) BeginWebAuthn(ctx context.Context, identifier string, register bool, owner bool) (challenge string, credentials []string, err error) {
+	ceremony := ceremony_login
+
+	if register {
+		ceremony = ceremony_register
+
+		if identifier == "" {
+			return "", nil, &mux.HttpError{
+				Body:   "an identifier is needed to register an authenticator",
+				Status: http.StatusUnprocessableEntity,
+			}
+		}
+
+		res, err := a.creds.Select(ctx, table, func() map[string]any {
+			return map[string]any{"id": new(int64)}
+		}, []db.Condition{{Column: "identifier", Value: identifier}})
+		if err != nil {
+			return "", nil, &mux.HttpError{
+				Body:   err.Error(),
+				Status: http.StatusInternalServerError,
+			}
+		}
+
+		if len(res.Rows) > 0 && !owner {
+			return "", nil, &mux.HttpError{
+				Body:   "credentials already in use, log in with them to add an authenticator",
+				Status: http.StatusConflict,
+			}
+		}
+	}
+
+	if identifier != "" {
+		if credentials, err = a.webAuthnCredentials(ctx, identifier); err != nil {
+			return "", nil, &mux.HttpError{
+				Body:   err.Error(),
+				Status: http.StatusInternalServerError,
+			}
+		}
+	}
+
+	if challenge, err = auth.RandomString(32); err != nil {
+		return "", nil, err
+	}
+
+	if _, err = a.creds.Insert(ctx, challenges_table, []map[string]any{
+		{
+			"challenge":  challenge,
+			"identifier": identifier,
+			"ceremony":   ceremony,
+			"expires":    time.Now().Add(auth.WebAuthnTimeout()).Unix(),
+		},
+	}); err != nil {
+		return "", nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return challenge, credentials, nil
+}
+
+// consumeChallenge returns the identifier the ceremony was started for. Each
+// challenge can only be used once.
+func (a *BloqsAuther) consumeChallenge(ctx context.Context, challenge string, ceremony string) (string, error) {
+	res, err := a.creds.Select(ctx, challenges_table, func() map[string]any {
+		return map[string]any{
+			"identifier": new(string),
+			"ceremony":   new(string),
+			"expires":    new(int64),
+		}
+	}, []db.Condition{{Column: "challenge", Value: challenge}})
+	if err != nil {
+		return "", &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) != 1 {
+		return "", &mux.HttpError{
+			Body:   "the WebAuthn ceremony was never started or was already finished",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	if err := a.creds.Delete(ctx, challenges_table, map[string]any{"challenge": challenge}); err != nil {
+		return "", &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	row := res.Rows[0]
+	if *row["ceremony"].(*string) != ceremony || time.Now().Unix() > *row["expires"].(*int64) {
+		return "", &mux.HttpError{
+			Body:   "the WebAuthn ceremony expired",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	return *row["identifier"].(*string), nil
+}
+
+func (a *BloqsAuther) SignInWebAuthn(ctx context.Context, c *proto.Credentials_Webauthn) (string, error) {
+	client, err := auth.ParseClientData(c.Webauthn.GetClientDataJson(), auth.WEBAUTHN_CREATE)
+	if err != nil {
+		return "", err
+	}
+
+	identifier, err := a.consumeChallenge(ctx, client.Challenge, ceremony_register)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := auth.ParseAttestationObject(c.Webauthn.GetAttestationObject())
+	if err != nil {
+		return "", err
+	}
+
+	if err := data.Check(); err != nil {
+		return "", err
+	}
+
+	if !bytes.Equal(data.CredentialID, c.Webauthn.GetCredentialId()) {
+		return "", &mux.HttpError{
+			Body:   "the credential id does not match the one attested",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	key, err := auth.MarshalWebAuthnKey(data.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(data.CredentialID)
+
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "external_id", Value: id},
+		{Column: "type", Value: strconv.Itoa(int(auth.WEBAUTHN))},
+	})
+	if err != nil {
+		return "", &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) > 0 {
+		return "", &mux.HttpError{
+			Body:   "the authenticator is already registered",
+			Status: http.StatusConflict,
+		}
+	}
+
+	account, err := a.accountFor(ctx, identifier)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := a.creds.Insert(ctx, table, []map[string]any{
+		{
+			"identifier":  identifier,
+			"type":        auth.WEBAUTHN,
+			"secret":      key,
+			"external_id": id,
+			"sign_count":  data.SignCount,
+			"account":     account,
+		},
+	}); err != nil {
+		return "", &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return identifier, nil
+}
+
+func (a *BloqsAuther) CheckAccessWebAuthn(ctx context.Context, c *proto.Credentials_Webauthn) (identifier string, super bool, err error) {
+	client, err := auth.ParseClientData(c.Webauthn.GetClientDataJson(), auth.WEBAUTHN_GET)
+	if err != nil {
+		return "", false, err
+	}
+
+	expected, err := a.consumeChallenge(ctx, client.Challenge, ceremony_login)
+	if err != nil {
+		return "", false, err
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(c.Webauthn.GetCredentialId())
+	res, err := a.creds.Select(ctx, table, func() map[string]any {
+		return map[string]any{
+			"identifier": new(string),
+			"secret":     new(string),
+			"sign_count": new(int64),
+			"is_super":   new(bool),
+		}
+	}, []db.Condition{
+		{Column: "external_id", Value: id},
+		{Column: "type", Value: strconv.Itoa(int(auth.WEBAUTHN))},
+	})
+	if err != nil {
+		return "", false, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if len(res.Rows) != 1 {
+		return "", false, &mux.HttpError{
+			Body:   "wrong credentials",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	row := res.Rows[0]
+	identifier = *row["identifier"].(*string)
+	if expected != "" && expected != identifier {
+		return "", false, &mux.HttpError{
+			Body:   "wrong credentials",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	data, err := auth.ParseAuthenticatorData(c.Webauthn.GetAuthenticatorData())
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := data.Check(); err != nil {
+		return "", false, err
+	}
+
+	pub, err := auth.ParseWebAuthnKey(*row["secret"].(*string))
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := auth.VerifyAssertion(pub, c.Webauthn.GetAuthenticatorData(), c.Webauthn.GetClientDataJson(), c.Webauthn.GetSignature()); err != nil {
+		return "", false, err
+	}
+
+	// authenticators that count their signatures always go up, if one didn't
+	// it may have been cloned
+	count := *row["sign_count"].(*int64)
+	if (count != 0 || data.SignCount != 0) && int64(data.SignCount) <= count {
+		return "", false, &mux.HttpError{
+			Body:   fmt.Sprintf("the signature counter of the authenticator went back from %d to %d", count, data.SignCount),
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	if err := a.creds.Update(ctx, table, map[string]any{
+		"sign_count":  data.SignCount,
+		"last_log_in": time.Now(),
+	}, map[string]any{
+		"external_id": id,
+		"type":        strconv.Itoa(int(auth.WEBAUTHN)),
+	}); err != nil {
+		return "", false, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if super = *row["is_super"].(*bool); !super {
+		if super, err = a.linkedSuper(ctx, identifier); err != nil {
+			return "", false, err
+		}
+	}
+
+	return identifier, super, nil
+}
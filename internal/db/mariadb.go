@@ -8,9 +8,11 @@ import (
 	"strings"
 
 	"github.com/bloqs-sites/bloqsenjin/pkg/db"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
+const migrations_table = "schema_migrations"
+
 type MySQL struct {
 	conn *sql.DB
 }
@@ -36,7 +38,11 @@ func NewMySQL(ctx context.Context, dsn string) (*MySQL, error) {
 	return dbh, nil
 }
 
-func (dbh *MySQL) Select(ctx context.Context, table string, columns func() map[string]any, where []db.Condition) (res db.Result, err error) {
+func (dbh *MySQL) Select(ctx context.Context, table string, columns func() map[string]any, where []db.Condition) (db.Result, error) {
+	return dbh.SelectPage(ctx, table, columns, where, db.Page{})
+}
+
+func (dbh *MySQL) SelectPage(ctx context.Context, table string, columns func() map[string]any, where []db.Condition, page db.Page) (res db.Result, err error) {
 	r := make([]db.JSON, 0)
 
 	res.Rows = r
@@ -69,15 +75,36 @@ func (dbh *MySQL) Select(ctx context.Context, table string, columns func() map[s
 			conditions = append(conditions, fmt.Sprintf("`%s` < ?", k))
 		case db.LE:
 			conditions = append(conditions, fmt.Sprintf("`%s` <= ?", k))
+		case db.IN:
+			in, _ := v.Value.([]any)
+			if len(in) < 1 {
+				conditions = append(conditions, "FALSE")
+				continue
+			}
+
+			conditions = append(conditions, fmt.Sprintf("`%s` IN (%s)", k, strings.TrimSuffix(strings.Repeat("?, ", len(in)), ", ")))
+			vals = append(vals, in...)
+			continue
 		}
 		vals = append(vals, v.Value)
 	}
 
+	var suffix strings.Builder
+	if page.OrderBy != "" {
+		suffix.WriteString(fmt.Sprintf(" ORDER BY `%s`", page.OrderBy))
+		if page.Desc {
+			suffix.WriteString(" DESC")
+		}
+	}
+	if page.Limit > 0 {
+		suffix.WriteString(fmt.Sprintf(" LIMIT %d", page.Limit))
+	}
+
 	var rows *sql.Rows
 
 	if len(where) > 0 {
 		var stmt *sql.Stmt
-		stmt, err = dbh.conn.PrepareContext(ctx, fmt.Sprintf("SELECT %s FROM `%s` WHERE %s;", strings.Join(keys, ", "), table, strings.Join(conditions, " AND ")))
+		stmt, err = dbh.conn.PrepareContext(ctx, fmt.Sprintf("SELECT %s FROM `%s` WHERE %s%s;", strings.Join(keys, ", "), table, strings.Join(conditions, " AND "), suffix.String()))
 		if err != nil {
 			return
 		}
@@ -85,7 +112,7 @@ func (dbh *MySQL) Select(ctx context.Context, table string, columns func() map[s
 
 		rows, err = stmt.QueryContext(ctx, vals...)
 	} else {
-		rows, err = dbh.conn.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM `%s`;", strings.Join(keys, ", "), table))
+		rows, err = dbh.conn.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM `%s`%s;", strings.Join(keys, ", "), table, suffix.String()))
 	}
 
 	defer rows.Close()
@@ -206,6 +233,11 @@ func (dbh *MySQL) Insert(ctx context.Context, table string, rows []map[string]an
 		}, err
 	}
 
+	var e *mysql.MySQLError
+	if errors.As(err, &e) && e.Number == 1062 { // ER_DUP_ENTRY
+		err = fmt.Errorf("%w:\t%s", db.ErrDuplicate, err)
+	}
+
 	return db.Result{
 		LastID: nil,
 		Rows:   nil,
@@ -267,6 +299,32 @@ func (dbh *MySQL) Delete(ctx context.Context, table string, conditions map[strin
 	return err
 }
 
+func (dbh *MySQL) Increment(ctx context.Context, table string, keys map[string]any, deltas map[string]int64) error {
+	if len(deltas) < 1 {
+		return errors.New("no deltas")
+	}
+
+	columns := make([]string, 0, len(keys)+len(deltas))
+	vals := make([]any, 0, len(keys)+len(deltas))
+	for k, v := range keys {
+		columns = append(columns, k)
+		vals = append(vals, v)
+	}
+
+	set := make([]string, 0, len(deltas))
+	for k, v := range deltas {
+		columns = append(columns, k)
+		vals = append(vals, v)
+		set = append(set, fmt.Sprintf("`%[1]s` = `%[1]s` + VALUES(`%[1]s`)", k))
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	_, err := dbh.conn.ExecContext(ctx, fmt.Sprintf("INSERT INTO `%s` (`%s`) VALUES (%s) ON DUPLICATE KEY UPDATE %s;",
+		table, strings.Join(columns, "`, `"), placeholders, strings.Join(set, ", ")), vals...)
+	return err
+}
+
 func (dbh *MySQL) CreateTables(ctx context.Context, ts []db.Table) error {
 	for _, t := range ts {
 		if _, err := dbh.conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`(%s);", t.Name, strings.Join(t.Columns, ", "))); err != nil {
@@ -304,6 +362,53 @@ func (dbh MySQL) DropTables(ctx context.Context, tables []db.Table) error {
 	return nil
 }
 
+func (dbh *MySQL) Migrate(ctx context.Context, ms []db.Migration) error {
+	if _, err := dbh.conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`(`id` VARCHAR(255) PRIMARY KEY, `at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);", migrations_table)); err != nil {
+		return err
+	}
+
+	for _, m := range ms {
+		var done bool
+		if err := dbh.conn.QueryRowContext(ctx, fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM `%s` WHERE `id` = ?);", migrations_table), m.ID).Scan(&done); err != nil {
+			return err
+		}
+		if done {
+			continue
+		}
+
+		for _, i := range m.Statements {
+			if _, err := dbh.conn.ExecContext(ctx, i); err != nil && !isAlreadyMigrated(err) {
+				return fmt.Errorf("the migration `%s` failed:\t%s", m.ID, err)
+			}
+		}
+
+		if _, err := dbh.conn.ExecContext(ctx, fmt.Sprintf("INSERT IGNORE INTO `%s` (`id`) VALUES (?);", migrations_table), m.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isAlreadyMigrated is if the error is because the change was already made,
+// the column or the key already exists or is already gone.
+func isAlreadyMigrated(err error) bool {
+	var e *mysql.MySQLError
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	switch e.Number {
+	case 1054, // ER_BAD_FIELD_ERROR
+		1060, // ER_DUP_FIELDNAME
+		1061, // ER_DUP_KEYNAME
+		1091: // ER_CANT_DROP_FIELD_OR_KEY
+		return true
+	}
+
+	return false
+}
+
 func (dbh *MySQL) Close() error {
 	return dbh.conn.Close()
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	//"net"
+	"strconv"
 	"time"
 
+	pkg_db "github.com/bloqs-sites/bloqsenjin/pkg/db"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -68,15 +70,34 @@ func (db *KeyDB) Delete(ctx context.Context, key ...string) error {
 }
 
 func (db *KeyDB) List(ctx context.Context, prefix *string, limit *uint) (keys []string, cursor uint64, err error) {
-	var max int64 = 1000
-	if limit != nil {
-		max = int64(*limit)
+	var count int64 = 1000
+	if limit != nil && *limit < uint(count) {
+		count = int64(*limit)
 	}
 
-	if limit != nil {
-		keys, _, err = db.rdb.Scan(ctx, cursor, fmt.Sprintf("%s*", *prefix), max).Result()
-	} else {
-		keys, _, err = db.rdb.Scan(ctx, cursor, "*", max).Result()
+	match := "*"
+	if prefix != nil {
+		match = fmt.Sprintf("%s*", *prefix)
+	}
+
+	// a single SCAN call only goes through one page of the keyspace, so it
+	// has to be iterated until the whole keyspace or the limit is reached
+	for {
+		var page []string
+		page, cursor, err = db.rdb.Scan(ctx, cursor, match, count).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		keys = append(keys, page...)
+
+		if cursor == 0 || (limit != nil && uint(len(keys)) >= *limit) {
+			break
+		}
+	}
+
+	if limit != nil && uint(len(keys)) > *limit {
+		keys = keys[:*limit]
 	}
 
 	return
@@ -92,6 +113,80 @@ func (db *KeyDB) Head(ctx context.Context, key ...string) (bool, error) {
 	return count >= int64(len(key)), err
 }
 
+func (db *KeyDB) PutIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return db.rdb.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (db *KeyDB) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	// the counter is made with its ttl in the same transaction it's
+	// incremented, so it can't be left without one
+	var incr *redis.IntCmd
+	if _, err := db.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if ttl > 0 {
+			pipe.SetNX(ctx, key, 0, ttl)
+		}
+		incr = pipe.Incr(ctx, key)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+func (db *KeyDB) ZAdd(ctx context.Context, keys []string, m pkg_db.ZMember, keep int64, ttl time.Duration) error {
+	pipe := db.rdb.Pipeline()
+	for _, key := range keys {
+		pipe.ZAdd(ctx, key, redis.Z{Score: m.Score, Member: m.Member})
+		if keep > 0 {
+			pipe.ZRemRangeByRank(ctx, key, 0, -keep-1)
+		}
+		if ttl > 0 {
+			pipe.Expire(ctx, key, ttl)
+		}
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (db *KeyDB) ZRevRange(ctx context.Context, key string, before *float64, limit int64) ([]pkg_db.ZMember, error) {
+	max := "+inf"
+	if before != nil {
+		max = "(" + strconv.FormatFloat(*before, 'f', -1, 64)
+	}
+
+	res, err := db.rdb.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Max:   max,
+		Min:   "-inf",
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]pkg_db.ZMember, 0, len(res))
+	for _, i := range res {
+		member, _ := i.Member.(string)
+		members = append(members, pkg_db.ZMember{Score: i.Score, Member: []byte(member)})
+	}
+
+	return members, nil
+}
+
+func (db *KeyDB) ZRem(ctx context.Context, key string, members ...[]byte) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	m := make([]any, 0, len(members))
+	for _, i := range members {
+		m = append(m, i)
+	}
+
+	return db.rdb.ZRem(ctx, key, m...).Err()
+}
+
 func (db *KeyDB) Close() error {
 	return db.rdb.Close()
 }
@@ -5,22 +5,27 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
 	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
 	"github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
 	"github.com/bloqs-sites/bloqsenjin/proto"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-func ValidateAndGetToken(w http.ResponseWriter, r *http.Request, a proto.AuthServer, p auth.Permission) ([]byte, error) {
+func ValidateAndGetToken(w http.ResponseWriter, r *http.Request, a auth.Validator, p auth.Permission) ([]byte, error) {
 	tk, err := helpers.ExtractToken(w, r)
 	if err != nil {
 		return nil, err
 	}
 
+	// the mask too, for the auth services from before the scopes
+	legacy := p.Legacy()
 	if v, err := a.Validate(r.Context(), &proto.Token{
 		Jwt:         string(tk),
-		Permissions: (*uint64)(&p),
+		Permissions: &legacy,
+		Scopes:      p.Scopes(),
 	}); err != nil {
 		return nil, err
 	} else if !v.Valid {
@@ -37,14 +42,51 @@ func ValidateAndGetToken(w http.ResponseWriter, r *http.Request, a proto.AuthSer
 
 	return tk, nil
 }
-func ValidateAndGetClaims(w http.ResponseWriter, r *http.Request, a proto.AuthServer, p auth.Permission) (*auth.Claims, error) {
+func ValidateAndGetClaims(w http.ResponseWriter, r *http.Request, a auth.Validator, p auth.Permission) (*auth.Claims, error) {
 	if tk, err := ValidateAndGetToken(w, r, a, p); err != nil {
 		return nil, err
+	} else if auth.IsAPIKey(string(tk)) {
+		return introspect(r, a, tk)
 	} else {
 		return ExtractClaims(string(tk))
 	}
 }
 
+// introspect asks the auth service for the claims of an API key, they aren't
+// in it like in the tokens.
+func introspect(r *http.Request, a auth.Validator, tk []byte) (*auth.Claims, error) {
+	i, ok := a.(auth.Introspector)
+	if !ok {
+		return nil, &mux.HttpError{
+			Body:   "API keys are not accepted here",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	in, err := i.Introspect(r.Context(), &proto.Token{Jwt: string(tk)})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &auth.Claims{
+		Payload: auth.Payload{
+			Client:      in.GetSubject(),
+			Permissions: auth.FromProto(in.GetScopes(), 0),
+			Super:       in.GetSuper(),
+			Type:        auth.AuthType(in.GetType()),
+			Session:     in.GetSession(),
+			Account:     in.GetAccount(),
+		},
+	}
+	claims.Subject = in.GetSubject()
+	claims.ID = in.GetId()
+	if in.Expires != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Unix(in.GetExpires(), 0))
+	}
+
+	return claims, nil
+}
+
 func ExtractClaims(tk string) (*auth.Claims, error) {
 	claims := &auth.Claims{}
 	claims_str, err := base64.RawStdEncoding.DecodeString(strings.Split(string(tk), ".")[1])
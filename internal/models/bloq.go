@@ -54,6 +54,7 @@ func (Bloq) CreateTable() []db.Table {
 			Columns: []string{
 				"`id` INT UNSIGNED AUTO_INCREMENT",
 				"`creator` INT UNSIGNED NOT NULL",
+				"`manufacturer` INT UNSIGNED DEFAULT NULL",
 				"`category` INT UNSIGNED NOT NULL",
 				"`hasAdultConsideration` BOOL DEFAULT 0",
 				"`releaseDate` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
@@ -94,7 +95,11 @@ func (Bloq) CreateTable() []db.Table {
 				"`dateCreated` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"`dateModified` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"`inLanguage` TEXT NOT NULL",
+				// only the reviews have it, the replies are NULL, so each
+				// profile can review a bloq once
+				"`topLevel` BOOLEAN AS (IF(`associatedReview` IS NULL, TRUE, NULL)) STORED",
 				"UNIQUE(`itemReviewed`, `author`, `associatedReview`)",
+				"UNIQUE `one_review_per_author` (`itemReviewed`, `author`, `topLevel`)",
 				"PRIMARY KEY(`id`)",
 			},
 		},
@@ -123,7 +128,37 @@ func (h *Bloq) CreateViews() []db.View {
 	}
 }
 
+func (Bloq) Migrations() []db.Migration {
+	return []db.Migration{
+		{
+			ID: "bloq_manufacturer",
+			Statements: []string{
+				"ALTER TABLE `bloq` ADD COLUMN `manufacturer` INT UNSIGNED DEFAULT NULL AFTER `creator`;",
+			},
+		},
+		{
+			// the reviews that got in twice at the same time before there
+			// was the key go, with their replies, the first one is kept
+			ID: "bloq_review_one_per_author",
+			Statements: []string{
+				"DELETE `a` FROM `bloq_review` `a` INNER JOIN `bloq_review` `r` ON `a`.`associatedReview` = `r`.`id` INNER JOIN `bloq_review` `k` ON `r`.`itemReviewed` = `k`.`itemReviewed` AND `r`.`author` = `k`.`author` AND `r`.`id` > `k`.`id` WHERE `r`.`associatedReview` IS NULL AND `k`.`associatedReview` IS NULL;",
+				"DELETE `r` FROM `bloq_review` `r` INNER JOIN `bloq_review` `k` ON `r`.`itemReviewed` = `k`.`itemReviewed` AND `r`.`author` = `k`.`author` AND `r`.`id` > `k`.`id` WHERE `r`.`associatedReview` IS NULL AND `k`.`associatedReview` IS NULL;",
+				"ALTER TABLE `bloq_review` ADD COLUMN `topLevel` BOOLEAN AS (IF(`associatedReview` IS NULL, TRUE, NULL)) STORED;",
+				"ALTER TABLE `bloq_review` ADD UNIQUE `one_review_per_author` (`itemReviewed`, `author`, `topLevel`);",
+			},
+		},
+	}
+}
+
 func (Bloq) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
+	if second := s.Seg(1); second != nil {
+		if *second == "reviews" {
+			return createBloqReview(w, r, s)
+		}
+
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
 	var (
 		status uint16 = http.StatusInternalServerError
 
@@ -135,6 +170,7 @@ func (Bloq) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*
 		image_header          *multipart.FileHeader
 		keywords              []string = []string{}
 		creator               int
+		manufacturer          *int64
 	)
 
 	nsfw := conf.MustGetConfOrDefault(false, "REST", "NSFW")
@@ -246,6 +282,11 @@ func (Bloq) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*
 		return nil, err
 	}
 
+	manufacturer, err = orgFormValue(r, s, int64(creator))
+	if err != nil {
+		return nil, err
+	}
+
 	result, err := s.DBH.Insert(r.Context(), "bloq", []map[string]any{
 		{
 			"name":                  name,
@@ -253,6 +294,7 @@ func (Bloq) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*
 			"hasAdultConsideration": hasAdultConsideration,
 			"category":              category,
 			"creator":               creator,
+			"manufacturer":          manufacturer,
 		},
 	})
 
@@ -306,6 +348,8 @@ func (Bloq) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*
 		}
 	}
 
+	publishActivity(r.Context(), s, FeedBloq, id, fmt.Sprintf("bloq/%d", id), int64(creator), manufacturer)
+
 	return &rest.Created{
 		LastID:  &id,
 		Message: "",
@@ -370,6 +414,7 @@ func (Bloq) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*re
 		return map[string]any{
 			"id":                    new(int64),
 			"creator":               new(int64),
+			"manufacturer":          new(sql.NullInt64),
 			"category":              new(int64),
 			"name":                  new(string),
 			"description":           new(string),
@@ -383,6 +428,11 @@ func (Bloq) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*re
 	}
 
 	if second == nil {
+		ratings, err := bloqAggregateRatings(r.Context(), s.DBH, bloqIDs(result.Rows))
+		if err != nil {
+			return nil, err
+		}
+
 		for _, v := range result.Rows {
 			id := *v["id"].(*int64)
 
@@ -416,6 +466,8 @@ func (Bloq) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*re
 
 			v["reviews"] = fmt.Sprintf("%s/bloq/%d/reviews/", api, id)
 
+			v["aggregateRating"] = ratings[id]
+
 			result, err = s.DBH.Select(r.Context(), "bloq_image", func() map[string]any {
 				return map[string]any{"image": new(sql.NullString)}
 			}, []db.Condition{{Column: "bloq_id", Value: id}})
@@ -428,6 +480,12 @@ func (Bloq) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*re
 				v["image"] = image.String
 			}
 
+			if manufacturer := v["manufacturer"].(*sql.NullInt64); manufacturer.Valid {
+				v["manufacturer"] = fmt.Sprintf("%s/org/%d", api, manufacturer.Int64)
+			} else {
+				v["manufacturer"] = nil
+			}
+
 			v["url"] = fmt.Sprintf("%s/bloq/%d", api, id)
 		}
 
@@ -473,74 +531,25 @@ func (Bloq) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*re
 			Unique: false,
 		}, nil
 	} else if *second == "reviews" {
-		second_id := s.Seg(2)
-
-		cols := map[string]any{
-			"id":           new(int64),
-			"author":       new(int64),
-			"reviewBody":   new(string),
-			"reviewRating": new(int8),
-			"dateCreated":  new(string),
-			"dateModified": new(string),
-			"inLanguage":   new(string),
-		}
-		where := []db.Condition{{Column: "itemReviewed", Value: *id}}
-		if second_id != nil {
-			where = append(where, db.Condition{Column: "id", Value: *second_id})
-			cols["associatedReview"] = new(int64)
-			if third := s.Seg(3); third != nil && *third == "associated" {
-				if s.Seg(4) != nil {
-					return nil, &mux.HttpError{
-						Status: http.StatusNotFound,
-					}
-				}
-				cols = map[string]any{"id": new(int64)}
-				where = append(where, db.Condition{Column: "associatedReview", Value: *id})
-			}
-		}
-
-		result, err := s.DBH.Select(r.Context(), "bloq_review", func() map[string]any {
-			return cols
-		}, where)
-		if err != nil {
-			return nil, err
-		}
-
-		unique := second_id != nil && s.Seg(3) == nil
-
-		related := make([]db.JSON, 0, len(result.Rows))
-		if !unique {
-			related = append(related, db.JSON{"@type": "Product"})
-			for _, i := range result.Rows {
-				i["url"] = fmt.Sprintf("%s/bloq/%d/reviews/%d", api, id, *i["related_id"].(*int64))
-				i["associated"] = fmt.Sprintf("%s/bloq/%d/associated", api, id)
-				related = append(related, i)
-			}
-		} else {
-			if len(result.Rows) == 1 {
-				i := result.Rows[0]
-				i["url"] = fmt.Sprintf("%s/bloq/%d/reviews/%d", api, id, *i["related_id"].(*int64))
-				i["associated"] = fmt.Sprintf("%s/bloq/%d/associated", api, id)
-				related = append(related, i)
-			}
-		}
-
-		return &rest.Resource{
-			Models: related,
-			Type:   "Review",
-			Status: http.StatusOK,
-			Unique: unique,
-		}, nil
+		return readBloqReviews(r, s)
 	}
 
 	return nil, nil
 }
 
-func (Bloq) Update(http.ResponseWriter, *http.Request, rest.RESTServer) (*rest.Resource, error) {
+func (Bloq) Update(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	if second := s.Seg(1); second != nil && *second == "reviews" {
+		return updateBloqReview(w, r, s)
+	}
+
 	return nil, nil
 }
 
-func (Bloq) Delete(http.ResponseWriter, *http.Request, rest.RESTServer) (*rest.Resource, error) {
+func (Bloq) Delete(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	if second := s.Seg(1); second != nil && *second == "reviews" {
+		return deleteBloqReview(w, r, s)
+	}
+
 	return nil, nil
 }
 
@@ -576,6 +585,7 @@ func personMakesOffer(ctx context.Context, person db.JSON, dbh db.DataManipulate
 		return map[string]any{
 			"id":                    new(int64),
 			"creator":               new(int64),
+			"manufacturer":          new(sql.NullInt64),
 			"category":              new(int64),
 			"name":                  new(string),
 			"description":           new(string),
@@ -596,6 +606,11 @@ func personMakesOffer(ctx context.Context, person db.JSON, dbh db.DataManipulate
 		}, nil
 	}
 
+	ratings, err := bloqAggregateRatings(ctx, dbh, bloqIDs(result.Rows))
+	if err != nil {
+		return nil, err
+	}
+
 	api := conf.MustGetConf("REST", "domain").(string)
 	for _, v := range result.Rows {
 		id := *v["id"].(*int64)
@@ -630,6 +645,8 @@ func personMakesOffer(ctx context.Context, person db.JSON, dbh db.DataManipulate
 
 		v["reviews"] = fmt.Sprintf("%s/bloq/%d/reviews/", api, id)
 
+		v["aggregateRating"] = ratings[id]
+
 		result, err = dbh.Select(ctx, "bloq_image", func() map[string]any {
 			return map[string]any{"image": new(sql.NullString)}
 		}, []db.Condition{{Column: "bloq_id", Value: id}})
@@ -642,6 +659,12 @@ func personMakesOffer(ctx context.Context, person db.JSON, dbh db.DataManipulate
 			v["image"] = image.String
 		}
 
+		if manufacturer := v["manufacturer"].(*sql.NullInt64); manufacturer.Valid {
+			v["manufacturer"] = fmt.Sprintf("%s/org/%d", api, manufacturer.Int64)
+		} else {
+			v["manufacturer"] = nil
+		}
+
 		v["url"] = fmt.Sprintf("%s/bloq/%d", api, id)
 	}
 
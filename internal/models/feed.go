@@ -0,0 +1,224 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+)
+
+type FeedKind = string
+
+const (
+	FeedBloq   FeedKind = "bloq"
+	FeedOffer  FeedKind = "offer"
+	FeedReview FeedKind = "review"
+
+	orgFollowsTable = "org_follows"
+)
+
+/*
+/profile/:id/feed
+/profile/@/feed
+*/
+
+// The feed is built on write: every new bloq, offer or review is added to
+// the feed of each follower of its author and of the organization it was made
+// for. The feed of a profile is a sorted set at `feed:<profile>` scored by the
+// microseconds of the publication, which are the cursors of its pages.
+
+func feedKey(profile int64) string {
+	return fmt.Sprintf("feed:%d", profile)
+}
+
+// publishActivity fans out a new activity to the feeds of the followers of
+// actor and org. It's best effort and does nothing without a KV store.
+func publishActivity(ctx context.Context, s rest.RESTServer, kind FeedKind, id int64, url string, actor int64, org *int64) {
+	if s.KV == nil {
+		return
+	}
+
+	followers := map[int64]struct{}{}
+
+	res, err := s.DBH.Select(ctx, followsTable, func() map[string]any {
+		return map[string]any{"follower_id": new(int64)}
+	}, []db.Condition{{Column: "profile_id", Value: actor}})
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	for _, i := range res.Rows {
+		followers[*i["follower_id"].(*int64)] = struct{}{}
+	}
+
+	if org != nil {
+		res, err := s.DBH.Select(ctx, orgFollowsTable, func() map[string]any {
+			return map[string]any{"follower_id": new(int64)}
+		}, []db.Condition{{Column: "org_id", Value: *org}})
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		for _, i := range res.Rows {
+			followers[*i["follower_id"].(*int64)] = struct{}{}
+		}
+	}
+	delete(followers, actor)
+
+	if len(followers) == 0 {
+		return
+	}
+
+	api := conf.MustGetConf("REST", "domain").(string)
+	now := time.Now()
+
+	activity := db.JSON{
+		"@type":     kind,
+		"url":       fmt.Sprintf("%s/%s", api, url),
+		"actor":     fmt.Sprintf("%s/profile/%d", api, actor),
+		"published": now.UTC().Format(time.RFC3339),
+	}
+	if org != nil {
+		activity["org"] = fmt.Sprintf("%s/org/%d", api, *org)
+	}
+
+	value, err := json.Marshal(activity)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	keys := make([]string, 0, len(followers))
+	for i := range followers {
+		keys = append(keys, feedKey(i))
+	}
+
+	keep := int64(conf.MustGetConfOrDefault[float64](1000, "REST", "feed", "max"))
+	ttl := time.Duration(conf.MustGetConfOrDefault[float64](60*60*24*30, "REST", "feed", "ttl")) * time.Second
+	if err := s.KV.ZAdd(ctx, keys, db.ZMember{
+		Score:  float64(now.UnixMicro()),
+		Member: value,
+	}, keep, ttl); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+func readFeed(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	if s.KV == nil {
+		return nil, &mux.HttpError{
+			Body:   "the activity feed is not available",
+			Status: http.StatusServiceUnavailable,
+		}
+	}
+
+	if s.Seg(2) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	after, limit, err := parsePage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	you := conf.MustGetConfOrDefault("@", "REST", "myself")
+
+	var profiles []int64
+	if id := s.Seg(0); id != nil && *id == you {
+		a, err := authSrv(r.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		claims, err := helpers.ValidateAndGetClaims(w, r, a, bloqs_auth.READ_PROFILE)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := s.DBH.Select(r.Context(), "credential_profiles", func() map[string]any {
+			return map[string]any{"profile_id": new(int64)}
+		}, []db.Condition{{Column: "credential_id", Value: claims.Payload.AccountID()}})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range res.Rows {
+			profiles = append(profiles, *i["profile_id"].(*int64))
+		}
+	} else {
+		profile, err := segToID(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, _, err := YourProfile(w, r, s, bloqs_auth.READ_PROFILE, profile); err != nil {
+			return nil, err
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	var before *float64
+	if after != nil {
+		cursor := float64(*after)
+		before = &cursor
+	}
+
+	// each feed has its page, the page is the most recent of all of them
+	seen := map[string]struct{}{}
+	entries := []db.ZMember{}
+	for _, profile := range profiles {
+		members, err := s.KV.ZRevRange(r.Context(), feedKey(profile), before, int64(limit)+1)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range members {
+			// the same activity can be in the feeds of more than one of
+			// your profiles
+			if _, ok := seen[string(i.Member)]; ok {
+				continue
+			}
+			seen[string(i.Member)] = struct{}{}
+
+			entries = append(entries, i)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Score > entries[j].Score
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+
+		api := conf.MustGetConf("REST", "domain").(string)
+		setNextPage(w, fmt.Sprintf("%s/profile/%s/feed", api, *s.Seg(0)), strconv.FormatInt(int64(entries[limit-1].Score), 10), limit)
+	}
+
+	models := make([]db.JSON, 0, len(entries))
+	for _, i := range entries {
+		var activity db.JSON
+		if err := json.Unmarshal(i.Member, &activity); err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+
+		models = append(models, activity)
+	}
+
+	return &rest.Resource{
+		Models: models,
+		Type:   "ItemList",
+		Status: http.StatusOK,
+	}, nil
+}
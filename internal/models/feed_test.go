@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+)
+
+// memFeeds are the sorted sets of the feeds in memory.
+type memFeeds struct {
+	db.KVDBer
+	sets map[string][]db.ZMember
+}
+
+func (kv *memFeeds) ZAdd(ctx context.Context, keys []string, m db.ZMember, keep int64, ttl time.Duration) error {
+	for _, key := range keys {
+		set := append(kv.sets[key], m)
+		sort.SliceStable(set, func(i, j int) bool { return set[i].Score > set[j].Score })
+		if keep > 0 && int64(len(set)) > keep {
+			set = set[:keep]
+		}
+		kv.sets[key] = set
+	}
+	return nil
+}
+
+func (kv *memFeeds) ZRevRange(ctx context.Context, key string, before *float64, limit int64) ([]db.ZMember, error) {
+	members := []db.ZMember{}
+	for _, i := range kv.sets[key] {
+		if before != nil && i.Score >= *before {
+			continue
+		}
+		if limit > 0 && int64(len(members)) >= limit {
+			break
+		}
+		members = append(members, i)
+	}
+	return members, nil
+}
+
+func TestPublishActivity(t *testing.T) {
+	org := int64(50)
+
+	kv := &memFeeds{sets: map[string][]db.ZMember{}}
+	s := rest.RESTServer{KV: kv, DBH: &memDB{tables: map[string][]db.JSON{
+		followsTable: {
+			{"profile_id": int64(1), "follower_id": int64(2)},
+			{"profile_id": int64(1), "follower_id": int64(3)},
+			{"profile_id": int64(9), "follower_id": int64(5)},
+		},
+		orgFollowsTable: {
+			{"org_id": org, "follower_id": int64(3)},
+			{"org_id": org, "follower_id": int64(4)},
+			// the author follows the organization too
+			{"org_id": org, "follower_id": int64(1)},
+		},
+	}}}
+
+	publishActivity(context.Background(), s, FeedBloq, 7, "bloq/7", 1, &org)
+
+	for profile, want := range map[int64]int{1: 0, 2: 1, 3: 1, 4: 1, 5: 0} {
+		if got := len(kv.sets[feedKey(profile)]); got != want {
+			t.Errorf("the feed of the profile %d has %d activities, want %d", profile, got, want)
+		}
+	}
+
+	var activity db.JSON
+	if err := json.Unmarshal(kv.sets[feedKey(3)][0].Member, &activity); err != nil {
+		t.Fatal(err)
+	}
+	if activity["@type"] != FeedBloq || activity["url"] != "https://api.example.com/bloq/7" ||
+		activity["actor"] != "https://api.example.com/profile/1" || activity["org"] != "https://api.example.com/org/50" {
+		t.Errorf("publishActivity() published %v", activity)
+	}
+
+	// without a KV store there's no feed to publish to
+	s.KV = nil
+	publishActivity(context.Background(), s, FeedBloq, 8, "bloq/8", 1, nil)
+}
+
+func TestReadFeed(t *testing.T) {
+	activity := func(url string, score float64) db.ZMember {
+		return db.ZMember{Score: score, Member: []byte(fmt.Sprintf(`{"url": %q}`, url))}
+	}
+
+	// the account has the profiles 3 and 4, and both follow the author of c
+	kv := &memFeeds{sets: map[string][]db.ZMember{
+		feedKey(3): {activity("b", 300), activity("c", 200), activity("a", 100)},
+		feedKey(4): {activity("c", 200), activity("d", 150)},
+	}}
+	dbh := &memDB{tables: map[string][]db.JSON{
+		"credential_profiles": {
+			{"credential_id": "account", "profile_id": int64(3), "birthDate": "2000-01-01"},
+			{"credential_id": "account", "profile_id": int64(4), "birthDate": "2000-01-01"},
+			{"credential_id": "other", "profile_id": int64(5), "birthDate": "2000-01-01"},
+		},
+	}}
+	s := rest.NewRESTServer("", dbh)
+	s.KV = kv
+	s.AttachHandler(context.Background(), "/profile", new(Profile))
+	h := s.Serve()
+
+	read := func(target, account string) (int, []string, string) {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Authorization", token(account, bloqs_auth.READ_PROFILE))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			return w.Code, nil, ""
+		}
+
+		var models []map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &models); err != nil {
+			t.Fatalf("GET %s = %q: %v", target, w.Body, err)
+		}
+
+		urls := []string{}
+		for _, i := range models {
+			if u, ok := i["url"].(string); ok {
+				urls = append(urls, u)
+			}
+		}
+
+		return w.Code, urls, w.Header().Get("Link")
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		account string
+		status  int
+		urls    []string
+		next    string
+	}{
+		{"yours", "/profile/@/feed?limit=2", "account", http.StatusOK, []string{"b", "c"}, "<https://api.example.com/profile/@/feed?after=200&limit=2>; rel=\"next\""},
+		{"yours, next page", "/profile/@/feed?limit=2&after=200", "account", http.StatusOK, []string{"d", "a"}, ""},
+		{"yours, the activities in both feeds once", "/profile/@/feed?limit=3", "account", http.StatusOK, []string{"b", "c", "d"}, "<https://api.example.com/profile/@/feed?after=150&limit=3>; rel=\"next\""},
+		{"one profile", "/profile/4/feed", "account", http.StatusOK, []string{"c", "d"}, ""},
+		{"no activity", "/profile/5/feed", "other", http.StatusOK, []string{}, ""},
+		{"someone else's", "/profile/4/feed", "other", http.StatusForbidden, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, urls, next := read(tt.target, tt.account)
+			if status != tt.status || (tt.urls != nil && !reflect.DeepEqual(urls, tt.urls)) || next != tt.next {
+				t.Errorf("GET %s = (%d, %v, %q), want (%d, %v, %q)", tt.target, status, urls, next, tt.status, tt.urls, tt.next)
+			}
+		})
+	}
+}
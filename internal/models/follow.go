@@ -0,0 +1,381 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+)
+
+const followsTable = "profile_follows"
+
+/*
+/profile/:id/followers
+/profile/:id/followers/:follower
+/profile/:id/following
+/org/:id/followers
+/org/:id/followers/:follower
+*/
+
+// parsePage reads the `after` cursor and the `limit` of a paginated listing
+// from the query string.
+func parsePage(r *http.Request) (after *int64, limit int, err error) {
+	q := r.URL.Query()
+
+	max := int(conf.MustGetConfOrDefault[float64](100, "REST", "pagination", "max"))
+	limit = int(conf.MustGetConfOrDefault[float64](20, "REST", "pagination", "default"))
+
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > max {
+			return nil, 0, &mux.HttpError{
+				Body:   fmt.Sprintf("`limit` query parameter has to be an integer between 1 and %d", max),
+				Status: http.StatusBadRequest,
+			}
+		}
+	}
+
+	if v := q.Get("after"); v != "" {
+		cursor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, 0, &mux.HttpError{
+				Body:   "`after` query parameter has to be a cursor returned by a previous page",
+				Status: http.StatusBadRequest,
+			}
+		}
+		after = &cursor
+	}
+
+	return
+}
+
+// setNextPage advertises the next page of a listing in the `Link` header.
+func setNextPage(w http.ResponseWriter, url string, after string, limit int) {
+	w.Header().Set("Link", fmt.Sprintf("<%s?after=%s&limit=%d>; rel=\"next\"", url, after, limit))
+}
+
+func profileExists(ctx context.Context, dbh db.DataManipulater, profile int64) (bool, error) {
+	res, err := dbh.Select(ctx, "profile", func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "id", Value: profile}})
+
+	return len(res.Rows) == 1, err
+}
+
+func isFollowing(ctx context.Context, dbh db.DataManipulater, profile, follower int64) (bool, error) {
+	res, err := dbh.Select(ctx, followsTable, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "profile_id", Value: profile},
+		{Column: "follower_id", Value: follower},
+	})
+
+	return len(res.Rows) == 1, err
+}
+
+func followProfile(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
+	profile, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Seg(2) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	follower, err := strconv.ParseInt(r.FormValue("follower"), 10, 64)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   "`follower` body field has to be the id of one of your profiles",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	if follower == profile {
+		return nil, &mux.HttpError{
+			Body:   "a profile cannot follow itself",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	if exists, err := profileExists(r.Context(), s.DBH, profile); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` does not exist", profile),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.FOLLOW_PROFILE, follower); err != nil {
+		return nil, err
+	}
+
+	if following, err := isFollowing(r.Context(), s.DBH, profile, follower); err != nil {
+		return nil, err
+	} else if following {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` already follows the profile with id `%d`", follower, profile),
+			Status: http.StatusConflict,
+		}
+	}
+
+	result, err := s.DBH.Insert(r.Context(), followsTable, []map[string]any{
+		{
+			"profile_id":  profile,
+			"follower_id": follower,
+		},
+	})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return &rest.Created{
+		LastID:   result.LastID,
+		Message:  "",
+		Status:   http.StatusCreated,
+		Location: fmt.Sprintf("profile/%d/followers/%d", profile, follower),
+	}, nil
+}
+
+func unfollowProfile(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	profile, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	seg := s.Seg(2)
+	if seg == nil {
+		v := r.FormValue("follower")
+		seg = &v
+	}
+
+	follower, err := segToID(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.FOLLOW_PROFILE, follower); err != nil {
+		return nil, err
+	}
+
+	if following, err := isFollowing(r.Context(), s.DBH, profile, follower); err != nil {
+		return nil, err
+	} else if !following {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` does not follow the profile with id `%d`", follower, profile),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if err := s.DBH.Delete(r.Context(), followsTable, map[string]any{
+		"profile_id":  profile,
+		"follower_id": follower,
+	}); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return &rest.Resource{
+		Type:   "Person",
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+// readFollows lists the followers of a profile or, when following is set, the
+// profiles it follows. Pages are sorted from the most recent follow.
+func readFollows(w http.ResponseWriter, r *http.Request, s rest.RESTServer, following bool) (*rest.Resource, error) {
+	profile, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Seg(2) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	after, limit, err := parsePage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists, err := profileExists(r.Context(), s.DBH, profile); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` does not exist", profile),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	by, other, second := "profile_id", "follower_id", "followers"
+	if following {
+		by, other, second = "follower_id", "profile_id", "following"
+	}
+
+	where := []db.Condition{{Column: by, Value: profile}}
+	if after != nil {
+		where = append(where, db.Condition{Column: "id", Op: db.LT, Value: *after})
+	}
+
+	// one more than the page to know if there's a next one
+	result, err := s.DBH.SelectPage(r.Context(), followsTable, func() map[string]any {
+		return map[string]any{
+			"id":    new(int64),
+			other:   new(int64),
+			"since": new(string),
+		}
+	}, where, db.Page{OrderBy: "id", Desc: true, Limit: uint(limit) + 1})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := result.Rows
+
+	api := conf.MustGetConf("REST", "domain").(string)
+	if len(rows) > limit {
+		rows = rows[:limit]
+		setNextPage(w, fmt.Sprintf("%s/profile/%d/%s", api, profile, second), strconv.FormatInt(*rows[limit-1]["id"].(*int64), 10), limit)
+	}
+
+	for _, i := range rows {
+		i["@type"] = "Person"
+		i["url"] = fmt.Sprintf("%s/profile/%d", api, *i[other].(*int64))
+		delete(i, "id")
+		delete(i, other)
+	}
+
+	return &rest.Resource{
+		Models: rows,
+		Type:   "Person",
+		Status: http.StatusOK,
+	}, nil
+}
+
+func followOrg(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Seg(2) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	follower, err := strconv.ParseInt(r.FormValue("follower"), 10, 64)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   "`follower` body field has to be the id of one of your profiles",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	res, err := s.DBH.Select(r.Context(), "org", func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "id", Value: org}})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) != 1 {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("organization with id `%d` does not exist", org),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.FOLLOW_PROFILE, follower); err != nil {
+		return nil, err
+	}
+
+	where := []db.Condition{
+		{Column: "org_id", Value: org},
+		{Column: "follower_id", Value: follower},
+	}
+	if res, err = s.DBH.Select(r.Context(), orgFollowsTable, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, where); err != nil {
+		return nil, err
+	} else if len(res.Rows) != 0 {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` already follows the organization with id `%d`", follower, org),
+			Status: http.StatusConflict,
+		}
+	}
+
+	result, err := s.DBH.Insert(r.Context(), orgFollowsTable, []map[string]any{
+		{
+			"org_id":      org,
+			"follower_id": follower,
+		},
+	})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return &rest.Created{
+		LastID:   result.LastID,
+		Message:  "",
+		Status:   http.StatusCreated,
+		Location: fmt.Sprintf("org/%d/followers/%d", org, follower),
+	}, nil
+}
+
+func unfollowOrg(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	seg := s.Seg(2)
+	if seg == nil {
+		v := r.FormValue("follower")
+		seg = &v
+	}
+
+	follower, err := segToID(seg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.FOLLOW_PROFILE, follower); err != nil {
+		return nil, err
+	}
+
+	if err := s.DBH.Delete(r.Context(), orgFollowsTable, map[string]any{
+		"org_id":      org,
+		"follower_id": follower,
+	}); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return &rest.Resource{
+		Type:   ORG_TYPE,
+		Status: http.StatusNoContent,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+)
+
+// restServer serves the handler on its route like the REST service does.
+func restServer(dbh db.DataManipulater, route string, h rest.Handler) http.HandlerFunc {
+	s := rest.NewRESTServer("", dbh)
+	s.AttachHandler(context.Background(), route, h)
+	return s.Serve()
+}
+
+// follows are the profiles 1 to 7, where the profiles 2 to 6 follow the 1,
+// one after the other, and the 1 follows the 2.
+func follows() *memDB {
+	dbh := &memDB{tables: map[string][]db.JSON{}}
+	for i := int64(1); i <= 7; i++ {
+		dbh.tables["profile"] = append(dbh.tables["profile"], db.JSON{"id": i})
+	}
+	for i := int64(2); i <= 6; i++ {
+		dbh.tables[followsTable] = append(dbh.tables[followsTable], db.JSON{
+			"id": i + 8, "profile_id": int64(1), "follower_id": i, "since": "2024-01-01 00:00:00",
+		})
+	}
+	dbh.tables[followsTable] = append(dbh.tables[followsTable], db.JSON{
+		"id": int64(15), "profile_id": int64(2), "follower_id": int64(1), "since": "2024-01-02 00:00:00",
+	})
+
+	return dbh
+}
+
+// readPage gets a page of follows, the urls of the profiles in it and the
+// cursor of the next one.
+func readPage(t *testing.T, h http.Handler, target string) (int, []string, string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != http.StatusOK {
+		return w.Code, nil, ""
+	}
+
+	var models []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &models); err != nil {
+		t.Fatalf("GET %s = %q: %v", target, w.Body, err)
+	}
+
+	urls := []string{}
+	for _, i := range models {
+		if u, ok := i["url"].(string); ok {
+			urls = append(urls, strings.TrimPrefix(u, "https://api.example.com/"))
+		}
+	}
+
+	var after string
+	if link := w.Header().Get("Link"); link != "" {
+		next, err := url.Parse(strings.TrimSuffix(strings.TrimPrefix(link, "<"), ">; rel=\"next\""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		after = next.Query().Get("after")
+	}
+
+	return w.Code, urls, after
+}
+
+func TestReadFollows(t *testing.T) {
+	h := restServer(follows(), "/profile", new(Profile))
+
+	// the most recent follows first, a page at a time
+	pages := []struct {
+		after string
+		urls  []string
+		next  string
+	}{
+		{"", []string{"profile/6", "profile/5"}, "13"},
+		{"13", []string{"profile/4", "profile/3"}, "11"},
+		{"11", []string{"profile/2"}, ""},
+	}
+
+	for _, tt := range pages {
+		target := "/profile/1/followers?limit=2"
+		if tt.after != "" {
+			target += "&after=" + tt.after
+		}
+
+		status, urls, next := readPage(t, h, target)
+		if status != http.StatusOK {
+			t.Fatalf("GET %s = %d", target, status)
+		}
+		if !reflect.DeepEqual(urls, tt.urls) || next != tt.next {
+			t.Errorf("GET %s = (%v, next %q), want (%v, next %q)", target, urls, next, tt.urls, tt.next)
+		}
+	}
+
+	tests := []struct {
+		target string
+		status int
+		urls   []string
+	}{
+		{"/profile/1/following", http.StatusOK, []string{"profile/2"}},
+		{"/profile/3/following", http.StatusOK, []string{"profile/1"}},
+		{"/profile/7/followers", http.StatusOK, []string{}},
+		{"/profile/7/following", http.StatusOK, []string{}},
+		{"/profile/8/followers", http.StatusNotFound, nil},
+		{"/profile/1/followers?limit=0", http.StatusBadRequest, nil},
+		{"/profile/1/followers?limit=101", http.StatusBadRequest, nil},
+		{"/profile/1/followers?after=last", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		status, urls, _ := readPage(t, h, tt.target)
+		if status != tt.status || (tt.urls != nil && !reflect.DeepEqual(urls, tt.urls)) {
+			t.Errorf("GET %s = (%d, %v), want (%d, %v)", tt.target, status, urls, tt.status, tt.urls)
+		}
+	}
+}
+
+func TestFollowYourself(t *testing.T) {
+	dbh := follows()
+	h := restServer(dbh, "/profile", new(Profile))
+
+	r := httptest.NewRequest(http.MethodPost, "/profile/1/followers", strings.NewReader(url.Values{"follower": {"1"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /profile/1/followers by itself = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if n := len(dbh.tables[followsTable]); n != 6 {
+		t.Errorf("there are %d follows, want 6", n)
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/testconf"
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+// acceptAll validates every token, the tests make the claims they need with
+// token.
+type acceptAll struct{}
+
+func (acceptAll) Validate(context.Context, *proto.Token) (*proto.Validation, error) {
+	return &proto.Validation{Valid: true}, nil
+}
+
+// token is an unsigned bearer token of the account with the permissions.
+func token(account string, p bloqs_auth.Permission) string {
+	claims, _ := json.Marshal(bloqs_auth.Claims{Payload: bloqs_auth.Payload{Account: account, Permissions: p}})
+	return "Bearer e30." + base64.RawStdEncoding.EncodeToString(claims) + ".c2ln"
+}
+
+func TestMain(m *testing.M) {
+	cleanup, err := testconf.Compile(`{"REST": {"domain": "https://api.example.com"}}`)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	validator_once.Do(func() { validator = acceptAll{} })
+
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -69,6 +70,7 @@ func (Offer) CreateTable() []db.Table {
 				"`availabilityStarts` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"`availabilityEnds` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"`offeredBy` INT UNSIGNED NOT NULL",
+				"`seller` INT UNSIGNED DEFAULT NULL",
 				"`price` DOUBLE NOT NULL",
 				"PRIMARY KEY(`id`)",
 			},
@@ -93,6 +95,17 @@ func (Offer) CreateViews() []db.View {
 	return []db.View{}
 }
 
+func (Offer) Migrations() []db.Migration {
+	return []db.Migration{
+		{
+			ID: "offer_seller",
+			Statements: []string{
+				fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `seller` INT UNSIGNED DEFAULT NULL AFTER `offeredBy`;", OfferTable),
+			},
+		},
+	}
+}
+
 func (Offer) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
 	var (
 		status uint16 = http.StatusInternalServerError
@@ -101,6 +114,7 @@ func (Offer) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 		availabilityStarts time.Time
 		availabilityEnds   time.Time
 		offeredBy          int64
+		seller             *int64
 		price              float32
 		itemsOffered       []int64
 	)
@@ -250,6 +264,11 @@ func (Offer) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 		return nil, err
 	}
 
+	seller, err = orgFormValue(r, s, offeredBy)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(itemsOffered) < 1 {
 		return nil, &mux.HttpError{
 			Body:   "No items offered",
@@ -262,6 +281,12 @@ func (Offer) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 		if err != nil {
 			return nil, err
 		}
+		if !valid && seller != nil {
+			valid, err = isOrgProduct(r.Context(), i, *seller, s.DBH)
+			if err != nil {
+				return nil, err
+			}
+		}
 		if !valid {
 			return nil, &mux.HttpError{
 				Body:   fmt.Sprintf("Item with id `%d` it's not yours", i),
@@ -276,6 +301,7 @@ func (Offer) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 			"availabilityStarts": availabilityStarts,
 			"availabilityEnds":   availabilityEnds,
 			"offeredBy":          offeredBy,
+			"seller":             seller,
 			"price":              price,
 		},
 	})
@@ -304,6 +330,8 @@ func (Offer) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 		}
 	}
 
+	publishActivity(r.Context(), s, FeedOffer, id, fmt.Sprintf("offer/%d", id), offeredBy, seller)
+
 	return &rest.Created{
 		LastID:  &id,
 		Message: "",
@@ -347,6 +375,7 @@ func (Offer) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 					"availabilityStarts": new(string),
 					"availabilityEnds":   new(string),
 					"offeredBy":          new(int64),
+					"seller":             new(sql.NullInt64),
 					"price":              new(float32),
 				}
 			}, []db.Condition{
@@ -358,6 +387,12 @@ func (Offer) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 			return nil, err
 		}
 		for _, o := range res.Rows {
+			if seller := o["seller"].(*sql.NullInt64); seller.Valid {
+				o["seller"] = fmt.Sprintf("%s/org/%d", api, seller.Int64)
+			} else {
+				o["seller"] = nil
+			}
+
 			res, err = s.DBH.Select(r.Context(), ItemsOfferedTable,
 				func() map[string]any {
 					return map[string]any{"offers": new(int64)}
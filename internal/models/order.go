@@ -131,7 +131,7 @@ func (Order) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 	for i := 0; i < quantity; i++ {
 		_, err := s.DBH.Insert(r.Context(), OrderTable, []map[string]any{
 			{
-				"customer":      claims.Payload.Client,
+				"customer":      claims.Payload.AccountID(),
 				"acceptedOffer": acceptedOffer,
 			},
 		})
@@ -170,7 +170,7 @@ func (Order) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 
 		where = append(where, db.Condition{
 			Column: "customer",
-			Value:  claims.Payload.Client,
+			Value:  claims.Payload.AccountID(),
 		})
 	}
 
@@ -209,7 +209,7 @@ func (Order) Delete(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 		return nil, err
 	}
 
-	where := map[string]any{"customer": claims.Payload.Client}
+	where := map[string]any{"customer": claims.Payload.AccountID()}
 	id := s.Seg(0)
 	if (id != nil) && (*id != "") {
 		where["id"] = *id
@@ -1,32 +1,70 @@
 package models
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
+	"net/mail"
+	uri "net/url"
 	"strconv"
 	"strings"
-	"sync"
 
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
 	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
 	"github.com/bloqs-sites/bloqsenjin/pkg/db"
 	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
 	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	bloqs_image "github.com/bloqs-sites/bloqsenjin/pkg/image"
 	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
-	"github.com/bloqs-sites/bloqsenjin/proto"
 )
 
 type Org struct {
 }
 
-const ORG_TYPE = "Organization"
+type OrgRole = string
+
+const (
+	ORG_TYPE = "Organization"
+
+	OrgOwner  OrgRole = bloqs_auth.ORG_OWNER
+	OrgAdmin  OrgRole = bloqs_auth.ORG_ADMIN
+	OrgMember OrgRole = bloqs_auth.ORG_MEMBER
+
+	orgInvited  = "invited"
+	orgAccepted = "accepted"
+)
+
+var (
+	OrgRoles = []OrgRole{
+		OrgOwner,
+		OrgAdmin,
+		OrgMember,
+	}
+)
+
+/*
+/org/
+/org/:id/
+/org/:id/members
+/org/:id/members/:profile
+/org/:id/ratings
+/org/:id/ratings/:profile
+/org/:id/followers
+/org/:id/followers/:follower
+*/
 
 func (Org) Table() string {
 	return "org"
 }
 
+func (Org) Type() string {
+	return ORG_TYPE
+}
+
 func (Org) CreateTable() []db.Table {
 	return []db.Table{
 		{
@@ -34,12 +72,12 @@ func (Org) CreateTable() []db.Table {
 			Columns: []string{
 				"`id` INT UNSIGNED AUTO_INCREMENT",
 				"`name` VARCHAR(80) NOT NULL",
-				"`description` VARCHAR(80) NOT NULL",
-				"`url` VARCHAR(255) NOT NULL",
-				"`logo` VARCHAR(255) NOT NULL",
+				"`description` VARCHAR(140) DEFAULT NULL",
+				"`url` VARCHAR(255) DEFAULT NULL",
+				"`logo` VARCHAR(255) DEFAULT NULL",
 				"`email` VARCHAR(320) DEFAULT NULL",
 				"`founder` INT UNSIGNED NOT NULL",
-				"`foudingDate` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
+				"`foundingDate` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"PRIMARY KEY(`id`)",
 			},
 		},
@@ -49,6 +87,9 @@ func (Org) CreateTable() []db.Table {
 				"`id` INT UNSIGNED AUTO_INCREMENT",
 				"`org_id` INT UNSIGNED NOT NULL",
 				"`profile_id` INT UNSIGNED NOT NULL",
+				fmt.Sprintf("`role` ENUM('%s') NOT NULL DEFAULT '%s'", strings.Join(OrgRoles, "','"), OrgMember),
+				fmt.Sprintf("`status` ENUM('%s', '%s') NOT NULL DEFAULT '%s'", orgInvited, orgAccepted, orgInvited),
+				"`startDate` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"UNIQUE(`org_id`, `profile_id`)",
 				"PRIMARY KEY(`id`)",
 			},
@@ -63,7 +104,7 @@ func (Org) CreateTable() []db.Table {
 			},
 		},
 		{
-			Name: "org_ratings",
+			Name: orgRatingsTable,
 			Columns: []string{
 				"`id` INT UNSIGNED AUTO_INCREMENT",
 				"`org_id` INT UNSIGNED NOT NULL",
@@ -74,6 +115,37 @@ func (Org) CreateTable() []db.Table {
 				"PRIMARY KEY(`id`)",
 			},
 		},
+		{
+			Name: orgFollowsTable,
+			Columns: []string{
+				"`id` INT UNSIGNED AUTO_INCREMENT",
+				"`org_id` INT UNSIGNED NOT NULL",
+				"`follower_id` INT UNSIGNED NOT NULL",
+				"`since` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
+				"UNIQUE (`org_id`, `follower_id`)",
+				"PRIMARY KEY(`id`)",
+			},
+		},
+		{
+			Name: orgAggregateTable,
+			Columns: []string{
+				"`org_id` INT UNSIGNED NOT NULL",
+				"`ratingCount` INT NOT NULL DEFAULT 0",
+				"`ratingSum` BIGINT NOT NULL DEFAULT 0",
+				"PRIMARY KEY(`org_id`)",
+			},
+		},
+		{
+			Name: orgDistributionTable,
+			Columns: []string{
+				"`id` INT UNSIGNED AUTO_INCREMENT",
+				"`org_id` INT UNSIGNED NOT NULL",
+				"`ratingValue` INT NOT NULL",
+				"`ratingCount` INT NOT NULL DEFAULT 0",
+				"UNIQUE(`org_id`, `ratingValue`)",
+				"PRIMARY KEY(`id`)",
+			},
+		},
 	}
 }
 
@@ -85,14 +157,65 @@ func (Org) CreateViews() []db.View {
 	return nil
 }
 
+func (Org) Migrations() []db.Migration {
+	return []db.Migration{
+		{
+			ID: "org_founding_date",
+			Statements: []string{
+				"ALTER TABLE `org` CHANGE COLUMN `foudingDate` `foundingDate` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;",
+				"ALTER TABLE `org` MODIFY COLUMN `description` VARCHAR(140) DEFAULT NULL, MODIFY COLUMN `url` VARCHAR(255) DEFAULT NULL, MODIFY COLUMN `logo` VARCHAR(255) DEFAULT NULL;",
+			},
+		},
+		{
+			ID: "org_members_roles",
+			Statements: []string{
+				fmt.Sprintf("ALTER TABLE `org_members` ADD COLUMN `role` ENUM('%s') NOT NULL DEFAULT '%s';", strings.Join(OrgRoles, "','"), OrgMember),
+				// the members from before invitations had already joined
+				fmt.Sprintf("ALTER TABLE `org_members` ADD COLUMN `status` ENUM('%s', '%s') NOT NULL DEFAULT '%s';", orgInvited, orgAccepted, orgAccepted),
+				fmt.Sprintf("ALTER TABLE `org_members` MODIFY COLUMN `status` ENUM('%s', '%s') NOT NULL DEFAULT '%s';", orgInvited, orgAccepted, orgInvited),
+				"ALTER TABLE `org_members` ADD COLUMN `startDate` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;",
+				// the founders own the organizations from before there were
+				// roles, that have no owner
+				fmt.Sprintf("INSERT INTO `org_members` (`org_id`, `profile_id`, `role`, `status`) SELECT `org`.`id`, `org`.`founder`, '%[1]s', '%[2]s' FROM `org` WHERE NOT EXISTS (SELECT 1 FROM `org_members` WHERE `org_members`.`org_id` = `org`.`id` AND `org_members`.`role` = '%[1]s') ON DUPLICATE KEY UPDATE `role` = VALUES(`role`), `status` = VALUES(`status`);", OrgOwner, orgAccepted),
+			},
+		},
+		{
+			// the counts are incremented with negative deltas too
+			ID: "org_ratings_signed_counts",
+			Statements: []string{
+				fmt.Sprintf("ALTER TABLE `%s` MODIFY COLUMN `ratingCount` INT NOT NULL DEFAULT 0;", orgAggregateTable),
+				fmt.Sprintf("ALTER TABLE `%s` MODIFY COLUMN `ratingCount` INT NOT NULL DEFAULT 0;", orgDistributionTable),
+			},
+		},
+	}
+}
+
 func (Org) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
+	if second := s.Seg(1); second != nil {
+		switch *second {
+		case "members":
+			return inviteOrgMember(w, r, s)
+		case "ratings":
+			return createOrgRating(w, r, s)
+		case "followers":
+			return followOrg(w, r, s)
+		}
+
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
 	var (
 		status uint16 = http.StatusInternalServerError
 
-		name                  string
-		hasAdultConsideration          = "0"
-		image                 string   = "NULL"
-		likes                 []string = []string{}
+		name         string
+		description  *string = nil
+		url          *string = nil
+		email        *string = nil
+		logo         multipart.File
+		logo_header  *multipart.FileHeader
+		founder      int64
+		languages    []string = []string{}
+		founder_form string
 	)
 
 	ct := r.Header.Get("Content-Type")
@@ -107,31 +230,33 @@ func (Org) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 					Status: status,
 				}
 		}
-
-		name = r.FormValue("name")
-		adult := r.FormValue("hasAdultConsideration")
-		if adult == "yes" || adult == "on" || adult == "1" || adult == "true" {
-			hasAdultConsideration = "1"
-		}
-		likes = r.Form["likes"]
 	} else if strings.HasPrefix(ct, bloqs_helpers.FORM_DATA) {
-		if err := r.ParseMultipartForm(0x400); err != nil {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
 			status = http.StatusBadRequest
 			return &rest.Created{
 					Status:  status,
-					Message: fmt.Sprintf("the HTTP request body could not be parsed as `%s`:\t%s", bloqs_helpers.X_WWW_FORM_URLENCODED, err),
+					Message: fmt.Sprintf("the HTTP request body could not be parsed as `%s`:\t%s", bloqs_helpers.FORM_DATA, err),
+				}, &mux.HttpError{
+					Body:   err.Error(),
+					Status: status,
+				}
+		}
+
+		var err error
+		logo, logo_header, err = r.FormFile("logo")
+		if err != nil && !errors.Is(err, http.ErrMissingFile) {
+			return &rest.Created{
+					Status:  status,
+					Message: fmt.Sprintf("the HTTP request body could not be parsed as `%s`:\t%s", bloqs_helpers.FORM_DATA, err),
 				}, &mux.HttpError{
 					Body:   err.Error(),
 					Status: status,
 				}
 		}
 
-		name = r.FormValue("name")
-		adult := r.FormValue("hasAdultConsideration")
-		if adult == "yes" || adult == "on" || adult == "1" || adult == "true" {
-			hasAdultConsideration = "1"
+		if logo != nil {
+			defer logo.Close()
 		}
-		likes = r.Form["likes"]
 	} else {
 		status = http.StatusUnsupportedMediaType
 		h := w.Header()
@@ -143,6 +268,19 @@ func (Org) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 		}, nil
 	}
 
+	name = r.FormValue("name")
+	if v := r.FormValue("description"); v != "" {
+		description = &v
+	}
+	if v := r.FormValue("url"); v != "" {
+		url = &v
+	}
+	if v := r.FormValue("email"); v != "" {
+		email = &v
+	}
+	founder_form = r.FormValue("founder")
+	languages = r.Form["languages"]
+
 	if l := len(name); l > 80 || l <= 0 {
 		status = http.StatusUnprocessableEntity
 		return &rest.Created{
@@ -151,85 +289,60 @@ func (Org) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 		}, nil
 	}
 
-	tk, err := bloqs_helpers.ExtractToken(w, r)
-
-	if err != nil {
+	if err := validateOrgFields(description, url, email); err != nil {
 		return nil, err
 	}
 
-	a, err := authSrv(r.Context())
-
-	if err != nil {
-		return nil, err
+	for _, v := range languages {
+		if l := len(v); l > 255 || l <= 0 {
+			status = http.StatusUnprocessableEntity
+			return &rest.Created{
+				Status:  status,
+				Message: fmt.Sprintf("`languages` `%s` body field has to have a length between 1 and 255 characters", v),
+			}, nil
+		}
 	}
 
-	permission := bloqs_auth.CREATE_PROFILE
-	v, err := a.Validate(r.Context(), &proto.Token{
-		Jwt:         string(tk),
-		Permissions: (*uint64)(&permission),
-	})
-
-	if err != nil {
-		return nil, err
+	if logo_header != nil {
+		if ct := logo_header.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/") {
+			status = http.StatusUnprocessableEntity
+			return &rest.Created{
+				Status:  status,
+				Message: "`logo` it's not really a `image/*`",
+			}, nil
+		}
 	}
 
-	claims := &bloqs_auth.Claims{}
-	claims_str, err := base64.RawStdEncoding.DecodeString(strings.Split(string(tk), ".")[1])
+	founder, err := strconv.ParseInt(founder_form, 10, 64)
 	if err != nil {
-		return nil, err
+		status = http.StatusUnprocessableEntity
+		return &rest.Created{
+			Status:  status,
+			Message: "`founder` body field has to be the id of one of your profiles",
+		}, nil
 	}
-	err = json.Unmarshal(claims_str, claims)
 
-	if err != nil {
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.CREATE_ORG, founder); err != nil {
 		return nil, err
 	}
 
-	if !v.Valid {
-		msg := ""
-
-		if v.Message != nil {
-			msg = *v.Message
-		}
-
-		if v.HttpStatusCode != nil {
-			status = uint16(*v.HttpStatusCode)
-		}
-
-		return nil, &mux.HttpError{
-			Body:   msg,
-			Status: status,
-		}
+	insert := map[string]any{
+		"name":        name,
+		"description": description,
+		"url":         url,
+		"email":       email,
+		"founder":     founder,
 	}
-
-	var result db.Result
-	result, err = s.DBH.Select(r.Context(), "credential_accounts", func() map[string]any {
-		return nil
-	}, []db.Condition{{Column: "credential_id", Value: claims.Payload.Client}})
-	if err != nil {
-		status = http.StatusInternalServerError
-		return nil, &mux.HttpError{
-			Body:   err.Error(),
-			Status: status,
+	if logo_header != nil {
+		logo, err := bloqs_image.Save(r.Context(), logo, logo_header)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	max := conf.MustGetConfOrDefault(1, "REST", "max")
-	if len(result.Rows) >= max {
-		status = http.StatusForbidden
-		return nil, &mux.HttpError{
-			Body:   fmt.Sprintf("the maximum limit of this resource (%d) has reached.", max),
-			Status: status,
-		}
+		insert["logo"] = logo
 	}
 
-	result, err = s.DBH.Insert(r.Context(), "account", []map[string]any{
-		{
-			"name":                  name,
-			"hasAdultConsideration": hasAdultConsideration,
-			"image":                 image,
-		},
-	})
-
+	result, err := s.DBH.Insert(r.Context(), "org", []map[string]any{insert})
 	if err != nil {
 		status = http.StatusInternalServerError
 		return nil, &mux.HttpError{
@@ -238,17 +351,18 @@ func (Org) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 		}
 	}
 
-	id := strconv.Itoa(int(*result.LastID))
+	id := *result.LastID
 
-	_, err = s.DBH.Insert(r.Context(), "credential_accounts", []map[string]any{
+	_, err = s.DBH.Insert(r.Context(), "org_members", []map[string]any{
 		{
-			"credential_id": claims.Payload.Client,
-			"account_id":    id,
+			"org_id":     id,
+			"profile_id": founder,
+			"role":       OrgOwner,
+			"status":     orgAccepted,
 		},
 	})
-
 	if err != nil {
-		s.DBH.Delete(r.Context(), "account", map[string]any{"id": id})
+		s.DBH.Delete(r.Context(), "org", map[string]any{"id": id})
 
 		status = http.StatusInternalServerError
 		return nil, &mux.HttpError{
@@ -257,26 +371,18 @@ func (Org) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 		}
 	}
 
-	if len(likes) != 0 {
-		likes_inserts := make([]map[string]any, 0, len(likes))
-		weight := strconv.Itoa(int(float64(100 / len(likes))))
-		for _, like := range likes {
-			likes_inserts = append(likes_inserts, map[string]any{
-				"account_id":    id,
-				"preference_id": like,
-				"weight":        weight,
+	if len(languages) != 0 {
+		languages_inserts := make([]map[string]any, 0, len(languages))
+		for _, language := range languages {
+			languages_inserts = append(languages_inserts, map[string]any{
+				"org_id":   id,
+				"language": language,
 			})
 		}
 
-		_, err = s.DBH.Insert(r.Context(), "account_likes", likes_inserts)
-
-		if err != nil {
-			s.DBH.Delete(r.Context(), "account", map[string]any{"id": id})
-
-			s.DBH.Delete(r.Context(), "credential_accounts", map[string]any{
-				"credential_id": claims.Payload.Client,
-				"account_id":    id,
-			})
+		if _, err = s.DBH.Insert(r.Context(), "org_languages", languages_inserts); err != nil {
+			s.DBH.Delete(r.Context(), "org", map[string]any{"id": id})
+			s.DBH.Delete(r.Context(), "org_members", map[string]any{"org_id": id})
 
 			status = http.StatusInternalServerError
 			return nil, &mux.HttpError{
@@ -284,60 +390,10 @@ func (Org) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 				Status: status,
 			}
 		}
-
-		for i := 0; i < len(likes); i++ {
-			for j := i + 1; j < len(likes); j++ {
-				var max, min int
-				if i < j {
-					min = i
-					max = j
-				} else {
-					min = j
-					max = i
-				}
-
-				res, err := s.DBH.Select(r.Context(), "shares", func() map[string]any {
-					return map[string]any{
-						"id":     new(int64),
-						"weight": new(float32),
-					}
-				}, []db.Condition{
-					{Column: "preference1_id", Value: min},
-					{Column: "preference2_id", Value: max},
-				})
-
-				if err != nil {
-					continue
-				}
-
-				if len(res.Rows) > 0 {
-					id := res.Rows[0]["id"].(*int64)
-					w := res.Rows[0]["weight"].(*float32)
-
-					if err := s.DBH.Update(r.Context(), "shares", map[string]any{
-						"weight": *w + 1.0,
-					}, map[string]any{
-						"id": *id,
-					}); err != nil {
-						fmt.Printf("%v\n", err)
-					}
-				} else {
-					if _, err := s.DBH.Insert(r.Context(), "shares", []map[string]any{
-						{
-							"preference1_id": strconv.Itoa(min),
-							"preference2_id": strconv.Itoa(max),
-							"weight":         "1",
-						},
-					}); err != nil {
-						fmt.Printf("%v\n", err)
-					}
-				}
-			}
-		}
 	}
 
 	return &rest.Created{
-		LastID:  result.LastID,
+		LastID:  &id,
 		Message: "",
 		Status:  http.StatusCreated,
 	}, nil
@@ -345,165 +401,714 @@ func (Org) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*r
 
 func (Org) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
 	id := s.Seg(0)
+	second := s.Seg(1)
 
-	you := conf.MustGetConfOrDefault("@", "REST", "myself")
 	api := conf.MustGetConf("REST", "domain").(string)
 
-	var (
-		result db.Result
-		err    error
-	)
+	if second != nil {
+		switch *second {
+		case "members":
+			return readOrgMembers(r, s)
+		case "ratings":
+			return readOrgRatings(r, s)
+		}
 
-	if id != nil && *id == you {
-		a, err := authSrv(r.Context())
+		return nil, nil
+	}
 
-		if err != nil {
-			return nil, err
+	var where []db.Condition = []db.Condition{}
+	if (id != nil) && (*id != "") {
+		where = append(where, db.Condition{Column: "id", Value: *id})
+	}
+
+	result, err := s.DBH.Select(r.Context(), "org", func() map[string]any {
+		return map[string]any{
+			"id":           new(int64),
+			"name":         new(string),
+			"description":  new(sql.NullString),
+			"url":          new(sql.NullString),
+			"logo":         new(sql.NullString),
+			"email":        new(sql.NullString),
+			"founder":      new(int64),
+			"foundingDate": new(string),
 		}
+	}, where)
 
-		tk, err := bloqs_helpers.ExtractToken(w, r)
+	for _, i := range result.Rows {
+		id := *i["id"].(*int64)
+
+		for _, k := range []string{"description", "url", "logo", "email"} {
+			if v := i[k].(*sql.NullString); v.Valid {
+				i[k] = v.String
+			} else {
+				i[k] = nil
+			}
+		}
 
+		res, err := s.DBH.Select(r.Context(), "org_languages", func() map[string]any {
+			return map[string]any{"language": new(string)}
+		}, []db.Condition{{Column: "org_id", Value: id}})
 		if err != nil {
 			return nil, err
 		}
 
-		p := bloqs_auth.READ_PROFILE
-		v, err := a.Validate(r.Context(), &proto.Token{
-			Jwt:         string(tk),
-			Permissions: (*uint64)(&p),
-		})
+		languages := make([]string, 0, len(res.Rows))
+		for _, l := range res.Rows {
+			languages = append(languages, *l["language"].(*string))
+		}
+
+		i["knowsLanguage"] = languages
 
+		rating, err := orgAggregateRating(r.Context(), s.DBH, id)
 		if err != nil {
 			return nil, err
 		}
+		i["aggregateRating"] = rating
+		i["ratings"] = fmt.Sprintf("%s/org/%d/ratings", api, id)
+		i["founder"] = fmt.Sprintf("%s/profile/%d", api, *i["founder"].(*int64))
+		i["member"] = fmt.Sprintf("%s/org/%d/members", api, id)
+		i["href"] = fmt.Sprintf("%s/org/%d", api, id)
+	}
 
-		if !v.Valid {
-			msg := ""
-			if v.Message != nil {
-				msg = *v.Message
-			}
+	status := http.StatusOK
+	msg := ""
+	if err != nil {
+		status = http.StatusInternalServerError
+		msg = err.Error()
+	}
 
+	return &rest.Resource{
+		Models:  result.Rows,
+		Type:    ORG_TYPE,
+		Unique:  (id != nil) && (*id != ""),
+		Status:  uint16(status),
+		Message: msg,
+	}, err
+}
+
+func (Org) Update(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	if second := s.Seg(1); second != nil {
+		switch *second {
+		case "members":
+			return updateOrgMember(w, r, s)
+		case "ratings":
+			return updateOrgRating(w, r, s)
+		}
+
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := orgResource(r.Context(), s.DBH, org)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := authorize(w, r, s, bloqs_auth.UPDATE_ORG, resource); err != nil {
+		return nil, err
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	var (
+		assignments = map[string]any{}
+
+		description *string
+		url         *string
+		email       *string
+	)
+
+	if _, ok := r.Form["name"]; ok {
+		name := r.FormValue("name")
+		if l := len(name); l > 80 || l <= 0 {
 			return nil, &mux.HttpError{
-				Body:   msg,
-				Status: http.StatusUnauthorized,
+				Body:   "`name` body field has to have a length between 1 and 80 characters",
+				Status: http.StatusUnprocessableEntity,
 			}
 		}
+		assignments["name"] = name
+	}
 
-		claims := &bloqs_auth.Claims{}
-		claims_str, err := base64.RawStdEncoding.DecodeString(strings.Split(string(tk), ".")[1])
-		if err != nil {
+	for k, v := range map[string]**string{"description": &description, "url": &url, "email": &email} {
+		if _, ok := r.Form[k]; ok {
+			if value := r.FormValue(k); value != "" {
+				*v = &value
+				assignments[k] = value
+			} else {
+				assignments[k] = nil
+			}
+		}
+	}
+
+	if err := validateOrgFields(description, url, email); err != nil {
+		return nil, err
+	}
+
+	if len(assignments) == 0 {
+		return nil, &mux.HttpError{
+			Body:   "no fields to update were received",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	if err := s.DBH.Update(r.Context(), "org", assignments, map[string]any{"id": org}); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return &rest.Resource{
+		Type:   ORG_TYPE,
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+func (Org) Delete(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	if second := s.Seg(1); second != nil {
+		switch *second {
+		case "members":
+			return removeOrgMember(w, r, s)
+		case "ratings":
+			return deleteOrgRating(w, r, s)
+		case "followers":
+			return unfollowOrg(w, r, s)
+		}
+
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := orgResource(r.Context(), s.DBH, org)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := authorize(w, r, s, bloqs_auth.DELETE_ORG, resource); err != nil {
+		return nil, err
+	}
+
+	where := map[string]any{"id": org}
+	if err = s.DBH.Delete(r.Context(), "org", where); err != nil {
+		return nil, err
+	}
+
+	where = map[string]any{"org_id": org}
+	for _, t := range []string{"org_members", "org_languages", orgRatingsTable, orgDistributionTable, orgAggregateTable, orgFollowsTable} {
+		if err = s.DBH.Delete(r.Context(), t, where); err != nil {
 			return nil, err
 		}
+	}
+
+	if err = s.DBH.Update(r.Context(), "bloq", map[string]any{"manufacturer": nil}, map[string]any{"manufacturer": org}); err != nil {
+		return nil, err
+	}
+	if err = s.DBH.Update(r.Context(), OfferTable, map[string]any{"seller": nil}, map[string]any{"seller": org}); err != nil {
+		return nil, err
+	}
+
+	return &rest.Resource{
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+func validateOrgFields(description, url, email *string) error {
+	if description != nil {
+		if l := len(*description); l > 140 {
+			return &mux.HttpError{
+				Body:   "`description` body field has to have a length between 0 and 140 characters",
+				Status: http.StatusUnprocessableEntity,
+			}
+		}
+	}
+
+	if url != nil {
+		if _, err := uri.ParseRequestURI(*url); err != nil || len(*url) > 255 {
+			return &mux.HttpError{
+				Body:   "`url` body field has to be a valid URL with a maximum of 255 characters",
+				Status: http.StatusUnprocessableEntity,
+			}
+		}
+	}
+
+	if email != nil {
+		if _, err := mail.ParseAddress(*email); err != nil || len(*email) > 320 {
+			return &mux.HttpError{
+				Body:   "`email` body field has to be a valid email address",
+				Status: http.StatusUnprocessableEntity,
+			}
+		}
+	}
+
+	return nil
+}
+
+func orgRoleRank(role OrgRole) int {
+	return bloqs_auth.OrgRoleRank(role)
+}
+
+func validOrgRole(role string) bool {
+	for _, i := range OrgRoles {
+		if i == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// orgMembership returns the role and status that profile has in the org. Both
+// are empty when the profile was never invited to it.
+func orgMembership(ctx context.Context, dbh db.DataManipulater, org, profile int64) (role OrgRole, status string, err error) {
+	res, err := dbh.Select(ctx, "org_members", func() map[string]any {
+		return map[string]any{
+			"role":   new(string),
+			"status": new(string),
+		}
+	}, []db.Condition{
+		{Column: "org_id", Value: org},
+		{Column: "profile_id", Value: profile},
+	})
+	if err != nil || len(res.Rows) == 0 {
+		return
+	}
+
+	return *res.Rows[0]["role"].(*string), *res.Rows[0]["status"].(*string), nil
+}
+
+// isOrgMember reports if profile accepted to be a member of org.
+func isOrgMember(ctx context.Context, dbh db.DataManipulater, org, profile int64) (bool, error) {
+	_, status, err := orgMembership(ctx, dbh, org, profile)
+	return status == orgAccepted, err
+}
+
+// yourOrgRole validates the token of the request and returns the highest role
+// one of its profiles has in org, and that profile.
+func yourOrgRole(w http.ResponseWriter, r *http.Request, s rest.RESTServer, p bloqs_auth.Permission, org int64) (*bloqs_auth.Claims, int64, OrgRole, error) {
+	a, err := authSrv(r.Context())
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	claims, err := helpers.ValidateAndGetClaims(w, r, a, p)
+	if err != nil {
+		return nil, 0, "", err
+	}
 
-		err = json.Unmarshal(claims_str, claims)
+	res, err := s.DBH.Select(r.Context(), "org", func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "id", Value: org}})
+	if err != nil {
+		return claims, 0, "", err
+	}
+	if len(res.Rows) == 0 {
+		return claims, 0, "", &mux.HttpError{
+			Body:   fmt.Sprintf("organization with id `%d` does not exist", org),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	res, err = s.DBH.Select(r.Context(), "credential_profiles", func() map[string]any {
+		return map[string]any{"profile_id": new(int64)}
+	}, []db.Condition{{Column: "credential_id", Value: claims.Payload.AccountID()}})
+	if err != nil {
+		return claims, 0, "", err
+	}
+
+	var (
+		profile int64
+		role    OrgRole
+	)
+	for _, i := range res.Rows {
+		id := *i["profile_id"].(*int64)
+
+		r, status, err := orgMembership(r.Context(), s.DBH, org, id)
 		if err != nil {
+			return claims, 0, "", err
+		}
+
+		if status == orgAccepted && orgRoleRank(r) > orgRoleRank(role) {
+			profile, role = id, r
+		}
+	}
+
+	return claims, profile, role, nil
+}
+
+// canRemoveOrgMember reports if a member with the role yours can remove one
+// with role. Admins remove the ones below them, and owners anyone.
+func canRemoveOrgMember(yours, role OrgRole) bool {
+	if yours == OrgOwner {
+		return true
+	}
+
+	return orgRoleRank(yours) >= orgRoleRank(OrgAdmin) && orgRoleRank(yours) > orgRoleRank(role)
+}
+
+func countOrgOwners(ctx context.Context, dbh db.DataManipulater, org int64) (int, error) {
+	res, err := dbh.Select(ctx, "org_members", func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "org_id", Value: org},
+		{Column: "role", Value: OrgOwner},
+		{Column: "status", Value: orgAccepted},
+	})
+
+	return len(res.Rows), err
+}
+
+func inviteOrgMember(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Seg(2) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	profile, err := strconv.ParseInt(r.FormValue("profile"), 10, 64)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   "`profile` body field has to be the id of the profile to invite",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	role := r.FormValue("role")
+	if role == "" {
+		role = OrgMember
+	}
+	if !validOrgRole(role) {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("`role` body field has to be one of `%s`", strings.Join(OrgRoles, "`, `")),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	_, _, yours, err := yourOrgRole(w, r, s, bloqs_auth.UPDATE_ORG, org)
+	if err != nil {
+		return nil, err
+	}
+
+	if orgRoleRank(yours) < orgRoleRank(OrgAdmin) {
+		return nil, &mux.HttpError{
+			Body:   "only the owners and admins of the organization can invite members",
+			Status: http.StatusForbidden,
+		}
+	}
+
+	if orgRoleRank(role) >= orgRoleRank(yours) && yours != OrgOwner {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("an `%s` cannot invite a profile as `%s`", yours, role),
+			Status: http.StatusForbidden,
+		}
+	}
+
+	res, err := s.DBH.Select(r.Context(), "profile", func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "id", Value: profile}})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) == 0 {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` does not exist", profile),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	if _, status, err := orgMembership(r.Context(), s.DBH, org, profile); err != nil {
+		return nil, err
+	} else if status != "" {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` was already invited to the organization", profile),
+			Status: http.StatusConflict,
+		}
+	}
+
+	result, err := s.DBH.Insert(r.Context(), "org_members", []map[string]any{
+		{
+			"org_id":     org,
+			"profile_id": profile,
+			"role":       role,
+			"status":     orgInvited,
+		},
+	})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return &rest.Created{
+		LastID:   result.LastID,
+		Message:  "",
+		Status:   http.StatusCreated,
+		Location: fmt.Sprintf("org/%d/members/%d", org, profile),
+	}, nil
+}
+
+func updateOrgMember(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := segToID(s.Seg(2))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	role, status, err := orgMembership(r.Context(), s.DBH, org, profile)
+	if err != nil {
+		return nil, err
+	}
+	if status == "" {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` is not a member of the organization", profile),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	where := map[string]any{"org_id": org, "profile_id": profile}
+
+	// without a new role it's the invited profile accepting the invitation
+	new_role := r.FormValue("role")
+	if new_role == "" {
+		if _, _, err := YourProfile(w, r, s, bloqs_auth.UPDATE_PROFILE, profile); err != nil {
 			return nil, err
 		}
-		*id = claims.Payload.Client
 
-		res, err := s.DBH.Select(r.Context(), "credential_accounts", func() map[string]any {
-			return map[string]any{
-				"account_id": new(int64),
+		if status == orgAccepted {
+			return nil, &mux.HttpError{
+				Body:   "the invitation was already accepted",
+				Status: http.StatusConflict,
 			}
-		}, []db.Condition{{Column: "credential_id", Value: *id}})
+		}
 
-		if err != nil {
+		if err := s.DBH.Update(r.Context(), "org_members", map[string]any{"status": orgAccepted}, where); err != nil {
 			return nil, err
 		}
 
-		var wait sync.WaitGroup
+		return &rest.Resource{Status: http.StatusNoContent}, nil
+	}
 
-		wait.Add(len(res.Rows))
+	if !validOrgRole(new_role) {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("`role` body field has to be one of `%s`", strings.Join(OrgRoles, "`, `")),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
 
-		accs := make([]db.JSON, 0, len(res.Rows))
+	_, _, yours, err := yourOrgRole(w, r, s, bloqs_auth.UPDATE_ORG, org)
+	if err != nil {
+		return nil, err
+	}
 
-		search := func(id any) {
-			defer wait.Done()
+	if yours != OrgOwner {
+		return nil, &mux.HttpError{
+			Body:   "only the owners of the organization can change the roles of its members",
+			Status: http.StatusForbidden,
+		}
+	}
 
-			var res db.Result
-			res, err = s.DBH.Select(r.Context(), "account", func() map[string]any {
-				return map[string]any{
-					"id":    new(int64),
-					"name":  new(string),
-					"image": new(string),
-				}
-			}, []db.Condition{{Column: "id", Value: id}})
+	if role == OrgOwner && new_role != OrgOwner && status == orgAccepted {
+		owners, err := countOrgOwners(r.Context(), s.DBH, org)
+		if err != nil {
+			return nil, err
+		}
 
-			if err != nil {
-				return
+		if owners <= 1 {
+			return nil, &mux.HttpError{
+				Body:   "the organization needs to have at least one owner",
+				Status: http.StatusConflict,
 			}
+		}
+	}
 
-			acc := res.Rows[0]
+	if err := s.DBH.Update(r.Context(), "org_members", map[string]any{"role": new_role}, where); err != nil {
+		return nil, err
+	}
 
-			res, err = s.DBH.Select(r.Context(), "account_likes", func() map[string]any {
-				return map[string]any{
-					"preference_id": new(int64),
-					"weight":        new(float32),
-				}
-			}, []db.Condition{{Column: "account_id", Value: acc["id"]}})
+	return &rest.Resource{Status: http.StatusNoContent}, nil
+}
 
-			if err != nil {
-				return
-			}
+func removeOrgMember(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
 
-			likes := make([]db.JSON, 0, len(res.Rows))
-			for _, i := range res.Rows {
-				i["url"] = fmt.Sprintf("%s/preference/%d", api, *i["preference_id"].(*int64))
-				i["@type"] = "Category"
-				delete(i, "preference_id")
-				likes = append(likes, i)
-			}
+	profile, err := segToID(s.Seg(2))
+	if err != nil {
+		return nil, err
+	}
 
-			acc["likes"] = likes
+	role, status, err := orgMembership(r.Context(), s.DBH, org, profile)
+	if err != nil {
+		return nil, err
+	}
+	if status == "" {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` is not a member of the organization", profile),
+			Status: http.StatusNotFound,
+		}
+	}
 
-			accs = append(accs, acc)
+	// members can always leave or decline an invitation, others need to outrank
+	// them in the organization
+	if _, _, err := YourProfile(w, r, s, bloqs_auth.NIL, profile); err != nil {
+		// it's not yours, anything else went wrong
+		if status := bloqs_auth.ErrorStatus(err, http.StatusInternalServerError); status != http.StatusForbidden && status != http.StatusNotFound {
+			return nil, err
 		}
 
-		for _, i := range res.Rows {
-			go search(i["account_id"])
+		_, _, yours, err := yourOrgRole(w, r, s, bloqs_auth.UPDATE_ORG, org)
+		if err != nil {
+			return nil, err
 		}
 
-		wait.Wait()
+		if !canRemoveOrgMember(yours, role) {
+			return nil, &mux.HttpError{
+				Body:   fmt.Sprintf("an `%s` cannot remove an `%s` from the organization", yours, role),
+				Status: http.StatusForbidden,
+			}
+		}
+	}
 
-		result = db.Result{Rows: accs}
-	} else {
-		var where []db.Condition = []db.Condition{}
-		if (id != nil) && (*id != "") {
-			where = append(where, db.Condition{Column: "id", Value: *id})
+	if role == OrgOwner && status == orgAccepted {
+		owners, err := countOrgOwners(r.Context(), s.DBH, org)
+		if err != nil {
+			return nil, err
 		}
 
-		result, err = s.DBH.Select(r.Context(), "account", func() map[string]any {
-			return map[string]any{
-				"id":    new(int64),
-				"name":  new(string),
-				"image": new(string),
+		if owners <= 1 {
+			return nil, &mux.HttpError{
+				Body:   "the organization needs to have at least one owner. Delete it instead",
+				Status: http.StatusConflict,
 			}
-		}, where)
+		}
+	}
+
+	if err := s.DBH.Delete(r.Context(), "org_members", map[string]any{"org_id": org, "profile_id": profile}); err != nil {
+		return nil, err
+	}
+
+	return &rest.Resource{Status: http.StatusNoContent}, nil
+}
+
+func readOrgMembers(r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	api := conf.MustGetConf("REST", "domain").(string)
 
-		for _, i := range result.Rows {
-			i["url"] = fmt.Sprintf("%s/account/%d", api, *i["id"].(*int64))
+	where := []db.Condition{{Column: "org_id", Value: org}}
+	unique := false
+	if profile := s.Seg(2); profile != nil && *profile != "" {
+		id, err := segToID(profile)
+		if err != nil {
+			return nil, err
 		}
+
+		where = append(where, db.Condition{Column: "profile_id", Value: id})
+		unique = true
+	} else if !bloqs_helpers.FormValueTrue(r.URL.Query().Get("invited")) {
+		where = append(where, db.Condition{Column: "status", Value: orgAccepted})
 	}
 
-	status := http.StatusOK
-	msg := ""
+	result, err := s.DBH.Select(r.Context(), "org_members", func() map[string]any {
+		return map[string]any{
+			"profile_id": new(int64),
+			"role":       new(string),
+			"status":     new(string),
+			"startDate":  new(string),
+		}
+	}, where)
 	if err != nil {
-		status = http.StatusInternalServerError
-		msg = err.Error()
+		return nil, err
+	}
+
+	for _, i := range result.Rows {
+		profile := *i["profile_id"].(*int64)
+
+		i["roleName"] = i["role"]
+		i["member"] = db.JSON{
+			"@type": "Person",
+			"url":   fmt.Sprintf("%s/profile/%d", api, profile),
+		}
+		i["url"] = fmt.Sprintf("%s/org/%d/members/%d", api, org, profile)
+		delete(i, "role")
+		delete(i, "profile_id")
 	}
 
 	return &rest.Resource{
-		Models:  result.Rows,
-		Type:    ORG_TYPE,
-		Status:  uint16(status),
-		Message: msg,
-	}, err
+		Models: result.Rows,
+		Type:   "OrganizationRole",
+		Status: http.StatusOK,
+		Unique: unique,
+	}, nil
 }
 
-func (Org) Update(http.ResponseWriter, *http.Request, rest.RESTServer) (*rest.Resource, error) {
-	return nil, nil
+// orgFormValue parses the optional `org` body field used to create things in
+// the name of an organization profile is a member of.
+func orgFormValue(r *http.Request, s rest.RESTServer, profile int64) (*int64, error) {
+	v := r.FormValue("org")
+	if v == "" {
+		return nil, nil
+	}
+
+	org, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   "`org` body field has to be the id of an organization",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	member, err := isOrgMember(r.Context(), s.DBH, org, profile)
+	if err != nil {
+		return nil, err
+	}
+	if !member {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` is not a member of the organization with id `%d`", profile, org),
+			Status: http.StatusForbidden,
+		}
+	}
+
+	return &org, nil
 }
 
-func (Org) Delete(http.ResponseWriter, *http.Request, rest.RESTServer) (*rest.Resource, error) {
-	return nil, nil
+func isOrgProduct(ctx context.Context, product int64, org int64, dbh db.DataManipulater) (bool, error) {
+	res, err := dbh.Select(ctx, "bloq", func() map[string]any {
+		return map[string]any{"manufacturer": new(int64)}
+	}, []db.Condition{
+		{Column: "id", Value: product},
+		{Column: "manufacturer", Value: org},
+	})
+
+	return len(res.Rows) == 1, err
 }
@@ -0,0 +1,452 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+)
+
+const (
+	orgRatingsTable      = "org_ratings"
+	orgAggregateTable    = "org_ratings_aggregate"
+	orgDistributionTable = "org_ratings_distribution"
+)
+
+/*
+/org/:id/ratings
+/org/:id/ratings/:profile
+*/
+
+func orgRatingRange() (worst int64, best int64) {
+	worst = int64(conf.MustGetConfOrDefault[float64](1, "REST", "orgs", "rating", "worst"))
+	best = int64(conf.MustGetConfOrDefault[float64](5, "REST", "orgs", "rating", "best"))
+	return
+}
+
+func parseOrgRating(v string) (int64, error) {
+	rating, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, &mux.HttpError{
+			Body:   "`ratingValue` body field has to be an integer",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	worst, best := orgRatingRange()
+	if rating < worst || rating > best {
+		return 0, &mux.HttpError{
+			Body:   fmt.Sprintf("`ratingValue` body field has to be between %d and %d", worst, best),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	return rating, nil
+}
+
+func parseOrgRatingExplanation(r *http.Request) (*string, error) {
+	v := r.FormValue("ratingExplanation")
+	if v == "" {
+		return nil, nil
+	}
+
+	if err := validateReviewBody(v); err != nil {
+		return nil, &mux.HttpError{
+			Body:   "`ratingExplanation` body field is too long",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	return &v, nil
+}
+
+func getOrgRating(ctx context.Context, dbh db.DataManipulater, org, profile int64) (db.JSON, error) {
+	res, err := dbh.Select(ctx, orgRatingsTable, func() map[string]any {
+		return map[string]any{
+			"profile_id":        new(int64),
+			"ratingValue":       new(int64),
+			"ratingExplanation": new(sql.NullString),
+		}
+	}, []db.Condition{
+		{Column: "org_id", Value: org},
+		{Column: "profile_id", Value: profile},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) == 0 {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` did not rate the organization with id `%d`", profile, org),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	return res.Rows[0], nil
+}
+
+func createOrgRating(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Seg(2) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	author, err := strconv.ParseInt(r.FormValue("author"), 10, 64)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   "`author` body field has to be the id of one of your profiles",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	rating, err := parseOrgRating(r.FormValue("ratingValue"))
+	if err != nil {
+		return nil, err
+	}
+
+	explanation, err := parseOrgRatingExplanation(r)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.DBH.Select(r.Context(), "org", func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "id", Value: org}})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) != 1 {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("organization with id `%d` does not exist", org),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.CREATE_ORG_RATING, author); err != nil {
+		return nil, err
+	}
+
+	if _, status, err := orgMembership(r.Context(), s.DBH, org, author); err != nil {
+		return nil, err
+	} else if status == orgAccepted {
+		return nil, &mux.HttpError{
+			Body:   "members cannot rate their own organization",
+			Status: http.StatusForbidden,
+		}
+	}
+
+	if _, err := getOrgRating(r.Context(), s.DBH, org, author); err == nil {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("profile with id `%d` already rated the organization with id `%d`", author, org),
+			Status: http.StatusConflict,
+		}
+	}
+
+	result, err := s.DBH.Insert(r.Context(), orgRatingsTable, []map[string]any{
+		{
+			"org_id":            org,
+			"profile_id":        author,
+			"ratingValue":       rating,
+			"ratingExplanation": explanation,
+		},
+	})
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if err := adjustOrgAggregateRating(r.Context(), s.DBH, org, rating, 1); err != nil {
+		return nil, err
+	}
+
+	return &rest.Created{
+		LastID:   result.LastID,
+		Message:  "",
+		Status:   http.StatusCreated,
+		Location: fmt.Sprintf("org/%d/ratings/%d", org, author),
+	}, nil
+}
+
+func updateOrgRating(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := segToID(s.Seg(2))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	rating, err := getOrgRating(r.Context(), s.DBH, org, author)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.UPDATE_ORG_RATING, author); err != nil {
+		return nil, err
+	}
+
+	old := *rating["ratingValue"].(*int64)
+	value := old
+	assignments := map[string]any{}
+
+	if _, ok := r.Form["ratingValue"]; ok {
+		if value, err = parseOrgRating(r.FormValue("ratingValue")); err != nil {
+			return nil, err
+		}
+		assignments["ratingValue"] = value
+	}
+
+	if _, ok := r.Form["ratingExplanation"]; ok {
+		explanation, err := parseOrgRatingExplanation(r)
+		if err != nil {
+			return nil, err
+		}
+		assignments["ratingExplanation"] = explanation
+	}
+
+	if len(assignments) == 0 {
+		return nil, &mux.HttpError{
+			Body:   "no fields to update were received",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	if err := s.DBH.Update(r.Context(), orgRatingsTable, assignments, map[string]any{
+		"org_id":     org,
+		"profile_id": author,
+	}); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if value != old {
+		if err := adjustOrgAggregateRating(r.Context(), s.DBH, org, old, -1); err != nil {
+			return nil, err
+		}
+		if err := adjustOrgAggregateRating(r.Context(), s.DBH, org, value, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return &rest.Resource{
+		Type:   "Rating",
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+func deleteOrgRating(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := segToID(s.Seg(2))
+	if err != nil {
+		return nil, err
+	}
+
+	rating, err := getOrgRating(r.Context(), s.DBH, org, author)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.DELETE_ORG_RATING, author); err != nil {
+		return nil, err
+	}
+
+	if err := s.DBH.Delete(r.Context(), orgRatingsTable, map[string]any{
+		"org_id":     org,
+		"profile_id": author,
+	}); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	if err := adjustOrgAggregateRating(r.Context(), s.DBH, org, *rating["ratingValue"].(*int64), -1); err != nil {
+		return nil, err
+	}
+
+	return &rest.Resource{
+		Type:   "Rating",
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+func readOrgRatings(r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	org, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	api := conf.MustGetConf("REST", "domain").(string)
+
+	where := []db.Condition{{Column: "org_id", Value: org}}
+	unique := false
+	if profile := s.Seg(2); profile != nil && *profile != "" {
+		id, err := segToID(profile)
+		if err != nil {
+			return nil, err
+		}
+
+		where = append(where, db.Condition{Column: "profile_id", Value: id})
+		unique = true
+	}
+
+	result, err := s.DBH.Select(r.Context(), orgRatingsTable, func() map[string]any {
+		return map[string]any{
+			"profile_id":        new(int64),
+			"ratingValue":       new(int64),
+			"ratingExplanation": new(sql.NullString),
+		}
+	}, where)
+	if err != nil {
+		return nil, err
+	}
+
+	worst, best := orgRatingRange()
+	for _, i := range result.Rows {
+		profile := *i["profile_id"].(*int64)
+
+		if v := i["ratingExplanation"].(*sql.NullString); v.Valid {
+			i["ratingExplanation"] = v.String
+		} else {
+			i["ratingExplanation"] = nil
+		}
+
+		i["bestRating"] = best
+		i["worstRating"] = worst
+		i["author"] = fmt.Sprintf("%s/profile/%d", api, profile)
+		i["url"] = fmt.Sprintf("%s/org/%d/ratings/%d", api, org, profile)
+		delete(i, "profile_id")
+	}
+
+	return &rest.Resource{
+		Models: result.Rows,
+		Type:   "Rating",
+		Status: http.StatusOK,
+		Unique: unique,
+	}, nil
+}
+
+// adjustOrgAggregateRating adds delta ratings of value to the running totals
+// of org so reads don't have to go through every rating.
+func adjustOrgAggregateRating(ctx context.Context, dbh db.DataManipulater, org, value, delta int64) error {
+	if err := dbh.Increment(ctx, orgAggregateTable, map[string]any{"org_id": org}, map[string]int64{
+		"ratingCount": delta,
+		"ratingSum":   delta * value,
+	}); err != nil {
+		return err
+	}
+
+	return dbh.Increment(ctx, orgDistributionTable, map[string]any{
+		"org_id":      org,
+		"ratingValue": value,
+	}, map[string]int64{"ratingCount": delta})
+}
+
+// deleteProfileOrgRatings removes the ratings of profile from the
+// organizations it rated, and from their totals.
+func deleteProfileOrgRatings(ctx context.Context, dbh db.DataManipulater, profile int64) error {
+	res, err := dbh.Select(ctx, orgRatingsTable, func() map[string]any {
+		return map[string]any{
+			"org_id":      new(int64),
+			"ratingValue": new(int64),
+		}
+	}, []db.Condition{{Column: "profile_id", Value: profile}})
+	if err != nil {
+		return err
+	}
+
+	for _, i := range res.Rows {
+		org := *i["org_id"].(*int64)
+		if err := dbh.Delete(ctx, orgRatingsTable, map[string]any{
+			"org_id":     org,
+			"profile_id": profile,
+		}); err != nil {
+			return err
+		}
+
+		if err := adjustOrgAggregateRating(ctx, dbh, org, *i["ratingValue"].(*int64), -1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func orgAggregateRating(ctx context.Context, dbh db.DataManipulater, org int64) (db.JSON, error) {
+	worst, best := orgRatingRange()
+	rating := db.JSON{
+		"@type":       "AggregateRating",
+		"ratingCount": int64(0),
+		"bestRating":  best,
+		"worstRating": worst,
+	}
+
+	res, err := dbh.Select(ctx, orgAggregateTable, func() map[string]any {
+		return map[string]any{
+			"ratingCount": new(int64),
+			"ratingSum":   new(int64),
+		}
+	}, []db.Condition{{Column: "org_id", Value: org}})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Rows) != 0 {
+		count := *res.Rows[0]["ratingCount"].(*int64)
+		rating["ratingCount"] = count
+
+		if count > 0 {
+			rating["ratingValue"] = float64(*res.Rows[0]["ratingSum"].(*int64)) / float64(count)
+		}
+	}
+
+	res, err = dbh.Select(ctx, orgDistributionTable, func() map[string]any {
+		return map[string]any{
+			"ratingValue": new(int64),
+			"ratingCount": new(int64),
+		}
+	}, []db.Condition{{Column: "org_id", Value: org}})
+	if err != nil {
+		return nil, err
+	}
+
+	distribution := make(map[string]int64, best-worst+1)
+	for i := worst; i <= best; i++ {
+		distribution[strconv.FormatInt(i, 10)] = 0
+	}
+	for _, i := range res.Rows {
+		distribution[strconv.FormatInt(*i["ratingValue"].(*int64), 10)] = *i["ratingCount"].(*int64)
+	}
+	rating["distribution"] = distribution
+
+	return rating, nil
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+)
+
+func TestParseOrgRating(t *testing.T) {
+	tests := []struct {
+		v     string
+		want  int64
+		valid bool
+	}{
+		{"1", 1, true},
+		{"3", 3, true},
+		{"5", 5, true},
+		{"0", 0, false},
+		{"6", 0, false},
+		{"-1", 0, false},
+		{"4.5", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOrgRating(tt.v)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("parseOrgRating(%q) = (%d, %v), want %d", tt.v, got, err, tt.want)
+		}
+	}
+}
+
+func TestOrgAggregateRating(t *testing.T) {
+	const org = 50
+
+	ctx := context.Background()
+	dbh := &memDB{tables: map[string][]db.JSON{}}
+
+	rate := func(profile, value int64) {
+		t.Helper()
+		dbh.tables[orgRatingsTable] = append(dbh.tables[orgRatingsTable], db.JSON{
+			"org_id":      int64(org),
+			"profile_id":  profile,
+			"ratingValue": value,
+		})
+		if err := adjustOrgAggregateRating(ctx, dbh, org, value, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	aggregate := func() db.JSON {
+		t.Helper()
+		rating, err := orgAggregateRating(ctx, dbh, org)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rating
+	}
+
+	// nothing rated it yet
+	rating := aggregate()
+	if rating["ratingCount"] != int64(0) || rating["ratingValue"] != nil {
+		t.Errorf("orgAggregateRating() = %v, want no ratings", rating)
+	}
+	if want := map[string]int64{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}; !reflect.DeepEqual(rating["distribution"], want) {
+		t.Errorf("orgAggregateRating() distribution = %v, want %v", rating["distribution"], want)
+	}
+
+	rate(1, 5)
+	rate(2, 3)
+	rate(3, 3)
+	rate(4, 1)
+
+	// the profile 4 changes its mind
+	if err := adjustOrgAggregateRating(ctx, dbh, org, 1, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := adjustOrgAggregateRating(ctx, dbh, org, 4, 1); err != nil {
+		t.Fatal(err)
+	}
+	dbh.tables[orgRatingsTable][3]["ratingValue"] = int64(4)
+
+	rating = aggregate()
+	if rating["ratingCount"] != int64(4) || rating["ratingValue"] != 3.75 {
+		t.Errorf("orgAggregateRating() = %v, want 4 ratings of 3.75", rating)
+	}
+	if want := map[string]int64{"1": 0, "2": 0, "3": 2, "4": 1, "5": 1}; !reflect.DeepEqual(rating["distribution"], want) {
+		t.Errorf("orgAggregateRating() distribution = %v, want %v", rating["distribution"], want)
+	}
+
+	// a deleted profile takes its rating with it
+	if err := deleteProfileOrgRatings(ctx, dbh, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	rating = aggregate()
+	if rating["ratingCount"] != int64(3) || rating["ratingValue"] != float64(10)/3 {
+		t.Errorf("orgAggregateRating() = %v, want 3 ratings of 3.33", rating)
+	}
+	if want := map[string]int64{"1": 0, "2": 0, "3": 2, "4": 1, "5": 0}; !reflect.DeepEqual(rating["distribution"], want) {
+		t.Errorf("orgAggregateRating() distribution = %v, want %v", rating["distribution"], want)
+	}
+	if n := len(dbh.tables[orgRatingsTable]); n != 3 {
+		t.Errorf("deleteProfileOrgRatings() left %d ratings, want 3", n)
+	}
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+)
+
+// orgMembers are the members of the organization 50: an owner, an admin, a
+// member and an invited owner. The admin owns the organization 51 too.
+func orgMembers() *memDB {
+	return &memDB{tables: map[string][]db.JSON{
+		"org_members": {
+			{"id": int64(1), "org_id": int64(50), "profile_id": int64(1), "role": OrgOwner, "status": orgAccepted},
+			{"id": int64(2), "org_id": int64(50), "profile_id": int64(2), "role": OrgAdmin, "status": orgAccepted},
+			{"id": int64(3), "org_id": int64(50), "profile_id": int64(3), "role": OrgMember, "status": orgAccepted},
+			{"id": int64(4), "org_id": int64(50), "profile_id": int64(4), "role": OrgOwner, "status": orgInvited},
+			{"id": int64(5), "org_id": int64(51), "profile_id": int64(2), "role": OrgOwner, "status": orgAccepted},
+		},
+		"bloq": {
+			{"id": int64(7), "manufacturer": int64(50)},
+			{"id": int64(8), "manufacturer": int64(51)},
+		},
+	}}
+}
+
+func TestOrgRoles(t *testing.T) {
+	tests := []struct {
+		role  string
+		valid bool
+	}{
+		{OrgOwner, true},
+		{OrgAdmin, true},
+		{OrgMember, true},
+		{"", false},
+		{"Owner", false},
+		{"moderator", false},
+	}
+
+	for _, tt := range tests {
+		if got := validOrgRole(tt.role); got != tt.valid {
+			t.Errorf("validOrgRole(%q) = %v, want %v", tt.role, got, tt.valid)
+		}
+	}
+
+	if !(orgRoleRank(OrgOwner) > orgRoleRank(OrgAdmin) && orgRoleRank(OrgAdmin) > orgRoleRank(OrgMember) && orgRoleRank(OrgMember) > orgRoleRank("")) {
+		t.Errorf("the roles aren't ranked owner > admin > member > none")
+	}
+}
+
+func TestCanRemoveOrgMember(t *testing.T) {
+	tests := []struct {
+		yours, role OrgRole
+		want        bool
+	}{
+		{OrgOwner, OrgOwner, true},
+		{OrgOwner, OrgAdmin, true},
+		{OrgOwner, OrgMember, true},
+		{OrgAdmin, OrgOwner, false},
+		{OrgAdmin, OrgAdmin, false},
+		{OrgAdmin, OrgMember, true},
+		{OrgMember, OrgMember, false},
+		{"", OrgMember, false},
+	}
+
+	for _, tt := range tests {
+		if got := canRemoveOrgMember(tt.yours, tt.role); got != tt.want {
+			t.Errorf("canRemoveOrgMember(%q, %q) = %v, want %v", tt.yours, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveOrgMember(t *testing.T) {
+	tests := []struct {
+		name    string
+		account string
+		profile string
+		status  int
+	}{
+		{"member leaves", "c", "3", http.StatusNoContent},
+		{"invited declines", "d", "4", http.StatusNoContent},
+		{"admin removes a member", "b", "3", http.StatusNoContent},
+		{"owner removes an admin", "a", "2", http.StatusNoContent},
+		{"member removes an admin", "c", "2", http.StatusForbidden},
+		{"admin removes an owner", "b", "1", http.StatusForbidden},
+		{"stranger removes a member", "z", "3", http.StatusForbidden},
+		{"last owner leaves", "a", "1", http.StatusConflict},
+		{"not a member", "a", "9", http.StatusNotFound},
+		{"no token", "", "3", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbh := orgMembers()
+			dbh.tables["org"] = []db.JSON{{"id": int64(50)}, {"id": int64(51)}}
+			for i, account := range []string{"a", "b", "c", "d"} {
+				dbh.tables["credential_profiles"] = append(dbh.tables["credential_profiles"], db.JSON{
+					"credential_id": account, "profile_id": int64(i + 1), "birthDate": "2000-01-01",
+				})
+			}
+			h := restServer(dbh, "/org", new(Org))
+
+			r := httptest.NewRequest(http.MethodDelete, "/org/50/members/"+tt.profile, nil)
+			if tt.account != "" {
+				r.Header.Set("Authorization", token(tt.account, bloqs_auth.UPDATE_ORG))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("DELETE /org/50/members/%s = %d %q, want %d", tt.profile, w.Code, w.Body, tt.status)
+			}
+
+			member, err := isOrgMember(context.Background(), dbh, 50, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed := tt.status == http.StatusNoContent && tt.profile == "3"; member == removed {
+				t.Errorf("isOrgMember() of the profile 3 = %v, want %v", member, !removed)
+			}
+		})
+	}
+}
+
+func TestOrgMembership(t *testing.T) {
+	ctx := context.Background()
+	dbh := orgMembers()
+
+	tests := []struct {
+		name    string
+		profile int64
+		role    OrgRole
+		status  string
+		member  bool
+	}{
+		{"owner", 1, OrgOwner, orgAccepted, true},
+		{"member", 3, OrgMember, orgAccepted, true},
+		{"invited", 4, OrgOwner, orgInvited, false},
+		{"stranger", 9, "", "", false},
+	}
+
+	for _, tt := range tests {
+		role, status, err := orgMembership(ctx, dbh, 50, tt.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if role != tt.role || status != tt.status {
+			t.Errorf("%s: orgMembership() = (%q, %q), want (%q, %q)", tt.name, role, status, tt.role, tt.status)
+		}
+
+		member, err := isOrgMember(ctx, dbh, 50, tt.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if member != tt.member {
+			t.Errorf("%s: isOrgMember() = %v, want %v", tt.name, member, tt.member)
+		}
+	}
+
+	// only the owners that accepted count, so the last one can't leave
+	if n, err := countOrgOwners(ctx, dbh, 50); err != nil || n != 1 {
+		t.Errorf("countOrgOwners() = (%d, %v), want 1", n, err)
+	}
+}
+
+func TestOrgFormValue(t *testing.T) {
+	s := rest.RESTServer{DBH: orgMembers()}
+
+	tests := []struct {
+		name    string
+		org     string
+		profile int64
+		want    int64
+		status  uint16
+	}{
+		{"none", "", 1, 0, 0},
+		{"member", "50", 3, 50, 0},
+		{"invited", "50", 4, 0, http.StatusForbidden},
+		{"stranger", "50", 9, 0, http.StatusForbidden},
+		{"not an id", "fifty", 1, 0, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/bloq", strings.NewReader(url.Values{"org": {tt.org}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			got, err := orgFormValue(r, s, tt.profile)
+			if tt.status != 0 {
+				var e *mux.HttpError
+				if !errors.As(err, &e) || e.Status != tt.status {
+					t.Errorf("orgFormValue() error = %v, want a %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("orgFormValue() error = %v", err)
+			}
+
+			if (got == nil) != (tt.want == 0) || (got != nil && *got != tt.want) {
+				t.Errorf("orgFormValue() = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOrgProduct(t *testing.T) {
+	ctx := context.Background()
+	dbh := orgMembers()
+
+	tests := []struct {
+		product, org int64
+		want         bool
+	}{
+		{7, 50, true},
+		{8, 50, false},
+		{9, 50, false},
+	}
+
+	for _, tt := range tests {
+		if got, err := isOrgProduct(ctx, tt.product, tt.org, dbh); err != nil || got != tt.want {
+			t.Errorf("isOrgProduct(%d, %d) = (%v, %v), want %v", tt.product, tt.org, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidateOrgFields(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name                    string
+		description, url, email *string
+		valid                   bool
+	}{
+		{"none", nil, nil, nil, true},
+		{"all", str("We sell bloqs"), str("https://example.com"), str("team@example.com"), true},
+		{"long description", str(strings.Repeat("a", 141)), nil, nil, false},
+		{"relative url", nil, str("example.com"), nil, false},
+		{"long url", nil, str("https://example.com/" + strings.Repeat("a", 255)), nil, false},
+		{"bad email", nil, nil, str("team"), false},
+	}
+
+	for _, tt := range tests {
+		err := validateOrgFields(tt.description, tt.url, tt.email)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: validateOrgFields() error = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
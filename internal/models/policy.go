@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+)
+
+// relations answers bloqs_auth.Authorize with the profiles and organizations
+// of the REST DB.
+type relations struct {
+	dbh db.DataManipulater
+}
+
+func (rel relations) Profiles(ctx context.Context, account string) ([]int64, error) {
+	res, err := rel.dbh.Select(ctx, "credential_profiles", func() map[string]any {
+		return map[string]any{"profile_id": new(int64)}
+	}, []db.Condition{{Column: "credential_id", Value: account}})
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]int64, 0, len(res.Rows))
+	for _, i := range res.Rows {
+		profiles = append(profiles, *i["profile_id"].(*int64))
+	}
+
+	return profiles, nil
+}
+
+func (rel relations) OrgRole(ctx context.Context, org, profile int64) (string, error) {
+	role, status, err := orgMembership(ctx, rel.dbh, org, profile)
+	if err != nil || status != orgAccepted {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// authorize validates the token of the request for the action and checks
+// with bloqs_auth.Authorize that it can be done to the resource.
+func authorize(w http.ResponseWriter, r *http.Request, s rest.RESTServer, action bloqs_auth.Permission, resource bloqs_auth.Resource) (*bloqs_auth.Claims, error) {
+	a, err := authSrv(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := helpers.ValidateAndGetClaims(w, r, a, action)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := bloqs_auth.WithRelations(r.Context(), relations{s.DBH})
+	if err := bloqs_auth.Authorize(ctx, claims, action, resource); err != nil {
+		return claims, err
+	}
+
+	return claims, nil
+}
+
+// orgResource is the organization as a resource, if it exists.
+func orgResource(ctx context.Context, dbh db.DataManipulater, org int64) (bloqs_auth.Resource, error) {
+	res, err := dbh.Select(ctx, "org", func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "id", Value: org}})
+	if err != nil {
+		return bloqs_auth.Resource{}, err
+	}
+	if len(res.Rows) == 0 {
+		return bloqs_auth.Resource{}, &mux.HttpError{
+			Body:   fmt.Sprintf("organization with id `%d` does not exist", org),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	return bloqs_auth.Resource{Kind: "organization", ID: org, Org: org}, nil
+}
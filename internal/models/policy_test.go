@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+)
+
+func TestRelations(t *testing.T) {
+	const org = 50
+
+	rel := relations{&memDB{tables: map[string][]db.JSON{
+		"credential_profiles": {
+			{"credential_id": "a@example.com", "profile_id": int64(1)},
+			{"credential_id": "a@example.com", "profile_id": int64(2)},
+			{"credential_id": "b@example.com", "profile_id": int64(3)},
+		},
+		"org_members": {
+			{"org_id": int64(org), "profile_id": int64(1), "role": string(OrgOwner), "status": orgAccepted},
+			{"org_id": int64(org), "profile_id": int64(3), "role": string(OrgAdmin), "status": orgInvited},
+		},
+	}}}
+	ctx := context.Background()
+
+	profiles, err := rel.Profiles(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(profiles, []int64{1, 2}) {
+		t.Errorf("Profiles() = %v, want [1 2]", profiles)
+	}
+
+	tests := []struct {
+		name    string
+		profile int64
+		want    string
+	}{
+		{"accepted", 1, bloqs_auth.ORG_OWNER},
+		{"not a member", 2, ""},
+		// it's not a member until it accepts
+		{"invited", 3, ""},
+	}
+
+	for _, tt := range tests {
+		got, err := rel.OrgRole(ctx, org, tt.profile)
+		if err != nil {
+			t.Fatalf("%s: OrgRole() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: OrgRole() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// an invited admin can't act as one
+	ctx = bloqs_auth.WithRelations(ctx, rel)
+	claims := &bloqs_auth.Claims{Payload: bloqs_auth.Payload{Account: "b@example.com", Permissions: bloqs_auth.UPDATE_BLOQ}}
+	if err := bloqs_auth.Authorize(ctx, claims, bloqs_auth.UPDATE_BLOQ, bloqs_auth.Resource{Kind: "bloq", ID: 7, Owner: 1, Org: org}); err == nil {
+		t.Errorf("Authorize() of an invited admin didn't fail")
+	}
+}
@@ -7,9 +7,9 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/bloqs-sites/bloqsenjin/internal/auth"
-	internal_db "github.com/bloqs-sites/bloqsenjin/internal/db"
 	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
 	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
@@ -17,8 +17,6 @@ import (
 	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
 	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
 	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
-	"github.com/bloqs-sites/bloqsenjin/proto"
-	"github.com/redis/go-redis/v9"
 )
 
 type Preference struct {
@@ -270,31 +268,27 @@ func (p Preference) Delete(http.ResponseWriter, *http.Request, rest.RESTServer)
 	return nil, nil
 }
 
-func authSrv(ctx context.Context) (proto.AuthServer, error) {
-	// TODO: How can I make it that you can specify which implementation of the interfaces you want to use?
-	creds, err := internal_db.NewMySQL(ctx, strings.TrimSpace(os.Getenv("BLOQS_AUTH_MYSQL_DSN")))
-	if err != nil {
-		return nil, fmt.Errorf("error creating DB instance of type `%T`:\t%s", creds, err)
-	}
-
-	opt, err := redis.ParseURL(strings.TrimSpace(os.Getenv("BLOQS_TOKENS_REDIS_DSN")))
-	if err != nil {
-		return nil, fmt.Errorf("could not parse the `BLOQS_TOKENS_REDIS_DSN` to create the credentials to connect to the DB:\t%s", err)
-	}
-
-	a, err := auth.NewBloqsAuther(ctx, creds)
-	if err != nil {
-		return nil, err
-	}
+var (
+	validator      bloqs_auth.Validator
+	validator_once sync.Once
+	validator_err  error
+)
 
-	secrets, err := internal_db.NewKeyDB(ctx, opt)
-	if err != nil {
-		return nil, fmt.Errorf("error creating DB instance of type `%T`:\t%s", secrets, err)
-	}
+// authSrv returns the client used to validate tokens with the auth service.
+// Only the auth service holds the credentials, the REST one just asks it.
+func authSrv(ctx context.Context) (bloqs_auth.Validator, error) {
+	validator_once.Do(func() {
+		// verifying offline doesn't see revoked tokens, so it's opt-in
+		if jwks := strings.TrimSpace(os.Getenv("BLOQS_AUTH_JWKS_URL")); jwks != "" {
+			ttl := time.Duration(conf.MustGetConfOrDefault[float64](300000, "auth", "jwks", "ttl")) * time.Millisecond
+			validator = bloqs_auth.NewJWKSVerifier(jwks, ttl)
+			return
+		}
 
-	t := auth.NewBloqsTokener(secrets)
+		validator, validator_err = bloqs_auth.NewAuthClient(strings.TrimSpace(os.Getenv("BLOQS_AUTH_GRPC_ADDR")))
+	})
 
-	return bloqs_auth.NewAuthServer(a, t), nil
+	return validator, validator_err
 }
 
 func PreferenceExists(ctx context.Context, id int64, s rest.RESTServer) (bool, error) {
@@ -89,6 +89,7 @@ func (Profile) CreateTable() []db.Table {
 				"`id` INT UNSIGNED AUTO_INCREMENT",
 				"`profile_id` INT UNSIGNED NOT NULL",
 				"`follower_id` INT UNSIGNED NOT NULL",
+				"`since` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
 				"UNIQUE (`profile_id`, `follower_id`)",
 				"PRIMARY KEY(`id`)",
 			},
@@ -104,9 +105,10 @@ func (Profile) CreateViews() []db.View {
 	var sql strings.Builder
 
 	sql.WriteString("SELECT")
-	sql.WriteString(" `profile`.*, COUNT(`profile_follows`.`profile_id`) AS `followers`, COUNT(`profile_follows`.`follower_id`) AS `following`")
-	sql.WriteString(" FROM `profile` INNER JOIN `profile_follows` ON `profile`.`id` = `profile_follows`.`profile_id`")
-	sql.WriteString(" GROUP BY `profile`.`id`")
+	sql.WriteString(" `profile`.*,")
+	sql.WriteString(" (SELECT COUNT(*) FROM `profile_follows` WHERE `profile_follows`.`profile_id` = `profile`.`id`) AS `followers`,")
+	sql.WriteString(" (SELECT COUNT(*) FROM `profile_follows` WHERE `profile_follows`.`follower_id` = `profile`.`id`) AS `following`")
+	sql.WriteString(" FROM `profile`")
 
 	return []db.View{
 		{
@@ -116,7 +118,26 @@ func (Profile) CreateViews() []db.View {
 	}
 }
 
+func (Profile) Migrations() []db.Migration {
+	return []db.Migration{
+		{
+			ID: "profile_follows_since",
+			Statements: []string{
+				"ALTER TABLE `profile_follows` ADD COLUMN `since` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;",
+			},
+		},
+	}
+}
+
 func (Profile) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
+	if second := s.Seg(1); second != nil {
+		if *second == "followers" {
+			return followProfile(w, r, s)
+		}
+
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
 	var (
 		status uint16 = http.StatusInternalServerError
 
@@ -243,7 +264,7 @@ func (Profile) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer)
 	var result db.Result
 	result, err = s.DBH.Select(r.Context(), "credential_profiles", func() map[string]any {
 		return nil
-	}, []db.Condition{{Column: "credential_id", Value: claims.Payload.Client}})
+	}, []db.Condition{{Column: "credential_id", Value: claims.Payload.AccountID()}})
 	if err != nil {
 		status = http.StatusInternalServerError
 		return nil, &mux.HttpError{
@@ -290,7 +311,7 @@ func (Profile) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer)
 
 	_, err = s.DBH.Insert(r.Context(), "credential_profiles", []map[string]any{
 		{
-			"credential_id": claims.Payload.Client,
+			"credential_id": claims.Payload.AccountID(),
 			"profile_id":    id,
 		},
 	})
@@ -321,7 +342,7 @@ func (Profile) Create(w http.ResponseWriter, r *http.Request, s rest.RESTServer)
 		if err != nil {
 			s.DBH.Delete(r.Context(), "profile", map[string]any{"id": id})
 			s.DBH.Delete(r.Context(), "credential_profiles", map[string]any{
-				"credential_id": claims.Payload.Client,
+				"credential_id": claims.Payload.AccountID(),
 				"account_id":    id,
 			})
 
@@ -427,7 +448,7 @@ func (Profile) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 				return nil, err
 			}
 
-			client := claims.Payload.Client
+			client := claims.Payload.AccountID()
 
 			res, err := s.DBH.Select(r.Context(), "credential_profiles", func() map[string]any {
 				return map[string]any{
@@ -446,8 +467,7 @@ func (Profile) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 				defer wait.Done()
 
 				var res db.Result
-				//res, err = s.DBH.Select(r.Context(), "profile_view", func() map[string]any {
-				res, err = s.DBH.Select(r.Context(), "profile", func() map[string]any {
+				res, err = s.DBH.Select(r.Context(), "profile_view", func() map[string]any {
 					return map[string]any{
 						"id":                    new(int64),
 						"name":                  new(string),
@@ -456,8 +476,8 @@ func (Profile) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 						"url":                   new(sql.NullString),
 						"hasAdultConsideration": new(bool),
 						"level":                 new(uint8),
-						//					"followers":             new(uint64),
-						//					"following":             new(uint64),
+						"followers":             new(uint64),
+						"following":             new(uint64),
 					}
 				}, []db.Condition{{Column: "id", Value: id}})
 				if err != nil {
@@ -485,6 +505,8 @@ func (Profile) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 				"image":       new(sql.NullString),
 				"url":         new(sql.NullString),
 				"level":       new(uint8),
+				"followers":   new(uint64),
+				"following":   new(uint64),
 			}
 
 			var birthDate *string = nil
@@ -506,20 +528,17 @@ func (Profile) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 						"birthDate":  new(string),
 					}
 				}, []db.Condition{
-					{Column: "credential_id", Value: claims.Payload.Client},
+					{Column: "credential_id", Value: claims.Payload.AccountID()},
 					{Column: "profile_id", Value: id},
 				})
 
 				if err == nil && len(res.Rows) > 0 && res.Rows[0] != nil {
 					birthDate = res.Rows[0]["birthDate"].(*string)
 					cols["hasAdultConsideration"] = new(bool)
-					//				cols["followers"] = new(uint64)
-					//				cols["following"] = new(uint64)
 				}
 			}
 
-			//result, err = s.DBH.Select(r.Context(), "profile_view", func() map[string]any {
-			result, err = s.DBH.Select(r.Context(), "profile", func() map[string]any {
+			result, err = s.DBH.Select(r.Context(), "profile_view", func() map[string]any {
 				return cols
 			}, where)
 
@@ -576,6 +595,12 @@ func (Profile) Read(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (
 		}
 
 		return personMakesOffer(r.Context(), acc, s.DBH)
+	} else if *second == "followers" {
+		return readFollows(w, r, s, false)
+	} else if *second == "following" {
+		return readFollows(w, r, s, true)
+	} else if *second == "feed" {
+		return readFeed(w, r, s)
 	}
 
 	return nil, nil
@@ -586,6 +611,14 @@ func (Profile) Update(http.ResponseWriter, *http.Request, rest.RESTServer) (*res
 }
 
 func (Profile) Delete(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	if second := s.Seg(1); second != nil {
+		if *second == "followers" {
+			return unfollowProfile(w, r, s)
+		}
+
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
 	idstr := s.Seg(0)
 
 	if idstr == nil {
@@ -625,12 +658,24 @@ func (Profile) Delete(w http.ResponseWriter, r *http.Request, s rest.RESTServer)
 	if err != nil {
 		return nil, err
 	}
+	err = s.DBH.Delete(r.Context(), "org_members", where)
+	if err != nil {
+		return nil, err
+	}
+	err = deleteProfileOrgRatings(r.Context(), s.DBH, id)
+	if err != nil {
+		return nil, err
+	}
 	where = map[string]any{"follower_id": id}
 	err = s.DBH.Delete(r.Context(), "profile_follows", where)
 	if err != nil {
 		return nil, err
 	}
-	where = map[string]any{"customer": claims.Payload.Client}
+	err = s.DBH.Delete(r.Context(), orgFollowsTable, where)
+	if err != nil {
+		return nil, err
+	}
+	where = map[string]any{"customer": claims.Payload.AccountID()}
 	err = s.DBH.Delete(r.Context(), OrderTable, where)
 	if err != nil {
 		return nil, err
@@ -755,13 +800,7 @@ func personalAccount(ctx context.Context, acc db.JSON, birthDate string, s rest.
 }
 
 func YourProfile(w http.ResponseWriter, r *http.Request, s rest.RESTServer, p bloqs_auth.Permission, you int64) (*bloqs_auth.Claims, db.JSON, error) {
-	a, err := authSrv(r.Context())
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	claims, err := helpers.ValidateAndGetClaims(w, r, a, p)
+	claims, err := authorize(w, r, s, p, bloqs_auth.Resource{Kind: "profile", ID: you, Owner: you})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -771,15 +810,15 @@ func YourProfile(w http.ResponseWriter, r *http.Request, s rest.RESTServer, p bl
 			"profile_id": new(int64),
 			"birthDate":  new(string),
 		}
-	}, []db.Condition{
-		{Column: "credential_id", Value: claims.Payload.Client},
-		{Column: "profile_id", Value: you},
-	})
+	}, []db.Condition{{Column: "profile_id", Value: you}})
+	if err != nil {
+		return claims, nil, err
+	}
 
-	if err != nil || res.Rows[0] == nil {
+	if len(res.Rows) == 0 || res.Rows[0] == nil {
 		return nil, nil, &mux.HttpError{
-			Body:   "Can't create a bloq for a profile that isn't yours.",
-			Status: http.StatusUnauthorized,
+			Body:   fmt.Sprintf("profile with id `%d` does not exist", you),
+			Status: http.StatusNotFound,
 		}
 	}
 
@@ -0,0 +1,619 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+)
+
+const (
+	BLOQ_REVIEW_TYPE = "Review"
+
+	reviewTable = "bloq_review"
+)
+
+/*
+/bloq/:id/reviews
+/bloq/:id/reviews/:review
+/bloq/:id/reviews/:review/associated
+*/
+
+func reviewRatingRange() (worst int64, best int64) {
+	worst = int64(conf.MustGetConfOrDefault[float64](1, "REST", "reviews", "rating", "worst"))
+	best = int64(conf.MustGetConfOrDefault[float64](5, "REST", "reviews", "rating", "best"))
+	return
+}
+
+func parseReviewRating(v string) (int64, error) {
+	rating, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, &mux.HttpError{
+			Body:   "`reviewRating` body field has to be an integer",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	worst, best := reviewRatingRange()
+	if rating < worst || rating > best {
+		return 0, &mux.HttpError{
+			Body:   fmt.Sprintf("`reviewRating` body field has to be between %d and %d", worst, best),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	return rating, nil
+}
+
+func validateReviewBody(body string) error {
+	if l := len(body); l > 0xFFFF {
+		return &mux.HttpError{
+			Body:   "`reviewBody` body field has to have a length with a maximum of 65535 characters",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	return nil
+}
+
+func validateInLanguage(lang string) error {
+	if l := len(lang); l > 35 || l <= 0 {
+		return &mux.HttpError{
+			Body:   "`inLanguage` body field has to be a language tag with a length between 1 and 35 characters",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	return nil
+}
+
+func segToID(seg *string) (int64, error) {
+	if seg == nil || *seg == "" {
+		return 0, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	id, err := strconv.ParseInt(*seg, 10, 64)
+	if err != nil {
+		return 0, &mux.HttpError{
+			Body:   fmt.Sprintf("`%s` it's not a valid id", *seg),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	return id, nil
+}
+
+func createBloqReview(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Created, error) {
+	bloq, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Seg(2) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	author, err := strconv.ParseInt(r.FormValue("author"), 10, 64)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   "`author` body field has to be the id of one of your profiles",
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	body := r.FormValue("reviewBody")
+	lang := r.FormValue("inLanguage")
+	if lang == "" {
+		lang = conf.MustGetConfOrDefault("en", "REST", "reviews", "inLanguage")
+	}
+
+	if err := validateReviewBody(body); err != nil {
+		return nil, err
+	}
+
+	if err := validateInLanguage(lang); err != nil {
+		return nil, err
+	}
+
+	var associated *int64
+	if v := r.FormValue("associatedReview"); v != "" {
+		parent, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, &mux.HttpError{
+				Body:   "`associatedReview` body field has to be the id of a review",
+				Status: http.StatusUnprocessableEntity,
+			}
+		}
+		associated = &parent
+	}
+
+	var rating int64
+	if associated == nil {
+		if rating, err = parseReviewRating(r.FormValue("reviewRating")); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := s.DBH.Select(r.Context(), "bloq", func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "id", Value: bloq}})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) != 1 {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("bloq with id `%d` does not exist", bloq),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	if _, _, err := YourProfile(w, r, s, bloqs_auth.CREATE_REVIEW, author); err != nil {
+		return nil, err
+	}
+
+	purchased, err := hasOrderedBloq(r.Context(), s.DBH, author, bloq)
+	if err != nil {
+		return nil, err
+	}
+
+	insert := map[string]any{
+		"itemReviewed": bloq,
+		"author":       author,
+		"reviewRating": rating,
+		"inLanguage":   lang,
+	}
+	if body != "" {
+		insert["reviewBody"] = body
+	}
+
+	if associated == nil {
+		if !purchased {
+			return nil, &mux.HttpError{
+				Body:   "only profiles that ordered an offer with this bloq can review it",
+				Status: http.StatusForbidden,
+			}
+		}
+
+		res, err := s.DBH.Select(r.Context(), reviewTable, func() map[string]any {
+			return map[string]any{
+				"id":               new(int64),
+				"associatedReview": new(sql.NullInt64),
+			}
+		}, []db.Condition{
+			{Column: "itemReviewed", Value: bloq},
+			{Column: "author", Value: author},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range res.Rows {
+			if !i["associatedReview"].(*sql.NullInt64).Valid {
+				return nil, &mux.HttpError{
+					Body:   fmt.Sprintf("the profile `%d` already reviewed this bloq with the review `%d`", author, *i["id"].(*int64)),
+					Status: http.StatusConflict,
+				}
+			}
+		}
+	} else {
+		res, err := s.DBH.Select(r.Context(), reviewTable, func() map[string]any {
+			return map[string]any{"id": new(int64)}
+		}, []db.Condition{
+			{Column: "id", Value: *associated},
+			{Column: "itemReviewed", Value: bloq},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Rows) != 1 {
+			return nil, &mux.HttpError{
+				Body:   fmt.Sprintf("`associatedReview` with id `%d` does not exist for this bloq", *associated),
+				Status: http.StatusUnprocessableEntity,
+			}
+		}
+
+		if !purchased {
+			creator, err := isProductCreator(r.Context(), bloq, author, s.DBH)
+			if err != nil {
+				return nil, err
+			}
+
+			if !creator {
+				return nil, &mux.HttpError{
+					Body:   "only the creator of the bloq and profiles that ordered an offer with it can reply to its reviews",
+					Status: http.StatusForbidden,
+				}
+			}
+		}
+
+		insert["associatedReview"] = *associated
+	}
+
+	result, err := s.DBH.Insert(r.Context(), reviewTable, []map[string]any{insert})
+	if errors.Is(err, db.ErrDuplicate) {
+		// reviewed at the same time, after the check above
+		msg := fmt.Sprintf("the profile `%d` already reviewed this bloq", author)
+		if associated != nil {
+			msg = fmt.Sprintf("the profile `%d` already replied to the review `%d`", author, *associated)
+		}
+
+		return nil, &mux.HttpError{
+			Body:   msg,
+			Status: http.StatusConflict,
+		}
+	}
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	location := fmt.Sprintf("bloq/%d/reviews/%d", bloq, *result.LastID)
+	publishActivity(r.Context(), s, FeedReview, *result.LastID, location, author, nil)
+
+	return &rest.Created{
+		LastID:   result.LastID,
+		Message:  "",
+		Status:   http.StatusCreated,
+		Location: location,
+	}, nil
+}
+
+func getBloqReview(ctx context.Context, dbh db.DataManipulater, bloq, review int64) (db.JSON, error) {
+	res, err := dbh.Select(ctx, reviewTable, func() map[string]any {
+		return map[string]any{
+			"id":               new(int64),
+			"author":           new(int64),
+			"associatedReview": new(sql.NullInt64),
+		}
+	}, []db.Condition{
+		{Column: "id", Value: review},
+		{Column: "itemReviewed", Value: bloq},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Rows) != 1 {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("review with id `%d` does not exist for this bloq", review),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	return res.Rows[0], nil
+}
+
+func updateBloqReview(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	bloq, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := segToID(s.Seg(2))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Seg(3) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	review, err := getBloqReview(r.Context(), s.DBH, bloq, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.UPDATE_REVIEW, *review["author"].(*int64)); err != nil {
+		return nil, err
+	}
+
+	if err := bloqs_helpers.ParseFormBody(w, r); err != nil {
+		return nil, err
+	}
+
+	assignments := map[string]any{"dateModified": time.Now()}
+
+	if _, ok := r.Form["reviewBody"]; ok {
+		body := r.FormValue("reviewBody")
+		if err := validateReviewBody(body); err != nil {
+			return nil, err
+		}
+
+		if body != "" {
+			assignments["reviewBody"] = body
+		} else {
+			assignments["reviewBody"] = nil
+		}
+	}
+
+	if _, ok := r.Form["inLanguage"]; ok {
+		lang := r.FormValue("inLanguage")
+		if err := validateInLanguage(lang); err != nil {
+			return nil, err
+		}
+
+		assignments["inLanguage"] = lang
+	}
+
+	if _, ok := r.Form["reviewRating"]; ok {
+		if review["associatedReview"].(*sql.NullInt64).Valid {
+			return nil, &mux.HttpError{
+				Body:   "replies to other reviews do not have a `reviewRating`",
+				Status: http.StatusUnprocessableEntity,
+			}
+		}
+
+		rating, err := parseReviewRating(r.FormValue("reviewRating"))
+		if err != nil {
+			return nil, err
+		}
+		assignments["reviewRating"] = rating
+	}
+
+	if err := s.DBH.Update(r.Context(), reviewTable, assignments, map[string]any{"id": id}); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return &rest.Resource{
+		Type:   BLOQ_REVIEW_TYPE,
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+func deleteBloqReview(w http.ResponseWriter, r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	bloq, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := segToID(s.Seg(2))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Seg(3) != nil {
+		return nil, &mux.HttpError{Status: http.StatusNotFound}
+	}
+
+	review, err := getBloqReview(r.Context(), s.DBH, bloq, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err = YourProfile(w, r, s, bloqs_auth.DELETE_REVIEW, *review["author"].(*int64)); err != nil {
+		return nil, err
+	}
+
+	if err := deleteReviewThread(r.Context(), s.DBH, id); err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	return &rest.Resource{
+		Type:   BLOQ_REVIEW_TYPE,
+		Status: http.StatusNoContent,
+	}, nil
+}
+
+// deleteReviewThread deletes a review and all the replies associated with it.
+func deleteReviewThread(ctx context.Context, dbh db.DataManipulater, id int64) error {
+	res, err := dbh.Select(ctx, reviewTable, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{{Column: "associatedReview", Value: id}})
+	if err != nil {
+		return err
+	}
+
+	for _, i := range res.Rows {
+		if err := deleteReviewThread(ctx, dbh, *i["id"].(*int64)); err != nil {
+			return err
+		}
+	}
+
+	return dbh.Delete(ctx, reviewTable, map[string]any{"id": id})
+}
+
+func readBloqReviews(r *http.Request, s rest.RESTServer) (*rest.Resource, error) {
+	bloq, err := segToID(s.Seg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	api := conf.MustGetConf("REST", "domain").(string)
+
+	where := []db.Condition{{Column: "itemReviewed", Value: bloq}}
+	unique := false
+	if second_id := s.Seg(2); second_id != nil && *second_id != "" {
+		review, err := segToID(second_id)
+		if err != nil {
+			return nil, err
+		}
+
+		if third := s.Seg(3); third != nil {
+			if *third != "associated" || s.Seg(4) != nil {
+				return nil, &mux.HttpError{
+					Status: http.StatusNotFound,
+				}
+			}
+			where = append(where, db.Condition{Column: "associatedReview", Value: review})
+		} else {
+			where = append(where, db.Condition{Column: "id", Value: review})
+			unique = true
+		}
+	}
+
+	result, err := s.DBH.Select(r.Context(), reviewTable, func() map[string]any {
+		return map[string]any{
+			"id":               new(int64),
+			"author":           new(int64),
+			"associatedReview": new(sql.NullInt64),
+			"reviewBody":       new(sql.NullString),
+			"reviewRating":     new(int64),
+			"dateCreated":      new(string),
+			"dateModified":     new(string),
+			"inLanguage":       new(string),
+		}
+	}, where)
+	if err != nil {
+		return nil, err
+	}
+
+	worst, best := reviewRatingRange()
+	for _, i := range result.Rows {
+		id := *i["id"].(*int64)
+
+		i["url"] = fmt.Sprintf("%s/bloq/%d/reviews/%d", api, bloq, id)
+		i["associated"] = fmt.Sprintf("%s/bloq/%d/reviews/%d/associated", api, bloq, id)
+		i["itemReviewed"] = fmt.Sprintf("%s/bloq/%d", api, bloq)
+		i["author"] = fmt.Sprintf("%s/profile/%d", api, *i["author"].(*int64))
+
+		if v := i["reviewBody"].(*sql.NullString); v.Valid {
+			i["reviewBody"] = v.String
+		} else {
+			i["reviewBody"] = nil
+		}
+
+		if v := i["associatedReview"].(*sql.NullInt64); v.Valid {
+			i["associatedReview"] = fmt.Sprintf("%s/bloq/%d/reviews/%d", api, bloq, v.Int64)
+			delete(i, "reviewRating")
+		} else {
+			i["associatedReview"] = nil
+			i["reviewRating"] = db.JSON{
+				"@type":       "Rating",
+				"ratingValue": *i["reviewRating"].(*int64),
+				"bestRating":  best,
+				"worstRating": worst,
+			}
+		}
+	}
+
+	return &rest.Resource{
+		Models: result.Rows,
+		Type:   BLOQ_REVIEW_TYPE,
+		Status: http.StatusOK,
+		Unique: unique,
+	}, nil
+}
+
+// bloqAggregateRatings computes the schema.org `AggregateRating` of each of
+// the bloqs from their reviews, all of them in one query. Replies to other
+// reviews do not count to the rating.
+func bloqAggregateRatings(ctx context.Context, dbh db.DataManipulater, bloqs []int64) (map[int64]db.JSON, error) {
+	ids := make([]any, 0, len(bloqs))
+	for _, i := range bloqs {
+		ids = append(ids, i)
+	}
+
+	res, err := dbh.Select(ctx, reviewTable, func() map[string]any {
+		return map[string]any{
+			"itemReviewed":     new(int64),
+			"reviewRating":     new(int64),
+			"associatedReview": new(sql.NullInt64),
+		}
+	}, []db.Condition{{Column: "itemReviewed", Op: db.IN, Value: ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int64, len(bloqs))
+	sums := make(map[int64]int64, len(bloqs))
+	for _, i := range res.Rows {
+		if i["associatedReview"].(*sql.NullInt64).Valid {
+			continue
+		}
+
+		bloq := *i["itemReviewed"].(*int64)
+		counts[bloq]++
+		sums[bloq] += *i["reviewRating"].(*int64)
+	}
+
+	worst, best := reviewRatingRange()
+	ratings := make(map[int64]db.JSON, len(bloqs))
+	for _, i := range bloqs {
+		rating := db.JSON{
+			"@type":       "AggregateRating",
+			"reviewCount": counts[i],
+			"bestRating":  best,
+			"worstRating": worst,
+		}
+
+		if counts[i] > 0 {
+			rating["ratingValue"] = float64(sums[i]) / float64(counts[i])
+		}
+
+		ratings[i] = rating
+	}
+
+	return ratings, nil
+}
+
+// bloqIDs are the ids of the selected bloqs.
+func bloqIDs(rows []db.JSON) []int64 {
+	ids := make([]int64, 0, len(rows))
+	for _, i := range rows {
+		ids = append(ids, *i["id"].(*int64))
+	}
+
+	return ids
+}
+
+// hasOrderedBloq is if the accounts of the author profile ordered an offer
+// with the bloq.
+func hasOrderedBloq(ctx context.Context, dbh db.DataManipulater, author int64, bloq int64) (bool, error) {
+	accounts, err := dbh.Select(ctx, "credential_profiles", func() map[string]any {
+		return map[string]any{"credential_id": new(string)}
+	}, []db.Condition{{Column: "profile_id", Value: author}})
+	if err != nil {
+		return false, err
+	}
+
+	offers, err := dbh.Select(ctx, ItemsOfferedTable, func() map[string]any {
+		return map[string]any{"offers": new(int64)}
+	}, []db.Condition{{Column: "item", Value: bloq}})
+	if err != nil {
+		return false, err
+	}
+
+	customers := make([]any, 0, len(accounts.Rows))
+	for _, i := range accounts.Rows {
+		customers = append(customers, *i["credential_id"].(*string))
+	}
+
+	accepted := make([]any, 0, len(offers.Rows))
+	for _, i := range offers.Rows {
+		accepted = append(accepted, *i["offers"].(*int64))
+	}
+
+	orders, err := dbh.SelectPage(ctx, OrderTable, func() map[string]any {
+		return map[string]any{"id": new(int64)}
+	}, []db.Condition{
+		{Column: "customer", Op: db.IN, Value: customers},
+		{Column: "acceptedOffer", Op: db.IN, Value: accepted},
+	}, db.Page{Limit: 1})
+	if err != nil {
+		return false, err
+	}
+
+	return len(orders.Rows) > 0, nil
+}
@@ -0,0 +1,305 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+)
+
+// memDB are tables in memory, it selects, with EQ, IN, LT and GT, pages,
+// inserts, deletes and increments, and counts the selects. It has no schema
+// to make. The inserts fail with insertErr when it's set.
+type memDB struct {
+	db.DataManipulater
+	tables    map[string][]db.JSON
+	selects   int
+	insertErr error
+}
+
+func (m *memDB) Select(ctx context.Context, table string, columns func() map[string]any, where []db.Condition) (db.Result, error) {
+	return m.SelectPage(ctx, table, columns, where, db.Page{})
+}
+
+func (m *memDB) SelectPage(ctx context.Context, table string, columns func() map[string]any, where []db.Condition, page db.Page) (db.Result, error) {
+	m.selects++
+
+	rows := []db.JSON{}
+	for _, row := range m.tables[table] {
+		if memMatches(row, where) {
+			rows = append(rows, row)
+		}
+	}
+
+	if page.OrderBy != "" {
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := rows[i][page.OrderBy].(int64), rows[j][page.OrderBy].(int64)
+			if page.Desc {
+				return a > b
+			}
+			return a < b
+		})
+	}
+
+	if page.Limit > 0 && uint(len(rows)) > page.Limit {
+		rows = rows[:page.Limit]
+	}
+
+	res := db.Result{Rows: []db.JSON{}}
+	for _, row := range rows {
+		scanned := columns()
+		for k, v := range scanned {
+			// the columns the row doesn't have are left as their zero
+			if _, ok := row[k]; ok {
+				reflect.ValueOf(v).Elem().Set(reflect.ValueOf(row[k]))
+			}
+		}
+		res.Rows = append(res.Rows, scanned)
+	}
+
+	return res, nil
+}
+
+func (m *memDB) CreateTables(context.Context, []db.Table) error  { return nil }
+func (m *memDB) CreateIndexes(context.Context, []db.Index) error { return nil }
+func (m *memDB) CreateViews(context.Context, []db.View) error    { return nil }
+func (m *memDB) Migrate(context.Context, []db.Migration) error   { return nil }
+
+// Insert numbers the rows after the biggest id in the table.
+func (m *memDB) Insert(ctx context.Context, table string, rows []map[string]any) (db.Result, error) {
+	if m.insertErr != nil {
+		return db.Result{}, m.insertErr
+	}
+
+	var last int64
+	for _, row := range m.tables[table] {
+		if id, ok := row["id"].(int64); ok && id > last {
+			last = id
+		}
+	}
+
+	for _, row := range rows {
+		last++
+		row["id"] = last
+		m.tables[table] = append(m.tables[table], row)
+	}
+
+	return db.Result{LastID: &last}, nil
+}
+
+func (m *memDB) Delete(ctx context.Context, table string, conditions map[string]any) error {
+	kept := []db.JSON{}
+	for _, row := range m.tables[table] {
+		if !memMatches(row, memWhere(conditions)) {
+			kept = append(kept, row)
+		}
+	}
+	m.tables[table] = kept
+
+	return nil
+}
+
+func (m *memDB) Increment(ctx context.Context, table string, keys map[string]any, deltas map[string]int64) error {
+	for _, row := range m.tables[table] {
+		if memMatches(row, memWhere(keys)) {
+			for k, v := range deltas {
+				row[k] = row[k].(int64) + v
+			}
+			return nil
+		}
+	}
+
+	row := db.JSON{}
+	for k, v := range keys {
+		row[k] = v
+	}
+	for k, v := range deltas {
+		row[k] = v
+	}
+	m.tables[table] = append(m.tables[table], row)
+
+	return nil
+}
+
+func memWhere(conditions map[string]any) []db.Condition {
+	where := make([]db.Condition, 0, len(conditions))
+	for k, v := range conditions {
+		where = append(where, db.Condition{Column: k, Value: v})
+	}
+
+	return where
+}
+
+func memMatches(row db.JSON, where []db.Condition) bool {
+	for _, c := range where {
+		switch c.Op {
+		case db.EQ:
+			if fmt.Sprint(row[c.Column]) != fmt.Sprint(c.Value) {
+				return false
+			}
+		case db.LT, db.GT:
+			v, ok := row[c.Column].(int64)
+			if !ok || (c.Op == db.LT && v >= c.Value.(int64)) || (c.Op == db.GT && v <= c.Value.(int64)) {
+				return false
+			}
+		case db.IN:
+			in := false
+			for _, v := range c.Value.([]any) {
+				in = in || fmt.Sprint(row[c.Column]) == fmt.Sprint(v)
+			}
+			if !in {
+				return false
+			}
+		default:
+			panic(fmt.Sprintf("memDB can't select with the operator %d", c.Op))
+		}
+	}
+
+	return true
+}
+
+func TestHasOrderedBloq(t *testing.T) {
+	const bloq = 7
+
+	dbh := &memDB{tables: map[string][]db.JSON{
+		"credential_profiles": {
+			{"credential_id": "a@example.com", "profile_id": int64(1)},
+			{"credential_id": "a@example.com", "profile_id": int64(2)},
+			{"credential_id": "b@example.com", "profile_id": int64(3)},
+		},
+		ItemsOfferedTable: {
+			{"offers": int64(10), "item": int64(bloq)},
+			{"offers": int64(11), "item": int64(bloq)},
+			{"offers": int64(12), "item": int64(8)},
+		},
+		OrderTable: {
+			{"id": int64(100), "customer": "b@example.com", "acceptedOffer": int64(12)},
+			{"id": int64(101), "customer": "b@example.com", "acceptedOffer": int64(13)},
+			{"id": int64(102), "customer": "a@example.com", "acceptedOffer": int64(11)},
+		},
+	}}
+
+	tests := []struct {
+		name   string
+		author int64
+		bloq   int64
+		want   bool
+	}{
+		{"ordered", 1, bloq, true},
+		{"other profile of the account", 2, bloq, true},
+		{"ordered another bloq", 3, bloq, false},
+		{"no profile", 4, bloq, false},
+		{"no offers", 1, 9, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbh.selects = 0
+
+			got, err := hasOrderedBloq(context.Background(), dbh, tt.author, tt.bloq)
+			if err != nil {
+				t.Fatalf("hasOrderedBloq() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("hasOrderedBloq() = %v, want %v", got, tt.want)
+			}
+
+			// however many offers and orders there are
+			if dbh.selects != 3 {
+				t.Errorf("hasOrderedBloq() selected %d times, want 3", dbh.selects)
+			}
+		})
+	}
+}
+
+func TestCreateBloqReview(t *testing.T) {
+	// the profile 1 and 2 of the account a ordered the bloq 7, the profile 2
+	// already reviewed it, and the profile 5 of the account e made it
+	reviews := func() *memDB {
+		dbh := &memDB{tables: map[string][]db.JSON{
+			"bloq": {
+				{"id": int64(7), "creator": int64(5)},
+				{"id": int64(8), "creator": int64(5)},
+			},
+			ItemsOfferedTable: {{"offers": int64(11), "item": int64(7)}},
+			OrderTable:        {{"id": int64(100), "customer": "a", "acceptedOffer": int64(11)}},
+			reviewTable: {
+				{"id": int64(20), "itemReviewed": int64(7), "author": int64(2)},
+				{"id": int64(21), "itemReviewed": int64(8), "author": int64(3)},
+			},
+		}}
+		for profile, account := range map[int64]string{1: "a", 2: "a", 3: "b", 5: "e"} {
+			dbh.tables["profile"] = append(dbh.tables["profile"], db.JSON{"id": profile})
+			dbh.tables["credential_profiles"] = append(dbh.tables["credential_profiles"], db.JSON{
+				"credential_id": account, "profile_id": profile, "birthDate": "2000-01-01",
+			})
+		}
+		return dbh
+	}
+
+	tests := []struct {
+		name      string
+		account   string
+		bloq      string
+		form      url.Values
+		insertErr error
+		status    int
+	}{
+		{"reviews", "a", "7", url.Values{"author": {"1"}, "reviewRating": {"4"}}, nil, http.StatusCreated},
+		{"reviews again", "a", "7", url.Values{"author": {"2"}, "reviewRating": {"4"}}, nil, http.StatusConflict},
+		{"reviewed at the same time", "a", "7", url.Values{"author": {"1"}, "reviewRating": {"4"}}, db.ErrDuplicate, http.StatusConflict},
+		{"didn't order it", "b", "7", url.Values{"author": {"3"}, "reviewRating": {"4"}}, nil, http.StatusForbidden},
+		{"not your profile", "b", "7", url.Values{"author": {"1"}, "reviewRating": {"4"}}, nil, http.StatusForbidden},
+		{"no token", "", "7", url.Values{"author": {"1"}, "reviewRating": {"4"}}, nil, http.StatusUnauthorized},
+		{"no such bloq", "a", "9", url.Values{"author": {"1"}, "reviewRating": {"4"}}, nil, http.StatusNotFound},
+		{"out of the range", "a", "7", url.Values{"author": {"1"}, "reviewRating": {"6"}}, nil, http.StatusUnprocessableEntity},
+		{"reply of the creator", "e", "7", url.Values{"author": {"5"}, "associatedReview": {"20"}}, nil, http.StatusCreated},
+		{"reply of who ordered it", "a", "7", url.Values{"author": {"2"}, "associatedReview": {"20"}}, nil, http.StatusCreated},
+		{"reply of a stranger", "b", "7", url.Values{"author": {"3"}, "associatedReview": {"20"}}, nil, http.StatusForbidden},
+		{"reply to a review of another bloq", "a", "7", url.Values{"author": {"1"}, "associatedReview": {"21"}}, nil, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbh := reviews()
+			dbh.insertErr = tt.insertErr
+			h := restServer(dbh, "/bloq", new(Bloq))
+
+			r := httptest.NewRequest(http.MethodPost, "/bloq/"+tt.bloq+"/reviews", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.account != "" {
+				r.Header.Set("Authorization", token(tt.account, bloqs_auth.CREATE_REVIEW))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("POST /bloq/%s/reviews %v = %d %q, want %d", tt.bloq, tt.form, w.Code, w.Body, tt.status)
+			}
+
+			n := len(dbh.tables[reviewTable])
+			if tt.status != http.StatusCreated {
+				if n != 2 {
+					t.Errorf("there are %d reviews, want 2", n)
+				}
+				return
+			}
+
+			review := dbh.tables[reviewTable][n-1]
+			if want := fmt.Sprintf("bloq/7/reviews/%d", review["id"]); !strings.HasSuffix(w.Header().Get("Location"), want) {
+				t.Errorf("Location: %q, want it to end with %q", w.Header().Get("Location"), want)
+			}
+			if _, reply := review["associatedReview"]; reply != (tt.form.Get("associatedReview") != "") || review["inLanguage"] != "en" {
+				t.Errorf("the review is %v", review)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Package testconf compiles a configuration for the tests of the packages
+// that read it.
+package testconf
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+)
+
+// Compile compiles the configuration in the JSON content, with a schema that
+// accepts anything. The returned function removes the files it needed.
+func Compile(content string) (func(), error) {
+	dir, err := os.MkdirTemp("", "bloqs-conf")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	for _, i := range []struct {
+		path    *string
+		name    string
+		content string
+	}{{conf.CnfPath, "conf.json", content}, {conf.SchPath, "schema.json", "{}"}} {
+		name := filepath.Join(dir, i.name)
+		if err := os.WriteFile(name, []byte(i.content), 0o600); err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		// absolute paths are taken as URLs
+		*i.path = "file://" + filepath.ToSlash(name)
+	}
+
+	if err := conf.Compile(); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	return cleanup, nil
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrNotQueryable is returned when none of the sinks can be read back from.
+var ErrNotQueryable = errors.New("the audit log can't be queried")
+
+type Action = string
+
+const (
+	SIGN_IN            Action = "sign_in"
+	SIGN_OUT           Action = "sign_out"
+	LOG_IN             Action = "log_in"
+	LOG_OUT            Action = "log_out"
+	LOG_OUT_EVERYWHERE Action = "log_out_everywhere"
+	REVOKE_SESSION     Action = "revoke_session"
+	GRANT_SUPER        Action = "grant_super"
+	REVOKE_SUPER       Action = "revoke_super"
+	GRANT_ROLE         Action = "grant_role"
+	REVOKE_ROLE        Action = "revoke_role"
+	CREATE_API_KEY     Action = "create_api_key"
+	REVOKE_API_KEY     Action = "revoke_api_key"
+	CHANGE_PASSWORD    Action = "change_password"
+	ENABLE_TOTP        Action = "enable_totp"
+	DISABLE_TOTP       Action = "disable_totp"
+)
+
+type Result = string
+
+const (
+	SUCCESS Result = "success"
+	FAILURE Result = "failure"
+)
+
+// Event is something security relevant that happened, actor did action to
+// target. Actor can be empty when who tried it isn't known, like in a failed
+// log in with a wrong challenge.
+type Event struct {
+	ID     int64  `json:"id"`
+	Actor  string `json:"actor"`
+	Target string `json:"target,omitempty"`
+	Action Action `json:"action"`
+	IP     string `json:"ip,omitempty"`
+	Result Result `json:"result"`
+	// Detail is why it failed, or what else is worth knowing about it.
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Filter picks events, the zero values don't filter.
+type Filter struct {
+	Actor  string
+	Action Action
+	Since  time.Time
+	Until  time.Time
+	// Before is the ID of the last event of the previous page.
+	Before int64
+	Limit  uint
+}
+
+// Sink is where the events are kept. It's append only, events can't be
+// changed or removed through it.
+type Sink interface {
+	Record(context.Context, *Event) error
+}
+
+// Querier is implemented by the sinks the events can be read back from, the
+// most recent first.
+type Querier interface {
+	Events(context.Context, *Filter) ([]Event, error)
+}
+
+// Multi records the events in all the sinks, and reads them back from the
+// first one that can.
+type Multi []Sink
+
+func (m Multi) Record(ctx context.Context, e *Event) (err error) {
+	for _, s := range m {
+		// one failing doesn't keep the event from the others
+		if s_err := s.Record(ctx, e); s_err != nil && err == nil {
+			err = s_err
+		}
+	}
+	return
+}
+
+func (m Multi) Events(ctx context.Context, f *Filter) ([]Event, error) {
+	for _, s := range m {
+		if q, ok := s.(Querier); ok {
+			return q.Events(ctx, f)
+		}
+	}
+	return nil, ErrNotQueryable
+}
+
+// Logger writes the events to the standard logger as JSON, for when they are
+// collected from the logs of the service.
+type Logger struct{}
+
+func (Logger) Record(ctx context.Context, e *Event) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("audit %s", buf)
+	return nil
+}
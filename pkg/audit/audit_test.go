@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failing is a sink that fails to record anything.
+type failing struct{ recorded int }
+
+func (f *failing) Record(ctx context.Context, e *Event) error {
+	f.recorded++
+	return errors.New("the sink is down")
+}
+
+func TestMulti(t *testing.T) {
+	ctx := context.Background()
+	down := &failing{}
+	log := &auditLog{}
+
+	s, err := NewDBSink(ctx, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := Multi{down, s}
+	if err := m.Record(ctx, &Event{Action: SIGN_IN, Result: SUCCESS}); err == nil {
+		t.Errorf("Record() didn't fail with a sink down")
+	}
+	if down.recorded != 1 || len(log.rows) != 1 {
+		t.Errorf("Record() didn't record in every sink")
+	}
+
+	events, err := m.Events(ctx, &Filter{})
+	if err != nil || len(events) != 1 {
+		t.Errorf("Events() = %v, %v, want the event from the sink that can be queried", events, err)
+	}
+
+	if _, err := (Multi{down, Logger{}}).Events(ctx, &Filter{}); !errors.Is(err, ErrNotQueryable) {
+		t.Errorf("Events() error = %v, want %v", err, ErrNotQueryable)
+	}
+}
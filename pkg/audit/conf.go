@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+)
+
+// NewSinkFromConf makes the sinks at `auth.audit.sinks`, `db` and `log`, only
+// `db` without it. dbh is where the `db` one keeps the events.
+func NewSinkFromConf(ctx context.Context, dbh db.DataManipulater) (Sink, error) {
+	var sinks Multi
+	for _, i := range conf.MustGetConfOrDefault([]any{"db"}, "auth", "audit", "sinks") {
+		switch i {
+		case "db":
+			s, err := NewDBSink(ctx, dbh)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, s)
+		case "log":
+			sinks = append(sinks, Logger{})
+		default:
+			return nil, fmt.Errorf("`%v` in `auth.audit.sinks` is not a sink, it can be `db` or `log`", i)
+		}
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+
+	return sinks, nil
+}
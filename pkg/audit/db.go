@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+)
+
+const table = "audit_log"
+
+// DBSink keeps the events in a table, it only ever inserts into it.
+type DBSink struct {
+	dbh db.DataManipulater
+}
+
+func NewDBSink(ctx context.Context, dbh db.DataManipulater) (*DBSink, error) {
+	err := dbh.CreateTables(ctx, []db.Table{
+		{
+			Name: table,
+			Columns: []string{
+				"`id` INTEGER PRIMARY KEY AUTO_INCREMENT",
+				"`actor` VARCHAR(320) NOT NULL DEFAULT ''",
+				"`target` VARCHAR(320) NOT NULL DEFAULT ''",
+				"`action` VARCHAR(64) NOT NULL",
+				"`ip` VARCHAR(45) NOT NULL DEFAULT ''",
+				"`result` VARCHAR(16) NOT NULL",
+				"`detail` TEXT NOT NULL",
+				"`at` BIGINT NOT NULL",
+				"INDEX (`actor`, `at`)",
+				"INDEX (`action`, `at`)",
+				"INDEX (`at`)",
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DBSink{dbh}, nil
+}
+
+func (s *DBSink) Record(ctx context.Context, e *Event) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	res, err := s.dbh.Insert(ctx, table, []map[string]any{
+		{
+			"actor":  e.Actor,
+			"target": e.Target,
+			"action": e.Action,
+			"ip":     e.IP,
+			"result": e.Result,
+			"detail": e.Detail,
+			"at":     e.At.Unix(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.LastID != nil {
+		e.ID = *res.LastID
+	}
+
+	return nil
+}
+
+// where are the conditions of the events f picks.
+func where(f *Filter) []db.Condition {
+	where := []db.Condition{}
+	if f.Actor != "" {
+		where = append(where, db.Condition{Column: "actor", Value: f.Actor})
+	}
+	if f.Action != "" {
+		where = append(where, db.Condition{Column: "action", Value: f.Action})
+	}
+	if !f.Since.IsZero() {
+		where = append(where, db.Condition{Column: "at", Op: db.GE, Value: f.Since.Unix()})
+	}
+	if !f.Until.IsZero() {
+		where = append(where, db.Condition{Column: "at", Op: db.LE, Value: f.Until.Unix()})
+	}
+	if f.Before > 0 {
+		where = append(where, db.Condition{Column: "id", Op: db.LT, Value: f.Before})
+	}
+
+	return where
+}
+
+func (s *DBSink) Events(ctx context.Context, f *Filter) ([]Event, error) {
+	res, err := s.dbh.SelectPage(ctx, table, func() map[string]any {
+		return map[string]any{
+			"id":     new(int64),
+			"actor":  new(string),
+			"target": new(string),
+			"action": new(string),
+			"ip":     new(string),
+			"result": new(string),
+			"detail": new(string),
+			"at":     new(int64),
+		}
+	}, where(f), db.Page{
+		OrderBy: "id",
+		Desc:    true,
+		Limit:   f.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := res.Rows
+	events := make([]Event, 0, len(rows))
+	for _, i := range rows {
+		events = append(events, Event{
+			ID:     *i["id"].(*int64),
+			Actor:  *i["actor"].(*string),
+			Target: *i["target"].(*string),
+			Action: *i["action"].(*string),
+			IP:     *i["ip"].(*string),
+			Result: *i["result"].(*string),
+			Detail: *i["detail"].(*string),
+			At:     time.Unix(*i["at"].(*int64), 0),
+		})
+	}
+
+	return events, nil
+}
@@ -0,0 +1,253 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/db"
+)
+
+// auditLog is the audit log table in memory, it only does what DBSink needs.
+type auditLog struct {
+	db.DataManipulater
+	rows  []db.JSON
+	pages []db.Page
+}
+
+func (l *auditLog) CreateTables(ctx context.Context, tables []db.Table) error {
+	return nil
+}
+
+func (l *auditLog) Insert(ctx context.Context, table string, rows []map[string]any) (db.Result, error) {
+	var id int64
+	for _, i := range rows {
+		id = int64(len(l.rows) + 1)
+		row := db.JSON{"id": id}
+		for k, v := range i {
+			row[k] = v
+		}
+		l.rows = append(l.rows, row)
+	}
+	return db.Result{LastID: &id}, nil
+}
+
+func (l *auditLog) SelectPage(ctx context.Context, table string, columns func() map[string]any, where []db.Condition, page db.Page) (db.Result, error) {
+	l.pages = append(l.pages, page)
+
+	rows := []db.JSON{}
+	for _, row := range l.rows {
+		if matches(row, where) {
+			rows = append(rows, row)
+		}
+	}
+
+	if page.OrderBy != "id" || !page.Desc {
+		return db.Result{}, fmt.Errorf("the events are ordered by %+v", page)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i]["id"].(int64) > rows[j]["id"].(int64) })
+	if page.Limit > 0 && uint(len(rows)) > page.Limit {
+		rows = rows[:page.Limit]
+	}
+
+	res := db.Result{Rows: make([]db.JSON, 0, len(rows))}
+	for _, row := range rows {
+		scanned := columns()
+		for k, v := range scanned {
+			switch v := v.(type) {
+			case *int64:
+				*v = toInt64(row[k])
+			case *string:
+				*v = fmt.Sprint(row[k])
+			}
+		}
+		res.Rows = append(res.Rows, scanned)
+	}
+	return res, nil
+}
+
+func toInt64(v any) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
+
+func matches(row db.JSON, where []db.Condition) bool {
+	for _, c := range where {
+		switch c.Op {
+		case db.EQ:
+			if fmt.Sprint(row[c.Column]) != fmt.Sprint(c.Value) {
+				return false
+			}
+		case db.GE:
+			if toInt64(row[c.Column]) < toInt64(c.Value) {
+				return false
+			}
+		case db.LE:
+			if toInt64(row[c.Column]) > toInt64(c.Value) {
+				return false
+			}
+		case db.LT:
+			if toInt64(row[c.Column]) >= toInt64(c.Value) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func TestWhere(t *testing.T) {
+	since := time.Unix(1000, 0)
+	until := time.Unix(2000, 0)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []db.Condition
+	}{
+		{
+			name:   "nothing",
+			filter: Filter{Limit: 10},
+			want:   []db.Condition{},
+		},
+		{
+			name:   "actor and action",
+			filter: Filter{Actor: "a@example.com", Action: LOG_IN},
+			want: []db.Condition{
+				{Column: "actor", Value: "a@example.com"},
+				{Column: "action", Value: LOG_IN},
+			},
+		},
+		{
+			name:   "time range",
+			filter: Filter{Since: since, Until: until},
+			want: []db.Condition{
+				{Column: "at", Op: db.GE, Value: int64(1000)},
+				{Column: "at", Op: db.LE, Value: int64(2000)},
+			},
+		},
+		{
+			name:   "next page",
+			filter: Filter{Before: 42},
+			want: []db.Condition{
+				{Column: "id", Op: db.LT, Value: int64(42)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := where(&tt.filter); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("where() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDBSink(t *testing.T) {
+	ctx := context.Background()
+	log := &auditLog{}
+
+	s, err := NewDBSink(ctx, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1700000000, 0)
+	recorded := []Event{
+		{Actor: "a@example.com", Action: LOG_IN, Result: SUCCESS},
+		{Actor: "b@example.com", Action: LOG_IN, Result: FAILURE, Detail: "wrong password"},
+		{Actor: "a@example.com", Target: "b@example.com", Action: GRANT_SUPER, Result: SUCCESS},
+		{Actor: "a@example.com", Action: LOG_OUT, Result: SUCCESS},
+		{Actor: "b@example.com", Action: LOG_IN, Result: SUCCESS, IP: "192.0.2.1"},
+	}
+	for i := range recorded {
+		recorded[i].At = start.Add(time.Duration(i) * time.Minute)
+		if err := s.Record(ctx, &recorded[i]); err != nil {
+			t.Fatal(err)
+		}
+		if recorded[i].ID != int64(i+1) {
+			t.Errorf("Record() set the id %d, want %d", recorded[i].ID, i+1)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int64
+	}{
+		{
+			name:   "all",
+			filter: Filter{},
+			want:   []int64{5, 4, 3, 2, 1},
+		},
+		{
+			name:   "limit",
+			filter: Filter{Limit: 2},
+			want:   []int64{5, 4},
+		},
+		{
+			name:   "next page",
+			filter: Filter{Before: 4, Limit: 2},
+			want:   []int64{3, 2},
+		},
+		{
+			name:   "actor",
+			filter: Filter{Actor: "a@example.com"},
+			want:   []int64{4, 3, 1},
+		},
+		{
+			name:   "action",
+			filter: Filter{Action: LOG_IN},
+			want:   []int64{5, 2, 1},
+		},
+		{
+			name:   "time range",
+			filter: Filter{Since: start.Add(time.Minute), Until: start.Add(3 * time.Minute)},
+			want:   []int64{4, 3, 2},
+		},
+		{
+			name:   "nothing",
+			filter: Filter{Actor: "c@example.com"},
+			want:   []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := s.Events(ctx, &tt.filter)
+			if err != nil {
+				t.Fatalf("Events() error = %v", err)
+			}
+
+			ids := make([]int64, 0, len(events))
+			for _, i := range events {
+				ids = append(ids, i.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("Events() = %v, want %v", ids, tt.want)
+			}
+
+			// the limit is in the query, not after it
+			if page := log.pages[len(log.pages)-1]; page.Limit != tt.filter.Limit {
+				t.Errorf("Events() selected with the limit %d, want %d", page.Limit, tt.filter.Limit)
+			}
+		})
+	}
+
+	events, err := s.Events(ctx, &Filter{Before: 3, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := events[0], recorded[1]; got != want {
+		t.Errorf("Events() = %+v, want %+v", got, want)
+	}
+}
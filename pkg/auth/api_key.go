@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// API_KEY_PREFIX starts every API key, so they're told apart from the tokens
+// and found by secret scanners.
+const API_KEY_PREFIX = "bloqs_"
+
+var ErrMalformedAPIKey = errors.New("that's not an API key")
+
+// APIKey is a long lived key for clients that can't log in, like other
+// servers. It has the permissions it was created with, never more than the
+// ones of the token of who created it.
+type APIKey struct {
+	// Prefix identifies the key, it's in the key and safe to show.
+	Prefix      string
+	Identifier  string
+	Label       string
+	Permissions Permission
+	CreatedAt   time.Time
+	// ExpiresAt is zero for keys that don't expire.
+	ExpiresAt time.Time
+	// LastUsed is zero for keys that were never used.
+	LastUsed time.Time
+	// Account is the one of the credentials, it's only known once the key
+	// is checked.
+	Account string
+}
+
+func (k *APIKey) Expired() bool {
+	return !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt)
+}
+
+// Claims are the claims of the tokens with what the key allows.
+func (k *APIKey) Claims() *Claims {
+	claims := &Claims{
+		Payload: Payload{
+			Client:      k.Identifier,
+			Permissions: k.Permissions,
+			Type:        API_KEY,
+			Account:     k.Account,
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  k.Identifier,
+			ID:       k.Prefix,
+			IssuedAt: jwt.NewNumericDate(k.CreatedAt),
+		},
+		IssuedAtMilli: k.CreatedAt.UnixMilli(),
+	}
+
+	if !k.ExpiresAt.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(k.ExpiresAt)
+	}
+
+	return claims
+}
+
+func IsAPIKey(tk string) bool {
+	return strings.HasPrefix(tk, API_KEY_PREFIX)
+}
+
+// GenerateAPIKey makes a key, `bloqs_<prefix>_<secret>`. Only the hash of the
+// key is kept, the key itself is shown once.
+func GenerateAPIKey() (key string, prefix string, hash string, err error) {
+	id := make([]byte, 5)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return
+	}
+
+	prefix = strings.ToLower(base32.StdEncoding.EncodeToString(id))
+	key = API_KEY_PREFIX + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// ParseAPIKey returns the prefix of the key.
+func ParseAPIKey(key string) (prefix string, err error) {
+	if !IsAPIKey(key) {
+		return "", ErrMalformedAPIKey
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(key, API_KEY_PREFIX), "_", 2)
+	if len(parts) != 2 || len(parts[0]) != 8 || parts[1] == "" {
+		return "", ErrMalformedAPIKey
+	}
+
+	return parts[0], nil
+}
+
+// HashAPIKey hashes a key. They're random enough that a fast hash is enough,
+// unlike passwords.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsAPIKey(key) {
+		t.Errorf("IsAPIKey(%q) = false", key)
+	}
+	if got, err := ParseAPIKey(key); err != nil || got != prefix {
+		t.Errorf("ParseAPIKey(%q) = (%q, %v), want %q", key, got, err, prefix)
+	}
+	if hash != HashAPIKey(key) || strings.Contains(hash, key) {
+		t.Errorf("GenerateAPIKey() hash = %q, want the hash of the key", hash)
+	}
+
+	// every key is different
+	if other, _, _, _ := GenerateAPIKey(); other == key {
+		t.Errorf("GenerateAPIKey() made %q twice", key)
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		prefix string
+		err    bool
+	}{
+		{"bloqs_abcdefgh_c2VjcmV0", "abcdefgh", false},
+		{"bloqs_abcdefgh_with_underscores", "abcdefgh", false},
+		{"eyJhbGciOiJFZERTQSJ9.e30.c2ln", "", true},
+		{"bloqs_abcdefgh", "", true},
+		{"bloqs_abcdefgh_", "", true},
+		{"bloqs_abc_c2VjcmV0", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAPIKey(tt.key)
+		if (err != nil) != tt.err || got != tt.prefix {
+			t.Errorf("ParseAPIKey(%q) = (%q, %v), want %q", tt.key, got, err, tt.prefix)
+		}
+	}
+}
+
+func TestAPIKeyExpired(t *testing.T) {
+	tests := []struct {
+		name    string
+		expires time.Time
+		want    bool
+	}{
+		{"never", time.Time{}, false},
+		{"later", time.Now().Add(time.Hour), false},
+		{"before", time.Now().Add(-time.Second), true},
+	}
+
+	for _, tt := range tests {
+		k := &APIKey{ExpiresAt: tt.expires}
+		if got := k.Expired(); got != tt.want {
+			t.Errorf("%s: Expired() = %v, want %v", tt.name, got, tt.want)
+		}
+
+		claims := k.Claims()
+		if (claims.ExpiresAt != nil) != !tt.expires.IsZero() {
+			t.Errorf("%s: Claims() expire at %v, want %v", tt.name, claims.ExpiresAt, tt.expires)
+		}
+	}
+}
+
+func TestAPIKeyClaims(t *testing.T) {
+	created := time.UnixMilli(1700000000123)
+	k := &APIKey{
+		Prefix:      "abcdefgh",
+		Identifier:  "user@example.com",
+		Permissions: Union(CREATE_BLOQ, READ_PROFILE),
+		CreatedAt:   created,
+		Account:     "account",
+	}
+
+	claims := k.Claims()
+	if claims.Type != API_KEY || claims.Subject != k.Identifier || claims.ID != k.Prefix || claims.Account != k.Account {
+		t.Errorf("Claims() = %+v, want the ones of the key", claims)
+	}
+	if claims.Permissions != k.Permissions {
+		t.Errorf("Claims() permissions = %v, want %v", claims.Permissions, k.Permissions)
+	}
+	if !claims.Issued().Equal(created) {
+		t.Errorf("Claims() issued at %v, want %v", claims.Issued(), created)
+	}
+}
+
+func TestAPIKeyPermissions(t *testing.T) {
+	claims := &Claims{Payload: Payload{Permissions: Union(CREATE_BLOQ, UPDATE_BLOQ, READ_PROFILE)}}
+	past, future := time.Now().Add(-time.Hour).Unix(), time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		in     *proto.APIKeyRequest
+		want   Permission
+		status uint32
+	}{
+		{
+			name:   "subset",
+			in:     &proto.APIKeyRequest{Scopes: []string{"bloq:create", "profile:read"}},
+			want:   Union(CREATE_BLOQ, READ_PROFILE),
+			status: http.StatusOK,
+		},
+		{
+			name:   "all of them",
+			in:     &proto.APIKeyRequest{Scopes: []string{"bloq:create", "bloq:update", "profile:read"}, Expires: &future},
+			want:   Union(CREATE_BLOQ, UPDATE_BLOQ, READ_PROFILE),
+			status: http.StatusOK,
+		},
+		{
+			name:   "more than the token",
+			in:     &proto.APIKeyRequest{Scopes: []string{"bloq:create", "bloq:delete"}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "super",
+			in:     &proto.APIKeyRequest{Scopes: []string{"super:grant"}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "no scopes",
+			in:     &proto.APIKeyRequest{},
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "unknown scope",
+			in:     &proto.APIKeyRequest{Scopes: []string{"bloq:fly"}},
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "long label",
+			in:     &proto.APIKeyRequest{Scopes: []string{"bloq:create"}, Label: strings.Repeat("é", 81)},
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "expired",
+			in:     &proto.APIKeyRequest{Scopes: []string{"bloq:create"}, Expires: &past},
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status, err := apiKeyPermissions(claims, tt.in)
+			if status != tt.status || (err != nil) != (tt.status != http.StatusOK) {
+				t.Fatalf("apiKeyPermissions() = (%d, %v), want %d", status, err, tt.status)
+			}
+			if got != tt.want {
+				t.Errorf("apiKeyPermissions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/audit"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+// AUDIT_MAX_LIMIT is the most events a page of the audit log can have.
+const AUDIT_MAX_LIMIT = 1000
+
+// record adds what happened to the audit log. It's best effort, what it
+// records already happened so it doesn't fail because of it.
+func (s *AuthServer) record(ctx context.Context, action audit.Action, actor string, target string, v *proto.Validation, err error) {
+	if s.auditor == nil {
+		return
+	}
+
+	e := &audit.Event{
+		Actor:  actor,
+		Target: target,
+		Action: action,
+		IP:     ClientIP(ctx),
+		Result: audit.SUCCESS,
+		At:     time.Now(),
+	}
+
+	if err != nil || (v != nil && !v.Valid) {
+		e.Result = audit.FAILURE
+		if e.Detail = v.GetMessage(); e.Detail == "" && err != nil {
+			e.Detail = err.Error()
+		}
+	}
+
+	if err := s.auditor.Record(ctx, e); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// actor is the subject of the token for the audit log, empty if it can't be
+// known.
+func (s *AuthServer) actor(ctx context.Context, in *proto.Token) string {
+	if in == nil || IsAPIKey(in.Jwt) {
+		return ""
+	}
+
+	claims, err := s.tokener.GetClaims(ctx, Token(in.Jwt))
+	if err != nil {
+		return ""
+	}
+
+	return claims.Subject
+}
+
+// Audit reads the audit log, the most recent events first, which only super
+// users can do.
+func (s *AuthServer) Audit(ctx context.Context, in *proto.AuditQuery) (*proto.AuditEvents, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return &proto.AuditEvents{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	if !claims.Super {
+		status = http.StatusForbidden
+		err := errors.New("only super users can read the audit log")
+		return &proto.AuditEvents{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	q, ok := s.auditor.(audit.Querier)
+	if !ok {
+		status = http.StatusNotImplemented
+		return &proto.AuditEvents{
+			Validation: ErrorToValidation(audit.ErrNotQueryable, &status),
+		}, audit.ErrNotQueryable
+	}
+
+	limit := uint(in.GetLimit())
+	if limit == 0 {
+		limit = uint(conf.MustGetConfOrDefault[float64](100, "auth", "audit", "limit"))
+	}
+	if limit > AUDIT_MAX_LIMIT {
+		limit = AUDIT_MAX_LIMIT
+	}
+
+	f := &audit.Filter{
+		Actor:  in.GetActor(),
+		Action: in.GetAction(),
+		Before: in.GetBefore(),
+		Limit:  limit,
+	}
+	if in.Since != nil {
+		f.Since = time.Unix(in.GetSince(), 0)
+	}
+	if in.Until != nil {
+		f.Until = time.Unix(in.GetUntil(), 0)
+	}
+
+	events, err := q.Events(ctx, f)
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.AuditEvents{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	list := make([]*proto.AuditEvent, 0, len(events))
+	for _, i := range events {
+		list = append(list, &proto.AuditEvent{
+			Id:     i.ID,
+			Actor:  i.Actor,
+			Target: i.Target,
+			Action: i.Action,
+			Ip:     i.IP,
+			Result: i.Result,
+			Detail: i.Detail,
+			At:     i.At.Unix(),
+		})
+	}
+
+	status = http.StatusOK
+	return &proto.AuditEvents{
+		Validation: Valid("", &status),
+		Events:     list,
+	}, nil
+}
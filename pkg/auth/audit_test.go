@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/audit"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+// sink keeps the events it records, it's only an audit.Querier when it's
+// wrapped by querier.
+type sink struct {
+	events []audit.Event
+}
+
+func (s *sink) Record(ctx context.Context, e *audit.Event) error {
+	s.events = append(s.events, *e)
+	return nil
+}
+
+// querier reads back the events of the sink and keeps the filters it was
+// asked for.
+type querier struct {
+	*sink
+	filters []audit.Filter
+}
+
+func (q *querier) Events(ctx context.Context, f *audit.Filter) ([]audit.Event, error) {
+	q.filters = append(q.filters, *f)
+	return q.events, nil
+}
+
+func TestRecord(t *testing.T) {
+	tk := &tokens{claims: map[Token]*Claims{"user": tokenOf("user@example.com", SIGN_OUT)}}
+	l := &sink{}
+	s := NewAuthServer(&credentials{roles: map[string][]string{}}, tk, nil, l)
+	ctx := WithClientIP(context.Background(), "192.0.2.1")
+
+	s.SignOut(ctx, &proto.Token{Jwt: "forged"})
+	s.SignOut(ctx, &proto.Token{Jwt: "user"})
+	s.RevokeRole(ctx, &proto.RoleAssignment{Token: &proto.Token{Jwt: "user"}, Identifier: "other@example.com", Role: ROLE_SELLER})
+
+	got := []string{}
+	for _, e := range l.events {
+		if e.At.IsZero() || time.Since(e.At) > time.Minute {
+			t.Errorf("the event %+v isn't from now", e)
+		}
+		got = append(got, fmt.Sprintf("%s %s %s %s %s %s", e.Action, e.Actor, e.Target, e.IP, e.Result, e.Detail))
+	}
+
+	want := []string{
+		// who tried a forged token isn't known
+		"sign_out   192.0.2.1 failure the token provided it's invalid",
+		"sign_out user@example.com  192.0.2.1 success ",
+		"revoke_role user@example.com other@example.com:seller 192.0.2.1 failure only super users can assign roles",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the events are %q, want %q", got, want)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	super := tokenOf("admin@example.com", READ_PROFILE)
+	super.Super = true
+	tk := &tokens{claims: map[Token]*Claims{
+		"super": super,
+		"user":  tokenOf("user@example.com", READ_PROFILE),
+	}}
+	at := time.Unix(1700000000, 0)
+	q := &querier{sink: &sink{events: []audit.Event{
+		{ID: 2, Actor: "user@example.com", Action: audit.SIGN_OUT, IP: "192.0.2.1", Result: audit.SUCCESS, At: at},
+		{ID: 1, Actor: "user@example.com", Action: audit.SIGN_IN, Result: audit.FAILURE, Detail: "wrong credentials", At: at},
+	}}}
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name   string
+		token  string
+		sink   audit.Sink
+		status uint32
+	}{
+		{"not a token", "forged", q, http.StatusUnauthorized},
+		{"not super", "user", q, http.StatusForbidden},
+		{"can't be read back", "super", &sink{}, http.StatusNotImplemented},
+		{"without an audit log", "super", nil, http.StatusNotImplemented},
+	} {
+		s := NewAuthServer(&credentials{}, tk, nil, tt.sink)
+		if r, err := s.Audit(ctx, &proto.AuditQuery{Token: &proto.Token{Jwt: tt.token}}); err == nil || r.GetValidation().GetHttpStatusCode() != tt.status {
+			t.Errorf("Audit() %s = (%v, %v), want a %d", tt.name, r, err, tt.status)
+		}
+	}
+
+	s := NewAuthServer(&credentials{}, tk, nil, q)
+
+	actor, before, since := "user@example.com", int64(3), at.Unix()
+	r, err := s.Audit(ctx, &proto.AuditQuery{Token: &proto.Token{Jwt: "super"}, Actor: &actor, Before: &before, Since: &since})
+	if err != nil || r.GetValidation().GetHttpStatusCode() != http.StatusOK {
+		t.Fatalf("Audit() = (%v, %v)", r, err)
+	}
+	if events := r.GetEvents(); len(events) != 2 || events[0].GetId() != 2 || events[0].GetIp() != "192.0.2.1" || events[1].GetDetail() != "wrong credentials" || events[1].GetAt() != at.Unix() {
+		t.Errorf("Audit() = %v", events)
+	}
+
+	if _, err := s.Audit(ctx, &proto.AuditQuery{Token: &proto.Token{Jwt: "super"}, Limit: 5000}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []audit.Filter{
+		{Actor: actor, Before: before, Since: at, Limit: 100},
+		{Limit: AUDIT_MAX_LIMIT},
+	}
+	if !reflect.DeepEqual(q.filters, want) {
+		t.Errorf("Audit() asked for %+v, want %+v", q.filters, want)
+	}
+}
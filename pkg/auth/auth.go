@@ -2,23 +2,20 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	"github.com/bloqs-sites/bloqsenjin/proto"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type Token string
-type Permission uint64
 type AuthType uint8
 
 const (
-	NIL                        Permission = 0
-	SIGN_OUT                              = 1 << iota
-	GRANT_SUPER                           = 1 << iota
-	REVOKE_SUPER                          = 1 << iota
-	NEEDLE_FOR_NEXT_PERMISSION            = iota
-
-	BASIC_EMAIL AuthType = iota - NEEDLE_FOR_NEXT_PERMISSION
+	BASIC_EMAIL AuthType = iota
+	OIDC
+	WEBAUTHN
+	API_KEY
 )
 
 type Payload struct {
@@ -26,24 +23,153 @@ type Payload struct {
 	Permissions Permission `json:"permissions"`
 	Super       bool       `json:"is_super"`
 	Type        AuthType   `json:"type"`
+	// Session identifies the log in the token came from, it's shared by all
+	// the tokens refreshed from it.
+	Session string `json:"session,omitempty"`
+	// Asked are the permissions asked for on the log in, so they can be
+	// capped again on a refresh. They aren't put in the tokens.
+	Asked *Permission `json:"asked,omitempty"`
+	// Account stays the same when the email of the credentials changes, it's
+	// what data is kept by.
+	Account string `json:"account,omitempty"`
+}
+
+// AccountID is what the data of the client is kept by, the client in the
+// tokens from before there were accounts.
+func (p *Payload) AccountID() string {
+	if p.Account != "" {
+		return p.Account
+	}
+
+	return p.Client
+}
+
+// Account is what all the credentials of an identifier share.
+type Account struct {
+	ID         string
+	Identifier string
+	Super      bool
+	Verified   bool
+	// Roles are the ones assigned, without ROLE_USER.
+	Roles []string
 }
 
 type Claims struct {
 	Payload
 	jwt.RegisteredClaims
+	// IssuedAtMilli is `iat` in milliseconds, so the tokens issued in the
+	// same second all the tokens of their subject were revoked can be told
+	// apart from the ones that were.
+	IssuedAtMilli int64 `json:"iat_ms,omitempty"`
+}
+
+// Issued is when the token was issued, to the millisecond when it was issued
+// with `iat_ms`.
+func (c *Claims) Issued() time.Time {
+	if c.IssuedAtMilli != 0 {
+		return time.UnixMilli(c.IssuedAtMilli)
+	}
+
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+
+	return time.Time{}
 }
 
 type Tokener interface {
 	GenToken(context.Context, *Payload) (Token, error)
 	VerifyToken(context.Context, Token, Permission) (bool, error)
+	// RevokeToken revokes the token and the session it belongs to.
 	RevokeToken(context.Context, Token) error
+	// RevokeAllTokens revokes every token of the subject of the token.
+	RevokeAllTokens(context.Context, Token) error
+	GetClaims(context.Context, Token) (*Claims, error)
+	GenRefreshToken(context.Context, *Payload) (Token, error)
+	// Refresh exchanges a refresh token for a new access token and the refresh
+	// token that replaces it. update can change the payload they're issued
+	// with, or fail the refresh.
+	Refresh(ctx context.Context, tk Token, update func(*Payload) error) (access Token, refresh Token, err error)
+	// JWKS returns the public keys tokens can be verified with.
+	JWKS(context.Context) (*JWKS, error)
+	// GenMFAChallenge remembers the payload of a log in that still needs a
+	// second factor and returns the challenge to finish it with.
+	GenMFAChallenge(context.Context, *Payload) (Token, error)
+	// CheckMFAChallenge returns the payload of the challenge if check passes,
+	// the challenge can't be used again after that or after too many fails.
+	CheckMFAChallenge(ctx context.Context, challenge Token, check func(*Payload) error) (*Payload, error)
+	// RevokeSubject revokes every token of sub.
+	RevokeSubject(ctx context.Context, sub string) error
+	// RevokedSince is when RevokeSubject was last called for sub, nil if it
+	// wasn't while it could matter.
+	RevokedSince(ctx context.Context, sub string) (*time.Time, error)
+	// GenEmailToken makes a single use token to send by email for identifier,
+	// data is whatever else is needed to use it.
+	GenEmailToken(ctx context.Context, identifier string, purpose EmailPurpose, data string) (Token, error)
+	// ConsumeEmailToken returns the identifier and the data of the token.
+	ConsumeEmailToken(ctx context.Context, tk Token, purpose EmailPurpose) (identifier string, data string, err error)
+	// PutSession records a session of sub, after its refresh token was made.
+	PutSession(ctx context.Context, sub string, s *Session) error
+	// Sessions are the sessions of sub that weren't revoked, the most
+	// recently used first.
+	Sessions(ctx context.Context, sub string) ([]Session, error)
+	// RevokeSession ends the session id of sub, and with it its tokens.
+	RevokeSession(ctx context.Context, sub string, id string) error
 }
 
 type Auther interface {
 	SignInBasic(context.Context, *proto.Credentials_Basic) error
 	SignOutBasic(context.Context, *proto.Credentials_Basic) error
+	SignOut(ctx context.Context, identifier string, typ AuthType) error
 	CheckAccessBasic(context.Context, *proto.Credentials_Basic) error
+	// IsSuperBasic only looks the credentials up, CheckAccessBasic has to be
+	// called before it.
 	IsSuperBasic(context.Context, *proto.Credentials_Basic) (bool, error)
+	// LogInOIDC finds, or creates on the first log in, the credentials of an
+	// identity verified by an OpenID provider and returns their identifier.
+	LogInOIDC(context.Context, *OIDCIdentity) (identifier string, super bool, err error)
+	// BeginWebAuthn starts a WebAuthn ceremony and returns its challenge and
+	// the ids of the authenticators identifier has. The identifier can be
+	// empty to log in with a discoverable credential. owner is if who started
+	// it is logged in as identifier, which is needed to add authenticators to
+	// credentials that already exist.
+	BeginWebAuthn(ctx context.Context, identifier string, register bool, owner bool) (challenge string, credentials []string, err error)
+	SignInWebAuthn(context.Context, *proto.Credentials_Webauthn) (identifier string, err error)
+	CheckAccessWebAuthn(context.Context, *proto.Credentials_Webauthn) (identifier string, super bool, err error)
+	HasTOTP(ctx context.Context, identifier string) (bool, error)
+	// EnrolTOTP makes a new TOTP secret for identifier that is only used
+	// after it is confirmed with ConfirmTOTP, which returns the recovery
+	// codes.
+	EnrolTOTP(ctx context.Context, identifier string) (secret string, err error)
+	ConfirmTOTP(ctx context.Context, identifier string, code string) (recovery []string, err error)
+	DisableTOTP(ctx context.Context, identifier string, code string) error
+	// CheckTOTP accepts a TOTP code or one of the recovery codes.
+	CheckTOTP(ctx context.Context, identifier string, code string) error
+	HasPassword(ctx context.Context, identifier string) (bool, error)
+	IsVerified(ctx context.Context, identifier string) (bool, error)
+	VerifyEmail(ctx context.Context, identifier string) error
+	ResetPassword(ctx context.Context, identifier string, password string) error
+	// ChangePassword needs the current password in the credentials.
+	ChangePassword(ctx context.Context, c *proto.Credentials_Basic, password string) error
+	// CheckNewEmail is if identifier can be changed to email, which is only
+	// changed with ChangeEmail after it's confirmed.
+	CheckNewEmail(ctx context.Context, identifier string, email string) error
+	ChangeEmail(ctx context.Context, identifier string, email string) error
 	GrantSuper(context.Context, *proto.Credentials) error
 	RevokeSuper(context.Context, *proto.Credentials) error
+	// Account is how the credentials of identifier are now, it's a 404 if
+	// there are none.
+	Account(ctx context.Context, identifier string) (*Account, error)
+	// Roles are the roles assigned to identifier, without ROLE_USER.
+	Roles(ctx context.Context, identifier string) ([]string, error)
+	// GrantRole assigns the role to identifier, by is who did it.
+	GrantRole(ctx context.Context, identifier string, role string, by string) error
+	RevokeRole(ctx context.Context, identifier string, role string) error
+	// CreateAPIKey keeps the key with the hash of its secret.
+	CreateAPIKey(ctx context.Context, key *APIKey, hash string) error
+	APIKeys(ctx context.Context, identifier string) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, identifier string, prefix string) error
+	// CheckAPIKey returns the key if it exists and didn't expire, with only
+	// the permissions its credentials still have, and records it was used.
+	CheckAPIKey(ctx context.Context, key string) (*APIKey, error)
 }
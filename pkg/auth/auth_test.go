@@ -0,0 +1,20 @@
+package auth
+
+import "testing"
+
+func TestPayloadAccountID(t *testing.T) {
+	tests := []struct {
+		payload Payload
+		want    string
+	}{
+		{Payload{Client: "new@example.com", Account: "old@example.com"}, "old@example.com"},
+		// the tokens from before there were accounts
+		{Payload{Client: "user@example.com"}, "user@example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.payload.AccountID(); got != tt.want {
+			t.Errorf("%+v.AccountID() = %q, want %q", tt.payload, got, tt.want)
+		}
+	}
+}
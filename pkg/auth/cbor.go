@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// decodeCBOR decodes the first CBOR item of buf, only what's needed for the
+// WebAuthn structures is supported. Integers are returned as int64, so they
+// can be used as map keys regardless of their sign, and it also returns how
+// many bytes were read.
+func decodeCBOR(buf []byte) (any, int, error) {
+	return decodeCBORItem(buf, 0)
+}
+
+func decodeCBORItem(buf []byte, depth int) (any, int, error) {
+	if depth > 16 {
+		return nil, 0, errors.New("cbor: too deeply nested")
+	}
+
+	if len(buf) < 1 {
+		return nil, 0, errors.New("cbor: unexpected end of data")
+	}
+
+	major, info := buf[0]>>5, buf[0]&0x1f
+	n := 1
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		if len(buf) < n+1 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arg = uint64(buf[n])
+		n += 1
+	case info == 25:
+		if len(buf) < n+2 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arg = uint64(binary.BigEndian.Uint16(buf[n:]))
+		n += 2
+	case info == 26:
+		if len(buf) < n+4 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arg = uint64(binary.BigEndian.Uint32(buf[n:]))
+		n += 4
+	case info == 27:
+		if len(buf) < n+8 {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		arg = binary.BigEndian.Uint64(buf[n:])
+		n += 8
+	default:
+		return nil, 0, fmt.Errorf("cbor: unsupported additional information %d", info)
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, 0, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, 0, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if uint64(len(buf)-n) < arg {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		data := buf[n : n+int(arg)]
+		n += int(arg)
+		if major == 3 {
+			return string(data), n, nil
+		}
+		return append([]byte(nil), data...), n, nil
+	case 4:
+		if arg > uint64(len(buf)) {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, m, err := decodeCBORItem(buf[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			n += m
+		}
+		return items, n, nil
+	case 5:
+		if arg > uint64(len(buf)) {
+			return nil, 0, errors.New("cbor: unexpected end of data")
+		}
+		items := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, m, err := decodeCBORItem(buf[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, 0, errors.New("cbor: unsupported map key")
+			}
+
+			v, m, err := decodeCBORItem(buf[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+
+			items[k] = v
+		}
+		return items, n, nil
+	case 7:
+		switch info {
+		case 20:
+			return false, n, nil
+		case 21:
+			return true, n, nil
+		case 22, 23:
+			return nil, n, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("cbor: unsupported major type %d", major)
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Validator is what services that only consume tokens need from the auth
+// service.
+type Validator interface {
+	Validate(context.Context, *proto.Token) (*proto.Validation, error)
+}
+
+// Introspector is what services need from the auth service to know the claims
+// of API keys, the ones of the tokens are in them.
+type Introspector interface {
+	Introspect(context.Context, *proto.Token) (*proto.Introspection, error)
+}
+
+// AuthClient validates tokens through the gRPC API of the auth service and
+// falls back to its HTTP `verify` path when the gRPC one is unreachable or was
+// not configured.
+type AuthClient struct {
+	conn   *grpc.ClientConn
+	client proto.AuthClient
+	http   *http.Client
+}
+
+// NewAuthClient creates a client for the auth service at the gRPC target. An
+// empty target makes the client only use HTTP.
+func NewAuthClient(target string) (*AuthClient, error) {
+	c := &AuthClient{
+		http: http.DefaultClient,
+	}
+
+	if target == "" {
+		return c, nil
+	}
+
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	c.conn = conn
+	c.client = proto.NewAuthClient(conn)
+
+	return c, nil
+}
+
+func (c *AuthClient) Validate(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
+	if c.client != nil {
+		v, err := c.client.Validate(ctx, in)
+		if status.Code(err) != codes.Unavailable {
+			return v, GRPCToHttpError(err)
+		}
+	}
+
+	return c.validateHTTP(ctx, in)
+}
+
+func (c *AuthClient) validateHTTP(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
+	v := new(proto.Validation)
+	code, err := c.postHTTP(ctx, in, "", v)
+	if err != nil {
+		return nil, err
+	}
+
+	if code < 200 || code >= 300 {
+		return v, &mux.HttpError{
+			Body:   v.GetMessage(),
+			Status: uint16(code),
+		}
+	}
+
+	return v, nil
+}
+
+// Introspect returns the claims of the token, it's how the ones of API keys
+// are known.
+func (c *AuthClient) Introspect(ctx context.Context, in *proto.Token) (*proto.Introspection, error) {
+	if c.client != nil {
+		i, err := c.client.Introspect(ctx, in)
+		if status.Code(err) != codes.Unavailable {
+			return i, GRPCToHttpError(err)
+		}
+	}
+
+	i := new(proto.Introspection)
+	code, err := c.postHTTP(ctx, in, "introspect", i)
+	if err != nil {
+		return nil, err
+	}
+
+	if code < 200 || code >= 300 {
+		return i, &mux.HttpError{
+			Body:   i.GetValidation().GetMessage(),
+			Status: uint16(code),
+		}
+	}
+
+	return i, nil
+}
+
+// postHTTP posts the token to the `verify` path of the auth service, with sub
+// appended to it, and decodes the JSON response into out.
+func (c *AuthClient) postHTTP(ctx context.Context, in *proto.Token, sub string, out any) (int, error) {
+	domain, err := conf.GetConf("auth", "domain")
+	if err != nil {
+		return 0, err
+	}
+	path := conf.MustGetConfOrDefault("/verify/", "auth", "paths", "verify")
+
+	form := url.Values{}
+	if in.Permissions != nil {
+		form.Add("permissions", strconv.FormatUint(*in.Permissions, 10))
+	}
+	for _, i := range in.Scopes {
+		form.Add("permissions", i)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprint(domain, path, sub), strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	scheme := "Bearer"
+	if IsAPIKey(in.Jwt) {
+		scheme = "ApiKey"
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s %s", scheme, in.Jwt))
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return 0, &mux.HttpError{
+			Body:   fmt.Sprintf("could not reach the auth service:\t%s", err),
+			Status: http.StatusServiceUnavailable,
+		}
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return 0, &mux.HttpError{
+			Body:   fmt.Sprintf("the auth service responded with an unexpected body:\t%s", err),
+			Status: http.StatusBadGateway,
+		}
+	}
+
+	return res.StatusCode, nil
+}
+
+func (c *AuthClient) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	return c.conn.Close()
+}
+
+var httpToGRPC = map[uint16]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusUnprocessableEntity: codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+}
+
+// HttpErrorInterceptor makes the errors returned by the gRPC handlers keep the
+// HTTP status they were created with, so clients can get it back with
+// GRPCToHttpError.
+func HttpErrorInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	res, err := handler(ctx, req)
+	if err == nil {
+		return res, nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return res, err
+	}
+
+	code := codes.Internal
+	switch x := err.(type) {
+	case *mux.HttpError:
+		if c, ok := httpToGRPC[x.Status]; ok {
+			code = c
+		}
+	case NoPermissionsError:
+		code = codes.PermissionDenied
+	}
+
+	return res, status.Error(code, err.Error())
+}
+
+func GRPCToHttpError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	var code uint16 = http.StatusInternalServerError
+	for k, v := range httpToGRPC {
+		if v == s.Code() {
+			code = k
+			break
+		}
+	}
+
+	return &mux.HttpError{
+		Body:   s.Message(),
+		Status: code,
+	}
+}
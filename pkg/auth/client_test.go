@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+	"google.golang.org/grpc"
+)
+
+// verifier is the `verify` path of the auth service, it accepts the token
+// `valid` with the scheme it's sent with and records what it was asked.
+func verifier(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	asked := &[]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		*asked = append(*asked, fmt.Sprintf("%s %s %v", r.URL.Path, r.Header.Get("Authorization"), r.PostForm["permissions"]))
+
+		var status uint32 = http.StatusOK
+		v := Valid("", &status)
+		if auth := r.Header.Get("Authorization"); auth != "Bearer valid" && auth != "ApiKey bloqs_abcdefgh_c2VjcmV0" {
+			status = http.StatusUnauthorized
+			v = Invalid("the token provided it's invalid", &status)
+		}
+
+		switch r.URL.Path {
+		case "/verify/":
+			w.WriteHeader(int(status))
+			json.NewEncoder(w).Encode(v)
+		case "/verify/introspect":
+			w.WriteHeader(int(status))
+			json.NewEncoder(w).Encode(&proto.Introspection{Validation: v, Subject: "user@example.com", Scopes: []string{"bloq:create"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, asked
+}
+
+func TestAuthClientHTTP(t *testing.T) {
+	srv, asked := verifier(t)
+	withConf(t, fmt.Sprintf(`{"auth": {"domain": %q}}`, srv.URL))
+
+	c, err := NewAuthClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	legacy := uint64(4)
+
+	if v, err := c.Validate(ctx, &proto.Token{Jwt: "valid", Permissions: &legacy, Scopes: []string{"bloq:create"}}); err != nil || !v.GetValid() {
+		t.Errorf("Validate() = (%v, %v), want it valid", v, err)
+	}
+	if v, err := c.Validate(ctx, &proto.Token{Jwt: "bloqs_abcdefgh_c2VjcmV0"}); err != nil || !v.GetValid() {
+		t.Errorf("Validate() of an API key = (%v, %v), want it valid", v, err)
+	}
+
+	var e *mux.HttpError
+	if _, err := c.Validate(ctx, &proto.Token{Jwt: "forged"}); !errors.As(err, &e) || e.Status != http.StatusUnauthorized || e.Body == "" {
+		t.Errorf("Validate() of a forged token error = %v, want a 401 with the message", err)
+	}
+
+	if i, err := c.Introspect(ctx, &proto.Token{Jwt: "bloqs_abcdefgh_c2VjcmV0"}); err != nil || i.GetSubject() != "user@example.com" || !reflect.DeepEqual(i.GetScopes(), []string{"bloq:create"}) {
+		t.Errorf("Introspect() = (%v, %v), want the claims of the key", i, err)
+	}
+
+	want := []string{
+		"/verify/ Bearer valid [4 bloq:create]",
+		"/verify/ ApiKey bloqs_abcdefgh_c2VjcmV0 []",
+		"/verify/ Bearer forged []",
+		"/verify/introspect ApiKey bloqs_abcdefgh_c2VjcmV0 []",
+	}
+	if !reflect.DeepEqual(*asked, want) {
+		t.Errorf("the auth service was asked %q, want %q", *asked, want)
+	}
+}
+
+func TestAuthClientHTTPUnreachable(t *testing.T) {
+	srv, _ := verifier(t)
+	withConf(t, fmt.Sprintf(`{"auth": {"domain": %q, "paths": {"verify": "/elsewhere/"}}}`, srv.URL))
+
+	c, err := NewAuthClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// it answers with something that isn't a validation
+	var e *mux.HttpError
+	if _, err := c.Validate(context.Background(), &proto.Token{Jwt: "valid"}); !errors.As(err, &e) || e.Status != http.StatusBadGateway {
+		t.Errorf("Validate() error = %v, want a 502", err)
+	}
+
+	srv.Close()
+	if _, err := c.Validate(context.Background(), &proto.Token{Jwt: "valid"}); !errors.As(err, &e) || e.Status != http.StatusServiceUnavailable {
+		t.Errorf("Validate() error = %v, want a 503", err)
+	}
+}
+
+// validating is an auth service that only validates, the token `valid` is
+// valid and the others are forbidden.
+type validating struct {
+	proto.UnimplementedAuthServer
+	calls int
+}
+
+func (s *validating) Validate(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
+	s.calls++
+
+	var status uint32 = http.StatusOK
+	if in.Jwt != "valid" {
+		status = http.StatusForbidden
+		err := &mux.HttpError{Body: "you can't do that", Status: http.StatusForbidden}
+		return ErrorToValidation(err, &status), err
+	}
+
+	return Valid("", &status), nil
+}
+
+func TestAuthClientGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &validating{}
+	s := grpc.NewServer(grpc.UnaryInterceptor(HttpErrorInterceptor))
+	proto.RegisterAuthServer(s, srv)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	// the HTTP path isn't used while the gRPC one is there
+	verify, asked := verifier(t)
+	withConf(t, fmt.Sprintf(`{"auth": {"domain": %q}}`, verify.URL))
+
+	c, err := NewAuthClient(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	if v, err := c.Validate(ctx, &proto.Token{Jwt: "valid"}); err != nil || !v.GetValid() {
+		t.Errorf("Validate() = (%v, %v), want it valid", v, err)
+	}
+
+	// the status goes through gRPC
+	var e *mux.HttpError
+	if _, err := c.Validate(ctx, &proto.Token{Jwt: "forged"}); !errors.As(err, &e) || e.Status != http.StatusForbidden || e.Body != "you can't do that" {
+		t.Errorf("Validate() error = %v, want the 403", err)
+	}
+
+	if srv.calls != 2 || len(*asked) != 0 {
+		t.Errorf("the auth service was asked %d times through gRPC and %d through HTTP, want 2 and 0", srv.calls, len(*asked))
+	}
+
+	// without the gRPC service it falls back to HTTP
+	s.Stop()
+	if v, err := c.Validate(ctx, &proto.Token{Jwt: "valid"}); err != nil || !v.GetValid() {
+		t.Errorf("Validate() without gRPC = (%v, %v), want it valid", v, err)
+	}
+	if len(*asked) != 1 {
+		t.Errorf("the auth service was asked %d times through HTTP, want 1", len(*asked))
+	}
+}
+
+func TestGRPCToHttpError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want uint16
+	}{
+		{&mux.HttpError{Body: "who?", Status: http.StatusUnauthorized}, http.StatusUnauthorized},
+		{&mux.HttpError{Body: "no", Status: http.StatusForbidden}, http.StatusForbidden},
+		{&mux.HttpError{Body: "slow down", Status: http.StatusTooManyRequests}, http.StatusTooManyRequests},
+		{&mux.HttpError{Body: "teapot", Status: http.StatusTeapot}, http.StatusInternalServerError},
+		{NoPermissionsError{Permission: CREATE_BLOQ}, http.StatusForbidden},
+		{errors.New("oops"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		_, err := HttpErrorInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+			return nil, tt.err
+		})
+
+		var e *mux.HttpError
+		if got := GRPCToHttpError(err); !errors.As(got, &e) || e.Status != tt.want || e.Body != tt.err.Error() {
+			t.Errorf("GRPCToHttpError() of %v = %v, want a %d", tt.err, got, tt.want)
+		}
+	}
+
+	if GRPCToHttpError(nil) != nil {
+		t.Errorf("GRPCToHttpError(nil) isn't nil")
+	}
+}
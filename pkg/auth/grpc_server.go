@@ -5,41 +5,79 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/bloqs-sites/bloqsenjin/pkg/audit"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
 	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
 	"github.com/bloqs-sites/bloqsenjin/proto"
-	"github.com/resendlabs/resend-go"
+	"github.com/google/uuid"
 )
 
+// Mailer sends emails made from the templates of pkg/email.
+type Mailer interface {
+	Mail(ctx context.Context, to string, template string, data map[string]any) error
+}
+
 type AuthServer struct {
 	proto.UnimplementedAuthServer
 
 	auther  Auther
 	tokener Tokener
+	// mailer can be nil, then no emails are sent
+	mailer Mailer
+	// auditor can be nil, then nothing is recorded in the audit log
+	auditor audit.Sink
 }
 
-func NewAuthServer(a Auther, t Tokener) *AuthServer {
+func NewAuthServer(a Auther, t Tokener, m Mailer, l audit.Sink) *AuthServer {
 	return &AuthServer{
 		auther:  a,
 		tokener: t,
+		mailer:  m,
+		auditor: l,
 	}
 }
 
 func (s *AuthServer) SignIn(ctx context.Context, in *proto.Credentials) (*proto.Validation, error) {
+	v, err := s.signIn(ctx, in)
+
+	var actor string
+	if id := CredentialsToID(in); id != nil {
+		actor = *id
+	}
+	s.record(ctx, audit.SIGN_IN, actor, "", v, err)
+
+	return v, err
+}
+
+func (s *AuthServer) signIn(ctx context.Context, in *proto.Credentials) (*proto.Validation, error) {
 	var status uint32
 	switch x := in.Credentials.(type) {
 	case *proto.Credentials_Basic:
 		if err := s.auther.SignInBasic(ctx, x); err != nil {
-			var status uint32 = http.StatusInternalServerError
-			if err, ok := err.(*mux.HttpError); ok {
-				status = uint32(err.Status)
-			}
+			status := ErrorStatus(err, http.StatusInternalServerError)
+
+			return ErrorToValidation(err, &status), err
+		}
+	case *proto.Credentials_Webauthn:
+		if !IsAuthMethodSupported("webauthn") {
+			status = http.StatusUnprocessableEntity
+			err := errors.New("WebAuthn is not supported")
+			return ErrorToValidation(err, &status), err
+		}
+
+		id, err := s.auther.SignInWebAuthn(ctx, x)
+		if err != nil {
+			status := ErrorStatus(err, http.StatusInternalServerError)
 
 			return ErrorToValidation(err, &status), err
 		}
+
+		status = http.StatusCreated
+		return Valid(fmt.Sprintf("An authenticator for `%s` was registered with success!", id), &status), nil
 	case nil:
 		status = http.StatusBadRequest
 		return Invalid("Did not recieve Credentials.", &status), errors.New("credentials cannot be nil")
@@ -51,23 +89,9 @@ func (s *AuthServer) SignIn(ctx context.Context, in *proto.Credentials) (*proto.
 	//status = http.StatusNoContent
 	status = http.StatusCreated
 	if id := CredentialsToID(in); id != nil {
-		k, exists := os.LookupEnv("BLOQS_EMAIL_RESEND_KEY")
-
-		if exists {
-			client := resend.NewClient(k)
-
-			params := &resend.SendEmailRequest{
-				From:    "Bloqs <onboarding@resend.dev>",
-				To:      []string{*id},
-				Subject: "Bloq credentials created",
-				Text:    fmt.Sprintf("Credentials created at `%s`", *conf.CnfPath),
-			}
-
-			_, err := client.Emails.Send(params)
-			if err != nil {
-				status = http.StatusInternalServerError
-				return ErrorToValidation(err, &status), err
-			}
+		// the credentials were created anyway, the email can be sent again
+		if err := s.sendVerification(ctx, *id); err != nil {
+			fmt.Printf("%v\n", err)
 		}
 
 		return Valid(fmt.Sprintf("Credentials for `%s` were created with success!", *id), &status), nil
@@ -77,29 +101,75 @@ func (s *AuthServer) SignIn(ctx context.Context, in *proto.Credentials) (*proto.
 }
 
 func (s *AuthServer) SignOut(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
-	//switch x := in.Credentials.Credentials.(type) {
-	//case *proto.Credentials_Basic:
-	//	if err := s.auther.SignOutBasic(ctx, x, in.Token, s.tokener); err != nil {
-	//		return ErrorToValidation(err), err
-	//	}
-	//case nil:
-	//	return Invalid("Did not recieve Credentials."), errors.New("Credentials cannot be nil")
-	//default:
-	//	return Invalid("Recieved forbidden on unsupported Credentials."), fmt.Errorf("Credentials has unexpected type %T", x)
-	//}
-
-	//if id := CredentialsToID(in.Credentials); id != nil {
-	//	return Valid(fmt.Sprintf("Credentials for `%s` were deleted with success!", *id)), nil
-	//} else {
-	//	return Valid("Credentials were deleted with success!"), nil
-	//}
-
-	str := "TODO"
-	var code uint32 = 500
-	return Invalid(str, &code), nil
+	// the token is revoked by it
+	actor := s.actor(ctx, in)
+	v, err := s.signOut(ctx, in)
+	s.record(ctx, audit.SIGN_OUT, actor, "", v, err)
+
+	return v, err
+}
+
+func (s *AuthServer) signOut(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
+	var status uint32
+
+	if valid, err := s.tokener.VerifyToken(ctx, Token(in.Jwt), SIGN_OUT); !valid {
+		status = http.StatusUnauthorized
+		switch x := err.(type) {
+		case *mux.HttpError:
+			status = uint32(x.Status)
+		case NoPermissionsError:
+			status = http.StatusForbidden
+		case nil:
+			err = errors.New("the token provided it's invalid")
+		}
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	claims, err := s.tokener.GetClaims(ctx, Token(in.Jwt))
+	if err != nil {
+		status = http.StatusUnauthorized
+		return ErrorToValidation(err, &status), err
+	}
+
+	if err := s.auther.SignOut(ctx, claims.Subject, claims.Type); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	// every token of the subject, from any device, or the sessions could keep
+	// being refreshed without the credentials
+	if err := s.tokener.RevokeSubject(ctx, claims.Subject); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusOK
+	return Valid(fmt.Sprintf("Credentials for `%s` were deleted with success!", claims.Subject), &status), nil
 }
 
 func (s *AuthServer) LogIn(ctx context.Context, in *proto.AskPermissions) (*proto.TokenValidation, error) {
+	v, err := s.logIn(ctx, in)
+
+	// it's recorded when it's finished with the second factor
+	if v.GetChallenge() != "" {
+		return v, err
+	}
+
+	var actor string
+	if tk := v.GetToken(); tk != nil {
+		actor = s.actor(ctx, tk)
+	} else if x, ok := in.GetCredentials().GetCredentials().(*proto.Credentials_Basic); ok {
+		actor = x.Basic.GetEmail()
+	}
+	s.record(ctx, audit.LOG_IN, actor, "", v.GetValidation(), err)
+
+	return v, err
+}
+
+func (s *AuthServer) logIn(ctx context.Context, in *proto.AskPermissions) (*proto.TokenValidation, error) {
 	var (
 		token       Token
 		err         error
@@ -108,17 +178,18 @@ func (s *AuthServer) LogIn(ctx context.Context, in *proto.AskPermissions) (*prot
 		status      uint32
 		super       bool
 		typ         AuthType
+		client      string
+		payload     *Payload
+		has_totp    bool
 	)
 
 	switch x := in.Credentials.Credentials.(type) {
 	case *proto.Credentials_Basic:
 		typ = BASIC_EMAIL
+		client = x.Basic.Email
 		err = s.auther.CheckAccessBasic(ctx, x)
 		if err != nil {
-			status = http.StatusInternalServerError
-			if err, ok := err.(*mux.HttpError); ok {
-				status = uint32(err.Status)
-			}
+			status = ErrorStatus(err, http.StatusInternalServerError)
 
 			return &proto.TokenValidation{
 				Validation: ErrorToValidation(err, &status),
@@ -126,11 +197,74 @@ func (s *AuthServer) LogIn(ctx context.Context, in *proto.AskPermissions) (*prot
 			}, err
 		}
 		super, err = s.auther.IsSuperBasic(ctx, x)
+		if err == nil {
+			has_totp, err = s.auther.HasTOTP(ctx, client)
+		}
+		if err != nil {
+			status = ErrorStatus(err, http.StatusInternalServerError)
+
+			return &proto.TokenValidation{
+				Validation: ErrorToValidation(err, &status),
+				Token:      nil,
+			}, err
+		}
+	case *proto.Credentials_Totp:
+		payload, err = s.tokener.CheckMFAChallenge(ctx, Token(x.Totp.GetChallenge()), func(p *Payload) error {
+			return s.auther.CheckTOTP(ctx, p.Client, x.Totp.GetCode())
+		})
 		if err != nil {
-			status = http.StatusInternalServerError
-			if err, ok := err.(*mux.HttpError); ok {
-				status = uint32(err.Status)
+			status = ErrorStatus(err, http.StatusInternalServerError)
+
+			return &proto.TokenValidation{
+				Validation: ErrorToValidation(err, &status),
+				Token:      nil,
+			}, err
+		}
+
+		// it was checked in the first step, the challenge has all that's needed
+		client = payload.Client
+		permissions = payload.Permissions
+		typ = payload.Type
+		super = payload.Super
+	case *proto.Credentials_Oidc:
+		typ = OIDC
+		if !IsAuthMethodSupported("oidc") {
+			status = http.StatusUnprocessableEntity
+			err = errors.New("OpenID Connect log in is not supported")
+			return &proto.TokenValidation{
+				Validation: ErrorToValidation(err, &status),
+			}, err
+		}
+
+		var (
+			provider *OIDCProvider
+			identity *OIDCIdentity
+		)
+		if provider, err = GetOIDCProvider(x.Oidc.GetProvider()); err == nil {
+			if identity, err = provider.Exchange(ctx, x.Oidc); err == nil {
+				client, super, err = s.auther.LogInOIDC(ctx, identity)
 			}
+		}
+		if err != nil {
+			status = ErrorStatus(err, http.StatusInternalServerError)
+
+			return &proto.TokenValidation{
+				Validation: ErrorToValidation(err, &status),
+				Token:      nil,
+			}, err
+		}
+	case *proto.Credentials_Webauthn:
+		typ = WEBAUTHN
+		if !IsAuthMethodSupported("webauthn") {
+			status = http.StatusUnprocessableEntity
+			err = errors.New("WebAuthn is not supported")
+			return &proto.TokenValidation{
+				Validation: ErrorToValidation(err, &status),
+			}, err
+		}
+
+		if client, super, err = s.auther.CheckAccessWebAuthn(ctx, x); err != nil {
+			status = ErrorStatus(err, http.StatusInternalServerError)
 
 			return &proto.TokenValidation{
 				Validation: ErrorToValidation(err, &status),
@@ -155,52 +289,209 @@ func (s *AuthServer) LogIn(ctx context.Context, in *proto.AskPermissions) (*prot
 		}, err
 	}
 
-	if token, err = s.tokener.GenToken(ctx, &Payload{
-		Client:      *CredentialsToID(in.Credentials),
-		Permissions: Permission(in.Permissions),
-		Super:       super,
-		Type:        typ,
-	}); err != nil {
-		status = http.StatusInternalServerError
-		if err, ok := err.(*mux.HttpError); ok {
-			status = uint32(err.Status)
+	// super users have to use a second factor, authenticators already are one
+	enrol := false
+	if super && typ == OIDC && payload == nil {
+		if has_totp, err = s.auther.HasTOTP(ctx, client); err != nil {
+			status = ErrorStatus(err, http.StatusInternalServerError)
+
+			return &proto.TokenValidation{
+				Validation: ErrorToValidation(err, &status),
+				Token:      nil,
+			}, err
+		}
+	}
+	if super && typ != WEBAUTHN && !has_totp && payload == nil {
+		super = false
+		enrol = true
+	}
+
+	if payload == nil {
+		var acc *Account
+		if acc, err = s.auther.Account(ctx, client); err != nil {
+			status = ErrorStatus(err, http.StatusInternalServerError)
+
+			return &proto.TokenValidation{
+				Validation: ErrorToValidation(err, &status),
+				Token:      nil,
+			}, err
+		}
+
+		// super credentials can have any scope, the others only the ones of
+		// their roles
+		asked := FromProto(in.Scopes, in.Permissions)
+		permissions = CapPermissions(asked, super, acc.Verified, acc.Roles)
+
+		payload = &Payload{
+			Client:      client,
+			Permissions: permissions,
+			Super:       super,
+			Type:        typ,
+			Session:     uuid.NewString(),
+			Asked:       &asked,
+			Account:     acc.ID,
+		}
+	}
+
+	if has_totp {
+		var challenge Token
+		if challenge, err = s.tokener.GenMFAChallenge(ctx, payload); err != nil {
+			status = ErrorStatus(err, http.StatusInternalServerError)
+
+			return &proto.TokenValidation{
+				Validation: ErrorToValidation(err, &status),
+				Token:      nil,
+			}, err
 		}
 
+		status = http.StatusUnauthorized
+		return &proto.TokenValidation{
+			Validation: Invalid("A code from the authenticator app is needed to finish the log in.", &status),
+			Token:      nil,
+			Challenge:  (*string)(&challenge),
+		}, nil
+	}
+
+	if token, err = s.tokener.GenToken(ctx, payload); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.TokenValidation{
+			Validation: ErrorToValidation(err, &status),
+			Token:      nil,
+		}, err
+	}
+
+	var refresh Token
+	if refresh, err = s.tokener.GenRefreshToken(ctx, payload); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
 		return &proto.TokenValidation{
 			Validation: ErrorToValidation(err, &status),
 			Token:      nil,
 		}, err
 	}
 
-	permissions = Permission(in.Permissions)
+	// the log in works without it, it just isn't listed in the sessions
+	if claims, err := s.tokener.GetClaims(ctx, token); err != nil {
+		fmt.Printf("%v\n", err)
+	} else if err := s.tokener.PutSession(ctx, payload.Client, &Session{
+		ID:        payload.Session,
+		TokenID:   claims.ID,
+		IssuedAt:  claims.Issued(),
+		LastUsed:  claims.Issued(),
+		IP:        ClientIP(ctx),
+		UserAgent: UserAgent(ctx),
+		Type:      payload.Type,
+	}); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
 	status = http.StatusOK
-	if id := CredentialsToID(in.Credentials); id != nil {
-		validation = Valid(fmt.Sprintf("Credentials for `%s` were created with success!", *id), &status)
-	} else {
-		validation = Valid("Credentials were created with success!", &status)
+	validation = Valid(fmt.Sprintf("Credentials for `%s` were created with success!", client), &status)
+	if enrol {
+		validation = Valid(fmt.Sprintf("Logged in as `%s` without super permissions, enrol in two-factor authentication to use them.", client), &status)
 	}
 
+	legacy := permissions.Legacy()
 	return &proto.TokenValidation{
 		Validation: validation,
 		Token: &proto.Token{
 			Jwt:         string(token),
-			Permissions: (*uint64)(&permissions),
+			Permissions: &legacy,
+			Refresh:     (*string)(&refresh),
+			Scopes:      permissions.Scopes(),
 		},
 	}, err
 }
 
+// recap caps the payload of a refresh again to what its credentials have now,
+// they could have lost roles or super, or been deleted, since the log in.
+func (s *AuthServer) recap(ctx context.Context) func(*Payload) error {
+	return func(p *Payload) error {
+		acc, err := s.auther.Account(ctx, p.Client)
+		if err != nil {
+			if err, ok := err.(*mux.HttpError); ok && err.Status == http.StatusNotFound {
+				return &mux.HttpError{
+					Body:   "the credentials of the refresh token provided do not exist anymore",
+					Status: http.StatusUnauthorized,
+				}
+			}
+			return err
+		}
+
+		asked := p.Permissions
+		if p.Asked != nil {
+			asked = *p.Asked
+		}
+
+		p.Account = acc.ID
+		p.Super = p.Super && acc.Super
+		p.Permissions = CapPermissions(asked, p.Super, acc.Verified, acc.Roles)
+		return nil
+	}
+}
+
+func (s *AuthServer) Refresh(ctx context.Context, in *proto.Token) (*proto.TokenValidation, error) {
+	var status uint32
+
+	if in.GetRefresh() == "" {
+		status = http.StatusBadRequest
+		err := errors.New("did not recieve a refresh token")
+		return &proto.TokenValidation{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	access, refresh, err := s.tokener.Refresh(ctx, Token(in.GetRefresh()), s.recap(ctx))
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.TokenValidation{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	claims, err := s.tokener.GetClaims(ctx, access)
+	if err != nil {
+		status = http.StatusInternalServerError
+		return &proto.TokenValidation{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	permissions := claims.Permissions
+	legacy := permissions.Legacy()
+	status = http.StatusOK
+	return &proto.TokenValidation{
+		Validation: Valid("Tokens were refreshed with success!", &status),
+		Token: &proto.Token{
+			Jwt:         string(access),
+			Permissions: &legacy,
+			Refresh:     (*string)(&refresh),
+			Scopes:      permissions.Scopes(),
+		},
+	}, nil
+}
+
 func (s *AuthServer) LogOut(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
-	err := s.tokener.RevokeToken(ctx, Token(in.Jwt))
+	return s.logOut(ctx, in, s.tokener.RevokeToken, audit.LOG_OUT, "Logged out of this session with success!")
+}
+
+func (s *AuthServer) LogOutEverywhere(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
+	return s.logOut(ctx, in, s.tokener.RevokeAllTokens, audit.LOG_OUT_EVERYWHERE, "Logged out of every session with success!")
+}
+
+func (s *AuthServer) logOut(ctx context.Context, in *proto.Token, revoke func(context.Context, Token) error, action audit.Action, msg string) (*proto.Validation, error) {
+	actor := s.actor(ctx, in)
+	err := revoke(ctx, Token(in.Jwt))
 	var status uint32 = http.StatusOK
-	v := Valid("LogOut", &status)
+	v := Valid(msg, &status)
 	if err != nil {
-		status = http.StatusInternalServerError
-		if err, ok := err.(*mux.HttpError); ok {
-			status = uint32(err.Status)
-		}
+		status = ErrorStatus(err, http.StatusInternalServerError)
 
 		v = ErrorToValidation(err, &status)
 	}
+	s.record(ctx, action, actor, "", v, err)
 
 	return v, err
 }
@@ -215,19 +506,13 @@ func (s *AuthServer) IsSuper(ctx context.Context, in *proto.Credentials) (v *pro
 	case *proto.Credentials_Basic:
 		err = s.auther.CheckAccessBasic(ctx, x)
 		if err != nil {
-			status = http.StatusInternalServerError
-			if err, ok := err.(*mux.HttpError); ok {
-				status = uint32(err.Status)
-			}
+			status = ErrorStatus(err, http.StatusInternalServerError)
 
 			return ErrorToValidation(err, &status), err
 		}
 		super, err = s.auther.IsSuperBasic(ctx, x)
 		if err != nil {
-			status = http.StatusInternalServerError
-			if err, ok := err.(*mux.HttpError); ok {
-				status = uint32(err.Status)
-			}
+			status = ErrorStatus(err, http.StatusInternalServerError)
 
 			return ErrorToValidation(err, &status), err
 		}
@@ -248,8 +533,99 @@ func (s *AuthServer) IsSuper(ctx context.Context, in *proto.Credentials) (v *pro
 	}, nil
 }
 
+func (s *AuthServer) GrantSuper(ctx context.Context, in *proto.CredentialsWithToken) (*proto.Validation, error) {
+	v, err := s.super(ctx, in, true)
+	s.record(ctx, audit.GRANT_SUPER, s.actor(ctx, in.GetToken()), superTarget(in), v, err)
+
+	return v, err
+}
+
+func (s *AuthServer) RevokeSuper(ctx context.Context, in *proto.CredentialsWithToken) (*proto.Validation, error) {
+	v, err := s.super(ctx, in, false)
+	s.record(ctx, audit.REVOKE_SUPER, s.actor(ctx, in.GetToken()), superTarget(in), v, err)
+
+	return v, err
+}
+
+// superTarget is who the credentials of a super change are of.
+func superTarget(in *proto.CredentialsWithToken) string {
+	if in.GetCredentials() == nil {
+		return ""
+	}
+
+	if id := CredentialsToID(in.GetCredentials()); id != nil {
+		return *id
+	}
+
+	return ""
+}
+
+// super grants or revokes super to the credentials, which only super users
+// can do.
+func (s *AuthServer) super(ctx context.Context, in *proto.CredentialsWithToken, grant bool) (*proto.Validation, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return ErrorToValidation(err, &status), err
+	}
+
+	if !claims.Super {
+		status = http.StatusForbidden
+		err := errors.New("only super users can grant or revoke super")
+		return ErrorToValidation(err, &status), err
+	}
+
+	if in.GetCredentials() == nil {
+		status = http.StatusBadRequest
+		err := errors.New("credentials cannot be nil")
+		return ErrorToValidation(err, &status), err
+	}
+
+	if grant {
+		err = s.auther.GrantSuper(ctx, in.GetCredentials())
+	} else {
+		err = s.auther.RevokeSuper(ctx, in.GetCredentials())
+	}
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	target := superTarget(in)
+	if !grant {
+		// the tokens it already has are super
+		if err := s.tokener.RevokeSubject(ctx, target); err != nil {
+			status = ErrorStatus(err, http.StatusInternalServerError)
+
+			return ErrorToValidation(err, &status), err
+		}
+
+		status = http.StatusOK
+		return Valid(fmt.Sprintf("Super was revoked from `%s` with success!", target), &status), nil
+	}
+
+	status = http.StatusOK
+	return Valid(fmt.Sprintf("Super was granted to `%s` with success! It's in the tokens from the next log in.", target), &status), nil
+}
+
 func (s *AuthServer) Validate(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
-	valid, err := s.tokener.VerifyToken(ctx, Token(in.Jwt), Permission(*in.Permissions))
+	var (
+		valid bool
+		err   error
+		p     = FromProto(in.GetScopes(), in.GetPermissions())
+	)
+
+	if IsAPIKey(in.Jwt) {
+		var key *APIKey
+		if key, err = s.checkAPIKey(ctx, in.Jwt); err == nil {
+			if valid = key.Permissions.Has(p); !valid {
+				err = NoPermissionsError{Permission: p}
+			}
+		}
+	} else {
+		valid, err = s.tokener.VerifyToken(ctx, Token(in.Jwt), p)
+	}
+
 	if valid {
 		return &proto.Validation{
 			Valid: valid,
@@ -276,3 +652,734 @@ func (s *AuthServer) Validate(ctx context.Context, in *proto.Token) (*proto.Vali
 		Message: &msg,
 	}, err
 }
+
+// tokenSubject returns the claims of a token that has to be valid.
+func (s *AuthServer) tokenSubject(ctx context.Context, in *proto.Token) (*Claims, uint32, error) {
+	if in == nil {
+		return nil, http.StatusUnauthorized, errors.New("did not recieve a token")
+	}
+
+	if valid, err := s.tokener.VerifyToken(ctx, Token(in.Jwt), NIL); !valid {
+		status := ErrorStatus(err, http.StatusUnauthorized)
+		if err == nil {
+			err = errors.New("the token provided it's invalid")
+		}
+
+		return nil, status, err
+	}
+
+	claims, err := s.tokener.GetClaims(ctx, Token(in.Jwt))
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+
+	return claims, http.StatusOK, nil
+}
+
+func (s *AuthServer) EnrolTOTP(ctx context.Context, in *proto.Token) (*proto.TOTPEnrolment, error) {
+	claims, status, err := s.tokenSubject(ctx, in)
+	if err != nil {
+		return &proto.TOTPEnrolment{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	secret, err := s.auther.EnrolTOTP(ctx, claims.Subject)
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.TOTPEnrolment{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	status = http.StatusCreated
+	return &proto.TOTPEnrolment{
+		Validation: Valid("Add the secret to an authenticator app and confirm it with a code to finish.", &status),
+		Uri:        TOTPURI(claims.Subject, secret),
+		Secret:     secret,
+	}, nil
+}
+
+func (s *AuthServer) ConfirmTOTP(ctx context.Context, in *proto.TOTPCode) (*proto.RecoveryCodes, error) {
+	v, err := s.confirmTOTP(ctx, in)
+	s.record(ctx, audit.ENABLE_TOTP, s.actor(ctx, in.GetToken()), "", v.GetValidation(), err)
+
+	return v, err
+}
+
+func (s *AuthServer) confirmTOTP(ctx context.Context, in *proto.TOTPCode) (*proto.RecoveryCodes, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return &proto.RecoveryCodes{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	codes, err := s.auther.ConfirmTOTP(ctx, claims.Subject, in.GetCode())
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.RecoveryCodes{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	status = http.StatusOK
+	return &proto.RecoveryCodes{
+		Validation: Valid("Two-factor authentication was enabled with success! Keep the recovery codes somewhere safe, they won't be shown again.", &status),
+		Codes:      codes,
+	}, nil
+}
+
+func (s *AuthServer) DisableTOTP(ctx context.Context, in *proto.TOTPCode) (*proto.Validation, error) {
+	v, err := s.disableTOTP(ctx, in)
+	s.record(ctx, audit.DISABLE_TOTP, s.actor(ctx, in.GetToken()), "", v, err)
+
+	return v, err
+}
+
+func (s *AuthServer) disableTOTP(ctx context.Context, in *proto.TOTPCode) (*proto.Validation, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return ErrorToValidation(err, &status), err
+	}
+
+	if claims.Super {
+		status = http.StatusForbidden
+		err := errors.New("super users can't disable two-factor authentication")
+		return ErrorToValidation(err, &status), err
+	}
+
+	if err := s.auther.DisableTOTP(ctx, claims.Subject, in.GetCode()); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusOK
+	return Valid("Two-factor authentication was disabled with success!", &status), nil
+}
+
+// EmailLink is where the token sent by email is used, `auth.email.<purpose>.link`
+// with the token appended. By default it's the route of the auth service.
+func EmailLink(purpose EmailPurpose, tk Token) string {
+	email_route := conf.MustGetConfOrDefault("/email/", "auth", "paths", "email")
+	def := conf.MustGetConfOrDefault("", "auth", "domain") + strings.TrimSuffix(email_route, "/") + "/" + string(purpose) + "?token="
+
+	return conf.MustGetConfOrDefault(def, "auth", "email", string(purpose), "link") + url.QueryEscape(string(tk))
+}
+
+func (s *AuthServer) sendVerification(ctx context.Context, identifier string) error {
+	if s.mailer == nil {
+		return nil
+	}
+
+	tk, err := s.tokener.GenEmailToken(ctx, identifier, VERIFY_EMAIL, "")
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Mail(ctx, identifier, string(VERIFY_EMAIL), map[string]any{
+		"Link": EmailLink(VERIFY_EMAIL, tk),
+	})
+}
+
+func (s *AuthServer) SendVerification(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
+	claims, status, err := s.tokenSubject(ctx, in)
+	if err != nil {
+		return ErrorToValidation(err, &status), err
+	}
+
+	if err := s.sendVerification(ctx, claims.Subject); err != nil {
+		status = http.StatusInternalServerError
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusAccepted
+	return Valid(fmt.Sprintf("An email to verify `%s` was sent!", claims.Subject), &status), nil
+}
+
+func (s *AuthServer) VerifyEmail(ctx context.Context, in *proto.EmailToken) (*proto.Validation, error) {
+	var status uint32
+
+	identifier, _, err := s.tokener.ConsumeEmailToken(ctx, Token(in.GetToken()), VERIFY_EMAIL)
+	if err == nil {
+		err = s.auther.VerifyEmail(ctx, identifier)
+	}
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusOK
+	return Valid(fmt.Sprintf("`%s` was verified with success!", identifier), &status), nil
+}
+
+// RequestPasswordReset always answers the same, so it can't be used to find
+// out which emails have credentials.
+func (s *AuthServer) RequestPasswordReset(ctx context.Context, in *proto.Email) (*proto.Validation, error) {
+	var status uint32 = http.StatusAccepted
+	v := Valid("If there are credentials for the email, an email to reset the password was sent to it.", &status)
+
+	if s.mailer == nil {
+		return v, nil
+	}
+
+	exists, err := s.auther.HasPassword(ctx, in.GetEmail())
+	if err != nil || !exists {
+		if err != nil {
+			fmt.Printf("%v\n", err)
+		}
+		return v, nil
+	}
+
+	tk, err := s.tokener.GenEmailToken(ctx, in.GetEmail(), RESET_PASSWORD, "")
+	if err == nil {
+		err = s.mailer.Mail(ctx, in.GetEmail(), string(RESET_PASSWORD), map[string]any{
+			"Link":    EmailLink(RESET_PASSWORD, tk),
+			"Minutes": int(RESET_PASSWORD.Exp().Minutes()),
+		})
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	return v, nil
+}
+
+func (s *AuthServer) ResetPassword(ctx context.Context, in *proto.EmailToken) (*proto.Validation, error) {
+	var status uint32
+
+	if in.GetPassword() == "" {
+		status = http.StatusUnprocessableEntity
+		err := errors.New("did not recieve the new password")
+		return ErrorToValidation(err, &status), err
+	}
+
+	identifier, _, err := s.tokener.ConsumeEmailToken(ctx, Token(in.GetToken()), RESET_PASSWORD)
+	if err == nil {
+		err = s.auther.ResetPassword(ctx, identifier, in.GetPassword())
+	}
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	// whoever knew the old password is logged out
+	if err := s.tokener.RevokeSubject(ctx, identifier); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusOK
+	return Valid("The password was reset with success!", &status), nil
+}
+
+func (s *AuthServer) ChangePassword(ctx context.Context, in *proto.PasswordChange) (*proto.Validation, error) {
+	// the tokens are revoked by it
+	actor := s.actor(ctx, in.GetToken())
+	v, err := s.changePassword(ctx, in)
+	s.record(ctx, audit.CHANGE_PASSWORD, actor, "", v, err)
+
+	return v, err
+}
+
+func (s *AuthServer) changePassword(ctx context.Context, in *proto.PasswordChange) (*proto.Validation, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return ErrorToValidation(err, &status), err
+	}
+
+	if in.GetNewPassword() == "" {
+		status = http.StatusUnprocessableEntity
+		err := errors.New("did not recieve the new password")
+		return ErrorToValidation(err, &status), err
+	}
+
+	if err := s.auther.ChangePassword(ctx, &proto.Credentials_Basic{
+		Basic: &proto.Credentials_BasicCredentials{
+			Email:    claims.Subject,
+			Password: in.GetOldPassword(),
+		},
+	}, in.GetNewPassword()); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	if err := s.tokener.RevokeSubject(ctx, claims.Subject); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusOK
+	return Valid("The password was changed with success! Log in again with it.", &status), nil
+}
+
+// ChangeEmail sends a link to the new email, it's only changed when it's
+// followed.
+func (s *AuthServer) ChangeEmail(ctx context.Context, in *proto.EmailChange) (*proto.Validation, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return ErrorToValidation(err, &status), err
+	}
+
+	if s.mailer == nil {
+		status = http.StatusNotImplemented
+		err := errors.New("there's no way to send the email to confirm the change")
+		return ErrorToValidation(err, &status), err
+	}
+
+	err = s.auther.CheckNewEmail(ctx, claims.Subject, in.GetEmail())
+	if err == nil {
+		var tk Token
+		if tk, err = s.tokener.GenEmailToken(ctx, claims.Subject, CHANGE_EMAIL, in.GetEmail()); err == nil {
+			err = s.mailer.Mail(ctx, in.GetEmail(), string(CHANGE_EMAIL), map[string]any{
+				"Link": EmailLink(CHANGE_EMAIL, tk),
+				"From": claims.Subject,
+			})
+		}
+	}
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusAccepted
+	return Valid(fmt.Sprintf("An email to confirm the change was sent to `%s`!", in.GetEmail()), &status), nil
+}
+
+func (s *AuthServer) ConfirmEmailChange(ctx context.Context, in *proto.EmailToken) (*proto.Validation, error) {
+	var status uint32
+
+	identifier, email, err := s.tokener.ConsumeEmailToken(ctx, Token(in.GetToken()), CHANGE_EMAIL)
+	if err == nil {
+		err = s.auther.ChangeEmail(ctx, identifier, email)
+	}
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	// the tokens have the old email as subject
+	if err := s.tokener.RevokeSubject(ctx, identifier); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusOK
+	return Valid(fmt.Sprintf("The email was changed to `%s` with success! Log in again with it.", email), &status), nil
+}
+
+func (s *AuthServer) GetRoles(ctx context.Context, in *proto.RoleAssignment) (*proto.Roles, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return &proto.Roles{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	if !claims.Super && claims.Subject != in.GetIdentifier() {
+		status = http.StatusForbidden
+		err := errors.New("only super users can see the roles of others")
+		return &proto.Roles{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	roles, err := s.auther.Roles(ctx, in.GetIdentifier())
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.Roles{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	status = http.StatusOK
+	return &proto.Roles{
+		Validation: Valid("", &status),
+		Roles:      roles,
+	}, nil
+}
+
+func (s *AuthServer) GrantRole(ctx context.Context, in *proto.RoleAssignment) (*proto.Validation, error) {
+	v, err := s.assignRole(ctx, in, true)
+	s.record(ctx, audit.GRANT_ROLE, s.actor(ctx, in.GetToken()), fmt.Sprintf("%s:%s", in.GetIdentifier(), in.GetRole()), v, err)
+
+	return v, err
+}
+
+func (s *AuthServer) RevokeRole(ctx context.Context, in *proto.RoleAssignment) (*proto.Validation, error) {
+	v, err := s.assignRole(ctx, in, false)
+	s.record(ctx, audit.REVOKE_ROLE, s.actor(ctx, in.GetToken()), fmt.Sprintf("%s:%s", in.GetIdentifier(), in.GetRole()), v, err)
+
+	return v, err
+}
+
+// assignRole grants or revokes a role, which only super users can do.
+func (s *AuthServer) assignRole(ctx context.Context, in *proto.RoleAssignment, grant bool) (*proto.Validation, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return ErrorToValidation(err, &status), err
+	}
+
+	if !claims.Super {
+		status = http.StatusForbidden
+		err := errors.New("only super users can assign roles")
+		return ErrorToValidation(err, &status), err
+	}
+
+	role := in.GetRole()
+	if role == ROLE_USER || !IsRole(role) {
+		status = http.StatusUnprocessableEntity
+		err := fmt.Errorf("`%s` is not a role that can be assigned, use one of `%s`", role, strings.Join(RoleNames(), "`, `"))
+		return ErrorToValidation(err, &status), err
+	}
+
+	if grant {
+		err = s.auther.GrantRole(ctx, in.GetIdentifier(), role, claims.Subject)
+	} else {
+		err = s.auther.RevokeRole(ctx, in.GetIdentifier(), role)
+	}
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	if !grant {
+		// the tokens it already has could have the scopes of the role
+		if err := s.tokener.RevokeSubject(ctx, in.GetIdentifier()); err != nil {
+			status = ErrorStatus(err, http.StatusInternalServerError)
+
+			return ErrorToValidation(err, &status), err
+		}
+
+		status = http.StatusOK
+		return Valid(fmt.Sprintf("The role `%s` was revoked from `%s` with success!", role, in.GetIdentifier()), &status), nil
+	}
+
+	status = http.StatusOK
+	return Valid(fmt.Sprintf("The role `%s` was granted to `%s` with success! It's in the tokens from the next log in.", role, in.GetIdentifier()), &status), nil
+}
+
+// Introspect returns the claims of a token or an API key.
+func (s *AuthServer) Introspect(ctx context.Context, in *proto.Token) (*proto.Introspection, error) {
+	var (
+		claims *Claims
+		status uint32
+		err    error
+	)
+
+	if IsAPIKey(in.GetJwt()) {
+		var key *APIKey
+		if key, err = s.checkAPIKey(ctx, in.GetJwt()); err != nil {
+			status = ErrorStatus(err, http.StatusUnauthorized)
+		} else {
+			claims = key.Claims()
+		}
+	} else {
+		claims, status, err = s.tokenSubject(ctx, in)
+	}
+	if err != nil {
+		return &proto.Introspection{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	status = http.StatusOK
+	i := &proto.Introspection{
+		Validation: Valid("", &status),
+		Subject:    claims.Subject,
+		Scopes:     claims.Permissions.Scopes(),
+		Super:      claims.Super,
+		Type:       uint32(claims.Type),
+		Id:         claims.ID,
+		Account:    claims.AccountID(),
+	}
+	if claims.ExpiresAt != nil {
+		expires := claims.ExpiresAt.Unix()
+		i.Expires = &expires
+	}
+	if claims.Session != "" {
+		i.Session = &claims.Session
+	}
+
+	return i, nil
+}
+
+// checkAPIKey is CheckAPIKey, with the keys made before every token of their
+// subject was revoked, like when the password changes, revoked too.
+func (s *AuthServer) checkAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	k, err := s.auther.CheckAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	since, err := s.tokener.RevokedSince(ctx, k.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if since != nil && !k.CreatedAt.After(*since) {
+		return nil, &mux.HttpError{
+			Body:   "the API key was revoked",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	return k, nil
+}
+
+func apiKeyToProto(k *APIKey) *proto.APIKey {
+	key := &proto.APIKey{
+		Prefix:  k.Prefix,
+		Label:   k.Label,
+		Scopes:  k.Permissions.Scopes(),
+		Created: k.CreatedAt.Unix(),
+	}
+
+	if !k.ExpiresAt.IsZero() {
+		expires := k.ExpiresAt.Unix()
+		key.Expires = &expires
+	}
+
+	if !k.LastUsed.IsZero() {
+		last_used := k.LastUsed.Unix()
+		key.LastUsed = &last_used
+	}
+
+	return key
+}
+
+// CreateAPIKey makes a key with some of the permissions of the token, up to
+// `auth.api_keys.max` for each credentials.
+func (s *AuthServer) CreateAPIKey(ctx context.Context, in *proto.APIKeyRequest) (*proto.APIKeyValidation, error) {
+	v, err := s.createAPIKey(ctx, in)
+	s.record(ctx, audit.CREATE_API_KEY, s.actor(ctx, in.GetToken()), v.GetKey().GetPrefix(), v.GetValidation(), err)
+
+	return v, err
+}
+
+// apiKeyPermissions are the permissions of the key the request asks for,
+// which can't be more than the ones of the claims of who asks for it.
+func apiKeyPermissions(claims *Claims, in *proto.APIKeyRequest) (Permission, uint32, error) {
+	permissions, err := ParseScopes(in.GetScopes())
+	if err == nil && permissions == NIL {
+		err = errors.New("an API key needs at least one scope")
+	}
+	if err == nil && len([]rune(in.GetLabel())) > 80 {
+		err = errors.New("the label can't have more than 80 characters")
+	}
+	if err == nil && in.Expires != nil && time.Unix(in.GetExpires(), 0).Before(time.Now()) {
+		err = errors.New("the API key can't expire in the past")
+	}
+	if err != nil {
+		return NIL, http.StatusUnprocessableEntity, err
+	}
+
+	if !claims.Permissions.Has(permissions) {
+		return NIL, http.StatusForbidden, fmt.Errorf("an API key can't have more permissions than the token that creates it, that doesn't have `%s`", permissions.Without(claims.Permissions))
+	}
+
+	return permissions, http.StatusOK, nil
+}
+
+func (s *AuthServer) createAPIKey(ctx context.Context, in *proto.APIKeyRequest) (*proto.APIKeyValidation, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return &proto.APIKeyValidation{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	permissions, status, err := apiKeyPermissions(claims, in)
+	if err != nil {
+		return &proto.APIKeyValidation{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	keys, err := s.auther.APIKeys(ctx, claims.Subject)
+	if err == nil && len(keys) >= int(conf.MustGetConfOrDefault[float64](25, "auth", "api_keys", "max")) {
+		err = &mux.HttpError{
+			Body:   "there are too many API keys, revoke one of them first",
+			Status: http.StatusConflict,
+		}
+	}
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.APIKeyValidation{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	str, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		status = http.StatusInternalServerError
+		return &proto.APIKeyValidation{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	key := &APIKey{
+		Prefix:      prefix,
+		Identifier:  claims.Subject,
+		Label:       in.GetLabel(),
+		Permissions: permissions,
+		CreatedAt:   time.Now(),
+	}
+	if in.Expires != nil {
+		key.ExpiresAt = time.Unix(in.GetExpires(), 0)
+	}
+
+	if err := s.auther.CreateAPIKey(ctx, key, hash); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.APIKeyValidation{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	res := apiKeyToProto(key)
+	res.Key = &str
+
+	status = http.StatusCreated
+	return &proto.APIKeyValidation{
+		Validation: Valid("The API key was created with success! Keep it safe, it won't be shown again.", &status),
+		Key:        res,
+	}, nil
+}
+
+func (s *AuthServer) ListAPIKeys(ctx context.Context, in *proto.Token) (*proto.APIKeys, error) {
+	claims, status, err := s.tokenSubject(ctx, in)
+	if err != nil {
+		return &proto.APIKeys{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	keys, err := s.auther.APIKeys(ctx, claims.Subject)
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.APIKeys{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	list := make([]*proto.APIKey, 0, len(keys))
+	for i := range keys {
+		list = append(list, apiKeyToProto(&keys[i]))
+	}
+
+	status = http.StatusOK
+	return &proto.APIKeys{
+		Validation: Valid("", &status),
+		Keys:       list,
+	}, nil
+}
+
+func (s *AuthServer) RevokeAPIKey(ctx context.Context, in *proto.APIKeyRevocation) (*proto.Validation, error) {
+	v, err := s.revokeAPIKey(ctx, in)
+	s.record(ctx, audit.REVOKE_API_KEY, s.actor(ctx, in.GetToken()), in.GetPrefix(), v, err)
+
+	return v, err
+}
+
+func (s *AuthServer) revokeAPIKey(ctx context.Context, in *proto.APIKeyRevocation) (*proto.Validation, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return ErrorToValidation(err, &status), err
+	}
+
+	if err := s.auther.RevokeAPIKey(ctx, claims.Subject, in.GetPrefix()); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusOK
+	return Valid(fmt.Sprintf("The API key `%s` was revoked with success!", in.GetPrefix()), &status), nil
+}
+
+func (s *AuthServer) ListSessions(ctx context.Context, in *proto.Token) (*proto.Sessions, error) {
+	claims, status, err := s.tokenSubject(ctx, in)
+	if err != nil {
+		return &proto.Sessions{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	sessions, err := s.tokener.Sessions(ctx, claims.Subject)
+	if err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return &proto.Sessions{
+			Validation: ErrorToValidation(err, &status),
+		}, err
+	}
+
+	list := make([]*proto.Session, 0, len(sessions))
+	for _, i := range sessions {
+		list = append(list, &proto.Session{
+			Id:        i.ID,
+			Jti:       i.TokenID,
+			Issued:    i.IssuedAt.Unix(),
+			LastUsed:  i.LastUsed.Unix(),
+			Ip:        i.IP,
+			UserAgent: i.UserAgent,
+			Type:      uint32(i.Type),
+			Current:   i.ID == claims.Session,
+		})
+	}
+
+	status = http.StatusOK
+	return &proto.Sessions{
+		Validation: Valid("", &status),
+		Sessions:   list,
+	}, nil
+}
+
+// RevokeSession logs out of one of the sessions of the token's credentials,
+// the device it was from can't refresh or use its tokens after it.
+func (s *AuthServer) RevokeSession(ctx context.Context, in *proto.SessionRevocation) (*proto.Validation, error) {
+	// the token can be of the session that is revoked
+	actor := s.actor(ctx, in.GetToken())
+	v, err := s.revokeSession(ctx, in)
+	s.record(ctx, audit.REVOKE_SESSION, actor, in.GetId(), v, err)
+
+	return v, err
+}
+
+func (s *AuthServer) revokeSession(ctx context.Context, in *proto.SessionRevocation) (*proto.Validation, error) {
+	claims, status, err := s.tokenSubject(ctx, in.GetToken())
+	if err != nil {
+		return ErrorToValidation(err, &status), err
+	}
+
+	if in.GetId() == "" {
+		status = http.StatusBadRequest
+		err = errors.New("did not recieve the session to revoke")
+		return ErrorToValidation(err, &status), err
+	}
+
+	if err := s.tokener.RevokeSession(ctx, claims.Subject, in.GetId()); err != nil {
+		status = ErrorStatus(err, http.StatusInternalServerError)
+
+		return ErrorToValidation(err, &status), err
+	}
+
+	status = http.StatusOK
+	return Valid(fmt.Sprintf("Logged out of the session `%s` with success!", in.GetId()), &status), nil
+}
@@ -0,0 +1,820 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokens takes each token as the claims it has in claims, the email tokens
+// as the ones in emails and the sessions of each subject as the ones in
+// sessions, and records the subjects it revokes. RevokeSubject fails with
+// revokeErr when it's set.
+type tokens struct {
+	Tokener
+	claims    map[Token]*Claims
+	emails    map[Token]emailToken
+	sessions  map[string][]Session
+	revoked   []string
+	revokeErr error
+}
+
+type emailToken struct {
+	identifier string
+	purpose    EmailPurpose
+	data       string
+}
+
+func (t *tokens) VerifyToken(ctx context.Context, tk Token, p Permission) (bool, error) {
+	claims, ok := t.claims[tk]
+	if !ok {
+		return false, nil
+	}
+	if !claims.Permissions.Has(p) {
+		return false, NoPermissionsError{Permission: p}
+	}
+
+	return true, nil
+}
+
+func (t *tokens) GetClaims(ctx context.Context, tk Token) (*Claims, error) {
+	claims, ok := t.claims[tk]
+	if !ok {
+		return nil, errors.New("not a token")
+	}
+
+	return claims, nil
+}
+
+func (t *tokens) GenEmailToken(ctx context.Context, identifier string, purpose EmailPurpose, data string) (Token, error) {
+	if t.emails == nil {
+		t.emails = map[Token]emailToken{}
+	}
+
+	tk := Token(fmt.Sprintf("%s-%d", purpose, len(t.emails)+1))
+	t.emails[tk] = emailToken{identifier, purpose, data}
+	return tk, nil
+}
+
+func (t *tokens) ConsumeEmailToken(ctx context.Context, tk Token, purpose EmailPurpose) (string, string, error) {
+	stored, ok := t.emails[tk]
+	if !ok || stored.purpose != purpose {
+		return "", "", &mux.HttpError{Body: "the token provided it's invalid, expired or was already used", Status: http.StatusUnauthorized}
+	}
+
+	delete(t.emails, tk)
+	return stored.identifier, stored.data, nil
+}
+
+func (t *tokens) Sessions(ctx context.Context, sub string) ([]Session, error) {
+	return t.sessions[sub], nil
+}
+
+func (t *tokens) RevokeSession(ctx context.Context, sub string, id string) error {
+	for i, session := range t.sessions[sub] {
+		if session.ID == id {
+			t.sessions[sub] = append(t.sessions[sub][:i], t.sessions[sub][i+1:]...)
+			return nil
+		}
+	}
+
+	return &mux.HttpError{Body: "the session does not exist", Status: http.StatusNotFound}
+}
+
+func (t *tokens) RevokeSubject(ctx context.Context, sub string) error {
+	if t.revokeErr != nil {
+		return t.revokeErr
+	}
+
+	t.revoked = append(t.revoked, sub)
+	return nil
+}
+
+// credentials records what's done to the credentials, failing with err when
+// it's set. The ones in passwords have a password, and the ones in roles the
+// roles.
+type credentials struct {
+	Auther
+	passwords map[string]string
+	roles     map[string][]string
+	signedOut []string
+	verified  []string
+	err       error
+}
+
+func (c *credentials) SignOut(ctx context.Context, identifier string, typ AuthType) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.signedOut = append(c.signedOut, identifier)
+	return nil
+}
+
+func (c *credentials) HasPassword(ctx context.Context, identifier string) (bool, error) {
+	_, ok := c.passwords[identifier]
+	return ok, c.err
+}
+
+func (c *credentials) VerifyEmail(ctx context.Context, identifier string) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.verified = append(c.verified, identifier)
+	return nil
+}
+
+func (c *credentials) ResetPassword(ctx context.Context, identifier string, password string) error {
+	if _, ok := c.passwords[identifier]; !ok {
+		return &mux.HttpError{Body: "credentials do not exist", Status: http.StatusNotFound}
+	}
+
+	c.passwords[identifier] = password
+	return nil
+}
+
+func (c *credentials) ChangePassword(ctx context.Context, basic *proto.Credentials_Basic, password string) error {
+	if c.passwords[basic.Basic.GetEmail()] != basic.Basic.GetPassword() {
+		return &mux.HttpError{Body: "wrong credentials", Status: http.StatusUnauthorized}
+	}
+
+	c.passwords[basic.Basic.GetEmail()] = password
+	return nil
+}
+
+func (c *credentials) CheckNewEmail(ctx context.Context, identifier string, email string) error {
+	if _, ok := c.passwords[email]; ok {
+		return &mux.HttpError{Body: "credentials already in use", Status: http.StatusConflict}
+	}
+
+	return nil
+}
+
+func (c *credentials) ChangeEmail(ctx context.Context, identifier string, email string) error {
+	if err := c.CheckNewEmail(ctx, identifier, email); err != nil {
+		return err
+	}
+
+	c.passwords[email] = c.passwords[identifier]
+	delete(c.passwords, identifier)
+	return nil
+}
+
+func (c *credentials) Roles(ctx context.Context, identifier string) ([]string, error) {
+	return c.roles[identifier], c.err
+}
+
+func (c *credentials) GrantRole(ctx context.Context, identifier string, role string, by string) error {
+	if _, ok := c.roles[identifier]; !ok {
+		return &mux.HttpError{Body: "credentials do not exist", Status: http.StatusNotFound}
+	}
+
+	c.roles[identifier] = append(c.roles[identifier], role)
+	return nil
+}
+
+func (c *credentials) RevokeRole(ctx context.Context, identifier string, role string) error {
+	roles := []string{}
+	for _, i := range c.roles[identifier] {
+		if i != role {
+			roles = append(roles, i)
+		}
+	}
+
+	c.roles[identifier] = roles
+	return nil
+}
+
+// mails records the emails sent, as the address, the template and the link.
+type mails struct {
+	sent []string
+}
+
+func (m *mails) Mail(ctx context.Context, to string, template string, data map[string]any) error {
+	m.sent = append(m.sent, fmt.Sprintf("%s %s %v", to, template, data["Link"]))
+	return nil
+}
+
+// tokenOf is the claims of a token of sub with the permissions.
+func tokenOf(sub string, p Permission) *Claims {
+	return &Claims{
+		Payload:          Payload{Client: sub, Permissions: p},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: sub},
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		signOut   error
+		revoke    error
+		status    uint32
+		signedOut []string
+		revoked   []string
+	}{
+		{
+			name:      "signs out",
+			token:     "user",
+			status:    http.StatusOK,
+			signedOut: []string{"user@example.com"},
+			revoked:   []string{"user@example.com"},
+		},
+		{
+			name:   "not a token",
+			token:  "forged",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "without the permission",
+			token:  "reader",
+			status: http.StatusForbidden,
+		},
+		{
+			name:    "no credentials",
+			token:   "user",
+			signOut: &mux.HttpError{Status: http.StatusNotFound},
+			status:  http.StatusNotFound,
+		},
+		{
+			name:      "tokens not revoked",
+			token:     "user",
+			revoke:    errors.New("the KV store is down"),
+			status:    http.StatusInternalServerError,
+			signedOut: []string{"user@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := &tokens{claims: map[Token]*Claims{
+				"user":   tokenOf("user@example.com", SIGN_OUT),
+				"reader": tokenOf("user@example.com", READ_PROFILE),
+			}, revokeErr: tt.revoke}
+			creds := &credentials{err: tt.signOut}
+			s := NewAuthServer(creds, tk, nil, nil)
+
+			v, err := s.SignOut(context.Background(), &proto.Token{Jwt: tt.token})
+			if v.GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusOK) || v.GetValid() != (err == nil) {
+				t.Errorf("SignOut() = (%v, %v), want a %d", v, err, tt.status)
+			}
+
+			if !reflect.DeepEqual(creds.signedOut, tt.signedOut) {
+				t.Errorf("SignOut() deleted the credentials of %v, want %v", creds.signedOut, tt.signedOut)
+			}
+			if !reflect.DeepEqual(tk.revoked, tt.revoked) {
+				t.Errorf("SignOut() revoked the tokens of %v, want %v", tk.revoked, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tk := &tokens{emails: map[Token]emailToken{
+		"verify": {identifier: "user@example.com", purpose: VERIFY_EMAIL},
+		"reset":  {identifier: "user@example.com", purpose: RESET_PASSWORD},
+	}}
+	creds := &credentials{}
+	s := NewAuthServer(creds, tk, nil, nil)
+
+	tests := []struct {
+		name   string
+		token  string
+		status uint32
+	}{
+		{"verifies", "verify", http.StatusOK},
+		{"already used", "verify", http.StatusUnauthorized},
+		{"for another purpose", "reset", http.StatusUnauthorized},
+		{"forged", "forged", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		v, err := s.VerifyEmail(context.Background(), &proto.EmailToken{Token: tt.token})
+		if v.GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusOK) {
+			t.Errorf("VerifyEmail() %s = (%v, %v), want a %d", tt.name, v, err, tt.status)
+		}
+	}
+
+	if want := []string{"user@example.com"}; !reflect.DeepEqual(creds.verified, want) {
+		t.Errorf("VerifyEmail() verified %v, want %v", creds.verified, want)
+	}
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	withConf(t, `{"auth": {"domain": "https://auth.example.com"}}`)
+
+	tk := &tokens{}
+	m := &mails{}
+	s := NewAuthServer(&credentials{passwords: map[string]string{"user@example.com": "old"}}, tk, m, nil)
+
+	// it answers the same with or without credentials
+	for _, email := range []string{"user@example.com", "nobody@example.com"} {
+		v, err := s.RequestPasswordReset(context.Background(), &proto.Email{Email: email})
+		if err != nil || v.GetHttpStatusCode() != http.StatusAccepted {
+			t.Errorf("RequestPasswordReset(%q) = (%v, %v), want a 202", email, v, err)
+		}
+	}
+
+	if want := []string{"user@example.com reset https://auth.example.com/email/reset?token=reset-1"}; !reflect.DeepEqual(m.sent, want) {
+		t.Errorf("RequestPasswordReset() sent %q, want %q", m.sent, want)
+	}
+	if got := tk.emails["reset-1"]; got.identifier != "user@example.com" || got.purpose != RESET_PASSWORD {
+		t.Errorf("RequestPasswordReset() made the token %+v", got)
+	}
+
+	// without a mailer there's nothing to send the token with
+	s = NewAuthServer(&credentials{passwords: map[string]string{"user@example.com": "old"}}, tk, nil, nil)
+	if v, err := s.RequestPasswordReset(context.Background(), &proto.Email{Email: "user@example.com"}); err != nil || v.GetHttpStatusCode() != http.StatusAccepted {
+		t.Errorf("RequestPasswordReset() without a mailer = (%v, %v), want a 202", v, err)
+	}
+	if len(tk.emails) != 1 {
+		t.Errorf("RequestPasswordReset() without a mailer made a token")
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		revoke   error
+		status   uint32
+		passwd   string
+		revoked  []string
+	}{
+		{
+			name:     "resets",
+			token:    "reset",
+			password: "new",
+			status:   http.StatusOK,
+			passwd:   "new",
+			revoked:  []string{"user@example.com"},
+		},
+		{
+			name:   "without the password",
+			token:  "reset",
+			status: http.StatusUnprocessableEntity,
+			passwd: "old",
+		},
+		{
+			name:     "for another purpose",
+			token:    "verify",
+			password: "new",
+			status:   http.StatusUnauthorized,
+			passwd:   "old",
+		},
+		{
+			name:     "no credentials",
+			token:    "nobody",
+			password: "new",
+			status:   http.StatusNotFound,
+			passwd:   "old",
+		},
+		{
+			name:     "tokens not revoked",
+			token:    "reset",
+			password: "new",
+			revoke:   errors.New("the KV store is down"),
+			status:   http.StatusInternalServerError,
+			passwd:   "new",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := &tokens{emails: map[Token]emailToken{
+				"reset":  {identifier: "user@example.com", purpose: RESET_PASSWORD},
+				"verify": {identifier: "user@example.com", purpose: VERIFY_EMAIL},
+				"nobody": {identifier: "nobody@example.com", purpose: RESET_PASSWORD},
+			}, revokeErr: tt.revoke}
+			creds := &credentials{passwords: map[string]string{"user@example.com": "old"}}
+			s := NewAuthServer(creds, tk, nil, nil)
+
+			v, err := s.ResetPassword(context.Background(), &proto.EmailToken{Token: tt.token, Password: &tt.password})
+			if v.GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusOK) {
+				t.Errorf("ResetPassword() = (%v, %v), want a %d", v, err, tt.status)
+			}
+
+			if creds.passwords["user@example.com"] != tt.passwd {
+				t.Errorf("ResetPassword() set the password to %q, want %q", creds.passwords["user@example.com"], tt.passwd)
+			}
+			if !reflect.DeepEqual(tk.revoked, tt.revoked) {
+				t.Errorf("ResetPassword() revoked the tokens of %v, want %v", tk.revoked, tt.revoked)
+			}
+		})
+	}
+
+	// the token can't be used again
+	tk := &tokens{emails: map[Token]emailToken{"reset": {identifier: "user@example.com", purpose: RESET_PASSWORD}}}
+	s := NewAuthServer(&credentials{passwords: map[string]string{"user@example.com": "old"}}, tk, nil, nil)
+	password := "new"
+	for i, want := range []uint32{http.StatusOK, http.StatusUnauthorized} {
+		if v, _ := s.ResetPassword(context.Background(), &proto.EmailToken{Token: "reset", Password: &password}); v.GetHttpStatusCode() != want {
+			t.Errorf("ResetPassword() %d = %v, want a %d", i+1, v, want)
+		}
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		old     string
+		new     string
+		revoke  error
+		status  uint32
+		passwd  string
+		revoked []string
+	}{
+		{
+			name:    "changes",
+			token:   "user",
+			old:     "old",
+			new:     "new",
+			status:  http.StatusOK,
+			passwd:  "new",
+			revoked: []string{"user@example.com"},
+		},
+		{
+			name:   "not a token",
+			token:  "forged",
+			old:    "old",
+			new:    "new",
+			status: http.StatusUnauthorized,
+			passwd: "old",
+		},
+		{
+			name:   "without the new password",
+			token:  "user",
+			old:    "old",
+			status: http.StatusUnprocessableEntity,
+			passwd: "old",
+		},
+		{
+			name:   "wrong password",
+			token:  "user",
+			old:    "wrong",
+			new:    "new",
+			status: http.StatusUnauthorized,
+			passwd: "old",
+		},
+		{
+			name:   "tokens not revoked",
+			token:  "user",
+			old:    "old",
+			new:    "new",
+			revoke: errors.New("the KV store is down"),
+			status: http.StatusInternalServerError,
+			passwd: "new",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := &tokens{claims: map[Token]*Claims{"user": tokenOf("user@example.com", READ_PROFILE)}, revokeErr: tt.revoke}
+			creds := &credentials{passwords: map[string]string{"user@example.com": "old"}}
+			s := NewAuthServer(creds, tk, nil, nil)
+
+			v, err := s.ChangePassword(context.Background(), &proto.PasswordChange{Token: &proto.Token{Jwt: tt.token}, OldPassword: tt.old, NewPassword: tt.new})
+			if v.GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusOK) {
+				t.Errorf("ChangePassword() = (%v, %v), want a %d", v, err, tt.status)
+			}
+
+			if creds.passwords["user@example.com"] != tt.passwd {
+				t.Errorf("ChangePassword() set the password to %q, want %q", creds.passwords["user@example.com"], tt.passwd)
+			}
+			if !reflect.DeepEqual(tk.revoked, tt.revoked) {
+				t.Errorf("ChangePassword() revoked the tokens of %v, want %v", tk.revoked, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	withConf(t, `{"auth": {"domain": "https://auth.example.com"}}`)
+
+	tests := []struct {
+		name   string
+		token  string
+		email  string
+		mailer bool
+		status uint32
+		sent   []string
+	}{
+		{"sends the link", "user", "new@example.com", true, http.StatusAccepted, []string{"new@example.com change https://auth.example.com/email/change?token=change-1"}},
+		{"not a token", "forged", "new@example.com", true, http.StatusUnauthorized, nil},
+		{"in use", "user", "taken@example.com", true, http.StatusConflict, nil},
+		{"without a mailer", "user", "new@example.com", false, http.StatusNotImplemented, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := &tokens{claims: map[Token]*Claims{"user": tokenOf("user@example.com", READ_PROFILE)}}
+			creds := &credentials{passwords: map[string]string{"user@example.com": "old", "taken@example.com": "other"}}
+			m := &mails{}
+			s := NewAuthServer(creds, tk, m, nil)
+			if !tt.mailer {
+				s = NewAuthServer(creds, tk, nil, nil)
+			}
+
+			v, err := s.ChangeEmail(context.Background(), &proto.EmailChange{Token: &proto.Token{Jwt: tt.token}, Email: tt.email})
+			if v.GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusAccepted) {
+				t.Errorf("ChangeEmail() = (%v, %v), want a %d", v, err, tt.status)
+			}
+			if !reflect.DeepEqual(m.sent, tt.sent) {
+				t.Errorf("ChangeEmail() sent %q, want %q", m.sent, tt.sent)
+			}
+
+			// the email only changes when the link is followed
+			if _, ok := creds.passwords["user@example.com"]; !ok {
+				t.Errorf("ChangeEmail() changed the email")
+			}
+			if tt.sent != nil {
+				if got := tk.emails["change-1"]; got.identifier != "user@example.com" || got.data != tt.email {
+					t.Errorf("ChangeEmail() made the token %+v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		revoke  error
+		status  uint32
+		emails  []string
+		revoked []string
+	}{
+		{
+			name:    "changes",
+			token:   "change",
+			status:  http.StatusOK,
+			emails:  []string{"new@example.com", "taken@example.com"},
+			revoked: []string{"user@example.com"},
+		},
+		{
+			name:   "for another purpose",
+			token:  "verify",
+			status: http.StatusUnauthorized,
+			emails: []string{"taken@example.com", "user@example.com"},
+		},
+		{
+			name:   "taken since",
+			token:  "taken",
+			status: http.StatusConflict,
+			emails: []string{"taken@example.com", "user@example.com"},
+		},
+		{
+			name:   "tokens not revoked",
+			token:  "change",
+			revoke: errors.New("the KV store is down"),
+			status: http.StatusInternalServerError,
+			emails: []string{"new@example.com", "taken@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := &tokens{emails: map[Token]emailToken{
+				"change": {identifier: "user@example.com", purpose: CHANGE_EMAIL, data: "new@example.com"},
+				"taken":  {identifier: "user@example.com", purpose: CHANGE_EMAIL, data: "taken@example.com"},
+				"verify": {identifier: "user@example.com", purpose: VERIFY_EMAIL},
+			}, revokeErr: tt.revoke}
+			creds := &credentials{passwords: map[string]string{"user@example.com": "old", "taken@example.com": "other"}}
+			s := NewAuthServer(creds, tk, nil, nil)
+
+			v, err := s.ConfirmEmailChange(context.Background(), &proto.EmailToken{Token: tt.token})
+			if v.GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusOK) {
+				t.Errorf("ConfirmEmailChange() = (%v, %v), want a %d", v, err, tt.status)
+			}
+
+			emails := []string{}
+			for k := range creds.passwords {
+				emails = append(emails, k)
+			}
+			sort.Strings(emails)
+			if !reflect.DeepEqual(emails, tt.emails) {
+				t.Errorf("ConfirmEmailChange() left the credentials of %v, want %v", emails, tt.emails)
+			}
+			if !reflect.DeepEqual(tk.revoked, tt.revoked) {
+				t.Errorf("ConfirmEmailChange() revoked the tokens of %v, want %v", tk.revoked, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestAssignRole(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		grant   bool
+		role    string
+		target  string
+		revoke  error
+		status  uint32
+		roles   []string
+		revoked []string
+	}{
+		{
+			name:   "grants",
+			token:  "super",
+			grant:  true,
+			role:   ROLE_SELLER,
+			target: "user@example.com",
+			status: http.StatusOK,
+			roles:  []string{ROLE_MODERATOR, ROLE_SELLER},
+		},
+		{
+			name:    "revokes",
+			token:   "super",
+			role:    ROLE_MODERATOR,
+			target:  "user@example.com",
+			status:  http.StatusOK,
+			roles:   []string{},
+			revoked: []string{"user@example.com"},
+		},
+		{
+			name:   "not super",
+			token:  "user",
+			grant:  true,
+			role:   ROLE_SELLER,
+			target: "user@example.com",
+			status: http.StatusForbidden,
+			roles:  []string{ROLE_MODERATOR},
+		},
+		{
+			name:   "not a token",
+			token:  "forged",
+			grant:  true,
+			role:   ROLE_SELLER,
+			target: "user@example.com",
+			status: http.StatusUnauthorized,
+			roles:  []string{ROLE_MODERATOR},
+		},
+		{
+			name:   "not a role",
+			token:  "super",
+			grant:  true,
+			role:   "owner",
+			target: "user@example.com",
+			status: http.StatusUnprocessableEntity,
+			roles:  []string{ROLE_MODERATOR},
+		},
+		{
+			name:   "the role of everyone",
+			token:  "super",
+			role:   ROLE_USER,
+			target: "user@example.com",
+			status: http.StatusUnprocessableEntity,
+			roles:  []string{ROLE_MODERATOR},
+		},
+		{
+			name:   "no credentials",
+			token:  "super",
+			grant:  true,
+			role:   ROLE_SELLER,
+			target: "nobody@example.com",
+			status: http.StatusNotFound,
+			roles:  []string{ROLE_MODERATOR},
+		},
+		{
+			name:   "tokens not revoked",
+			token:  "super",
+			role:   ROLE_MODERATOR,
+			target: "user@example.com",
+			revoke: errors.New("the KV store is down"),
+			status: http.StatusInternalServerError,
+			roles:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			super := tokenOf("admin@example.com", READ_PROFILE)
+			super.Super = true
+			tk := &tokens{claims: map[Token]*Claims{
+				"super": super,
+				"user":  tokenOf("user@example.com", READ_PROFILE),
+			}, revokeErr: tt.revoke}
+			creds := &credentials{roles: map[string][]string{"user@example.com": {ROLE_MODERATOR}}}
+			s := NewAuthServer(creds, tk, nil, nil)
+
+			in := &proto.RoleAssignment{Token: &proto.Token{Jwt: tt.token}, Identifier: tt.target, Role: tt.role}
+			assign := s.RevokeRole
+			if tt.grant {
+				assign = s.GrantRole
+			}
+
+			v, err := assign(context.Background(), in)
+			if v.GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusOK) {
+				t.Errorf("assignRole() = (%v, %v), want a %d", v, err, tt.status)
+			}
+
+			if got := creds.roles["user@example.com"]; !reflect.DeepEqual(got, tt.roles) {
+				t.Errorf("assignRole() left the roles %v, want %v", got, tt.roles)
+			}
+			if !reflect.DeepEqual(tk.revoked, tt.revoked) {
+				t.Errorf("assignRole() revoked the tokens of %v, want %v", tk.revoked, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestGetRoles(t *testing.T) {
+	super := tokenOf("admin@example.com", READ_PROFILE)
+	super.Super = true
+	tk := &tokens{claims: map[Token]*Claims{
+		"super": super,
+		"user":  tokenOf("user@example.com", READ_PROFILE),
+	}}
+	s := NewAuthServer(&credentials{roles: map[string][]string{
+		"user@example.com":  {ROLE_SELLER},
+		"admin@example.com": {},
+	}}, tk, nil, nil)
+
+	tests := []struct {
+		name   string
+		token  string
+		target string
+		status uint32
+		roles  []string
+	}{
+		{"yours", "user", "user@example.com", http.StatusOK, []string{ROLE_SELLER}},
+		{"of others by super users", "super", "user@example.com", http.StatusOK, []string{ROLE_SELLER}},
+		{"of others", "user", "admin@example.com", http.StatusForbidden, nil},
+		{"not a token", "forged", "user@example.com", http.StatusUnauthorized, nil},
+	}
+
+	for _, tt := range tests {
+		r, err := s.GetRoles(context.Background(), &proto.RoleAssignment{Token: &proto.Token{Jwt: tt.token}, Identifier: tt.target})
+		if r.GetValidation().GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusOK) || !reflect.DeepEqual(r.GetRoles(), tt.roles) {
+			t.Errorf("GetRoles() %s = (%v, %v), want a %d with %v", tt.name, r, err, tt.status, tt.roles)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	current := tokenOf("user@example.com", READ_PROFILE)
+	current.Session = "phone"
+	tk := &tokens{
+		claims: map[Token]*Claims{"user": current},
+		sessions: map[string][]Session{"user@example.com": {
+			{ID: "laptop", TokenID: "2", IP: "192.0.2.1", UserAgent: "Firefox"},
+			{ID: "phone", TokenID: "1", IP: "192.0.2.2", UserAgent: "Safari"},
+		}},
+	}
+	s := NewAuthServer(&credentials{}, tk, nil, nil)
+	ctx := context.Background()
+
+	list, err := s.ListSessions(ctx, &proto.Token{Jwt: "user"})
+	if err != nil || list.GetValidation().GetHttpStatusCode() != http.StatusOK {
+		t.Fatalf("ListSessions() = (%v, %v)", list, err)
+	}
+
+	got := []string{}
+	for _, i := range list.GetSessions() {
+		got = append(got, fmt.Sprintf("%s %s %s %v", i.GetId(), i.GetIp(), i.GetUserAgent(), i.GetCurrent()))
+	}
+	if want := []string{"laptop 192.0.2.1 Firefox false", "phone 192.0.2.2 Safari true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListSessions() = %q, want %q", got, want)
+	}
+
+	if list, err := s.ListSessions(ctx, &proto.Token{Jwt: "forged"}); err == nil || list.GetValidation().GetHttpStatusCode() != http.StatusUnauthorized {
+		t.Errorf("ListSessions() of a forged token = (%v, %v), want a 401", list, err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		id     string
+		status uint32
+	}{
+		{"another one", "user", "laptop", http.StatusOK},
+		{"already revoked", "user", "laptop", http.StatusNotFound},
+		{"without the session", "user", "", http.StatusBadRequest},
+		{"not a token", "forged", "phone", http.StatusUnauthorized},
+		{"this one", "user", "phone", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		v, err := s.RevokeSession(ctx, &proto.SessionRevocation{Token: &proto.Token{Jwt: tt.token}, Id: tt.id})
+		if v.GetHttpStatusCode() != tt.status || (err == nil) != (tt.status == http.StatusOK) {
+			t.Errorf("RevokeSession() %s = (%v, %v), want a %d", tt.name, v, err, tt.status)
+		}
+	}
+
+	if left := tk.sessions["user@example.com"]; len(left) != 0 {
+		t.Errorf("RevokeSession() left the sessions %v", left)
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ARGON2ID = "argon2id"
+	BCRYPT   = "bcrypt"
+)
+
+// BCRYPT_MAX_LENGTH is the longest password bcrypt hashes, it refuses longer
+// ones.
+const BCRYPT_MAX_LENGTH = 72
+
+var ErrMalformedHash = errors.New("the password hash is not in a known format")
+
+// PasswordHashing is how new passwords are hashed, defined at
+// `auth.password.hash`. The `algorithm` is `argon2id`, by default, or
+// `bcrypt`. argon2id uses `memory` KiB, `time` passes, `threads`, a `salt`
+// and a `key` of that many bytes, bcrypt uses `cost`.
+type PasswordHashing struct {
+	Algorithm string
+	Memory    uint32
+	Time      uint32
+	Threads   uint8
+	SaltLen   uint32
+	KeyLen    uint32
+	Cost      int
+}
+
+func PasswordHashingConf() PasswordHashing {
+	get := func(def float64, key string) float64 {
+		return conf.MustGetConfOrDefault(def, "auth", "password", "hash", key)
+	}
+
+	return PasswordHashing{
+		Algorithm: conf.MustGetConfOrDefault(ARGON2ID, "auth", "password", "hash", "algorithm"),
+		Memory:    uint32(get(64*1024, "memory")),
+		Time:      uint32(get(3, "time")),
+		Threads:   uint8(get(2, "threads")),
+		SaltLen:   uint32(get(16, "salt")),
+		KeyLen:    uint32(get(32, "key")),
+		Cost:      int(get(float64(bcrypt.DefaultCost), "cost")),
+	}
+}
+
+// MaxLength is the longest password it can hash, 0 if there's no limit.
+func (h PasswordHashing) MaxLength() int {
+	if h.Algorithm == BCRYPT {
+		return BCRYPT_MAX_LENGTH
+	}
+
+	return 0
+}
+
+// Hash hashes the password into a self-describing PHC string, like
+// `$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>`, or the modular crypt format
+// bcrypt already uses.
+func (h PasswordHashing) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case ARGON2ID:
+		salt := make([]byte, h.SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+		return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", ARGON2ID, argon2.Version, h.Memory, h.Time, h.Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	case BCRYPT:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+		return string(hash), err
+	default:
+		return "", fmt.Errorf("the password hashing algorithm `%s` is not supported", h.Algorithm)
+	}
+}
+
+// Verify checks the password against the hash, made with any of the supported
+// algorithms and parameters. needs_rehash is if the hash is not how
+// passwords are hashed now, so it should be replaced with a new one.
+func (h PasswordHashing) Verify(hash string, password string) (ok bool, needs_rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$"+ARGON2ID+"$"):
+		var (
+			version      int
+			memory, time uint32
+			threads      uint8
+		)
+
+		parts := strings.Split(hash, "$")
+		if len(parts) != 6 {
+			return false, false, ErrMalformedHash
+		}
+
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return false, false, ErrMalformedHash
+		}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+			return false, false, ErrMalformedHash
+		}
+
+		salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+		if err != nil {
+			return false, false, ErrMalformedHash
+		}
+		want, err := base64.RawStdEncoding.DecodeString(parts[5])
+		if err != nil {
+			return false, false, ErrMalformedHash
+		}
+
+		got := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(want)))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			return false, false, nil
+		}
+
+		return true, h.Algorithm != ARGON2ID || memory != h.Memory || time != h.Time || threads != h.Threads ||
+			uint32(len(salt)) != h.SaltLen || uint32(len(want)) != h.KeyLen, nil
+	case strings.HasPrefix(hash, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, false, err
+		}
+
+		return true, h.Algorithm != BCRYPT || cost != h.Cost, nil
+	default:
+		return false, false, ErrMalformedHash
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// small parameters, so the tests don't take the time real hashes do
+var testHashing = PasswordHashing{
+	Algorithm: ARGON2ID,
+	Memory:    64,
+	Time:      1,
+	Threads:   1,
+	SaltLen:   8,
+	KeyLen:    16,
+	Cost:      4,
+}
+
+func argon2idPHC(password string, memory, time uint32, threads uint8) string {
+	salt := []byte("saltsalt")
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, 16)
+
+	return fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$%s$%s", memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestPasswordHashingConf(t *testing.T) {
+	h := PasswordHashingConf()
+
+	if h.Algorithm != ARGON2ID || h.Memory != 64*1024 || h.Time != 3 || h.Threads != 2 || h.SaltLen != 16 || h.KeyLen != 32 {
+		t.Errorf("PasswordHashingConf() = %+v, want the argon2id defaults", h)
+	}
+	if h.MaxLength() != 0 {
+		t.Errorf("MaxLength() = %d, want 0 for argon2id", h.MaxLength())
+	}
+}
+
+func TestPasswordHashingHash(t *testing.T) {
+	bcrypt := testHashing
+	bcrypt.Algorithm = BCRYPT
+
+	scrypt := testHashing
+	scrypt.Algorithm = "scrypt"
+
+	tests := []struct {
+		name    string
+		hashing PasswordHashing
+		err     bool
+	}{
+		{"argon2id", testHashing, false},
+		{"bcrypt", bcrypt, false},
+		{"unsupported", scrypt, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hashing.Hash("correct horse battery staple")
+			if (err != nil) != tt.err {
+				t.Fatalf("Hash() error = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+
+			ok, rehash, err := tt.hashing.Verify(hash, "correct horse battery staple")
+			if err != nil || !ok || rehash {
+				t.Errorf("Verify(%q) = (%v, %v, %v), want (true, false, nil)", hash, ok, rehash, err)
+			}
+
+			if ok, _, _ := tt.hashing.Verify(hash, "correct horse battery stapler"); ok {
+				t.Errorf("Verify(%q) accepted the wrong password", hash)
+			}
+		})
+	}
+}
+
+func TestPasswordHashingVerify(t *testing.T) {
+	bcrypt := testHashing
+	bcrypt.Algorithm = BCRYPT
+	bcrypt.Cost = 5
+
+	current := argon2idPHC("password", testHashing.Memory, testHashing.Time, testHashing.Threads)
+
+	tests := []struct {
+		name     string
+		hashing  PasswordHashing
+		hash     string
+		password string
+		ok       bool
+		rehash   bool
+		err      error
+	}{
+		{"argon2id", testHashing, current, "password", true, false, nil},
+		{"argon2id wrong password", testHashing, current, "passwort", false, false, nil},
+		{"argon2id older memory", testHashing, argon2idPHC("password", 32, 1, 1), "password", true, true, nil},
+		{"argon2id older time", testHashing, argon2idPHC("password", 64, 2, 1), "password", true, true, nil},
+		{"argon2id older threads", testHashing, argon2idPHC("password", 64, 1, 2), "password", true, true, nil},
+		{"argon2id when bcrypt is used", bcrypt, current, "password", true, true, nil},
+		// from the test vectors of OpenBSD's bcrypt
+		{"bcrypt", bcrypt, "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true, false, nil},
+		{"bcrypt wrong password", bcrypt, "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*V", false, false, nil},
+		{"bcrypt when argon2id is used", testHashing, "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true, true, nil},
+		{"unknown format", testHashing, "password", "password", false, false, ErrMalformedHash},
+		{"missing parts", testHashing, "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ", "password", false, false, ErrMalformedHash},
+		{"other version", testHashing, "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$c2FsdHNhbHQ", "password", false, false, ErrMalformedHash},
+		{"malformed parameters", testHashing, "$argon2id$v=19$m=64;t=1;p=1$c2FsdHNhbHQ$c2FsdHNhbHQ", "password", false, false, ErrMalformedHash},
+		{"malformed salt", testHashing, "$argon2id$v=19$m=64,t=1,p=1$!!$c2FsdHNhbHQ", "password", false, false, ErrMalformedHash},
+		{"malformed key", testHashing, "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$!!", "password", false, false, ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := tt.hashing.Verify(tt.hash, tt.password)
+			if ok != tt.ok || rehash != tt.rehash || !errors.Is(err, tt.err) {
+				t.Errorf("Verify() = (%v, %v, %v), want (%v, %v, %v)", ok, rehash, err, tt.ok, tt.rehash, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+/*
+GET /audit/?actor=&action=&since=&until=&before=&limit=  the audit log
+
+`since` and `until` are RFC 3339 dates and `before` is the id of the last
+event of the previous page.
+*/
+
+func AuditRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	var (
+		err    error
+		res    any
+		status uint32
+	)
+
+	h := w.Header()
+	status, err = helpers.CheckOriginHeader(&h, r, true)
+
+	if len(segs) > 0 && segs[len(segs)-1] == "" {
+		segs = segs[:len(segs)-1]
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if err != nil {
+			break
+		}
+
+		if len(segs) != 0 {
+			err = &mux.HttpError{Status: http.StatusNotFound}
+			break
+		}
+
+		var in *proto.AuditQuery
+		if in, err = auditQuery(r); err != nil {
+			break
+		}
+
+		var jwt []byte
+		if jwt, err = bloqs_helpers.ExtractToken(w, r); err != nil {
+			break
+		}
+		in.Token = &proto.Token{Jwt: string(jwt)}
+
+		var a proto.AuthServer
+		if a, err = authSrv(r.Context()); err != nil {
+			break
+		}
+
+		var events *proto.AuditEvents
+		events, err = a.Audit(r.Context(), in)
+		if events.GetValidation() == nil {
+			break
+		}
+
+		res = events
+		status = events.Validation.GetHttpStatusCode()
+		events.Validation.HttpStatusCode = nil
+		// the validation already describes the error
+		err = nil
+	case http.MethodOptions:
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodGet)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Max-Age", "0")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		err = &mux.HttpError{Status: http.StatusMethodNotAllowed}
+	}
+
+	if err != nil {
+		status = auth.ErrorStatus(err, http.StatusInternalServerError)
+		res = auth.ErrorToValidation(err, nil)
+	}
+
+	if status == 0 {
+		status = http.StatusInternalServerError
+		if err == nil {
+			status = http.StatusOK
+		}
+	}
+
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(int(status))
+	json.NewEncoder(w).Encode(res)
+}
+
+// auditQuery reads the filters of the audit log from the query string.
+func auditQuery(r *http.Request) (*proto.AuditQuery, error) {
+	q := r.URL.Query()
+	in := new(proto.AuditQuery)
+
+	if actor := q.Get("actor"); actor != "" {
+		in.Actor = &actor
+	}
+
+	if action := q.Get("action"); action != "" {
+		in.Action = &action
+	}
+
+	for _, i := range []struct {
+		name string
+		dst  **int64
+	}{{"since", &in.Since}, {"until", &in.Until}} {
+		if v := q.Get(i.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, &mux.HttpError{
+					Body:   fmt.Sprintf("the HTTP query parameter `%s` has to be a RFC 3339 date:\t%s", i.name, err),
+					Status: http.StatusBadRequest,
+				}
+			}
+			unix := t.Unix()
+			*i.dst = &unix
+		}
+	}
+
+	if v := q.Get("before"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, &mux.HttpError{
+				Body:   fmt.Sprintf("the HTTP query parameter `before` has to be the id of an event:\t%s", err),
+				Status: http.StatusBadRequest,
+			}
+		}
+		in.Before = &before
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, &mux.HttpError{
+				Body:   fmt.Sprintf("the HTTP query parameter `limit` has to be an unsigned integer:\t%s", err),
+				Status: http.StatusBadRequest,
+			}
+		}
+		in.Limit = uint32(limit)
+	}
+
+	return in, nil
+}
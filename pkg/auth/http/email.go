@@ -0,0 +1,112 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/email"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+/*
+GET|POST /email/verify       verify with the `token` sent by email
+GET|POST /email/change       change to the new email with the `token` sent to it
+POST     /email/verify/send  send the email to verify again, needs to be logged in
+POST     /email/reset        send an email to reset the password of `email` or,
+                             with the `token` sent by it, reset it to `pass`
+*/
+
+func EmailRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	var (
+		err    error
+		v      *proto.Validation
+		status uint32
+	)
+
+	r = r.WithContext(email.WithAcceptLanguage(r.Context(), r.Header.Get("Accept-Language")))
+
+	h := w.Header()
+
+	if r.Method == http.MethodGet {
+		// it's reached by following the link in the email, so there's no
+		// `Origin` to check
+		status, err = http.StatusOK, nil
+	} else {
+		status, err = helpers.CheckOriginHeader(&h, r, true)
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+		if err != nil {
+			break
+		}
+
+		var a proto.AuthServer
+		if a, err = authSrv(r.Context()); err != nil {
+			break
+		}
+
+		route := ""
+		if len(segs) > 0 {
+			route = segs[0]
+		}
+		if len(segs) > 1 && segs[1] != "" {
+			route += "/" + segs[1]
+		}
+
+		switch {
+		case route == "verify":
+			v, err = a.VerifyEmail(r.Context(), &proto.EmailToken{Token: r.FormValue("token")})
+		case route == "change":
+			v, err = a.ConfirmEmailChange(r.Context(), &proto.EmailToken{Token: r.FormValue("token")})
+		case route == "verify/send" && r.Method == http.MethodPost:
+			var jwt []byte
+			if jwt, err = bloqs_helpers.ExtractToken(w, r); err != nil {
+				break
+			}
+
+			v, err = a.SendVerification(r.Context(), &proto.Token{Jwt: string(jwt)})
+		case route == "reset" && r.Method == http.MethodPost:
+			if tk := r.FormValue("token"); tk != "" {
+				pass := r.FormValue("pass")
+				v, err = a.ResetPassword(r.Context(), &proto.EmailToken{Token: tk, Password: &pass})
+			} else {
+				v, err = a.RequestPasswordReset(r.Context(), &proto.Email{Email: r.FormValue("email")})
+			}
+		default:
+			err = &mux.HttpError{Status: http.StatusNotFound}
+		}
+	case http.MethodOptions:
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodGet)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPost)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Max-Age", "0")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		err = &mux.HttpError{Status: http.StatusMethodNotAllowed}
+	}
+
+	if v != nil && v.HttpStatusCode != nil {
+		status = v.GetHttpStatusCode()
+		v.HttpStatusCode = nil
+	} else if err != nil {
+		status = auth.ErrorStatus(err, http.StatusInternalServerError)
+		v = auth.ErrorToValidation(err, nil)
+	}
+
+	if see_other := redirect(r); see_other != nil && status >= 200 && status < 300 {
+		status = http.StatusSeeOther
+		h.Set("Location", *see_other)
+	}
+
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(int(status))
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+)
+
+// JWKSRoute publishes the public keys the tokens are signed with, so other
+// services can verify them without calling the auth service.
+func JWKSRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	h := w.Header()
+
+	jwks_route := conf.MustGetConfOrDefault("/.well-known/jwks.json", "auth", "paths", "jwks")
+	if len(segs) != 1 || segs[0] != path.Base(jwks_route) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		t, err := tokenerSrv(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		set, err := t.JWKS(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Origin", "*")
+		h.Set("Cache-Control", "public, max-age=300")
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(set)
+	case http.MethodOptions:
+		h.Set("Access-Control-Allow-Origin", "*")
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodGet)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
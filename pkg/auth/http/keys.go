@@ -0,0 +1,135 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+/*
+GET    /keys/          the API keys of the token's credentials
+POST   /keys/          create an API key, `label`, `permissions` and `expires`
+DELETE /keys/<prefix>  revoke the API key
+*/
+
+func APIKeysRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	var (
+		err    error
+		res    any
+		status uint32
+	)
+
+	h := w.Header()
+	status, err = helpers.CheckOriginHeader(&h, r, true)
+
+	if len(segs) > 0 && segs[len(segs)-1] == "" {
+		segs = segs[:len(segs)-1]
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPost, http.MethodDelete:
+		if err != nil {
+			break
+		}
+
+		if (r.Method == http.MethodDelete && len(segs) != 1) || (r.Method != http.MethodDelete && len(segs) != 0) {
+			err = &mux.HttpError{Status: http.StatusNotFound}
+			break
+		}
+
+		var jwt []byte
+		if jwt, err = bloqs_helpers.ExtractToken(w, r); err != nil {
+			break
+		}
+
+		var a proto.AuthServer
+		if a, err = authSrv(r.Context()); err != nil {
+			break
+		}
+
+		tk := &proto.Token{Jwt: string(jwt)}
+
+		var valid *proto.Validation
+		switch r.Method {
+		case http.MethodGet:
+			var keys *proto.APIKeys
+			keys, err = a.ListAPIKeys(r.Context(), tk)
+			valid, res = keys.GetValidation(), keys
+		case http.MethodPost:
+			r.ParseForm()
+			in := &proto.APIKeyRequest{
+				Token:  tk,
+				Label:  r.Form.Get("label"),
+				Scopes: r.Form["permissions"],
+			}
+
+			if e := r.Form.Get("expires"); e != "" {
+				var t time.Time
+				if t, err = time.Parse(time.RFC3339, e); err != nil {
+					err = &mux.HttpError{
+						Body:   fmt.Sprintf("`expires` body field has to be a RFC 3339 date:\t%s", err),
+						Status: http.StatusUnprocessableEntity,
+					}
+					break
+				}
+				expires := t.Unix()
+				in.Expires = &expires
+			}
+
+			var key *proto.APIKeyValidation
+			key, err = a.CreateAPIKey(r.Context(), in)
+			valid, res = key.GetValidation(), key
+		default:
+			valid, err = a.RevokeAPIKey(r.Context(), &proto.APIKeyRevocation{
+				Token:  tk,
+				Prefix: segs[0],
+			})
+			res = valid
+		}
+
+		if valid == nil {
+			res = nil
+			break
+		}
+
+		status = valid.GetHttpStatusCode()
+		valid.HttpStatusCode = nil
+		// the validation already describes the error
+		err = nil
+	case http.MethodOptions:
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodGet)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPost)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodDelete)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Max-Age", "0")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		err = &mux.HttpError{Status: http.StatusMethodNotAllowed}
+	}
+
+	if err != nil {
+		status = auth.ErrorStatus(err, http.StatusInternalServerError)
+		res = auth.ErrorToValidation(err, nil)
+	}
+
+	if status == 0 {
+		status = http.StatusInternalServerError
+		if err == nil {
+			status = http.StatusOK
+		}
+	}
+
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(int(status))
+	json.NewEncoder(w).Encode(res)
+}
@@ -28,6 +28,12 @@ func LogRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 		a proto.AuthServer
 	)
 
+	// the sessions answer with other messages than the log in and out
+	if len(segs) > 0 && segs[0] == "sessions" {
+		SessionsRoute(w, r, segs[1:])
+		return
+	}
+
 	h := w.Header()
 	status, err = helpers.CheckOriginHeader(&h, r, true)
 
@@ -42,6 +48,44 @@ func LogRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 			goto respond
 		}
 
+		if len(segs) > 0 && segs[0] == "refresh" {
+			var refresh []byte
+			refresh, err = bloqs_helpers.ExtractRefreshToken(r)
+			if err != nil {
+				status = http.StatusUnauthorized
+				v = &proto.TokenValidation{
+					Validation: auth.ErrorToValidation(err, &status),
+				}
+				goto respond
+			}
+
+			a, err = authSrv(r.Context())
+			if err != nil {
+				status = http.StatusInternalServerError
+				v = &proto.TokenValidation{
+					Validation: auth.ErrorToValidation(err, &status),
+				}
+				goto respond
+			}
+
+			str := string(refresh)
+			v, err = a.Refresh(r.Context(), &proto.Token{
+				Refresh: &str,
+			})
+			if err == nil {
+				bloqs_helpers.SetToken(w, r, v.Token.Jwt)
+				bloqs_helpers.SetRefreshToken(w, r, v.Token.GetRefresh())
+			}
+
+			goto respond
+		} else if len(segs) > 0 && segs[0] != "" {
+			status = http.StatusNotFound
+			v = &proto.TokenValidation{
+				Validation: auth.Invalid("", &status),
+			}
+			goto respond
+		}
+
 		var ask *proto.AskPermissions
 		var creds *proto.Credentials
 
@@ -142,6 +186,40 @@ func LogRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 					},
 				}
 			}
+		case "totp": // the second step of a log in that needs it
+			if ask == nil {
+				challenge := r.FormValue("challenge")
+
+				if challenge == "" {
+					status = http.StatusUnprocessableEntity
+					v = &proto.TokenValidation{
+						Validation: auth.Invalid("`challenge` body field is empty and needs to be defined to proceed.\n", &status),
+					}
+					goto respond
+				}
+
+				code := r.FormValue("code")
+
+				if code == "" {
+					status = http.StatusUnprocessableEntity
+					v = &proto.TokenValidation{
+						Validation: auth.Invalid("`code` body field is empty and needs to be defined to proceed.\n", &status),
+					}
+					goto respond
+				}
+
+				// the permissions were already asked for in the first step
+				ask = &proto.AskPermissions{
+					Credentials: &proto.Credentials{
+						Credentials: &proto.Credentials_Totp{
+							Totp: &proto.Credentials_TOTPCredentials{
+								Challenge: challenge,
+								Code:      code,
+							},
+						},
+					},
+				}
+			}
 		default:
 			status = http.StatusBadRequest
 			v = &proto.TokenValidation{
@@ -159,45 +237,78 @@ func LogRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 			goto respond
 		}
 
-		var validation *proto.Validation
-
-		if ask != nil && creds == nil {
-			creds = ask.Credentials
-		}
-
-		validation, err = a.IsSuper(r.Context(), creds)
-
 		if ask == nil {
-			perm := conf.MustGetConfOrDefault("permissions", "auth", "queryParams", "permissions")
-			permissions := auth.DEFAULT_PERMISSIONS
-			list := auth.GetPermissionsList(validation.Valid)
-			if r.URL.Query().Has(perm) {
-				ps, ok := r.URL.Query()[perm]
-				if ok {
-					permissions = auth.NIL
-					for _, i := range ps {
-						p, ok := list[i]
-						if !ok {
-							status = http.StatusBadRequest
-							v = &proto.TokenValidation{
-								Validation: auth.Invalid(fmt.Sprintf("the HTTP query parameter `%s` that specifies the permissions for the token to have was has an invalid value. Check which values are supported (.%s).\n", perm, "TODO"), &status),
-							}
-							goto respond
-						}
-						permissions |= p
-					}
+			var permissions auth.Permission
+			if permissions, err = askedPermissions(r); err != nil {
+				status = auth.ErrorStatus(err, http.StatusBadRequest)
+				v = &proto.TokenValidation{
+					Validation: auth.ErrorToValidation(err, &status),
 				}
+				goto respond
 			}
 
 			ask = &proto.AskPermissions{
 				Credentials: creds,
-				Permissions: uint64(permissions),
+				Scopes:      permissions.Scopes(),
 			}
 		}
 
 		v, err = a.LogIn(r.Context(), ask)
-		if err == nil {
+		if err == nil && v.Token != nil {
 			bloqs_helpers.SetToken(w, r, v.Token.Jwt)
+			bloqs_helpers.SetRefreshToken(w, r, v.Token.GetRefresh())
+		}
+
+		goto respond
+	case http.MethodGet: // log in with an OpenID provider
+		// it's reached by navigating, so there's no `Origin` to check
+		status, err = http.StatusOK, nil
+
+		t := conf.MustGetConfOrDefault("type", "auth", "queryParams", "type")
+		if method := r.URL.Query().Get(t); method != "oidc" || !auth.IsAuthMethodSupported(method) {
+			status = http.StatusBadRequest
+			v = &proto.TokenValidation{
+				Validation: auth.Invalid(fmt.Sprintf("the HTTP query parameter `%s` has an unsupported value to log in with `GET`. Define it with `oidc` if it's one of the supported values (.%s).\n", t, types_route), &status),
+			}
+			goto respond
+		}
+
+		if !r.URL.Query().Has("code") && !r.URL.Query().Has("error") {
+			if err = oidcAuthorize(w, r); err != nil {
+				status = auth.ErrorStatus(err, http.StatusInternalServerError)
+				v = &proto.TokenValidation{
+					Validation: auth.ErrorToValidation(err, &status),
+				}
+				goto respond
+			}
+
+			return
+		}
+
+		a, err = authSrv(r.Context())
+		if err != nil {
+			status = http.StatusInternalServerError
+			v = &proto.TokenValidation{
+				Validation: auth.ErrorToValidation(err, &status),
+			}
+			goto respond
+		}
+
+		var see_other *string
+		v, see_other, err = oidcCallback(w, r, a)
+		if err != nil {
+			status = auth.ErrorStatus(err, http.StatusInternalServerError)
+			if v == nil || v.Validation == nil {
+				v = &proto.TokenValidation{
+					Validation: auth.ErrorToValidation(err, &status),
+				}
+			}
+			goto respond
+		}
+
+		if see_other != nil {
+			http.Redirect(w, r, *see_other, http.StatusSeeOther)
+			return
 		}
 
 		goto respond
@@ -283,10 +394,7 @@ func LogRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 			jwt, err = bloqs_helpers.ExtractToken(w, r)
 
 			if err != nil {
-				status = http.StatusInternalServerError
-				if err, ok := err.(*mux.HttpError); ok {
-					status = uint32(err.Status)
-				}
+				status = auth.ErrorStatus(err, http.StatusInternalServerError)
 
 				v = &proto.TokenValidation{
 					Validation: auth.ErrorToValidation(err, &status),
@@ -310,13 +418,18 @@ func LogRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 		}
 
 		var valid *proto.Validation
-		valid, err = a.LogOut(r.Context(), tk)
+		if len(segs) > 0 && segs[0] == "everywhere" {
+			valid, err = a.LogOutEverywhere(r.Context(), tk)
+		} else {
+			valid, err = a.LogOut(r.Context(), tk)
+		}
 		v = &proto.TokenValidation{
 			Validation: valid,
 		}
 
 		goto respond
 	case http.MethodOptions:
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodGet)
 		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPost)
 		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodDelete)
 		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
@@ -349,6 +462,10 @@ respond:
 	see_other := redirect(r)
 
 	if valid := v.Validation; valid != nil {
+		if valid.RetryAfter != nil {
+			h.Set("Retry-After", fmt.Sprint(valid.GetRetryAfter()))
+		}
+
 		if code := valid.HttpStatusCode; code != nil {
 			status = *code
 			valid.HttpStatusCode = nil
@@ -402,3 +519,25 @@ func redirect(r *http.Request) *string {
 		return &str
 	}
 }
+
+// askedPermissions reads the permissions asked for the token from the query
+// string. The ones only for super credentials are accepted and then dropped on
+// the log in if the credentials aren't.
+func askedPermissions(r *http.Request) (auth.Permission, error) {
+	perm := conf.MustGetConfOrDefault("permissions", "auth", "queryParams", "permissions")
+
+	ps, ok := r.URL.Query()[perm]
+	if !ok {
+		return auth.DEFAULT_PERMISSIONS, nil
+	}
+
+	permissions, err := auth.ParseScopes(ps)
+	if err != nil {
+		return auth.NIL, &mux.HttpError{
+			Body:   fmt.Sprintf("the HTTP query parameter `%s` that specifies the permissions for the token to have has an invalid value:\t%s", perm, err),
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,21 @@
+package http
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/testconf"
+)
+
+func TestMain(m *testing.M) {
+	cleanup, err := testconf.Compile(`{"auth": {"domain": "https://auth.example.com"}}`)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
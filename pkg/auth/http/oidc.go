@@ -0,0 +1,174 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+const OIDC_COOKIE = "_Secure-bloqs-oidc"
+
+// oidcState is what has to be remembered between the redirect to the OpenID
+// provider and its callback. It's kept in a short lived cookie.
+type oidcState struct {
+	State       string          `json:"state"`
+	Verifier    string          `json:"verifier"`
+	Nonce       string          `json:"nonce"`
+	Provider    string          `json:"provider"`
+	Redirect    string          `json:"redirect,omitempty"`
+	Permissions auth.Permission `json:"permissions"`
+}
+
+// oidcRedirectURI is where the providers send the users back to, it has to be
+// registered on each one of them.
+func oidcRedirectURI() string {
+	log_route := conf.MustGetConfOrDefault("/log/", "auth", "paths", "log")
+	t := conf.MustGetConfOrDefault("type", "auth", "queryParams", "type")
+
+	return fmt.Sprintf("%s%s?%s=oidc", conf.MustGetConfOrDefault("", "auth", "domain"), log_route, t)
+}
+
+// oidcAuthorize redirects the user to the OpenID provider in the `provider`
+// query parameter to log in.
+func oidcAuthorize(w http.ResponseWriter, r *http.Request) error {
+	provider, err := auth.GetOIDCProvider(r.URL.Query().Get("provider"))
+	if err != nil {
+		return err
+	}
+
+	permissions, err := askedPermissions(r)
+	if err != nil {
+		return err
+	}
+
+	s := &oidcState{
+		Provider:    provider.Name,
+		Permissions: permissions,
+	}
+	if see_other := redirect(r); see_other != nil {
+		s.Redirect = *see_other
+	}
+
+	var challenge string
+	if s.Verifier, challenge, err = auth.NewPKCE(); err != nil {
+		return err
+	}
+	if s.State, err = auth.RandomString(16); err != nil {
+		return err
+	}
+	if s.Nonce, err = auth.RandomString(16); err != nil {
+		return err
+	}
+
+	location, err := provider.AuthURL(r.Context(), oidcRedirectURI(), s.State, challenge, s.Nonce)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDC_COOKIE,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Expires:  time.Now().Add(10 * time.Minute),
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		// it has to be sent on the navigation back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, location, http.StatusFound)
+
+	return nil
+}
+
+// oidcCallback logs in with the authorization code the OpenID provider sent
+// the user back with. It returns where the user wanted to go after.
+func oidcCallback(w http.ResponseWriter, r *http.Request, a proto.AuthServer) (*proto.TokenValidation, *string, error) {
+	cookie, err := r.Cookie(OIDC_COOKIE)
+	if err != nil {
+		return nil, nil, &mux.HttpError{
+			Body:   "the log in with the OpenID provider expired or was never started",
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDC_COOKIE,
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+	})
+
+	s := new(oidcState)
+	if value, err := base64.RawURLEncoding.DecodeString(cookie.Value); err != nil || json.Unmarshal(value, s) != nil {
+		return nil, nil, &mux.HttpError{
+			Body:   fmt.Sprintf("invalid HTTP Cookie `%s`", OIDC_COOKIE),
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	q := r.URL.Query()
+	if s.State == "" || q.Get("state") != s.State {
+		return nil, nil, &mux.HttpError{
+			Body:   "the `state` query parameter does not match the log in that was started",
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	if e := q.Get("error"); e != "" {
+		return nil, nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the OpenID provider `%s` did not authorize the log in:\t%s %s", s.Provider, e, q.Get("error_description")),
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	v, err := a.LogIn(r.Context(), &proto.AskPermissions{
+		Credentials: &proto.Credentials{
+			Credentials: &proto.Credentials_Oidc{
+				Oidc: &proto.Credentials_OIDCCredentials{
+					Provider:    s.Provider,
+					Code:        q.Get("code"),
+					Verifier:    s.Verifier,
+					RedirectUri: oidcRedirectURI(),
+					Nonce:       s.Nonce,
+				},
+			},
+		},
+		Scopes: s.Permissions.Scopes(),
+	})
+	if err != nil || v.Token == nil {
+		return v, nil, err
+	}
+
+	bloqs_helpers.SetToken(w, r, v.Token.Jwt)
+	bloqs_helpers.SetRefreshToken(w, r, v.Token.GetRefresh())
+
+	if s.Redirect == "" {
+		return v, nil, nil
+	}
+
+	// it came from a cookie, so it's checked again to not be an open redirect
+	location, err := url.Parse(s.Redirect)
+	if err != nil || (location.Hostname() != "" && helpers.ValidateDomain(location.Hostname()) != nil) {
+		return v, nil, nil
+	}
+
+	return v, &s.Redirect, nil
+}
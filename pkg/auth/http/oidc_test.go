@@ -0,0 +1,101 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+// logInRecorder keeps the last log in it was asked for and refuses it.
+type logInRecorder struct {
+	proto.UnimplementedAuthServer
+	in *proto.AskPermissions
+}
+
+func (a *logInRecorder) LogIn(ctx context.Context, in *proto.AskPermissions) (*proto.TokenValidation, error) {
+	a.in = in
+	return &proto.TokenValidation{}, nil
+}
+
+func oidcCookie(t *testing.T, s *oidcState) *http.Cookie {
+	t.Helper()
+
+	value, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &http.Cookie{Name: OIDC_COOKIE, Value: base64.RawURLEncoding.EncodeToString(value)}
+}
+
+func TestOIDCCallback(t *testing.T) {
+	started := &oidcState{
+		State:    "state",
+		Verifier: "verifier",
+		Nonce:    "nonce",
+		Provider: "mock",
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie *http.Cookie
+		status uint16
+	}{
+		{"matching state", "?type=oidc&state=state&code=code", oidcCookie(t, started), 0},
+		{"never started", "?type=oidc&state=state&code=code", nil, http.StatusBadRequest},
+		{"malformed cookie", "?type=oidc&state=state&code=code", &http.Cookie{Name: OIDC_COOKIE, Value: "!!"}, http.StatusBadRequest},
+		{"other state", "?type=oidc&state=other&code=code", oidcCookie(t, started), http.StatusBadRequest},
+		{"no state", "?type=oidc&code=code", oidcCookie(t, started), http.StatusBadRequest},
+		{"empty state", "?type=oidc&state=&code=code", oidcCookie(t, &oidcState{Provider: "mock"}), http.StatusBadRequest},
+		{"refused by the provider", "?type=oidc&state=state&error=access_denied", oidcCookie(t, started), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/log/"+tt.query, nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			a := new(logInRecorder)
+
+			_, _, err := oidcCallback(w, r, a)
+
+			// the cookie is only good for one callback
+			if tt.cookie != nil {
+				if c := w.Result().Cookies(); len(c) != 1 || c[0].Name != OIDC_COOKIE || c[0].Expires.After(time.Now()) {
+					t.Errorf("oidcCallback() didn't clear the HTTP Cookie `%s`", OIDC_COOKIE)
+				}
+			}
+
+			if tt.status != 0 {
+				if err, ok := err.(*mux.HttpError); !ok || err.Status != tt.status {
+					t.Errorf("oidcCallback() error = %v, want the status %d", err, tt.status)
+				}
+				if a.in != nil {
+					t.Error("oidcCallback() logged in")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("oidcCallback() error = %v", err)
+			}
+
+			oidc := a.in.GetCredentials().GetOidc()
+			if oidc.GetProvider() != "mock" || oidc.GetCode() != "code" || oidc.GetVerifier() != "verifier" || oidc.GetNonce() != "nonce" {
+				t.Errorf("oidcCallback() logged in with %v, want the verifier and nonce of the HTTP Cookie", oidc)
+			}
+			if want := "https://auth.example.com/log/?type=oidc"; oidc.GetRedirectUri() != want {
+				t.Errorf("oidcCallback() redirect uri = %q, want %q", oidc.GetRedirectUri(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+)
+
+// PermissionsRoute lists the scopes that can be asked for a token, and which
+// of them are given by `default`.
+func PermissionsRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	h := w.Header()
+
+	if len(segs) > 1 || (len(segs) == 1 && segs[0] != "") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.Set("Access-Control-Allow-Origin", "*")
+		h.Set("Cache-Control", "public, max-age=3600")
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Scopes  []auth.Scope `json:"scopes"`
+			Default []string     `json:"default"`
+		}{
+			Scopes:  auth.Registry(),
+			Default: auth.DEFAULT_PERMISSIONS.Scopes(),
+		})
+	case http.MethodOptions:
+		h.Set("Access-Control-Allow-Origin", "*")
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodGet)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+/*
+GET    /roles/                     the roles and their scopes
+GET    /roles/<identifier>         the roles of identifier
+PUT    /roles/<identifier>/<role>  grant the role
+DELETE /roles/<identifier>/<role>  revoke the role
+*/
+
+func RolesRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	var (
+		err    error
+		res    any
+		status uint32
+	)
+
+	h := w.Header()
+	status, err = helpers.CheckOriginHeader(&h, r, true)
+
+	if len(segs) > 0 && segs[len(segs)-1] == "" {
+		segs = segs[:len(segs)-1]
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		if r.Method == http.MethodGet && len(segs) == 0 {
+			roles := make(map[string][]string)
+			for k, v := range auth.Roles() {
+				roles[k] = v.Scopes()
+			}
+
+			res, status, err = roles, http.StatusOK, nil
+			break
+		}
+
+		if err != nil {
+			break
+		}
+
+		if (r.Method == http.MethodGet && len(segs) != 1) || (r.Method != http.MethodGet && len(segs) != 2) {
+			err = &mux.HttpError{Status: http.StatusNotFound}
+			break
+		}
+
+		var jwt []byte
+		if jwt, err = bloqs_helpers.ExtractToken(w, r); err != nil {
+			break
+		}
+
+		var a proto.AuthServer
+		if a, err = authSrv(r.Context()); err != nil {
+			break
+		}
+
+		in := &proto.RoleAssignment{
+			Token:      &proto.Token{Jwt: string(jwt)},
+			Identifier: segs[0],
+		}
+
+		var valid *proto.Validation
+		switch r.Method {
+		case http.MethodGet:
+			var roles *proto.Roles
+			roles, err = a.GetRoles(r.Context(), in)
+			valid, res = roles.GetValidation(), roles
+		case http.MethodPut:
+			in.Role = segs[1]
+			valid, err = a.GrantRole(r.Context(), in)
+			res = valid
+		default:
+			in.Role = segs[1]
+			valid, err = a.RevokeRole(r.Context(), in)
+			res = valid
+		}
+
+		if valid == nil {
+			res = nil
+			break
+		}
+
+		status = valid.GetHttpStatusCode()
+		valid.HttpStatusCode = nil
+		// the validation already describes the error
+		err = nil
+	case http.MethodOptions:
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodGet)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPut)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodDelete)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Max-Age", "0")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		err = &mux.HttpError{Status: http.StatusMethodNotAllowed}
+	}
+
+	if err != nil {
+		status = auth.ErrorStatus(err, http.StatusInternalServerError)
+		res = auth.ErrorToValidation(err, nil)
+	}
+
+	if status == 0 {
+		status = http.StatusInternalServerError
+		if err == nil {
+			status = http.StatusOK
+		}
+	}
+
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(int(status))
+	json.NewEncoder(w).Encode(res)
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
 	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
 )
 
 func Server(endpoint string) http.HandlerFunc {
@@ -21,16 +23,44 @@ func Server(endpoint string) http.HandlerFunc {
 	sign_route := conf.MustGetConfOrDefault("/sign/", "auth", "paths", "sign")
 	log_route := conf.MustGetConfOrDefault("/log/", "auth", "paths", "log")
 	types_route := conf.MustGetConfOrDefault("/types/", "auth", "paths", "types")
+	verify_route := conf.MustGetConfOrDefault("/verify/", "auth", "paths", "verify")
+	jwks_route := conf.MustGetConfOrDefault("/.well-known/jwks.json", "auth", "paths", "jwks")
+	webauthn_route := conf.MustGetConfOrDefault("/webauthn/", "auth", "paths", "webauthn")
+	totp_route := conf.MustGetConfOrDefault("/totp/", "auth", "paths", "totp")
+	email_route := conf.MustGetConfOrDefault("/email/", "auth", "paths", "email")
+	permissions_route := conf.MustGetConfOrDefault("/permissions/", "auth", "paths", "permissions")
+	roles_route := conf.MustGetConfOrDefault("/roles/", "auth", "paths", "roles")
+	keys_route := conf.MustGetConfOrDefault("/keys/", "auth", "paths", "keys")
+	audit_route := conf.MustGetConfOrDefault("/audit/", "auth", "paths", "audit")
 
 	r := mux.NewRouter(endpoint)
 	r.Route(sign_route, SignRoute)
 	r.Route(log_route, LogRoute)
+	r.Route(verify_route, VerifyRoute)
+	r.Route(jwks_route, JWKSRoute)
+	r.Route(webauthn_route, WebAuthnRoute)
+	r.Route(totp_route, TOTPRoute)
+	r.Route(email_route, EmailRoute)
+	r.Route(permissions_route, PermissionsRoute)
+	r.Route(roles_route, RolesRoute)
+	r.Route(keys_route, APIKeysRoute)
+	r.Route(audit_route, AuditRoute)
 	r.Route(types_route, func(w http.ResponseWriter, r *http.Request, segs []string) {
-		// XXX
-		json.NewEncoder(w).Encode(conf.MustGetConfOrDefault(map[string]bool{}, "auth", "supported"))
+		types := make(map[string]bool, len(auth.AuthTypes))
+		for _, i := range auth.AuthTypes {
+			types[i] = auth.IsAuthMethodSupported(i)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types)
 	})
 
-	return r.ServeHTTP
+	return func(w http.ResponseWriter, req *http.Request) {
+		// who made the request, for the throttling, the sessions and the audit
+		// log
+		ctx := auth.WithClientIP(req.Context(), bloqs_helpers.ClientIP(req))
+		r.ServeHTTP(w, req.WithContext(auth.WithUserAgent(ctx, req.UserAgent())))
+	}
 }
 
 func Serve(endpoint string, w http.ResponseWriter, r *http.Request) {
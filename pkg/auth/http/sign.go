@@ -8,13 +8,15 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/bloqs-sites/bloqsenjin/internal/auth"
 	"github.com/bloqs-sites/bloqsenjin/internal/db"
 	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/audit"
 	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
-	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/pkg/email"
 	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
 	"github.com/bloqs-sites/bloqsenjin/proto"
 	"github.com/redis/go-redis/v9"
@@ -36,6 +38,8 @@ func SignRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 		a proto.AuthServer
 	)
 
+	r = r.WithContext(email.WithAcceptLanguage(r.Context(), r.Header.Get("Accept-Language")))
+
 	h := w.Header()
 	status, err = helpers.CheckOriginHeader(&h, r, true)
 
@@ -138,12 +142,10 @@ func SignRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 		}
 	case http.MethodDelete:
 		if err != nil {
-			v = bloqs_auth.Invalid("", &status)
+			v = bloqs_auth.ErrorToValidation(err, &status)
 			goto respond
 		}
 
-		var token *proto.Token
-
 		a, err = authSrv(r.Context())
 		if err != nil {
 			status = http.StatusInternalServerError
@@ -153,32 +155,59 @@ func SignRoute(w http.ResponseWriter, r *http.Request, segs []string) {
 
 		var jwt []byte
 		jwt, err = bloqs_helpers.ExtractToken(w, r)
-		token = &proto.Token{
-			Jwt: string(jwt),
+		if err != nil {
+			status = bloqs_auth.ErrorStatus(err, http.StatusUnauthorized)
+			v = bloqs_auth.ErrorToValidation(err, &status)
+			goto respond
 		}
 
+		v, err = a.SignOut(r.Context(), &proto.Token{
+			Jwt: string(jwt),
+		})
+		goto respond
+	case http.MethodPatch: // change the password or the email
 		if err != nil {
-			if err, ok := err.(*mux.HttpError); ok {
-				*v.HttpStatusCode = uint32(err.Status)
-			} else {
-				*v.HttpStatusCode = http.StatusInternalServerError
-			}
-			v, err = a.LogOut(r.Context(), token)
+			v = bloqs_auth.ErrorToValidation(err, &status)
 			goto respond
 		}
 
-		if jwt != nil {
-			status = http.StatusUnauthorized
+		if len(segs) != 1 || (segs[0] != "password" && segs[0] != "email") {
+			status = http.StatusNotFound
 			v = bloqs_auth.Invalid("", &status)
 			goto respond
 		}
 
-		switch r.URL.Query().Get(conf.MustGetConfOrDefault("type", "auth", "queryParams", "type")) {
-		case "basic":
-			v, err = a.SignOut(r.Context(), token)
+		var jwt []byte
+		jwt, err = bloqs_helpers.ExtractToken(w, r)
+		if err != nil {
+			status = bloqs_auth.ErrorStatus(err, http.StatusUnauthorized)
+			v = bloqs_auth.ErrorToValidation(err, &status)
+			goto respond
+		}
+
+		a, err = authSrv(r.Context())
+		if err != nil {
+			status = http.StatusInternalServerError
+			v = bloqs_auth.ErrorToValidation(err, &status)
+			goto respond
 		}
+
+		if segs[0] == "password" {
+			v, err = a.ChangePassword(r.Context(), &proto.PasswordChange{
+				Token:       &proto.Token{Jwt: string(jwt)},
+				OldPassword: r.FormValue("old"),
+				NewPassword: r.FormValue("pass"),
+			})
+		} else {
+			v, err = a.ChangeEmail(r.Context(), &proto.EmailChange{
+				Token: &proto.Token{Jwt: string(jwt)},
+				Email: r.FormValue("email"),
+			})
+		}
+		goto respond
 	case http.MethodOptions:
 		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPost)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPatch)
 		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodDelete)
 		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
 		h.Set("Access-Control-Allow-Credentials", "true")
@@ -252,28 +281,84 @@ respond:
 //}
 
 func authSrv(ctx context.Context) (proto.AuthServer, error) {
+	a, err := autherSrv(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := tokenerSrv(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := mailerSrv()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := auditSrv(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return bloqs_auth.NewAuthServer(a, t, m, l), nil
+}
+
+// auditSrv keeps the audit log in the DB at `BLOQS_AUDIT_MYSQL_DSN`, or with
+// the credentials without it.
+func auditSrv(ctx context.Context) (audit.Sink, error) {
+	dsn, ok := os.LookupEnv("BLOQS_AUDIT_MYSQL_DSN")
+	if !ok {
+		dsn = os.Getenv("BLOQS_AUTH_MYSQL_DSN")
+	}
+
+	logs, err := db.NewMySQL(ctx, strings.TrimSpace(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("error creating DB instance of type `%T`:\t%s", logs, err)
+	}
+
+	return audit.NewSinkFromConf(ctx, logs)
+}
+
+var (
+	mailer      bloqs_auth.Mailer
+	mailer_err  error
+	mailer_once sync.Once
+)
+
+// mailerSrv is shared by all the requests, so the emails queued by one are
+// still sent after it's answered.
+func mailerSrv() (bloqs_auth.Mailer, error) {
+	mailer_once.Do(func() {
+		var q *email.Queue
+		if q, mailer_err = email.NewQueueFromConf(); q != nil {
+			mailer = q
+		}
+	})
+
+	return mailer, mailer_err
+}
+
+func autherSrv(ctx context.Context) (bloqs_auth.Auther, error) {
 	// TODO: How can I make it that you can specify which implementation of the interfaces you want to use?
 	creds, err := db.NewMySQL(ctx, strings.TrimSpace(os.Getenv("BLOQS_AUTH_MYSQL_DSN")))
 	if err != nil {
 		return nil, fmt.Errorf("error creating DB instance of type `%T`:\t%s", creds, err)
 	}
 
+	return auth.NewBloqsAuther(ctx, creds)
+}
+
+func tokenerSrv(ctx context.Context) (bloqs_auth.Tokener, error) {
 	opt, err := redis.ParseURL(strings.TrimSpace(os.Getenv("BLOQS_TOKENS_REDIS_DSN")))
 	if err != nil {
 		return nil, fmt.Errorf("could not parse the `BLOQS_TOKENS_REDIS_DSN` to create the credentials to connect to the DB:\t%s", err)
 	}
 
-	a, err := auth.NewBloqsAuther(ctx, creds)
-	if err != nil {
-		return nil, err
-	}
-
 	secrets, err := db.NewKeyDB(ctx, opt)
 	if err != nil {
 		return nil, fmt.Errorf("error creating DB instance of type `%T`:\t%s", secrets, err)
 	}
 
-	t := auth.NewBloqsTokener(secrets)
-
-	return bloqs_auth.NewAuthServer(a, t), nil
+	return auth.NewBloqsTokener(secrets), nil
 }
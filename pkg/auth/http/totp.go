@@ -0,0 +1,105 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+/*
+POST   /totp/         enrol
+POST   /totp/confirm  confirm the enrolment with a `code`
+DELETE /totp/         disable with a `code`
+*/
+
+func TOTPRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	var (
+		err    error
+		res    any
+		status uint32
+	)
+
+	h := w.Header()
+	status, err = helpers.CheckOriginHeader(&h, r, true)
+
+	switch r.Method {
+	case http.MethodPost, http.MethodDelete:
+		if err != nil {
+			break
+		}
+
+		confirm := len(segs) > 0 && segs[0] == "confirm"
+		if len(segs) > 1 || (len(segs) == 1 && segs[0] != "" && (!confirm || r.Method != http.MethodPost)) {
+			err = &mux.HttpError{Status: http.StatusNotFound}
+			break
+		}
+
+		var jwt []byte
+		if jwt, err = bloqs_helpers.ExtractToken(w, r); err != nil {
+			break
+		}
+		tk := &proto.Token{Jwt: string(jwt)}
+
+		var a proto.AuthServer
+		if a, err = authSrv(r.Context()); err != nil {
+			break
+		}
+
+		var valid *proto.Validation
+		switch {
+		case r.Method == http.MethodDelete:
+			valid, err = a.DisableTOTP(r.Context(), &proto.TOTPCode{Token: tk, Code: r.FormValue("code")})
+			res = valid
+		case confirm:
+			var codes *proto.RecoveryCodes
+			codes, err = a.ConfirmTOTP(r.Context(), &proto.TOTPCode{Token: tk, Code: r.FormValue("code")})
+			valid, res = codes.GetValidation(), codes
+		default:
+			var enrolment *proto.TOTPEnrolment
+			enrolment, err = a.EnrolTOTP(r.Context(), tk)
+			valid, res = enrolment.GetValidation(), enrolment
+		}
+
+		if valid == nil {
+			res = nil
+			break
+		}
+
+		status = valid.GetHttpStatusCode()
+		valid.HttpStatusCode = nil
+		// the validation already describes the error
+		err = nil
+	case http.MethodOptions:
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPost)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodDelete)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Max-Age", "0")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		err = &mux.HttpError{Status: http.StatusMethodNotAllowed}
+	}
+
+	if err != nil {
+		status = auth.ErrorStatus(err, http.StatusInternalServerError)
+		res = auth.ErrorToValidation(err, nil)
+	}
+
+	if status == 0 {
+		status = http.StatusInternalServerError
+		if err == nil {
+			status = http.StatusOK
+		}
+	}
+
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(int(status))
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	bloqs_auth "github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+// VerifyRoute is the HTTP counterpart of the `Validate` and `Introspect` RPCs
+// for the services that cannot reach the gRPC server.
+func VerifyRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	var (
+		err    error
+		v      *proto.Validation
+		status uint32
+
+		a proto.AuthServer
+	)
+
+	h := w.Header()
+	status, err = helpers.CheckOriginHeader(&h, r, false)
+
+	switch r.Method {
+	case http.MethodPost:
+		if err != nil {
+			v = bloqs_auth.ErrorToValidation(err, &status)
+			goto respond
+		}
+
+		var jwt []byte
+		jwt, err = bloqs_helpers.ExtractToken(w, r)
+		if err != nil {
+			status = bloqs_auth.ErrorStatus(err, http.StatusUnauthorized)
+			v = bloqs_auth.ErrorToValidation(err, &status)
+			goto respond
+		}
+
+		// `introspect` answers with the claims, it's how the ones of the API
+		// keys are known
+		if len(segs) > 0 && segs[0] == "introspect" {
+			if a, err = authSrv(r.Context()); err != nil {
+				status = http.StatusInternalServerError
+				v = bloqs_auth.ErrorToValidation(err, &status)
+				goto respond
+			}
+
+			i, _ := a.Introspect(r.Context(), &proto.Token{Jwt: string(jwt)})
+			status = i.GetValidation().GetHttpStatusCode()
+			i.Validation.HttpStatusCode = nil
+
+			h.Set("Content-Type", "application/json")
+			w.WriteHeader(int(status))
+			json.NewEncoder(w).Encode(i)
+			return
+		}
+
+		// the scopes, or the mask from before them
+		var (
+			permissions uint64
+			scopes      []string
+		)
+		r.ParseForm()
+		for _, p := range r.Form["permissions"] {
+			if mask, err := strconv.ParseUint(p, 10, 64); err == nil {
+				permissions |= mask
+				continue
+			}
+
+			if _, err = bloqs_auth.ParseScopes([]string{p}); err != nil {
+				status = http.StatusUnprocessableEntity
+				v = bloqs_auth.Invalid(fmt.Sprintf("`permissions` body field has to be scopes or an unsigned integer:\t%s", err), &status)
+				goto respond
+			}
+			scopes = append(scopes, p)
+		}
+
+		a, err = authSrv(r.Context())
+		if err != nil {
+			status = http.StatusInternalServerError
+			v = bloqs_auth.ErrorToValidation(err, &status)
+			goto respond
+		}
+
+		v, err = a.Validate(r.Context(), &proto.Token{
+			Jwt:         string(jwt),
+			Permissions: &permissions,
+			Scopes:      scopes,
+		})
+		if v != nil && v.HttpStatusCode == nil {
+			status = http.StatusOK
+			if err != nil {
+				status = bloqs_auth.ErrorStatus(err, http.StatusUnauthorized)
+			}
+			v.HttpStatusCode = &status
+		}
+	case http.MethodOptions:
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPost)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		h.Set("Access-Control-Max-Age", "0")
+		status = http.StatusNoContent
+		v = bloqs_auth.Valid("", &status)
+	default:
+		status = http.StatusMethodNotAllowed
+		v = bloqs_auth.Invalid("", &status)
+	}
+
+respond:
+	if v == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status = http.StatusInternalServerError
+	if v.HttpStatusCode != nil {
+		status = *v.HttpStatusCode
+		v.HttpStatusCode = nil
+	}
+
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(int(status))
+	if status != http.StatusNoContent {
+		json.NewEncoder(w).Encode(v)
+	}
+}
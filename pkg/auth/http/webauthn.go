@@ -0,0 +1,213 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
+	"github.com/bloqs-sites/bloqsenjin/pkg/auth"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	bloqs_helpers "github.com/bloqs-sites/bloqsenjin/pkg/http/helpers"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+)
+
+/*
+/webauthn/register/options
+/webauthn/register
+/webauthn/login/options
+/webauthn/login
+*/
+
+// publicKeyCredential is the JSON encoding of the `PublicKeyCredential` the
+// browser returns, with the binary values as base64url.
+type publicKeyCredential struct {
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+	} `json:"response"`
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func (c *publicKeyCredential) toProto() (*proto.Credentials_Webauthn, error) {
+	if c.Type != "public-key" {
+		return nil, fmt.Errorf("unsupported credential type `%s`", c.Type)
+	}
+
+	fields := []string{c.RawID, c.Response.ClientDataJSON, c.Response.AttestationObject, c.Response.AuthenticatorData, c.Response.Signature}
+	decoded := make([][]byte, len(fields))
+	for k, v := range fields {
+		var err error
+		if decoded[k], err = decodeBase64URL(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return &proto.Credentials_Webauthn{
+		Webauthn: &proto.Credentials_WebAuthnCredentials{
+			CredentialId:      decoded[0],
+			ClientDataJson:    decoded[1],
+			AttestationObject: decoded[2],
+			AuthenticatorData: decoded[3],
+			Signature:         decoded[4],
+		},
+	}, nil
+}
+
+func WebAuthnRoute(w http.ResponseWriter, r *http.Request, segs []string) {
+	var (
+		err    error
+		res    any
+		status uint32
+	)
+
+	h := w.Header()
+	status, err = helpers.CheckOriginHeader(&h, r, true)
+
+	switch r.Method {
+	case http.MethodPost:
+		if err != nil {
+			break
+		}
+
+		if !auth.IsAuthMethodSupported("webauthn") {
+			err = &mux.HttpError{
+				Body:   "WebAuthn is not supported",
+				Status: http.StatusUnprocessableEntity,
+			}
+			break
+		}
+
+		if len(segs) == 0 || (segs[0] != "register" && segs[0] != "login") || len(segs) > 2 || (len(segs) == 2 && segs[1] != "options" && segs[1] != "") {
+			err = &mux.HttpError{Status: http.StatusNotFound}
+			break
+		}
+
+		register := segs[0] == "register"
+		status = http.StatusOK
+		if len(segs) == 2 && segs[1] == "options" {
+			res, err = webAuthnOptions(w, r, register)
+		} else {
+			res, err = webAuthnFinish(w, r, register)
+			if register {
+				status = http.StatusCreated
+			}
+		}
+	case http.MethodOptions:
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodPost)
+		bloqs_helpers.Append(&h, "Access-Control-Allow-Methods", http.MethodOptions)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Max-Age", "0")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		err = &mux.HttpError{Status: http.StatusMethodNotAllowed}
+	}
+
+	if err != nil {
+		status = auth.ErrorStatus(err, http.StatusInternalServerError)
+		res = auth.ErrorToValidation(err, nil)
+	}
+
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(int(status))
+	json.NewEncoder(w).Encode(res)
+}
+
+// webAuthnOptions starts a ceremony. To add an authenticator to credentials
+// that already exist you have to be logged in with them.
+func webAuthnOptions(w http.ResponseWriter, r *http.Request, register bool) (any, error) {
+	identifier := r.FormValue("email")
+
+	a, err := autherSrv(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	owner := false
+	if register && identifier != "" {
+		if jwt, err := bloqs_helpers.ExtractToken(w, r); err == nil {
+			t, err := tokenerSrv(r.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			if claims, err := t.GetClaims(r.Context(), auth.Token(jwt)); err == nil {
+				owner = claims.Subject == identifier
+			}
+		}
+	}
+
+	challenge, credentials, err := a.BeginWebAuthn(r.Context(), identifier, register, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if register {
+		return map[string]any{"publicKey": auth.CreationOptions(challenge, identifier, credentials)}, nil
+	}
+
+	return map[string]any{"publicKey": auth.RequestOptions(challenge, credentials)}, nil
+}
+
+func webAuthnFinish(w http.ResponseWriter, r *http.Request, register bool) (any, error) {
+	var c publicKeyCredential
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&c); err != nil {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the HTTP request body could not be parsed as a `PublicKeyCredential`:\t%s", err),
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	creds, err := c.toProto()
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the `PublicKeyCredential` is malformed:\t%s", err),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	a, err := authSrv(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	if register {
+		v, err := a.SignIn(r.Context(), &proto.Credentials{Credentials: creds})
+		if v != nil {
+			v.HttpStatusCode = nil
+		}
+		return v, err
+	}
+
+	permissions, err := askedPermissions(r)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := a.LogIn(r.Context(), &proto.AskPermissions{
+		Credentials: &proto.Credentials{Credentials: creds},
+		Scopes:      permissions.Scopes(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bloqs_helpers.SetToken(w, r, v.Token.Jwt)
+	bloqs_helpers.SetRefreshToken(w, r, v.Token.GetRefresh())
+	if v.Validation != nil {
+		v.Validation.HttpStatusCode = nil
+	}
+
+	return v, nil
+}
@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// AsymmetricMethods are the signing methods the tokens can be verified
+	// with using only the published keys.
+	AsymmetricMethods = []string{"EdDSA", "ES256"}
+	// ValidMethods are the signing methods accepted by the auth service. The
+	// HMAC ones are only there for the tokens signed before the asymmetric
+	// keys.
+	ValidMethods = append([]string{"HS256", "HS384", "HS512"}, AsymmetricMethods...)
+)
+
+// JWK is a public key as described in RFC 7517. Only Ed25519, P-256 and RSA
+// keys are supported, the RSA ones only to verify the tokens of OpenID
+// providers.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewJWK(kid string, pub crypto.PublicKey) (*JWK, error) {
+	enc := base64.RawURLEncoding
+
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		return &JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(k),
+			Kid: kid,
+			Alg: "EdDSA",
+			Use: "sig",
+		}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve `%s`", k.Curve.Params().Name)
+		}
+
+		x, y := make([]byte, 32), make([]byte, 32)
+		k.X.FillBytes(x)
+		k.Y.FillBytes(y)
+
+		return &JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   enc.EncodeToString(x),
+			Y:   enc.EncodeToString(y),
+			Kid: kid,
+			Alg: "ES256",
+			Use: "sig",
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type `%T`", pub)
+	}
+}
+
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	enc := base64.RawURLEncoding
+
+	if k.Kty == "RSA" {
+		n, err := enc.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := enc.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > math.MaxInt32 {
+			return nil, errors.New("invalid RSA public key")
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exp.Int64()),
+		}, nil
+	}
+
+	x, err := enc.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		y, err := enc.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("invalid P-256 public key")
+		}
+
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported key `%s` `%s`", k.Kty, k.Crv)
+	}
+}
+
+// JWKS_REFETCH_INTERVAL is how long a JWKSVerifier waits after fetching the
+// keys before a token signed by an unknown key makes it fetch them again.
+const JWKS_REFETCH_INTERVAL = 10 * time.Second
+
+// JWKSVerifier validates tokens offline with the public keys the auth service
+// publishes at its `jwks` path. The keys are cached and fetched again once the
+// cache is stale or a token comes signed by an unknown key, at most once every
+// JWKS_REFETCH_INTERVAL, and the fetches at the same time are made only once.
+// The cached keys keep being used while they can't be fetched.
+//
+// It can't know about tokens that were revoked, so it should only be used
+// where the short life of the access tokens is enough.
+type JWKSVerifier struct {
+	url    string
+	ttl    time.Duration
+	http   *http.Client
+	mu     sync.RWMutex
+	keys   map[string]crypto.PublicKey
+	expiry time.Time
+	// fetched is when the keys were last fetched, successfully or not
+	fetched time.Time
+	// fetching is closed when the fetch going on ends, with its error in
+	// fetchErr
+	fetching chan struct{}
+	fetchErr error
+}
+
+// NewJWKSVerifier creates a verifier for the JWKS at url. An empty url uses
+// `auth.domain` and `auth.paths.jwks`.
+func NewJWKSVerifier(url string, ttl time.Duration) *JWKSVerifier {
+	if url == "" {
+		url = fmt.Sprint(
+			conf.MustGetConfOrDefault("", "auth", "domain"),
+			conf.MustGetConfOrDefault("/.well-known/jwks.json", "auth", "paths", "jwks"),
+		)
+	}
+
+	return &JWKSVerifier{
+		url:  url,
+		ttl:  ttl,
+		http: http.DefaultClient,
+		keys: map[string]crypto.PublicKey{},
+	}
+}
+
+func (v *JWKSVerifier) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := v.http.Do(req)
+	if err != nil {
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("could not fetch the JWKS:\t%s", err),
+			Status: http.StatusServiceUnavailable,
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("could not fetch the JWKS:\t%s", res.Status),
+			Status: http.StatusBadGateway,
+		}
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("the JWKS is malformed:\t%s", err),
+			Status: http.StatusBadGateway,
+		}
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.PublicKey()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.expiry = time.Now().Add(v.ttl)
+	v.mu.Unlock()
+
+	return nil
+}
+
+// refetch fetches the keys, or waits for the fetch that's going on.
+func (v *JWKSVerifier) refetch(ctx context.Context) error {
+	v.mu.Lock()
+	if ch := v.fetching; ch != nil {
+		v.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		v.mu.RLock()
+		defer v.mu.RUnlock()
+		return v.fetchErr
+	}
+
+	ch := make(chan struct{})
+	v.fetching = ch
+	v.mu.Unlock()
+
+	err := v.fetch(ctx)
+
+	v.mu.Lock()
+	v.fetching = nil
+	v.fetchErr = err
+	v.fetched = time.Now()
+	v.mu.Unlock()
+	close(ch)
+
+	return err
+}
+
+func (v *JWKSVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	pub, ok := v.keys[kid]
+	stale := time.Now().After(v.expiry)
+	recent := time.Since(v.fetched) < JWKS_REFETCH_INTERVAL
+	v.mu.RUnlock()
+
+	if ok && !stale {
+		return pub, nil
+	}
+
+	// tokens signed by made up keys don't get to fetch the keys each time, and
+	// while they can't be fetched the ones there are keep being used
+	if recent {
+		if ok {
+			return pub, nil
+		}
+
+		return nil, fmt.Errorf("unknown signing key `%s`", kid)
+	}
+
+	if err := v.refetch(ctx); err != nil {
+		if ok {
+			fmt.Printf("%v\n", err)
+			return pub, nil
+		}
+
+		return nil, err
+	}
+
+	v.mu.RLock()
+	pub, ok = v.keys[kid]
+	v.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key `%s`", kid)
+	}
+
+	return pub, nil
+}
+
+// Keyfunc finds the key a token was signed with by its `kid` header.
+func (v *JWKSVerifier) Keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		kid, ok := t.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("the token has no `kid` header")
+		}
+
+		return v.key(ctx, kid)
+	}
+}
+
+func (v *JWKSVerifier) GetClaims(ctx context.Context, tk Token) (*Claims, error) {
+	auth_api := conf.MustGetConfOrDefault("", "auth", "domain")
+	rest_api := conf.MustGetConfOrDefault("", "REST", "domain")
+
+	token, err := jwt.ParseWithClaims(string(tk), &Claims{}, v.Keyfunc(ctx), jwt.WithValidMethods(AsymmetricMethods), jwt.WithJSONNumber(), jwt.WithLeeway(5*time.Second))
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || (claims.Issuer != auth_api && claims.Issuer != rest_api) {
+		return nil, &mux.HttpError{
+			Body:   "couldn't handle this token",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	return claims, nil
+}
+
+func (v *JWKSVerifier) Validate(ctx context.Context, in *proto.Token) (*proto.Validation, error) {
+	if IsAPIKey(in.Jwt) {
+		msg := "API keys can only be checked by the auth service"
+		return Invalid(msg, nil), &mux.HttpError{
+			Body:   msg,
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	claims, err := v.GetClaims(ctx, Token(strings.TrimSpace(in.Jwt)))
+	if err != nil {
+		msg := err.Error()
+		return Invalid(msg, nil), err
+	}
+
+	p := FromProto(in.GetScopes(), in.GetPermissions())
+	if !claims.Payload.Permissions.Has(p) {
+		err := NoPermissionsError{Permission: p}
+		msg := err.Error()
+
+		return Invalid(msg, nil), &mux.HttpError{
+			Body:   msg,
+			Status: http.StatusForbidden,
+		}
+	}
+
+	return &proto.Validation{
+		Valid: true,
+	}, nil
+}
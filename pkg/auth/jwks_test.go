@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer serves a JWKS with one key and counts how many times it was
+// fetched. Each fetch takes a while so the ones at the same time overlap.
+func jwksServer(t *testing.T, kid string) (*httptest.Server, *int32) {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := NewJWK(kid, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(20 * time.Millisecond)
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{*jwk}})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &fetches
+}
+
+func TestJWKSVerifierKey(t *testing.T) {
+	ctx := context.Background()
+	srv, fetches := jwksServer(t, "known")
+	v := NewJWKSVerifier(srv.URL, time.Hour)
+
+	// the first tokens all need the keys, they're fetched once
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.key(ctx, "known"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(fetches); n != 1 {
+		t.Errorf("the keys were fetched %d times at once, want 1", n)
+	}
+
+	// unknown keys right after don't fetch them again
+	for i := 0; i < 16; i++ {
+		if _, err := v.key(ctx, "unknown"); err == nil {
+			t.Errorf("key() of an unknown key didn't fail")
+		}
+	}
+	if n := atomic.LoadInt32(fetches); n != 1 {
+		t.Errorf("the keys were fetched %d times for unknown keys, want 1", n)
+	}
+
+	// until a while after the last fetch
+	v.mu.Lock()
+	v.fetched = time.Now().Add(-JWKS_REFETCH_INTERVAL)
+	v.mu.Unlock()
+
+	if _, err := v.key(ctx, "unknown"); err == nil {
+		t.Errorf("key() of an unknown key didn't fail")
+	}
+	if n := atomic.LoadInt32(fetches); n != 2 {
+		t.Errorf("the keys were fetched %d times, want 2", n)
+	}
+}
+
+func TestJWKSVerifierStale(t *testing.T) {
+	ctx := context.Background()
+	srv, fetches := jwksServer(t, "known")
+	v := NewJWKSVerifier(srv.URL, time.Hour)
+
+	if _, err := v.key(ctx, "known"); err != nil {
+		t.Fatal(err)
+	}
+
+	// stale keys are fetched again
+	v.mu.Lock()
+	v.expiry = time.Now().Add(-time.Second)
+	v.fetched = time.Now().Add(-JWKS_REFETCH_INTERVAL)
+	v.mu.Unlock()
+
+	if _, err := v.key(ctx, "known"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(fetches); n != 2 {
+		t.Errorf("the keys were fetched %d times, want 2", n)
+	}
+
+	// and while they can't be fetched the ones there are keep being used
+	srv.Close()
+	v.mu.Lock()
+	v.expiry = time.Now().Add(-time.Second)
+	v.fetched = time.Now().Add(-JWKS_REFETCH_INTERVAL)
+	v.mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if _, err := v.key(ctx, "known"); err != nil {
+			t.Errorf("key() while the keys can't be fetched error = %v", err)
+		}
+	}
+	if _, err := v.key(ctx, "unknown"); err == nil {
+		t.Errorf("key() of an unknown key didn't fail")
+	}
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/testconf"
+)
+
+// testConf is the configuration the tests run with, everything but the
+// WebAuthn relying party is its default.
+const testConf = `{"auth": {"webauthn": {"rp_id": "example.com"}}}`
+
+func TestMain(m *testing.M) {
+	cleanup, err := testconf.Compile(testConf)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCClient is the HTTP client used to talk with the OpenID providers, it
+// can be replaced to point them to a mock provider.
+var OIDCClient = http.DefaultClient
+
+// OIDCProvider is an OpenID Connect provider as configured at
+// `auth.oidc.providers.<name>`. The client secret can also be defined in the
+// `BLOQS_OIDC_<NAME>_SECRET` environment variable.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// OIDCIdentity is who the provider says logged in.
+type OIDCIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// ExternalID is how the identity is stored on the credentials.
+func (i *OIDCIdentity) ExternalID() string {
+	return fmt.Sprintf("%s:%s", i.Provider, i.Subject)
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+
+	keys    *JWKSVerifier
+	expires time.Time
+}
+
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+var (
+	discoveries    = map[string]*oidcDiscovery{}
+	discoveries_mu sync.Mutex
+)
+
+func GetOIDCProvider(name string) (*OIDCProvider, error) {
+	providers := conf.MustGetConfOrDefault(map[string]any{}, "auth", "oidc", "providers")
+
+	c, ok := providers[name].(map[string]any)
+	if !ok {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the OpenID provider `%s` is not supported", name),
+			Status: http.StatusUnprocessableEntity,
+		}
+	}
+
+	p := &OIDCProvider{
+		Name:   name,
+		Scopes: []string{"openid", "email"},
+	}
+	p.Issuer, _ = c["issuer"].(string)
+	p.ClientID, _ = c["client_id"].(string)
+	p.ClientSecret, _ = c["client_secret"].(string)
+	if p.ClientSecret == "" {
+		p.ClientSecret = strings.TrimSpace(os.Getenv(fmt.Sprintf("BLOQS_OIDC_%s_SECRET", strings.ToUpper(name))))
+	}
+	if scopes, ok := c["scopes"].([]any); ok {
+		p.Scopes = p.Scopes[:0]
+		for _, i := range scopes {
+			if scope, ok := i.(string); ok {
+				p.Scopes = append(p.Scopes, scope)
+			}
+		}
+	}
+
+	if p.Issuer == "" || p.ClientID == "" {
+		return nil, fmt.Errorf("the OpenID provider `%s` needs an `issuer` and a `client_id`", name)
+	}
+
+	return p, nil
+}
+
+// discover gets the endpoints of the provider from its discovery document,
+// caching it for an hour.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	discoveries_mu.Lock()
+	d, ok := discoveries[p.Issuer]
+	discoveries_mu.Unlock()
+
+	if ok && time.Now().Before(d.expires) {
+		return d, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(p.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := OIDCClient.Do(req)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("could not reach the OpenID provider `%s`:\t%s", p.Name, err),
+			Status: http.StatusBadGateway,
+		}
+	}
+	defer res.Body.Close()
+
+	d = new(oidcDiscovery)
+	if res.StatusCode != http.StatusOK {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the OpenID provider `%s` responded with `%s` to the discovery", p.Name, res.Status),
+			Status: http.StatusBadGateway,
+		}
+	}
+	if err := json.NewDecoder(res.Body).Decode(d); err != nil {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the discovery document of the OpenID provider `%s` is malformed:\t%s", p.Name, err),
+			Status: http.StatusBadGateway,
+		}
+	}
+
+	if d.Issuer != p.Issuer {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the OpenID provider `%s` says its issuer is `%s`", p.Name, d.Issuer),
+			Status: http.StatusBadGateway,
+		}
+	}
+
+	d.keys = NewJWKSVerifier(d.JwksURI, time.Hour)
+	d.keys.http = OIDCClient
+	d.expires = time.Now().Add(time.Hour)
+
+	discoveries_mu.Lock()
+	discoveries[p.Issuer] = d
+	discoveries_mu.Unlock()
+
+	return d, nil
+}
+
+// AuthURL is where the user has to be redirected to log in with the provider.
+func (p *OIDCProvider) AuthURL(ctx context.Context, redirect_uri, state, challenge, nonce string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", redirect_uri)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange trades the authorization code for the ID token of the user and
+// verifies it.
+func (p *OIDCProvider) Exchange(ctx context.Context, c *proto.Credentials_OIDCCredentials) (*OIDCIdentity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", c.GetCode())
+	form.Set("redirect_uri", c.GetRedirectUri())
+	form.Set("code_verifier", c.GetVerifier())
+	form.Set("client_id", p.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	res, err := OIDCClient.Do(req)
+	if err != nil {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("could not reach the OpenID provider `%s`:\t%s", p.Name, err),
+			Status: http.StatusBadGateway,
+		}
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the OpenID provider `%s` responded with a malformed body:\t%s", p.Name, err),
+			Status: http.StatusBadGateway,
+		}
+	}
+
+	if res.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the OpenID provider `%s` refused the authorization code:\t%s %s", p.Name, body.Error, body.ErrorDescription),
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	claims := new(oidcClaims)
+	if _, err := jwt.ParseWithClaims(body.IDToken, claims, d.keys.Keyfunc(ctx),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "EdDSA"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithLeeway(5*time.Second),
+	); err != nil {
+		return nil, &mux.HttpError{
+			Body:   fmt.Sprintf("the ID token of the OpenID provider `%s` is invalid:\t%s", p.Name, err),
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, &mux.HttpError{
+			Body:   "the ID token does not expire",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	if claims.Nonce != c.GetNonce() {
+		return nil, &mux.HttpError{
+			Body:   "the ID token was not issued for this log in",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	if claims.Subject == "" {
+		return nil, &mux.HttpError{
+			Body:   "the ID token has no subject",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	// some providers send it as a string
+	verified := false
+	switch x := claims.EmailVerified.(type) {
+	case bool:
+		verified = x
+	case string:
+		verified = x == "true"
+	}
+
+	return &OIDCIdentity{
+		Provider:      p.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+	}, nil
+}
+
+// RandomString returns n random bytes encoded as unpadded base64url.
+func RandomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// NewPKCE returns a PKCE code verifier and its S256 challenge.
+func NewPKCE() (verifier string, challenge string, err error) {
+	if verifier, err = RandomString(32); err != nil {
+		return
+	}
+
+	return verifier, pkceChallenge(verifier), nil
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
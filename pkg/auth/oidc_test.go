@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+	"github.com/bloqs-sites/bloqsenjin/proto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	// from RFC 7636, appendix B
+	if got := pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("pkceChallenge() = %q, want the one of RFC 7636", got)
+	}
+}
+
+func TestNewPKCE(t *testing.T) {
+	seen := map[string]bool{}
+
+	for i := 0; i < 8; i++ {
+		verifier, challenge, err := NewPKCE()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// RFC 7636 wants between 43 and 128 characters
+		if len(verifier) != 43 {
+			t.Errorf("NewPKCE() verifier %q has %d characters, want 43", verifier, len(verifier))
+		}
+		if challenge != pkceChallenge(verifier) {
+			t.Errorf("NewPKCE() challenge %q is not the S256 of %q", challenge, verifier)
+		}
+		if seen[verifier] {
+			t.Errorf("NewPKCE() repeated the verifier %q", verifier)
+		}
+		seen[verifier] = true
+	}
+}
+
+// mockOIDCProvider is an OpenID provider that only issues ID tokens for the
+// code `code` asked with the verifier of its challenge.
+type mockOIDCProvider struct {
+	*httptest.Server
+	key       *ecdsa.PrivateKey
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &mockOIDCProvider{key: key}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 p.URL,
+				"authorization_endpoint": p.URL + "/authorize",
+				"token_endpoint":         p.URL + "/token",
+				"jwks_uri":               p.URL + "/jwks",
+			})
+		case "/jwks":
+			jwk, _ := NewJWK("test", &p.key.PublicKey)
+			json.NewEncoder(w).Encode(JWKS{Keys: []JWK{*jwk}})
+		case "/token":
+			if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != "code" ||
+				pkceChallenge(r.PostFormValue("code_verifier")) != p.challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+
+			token := jwt.NewWithClaims(jwt.SigningMethodES256, p.claims)
+			token.Header["kid"] = "test"
+			id_token, _ := token.SignedString(p.key)
+			json.NewEncoder(w).Encode(map[string]string{"id_token": id_token})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *mockOIDCProvider) provider() *OIDCProvider {
+	return &OIDCProvider{
+		Name:     "mock",
+		Issuer:   p.URL,
+		ClientID: "client",
+		Scopes:   []string{"openid", "email"},
+	}
+}
+
+func TestOIDCProviderAuthURL(t *testing.T) {
+	p := newMockOIDCProvider(t)
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := p.provider().AuthURL(context.Background(), "https://example.com/log/?type=oidc", "state", challenge, "nonce")
+	if err != nil {
+		t.Fatalf("AuthURL() error = %v", err)
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != p.URL+"/authorize" {
+		t.Errorf("AuthURL() redirects to %q, want the authorization endpoint", got)
+	}
+
+	q := u.Query()
+	for k, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"redirect_uri":          "https://example.com/log/?type=oidc",
+		"scope":                 "openid email",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        pkceChallenge(verifier),
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("AuthURL() %s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	p := newMockOIDCProvider(t)
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.challenge = challenge
+
+	now := time.Now()
+	claims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":            p.URL,
+			"aud":            "client",
+			"sub":            "subject",
+			"email":          "user@example.com",
+			"email_verified": true,
+			"nonce":          "nonce",
+			"iat":            now.Unix(),
+			"exp":            now.Add(time.Hour).Unix(),
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		verifier string
+		nonce    string
+		verified bool
+		status   uint16
+	}{
+		{"valid", claims(nil), verifier, "nonce", true, 0},
+		{"verified as a string", claims(func(c jwt.MapClaims) { c["email_verified"] = "true" }), verifier, "nonce", true, 0},
+		{"not verified", claims(func(c jwt.MapClaims) { c["email_verified"] = false }), verifier, "nonce", false, 0},
+		{"other verifier", claims(nil), "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", "nonce", false, http.StatusUnauthorized},
+		{"no verifier", claims(nil), "", "nonce", false, http.StatusUnauthorized},
+		{"other nonce", claims(nil), verifier, "other", false, http.StatusUnauthorized},
+		{"other audience", claims(func(c jwt.MapClaims) { c["aud"] = "other" }), verifier, "nonce", false, http.StatusUnauthorized},
+		{"other issuer", claims(func(c jwt.MapClaims) { c["iss"] = "https://example.org" }), verifier, "nonce", false, http.StatusUnauthorized},
+		{"expired", claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() }), verifier, "nonce", false, http.StatusUnauthorized},
+		{"never expires", claims(func(c jwt.MapClaims) { delete(c, "exp") }), verifier, "nonce", false, http.StatusUnauthorized},
+		{"no subject", claims(func(c jwt.MapClaims) { delete(c, "sub") }), verifier, "nonce", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.claims = tt.claims
+
+			id, err := p.provider().Exchange(context.Background(), &proto.Credentials_OIDCCredentials{
+				Provider:    "mock",
+				Code:        "code",
+				Verifier:    tt.verifier,
+				RedirectUri: "https://example.com/log/?type=oidc",
+				Nonce:       tt.nonce,
+			})
+
+			if tt.status != 0 {
+				if err, ok := err.(*mux.HttpError); !ok || err.Status != tt.status {
+					t.Fatalf("Exchange() error = %v, want the status %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+
+			if id.ExternalID() != "mock:subject" || id.Email != "user@example.com" || id.EmailVerified != tt.verified {
+				t.Errorf("Exchange() = %+v, want `mock:subject` verified %v", id, tt.verified)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// BREACHED_PREFIX_LEN is how many characters of the SHA-1 of the passwords
+// name the bucket they're looked for in.
+const BREACHED_PREFIX_LEN = 5
+
+var (
+	//go:embed common_passwords.txt
+	common_passwords_txt string
+	common_passwords     = strings.Fields(common_passwords_txt)
+
+	keyboard_rows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+)
+
+// CheckPassword checks the password against the policy at `auth.password`:
+//   - `min` characters, 8 by default;
+//   - at least `entropy` bits, 36 by default, of the estimate of
+//     PasswordEntropy;
+//   - none of the `banned` strings, nor the local part of the email;
+//   - not in the `breached` hash prefix buckets, see IsBreached.
+//
+// It returns a 422 with everything that has to change.
+func CheckPassword(password string, identifier string) error {
+	var problems []string
+
+	min := int(conf.MustGetConfOrDefault[float64](8, "auth", "password", "min"))
+	if n := len([]rune(password)); n < min {
+		problems = append(problems, fmt.Sprintf("it needs at least %d characters, it has %d", min, n))
+	}
+
+	lower := strings.ToLower(password)
+	banned := []string{}
+	for _, i := range conf.MustGetConfOrDefault([]any{}, "auth", "password", "banned") {
+		if str, ok := i.(string); ok {
+			banned = append(banned, str)
+		}
+	}
+	if local := strings.SplitN(identifier, "@", 2)[0]; len(local) >= 3 {
+		banned = append(banned, local)
+	}
+	for _, i := range banned {
+		if i != "" && strings.Contains(lower, strings.ToLower(i)) {
+			problems = append(problems, fmt.Sprintf("it can't contain `%s`", i))
+		}
+	}
+
+	entropy := conf.MustGetConfOrDefault[float64](36, "auth", "password", "entropy")
+	if len(problems) == 0 && PasswordEntropy(password) < entropy {
+		problems = append(problems, "it's too easy to guess, make it longer with more words or uncommon characters and avoid sequences, repetitions and common passwords")
+	}
+
+	// a password that can't be checked isn't taken as one that wasn't breached
+	if breached, err := IsBreached(password); err != nil {
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("could not check if the password was breached:\t%s", err),
+			Status: http.StatusInternalServerError,
+		}
+	} else if breached {
+		problems = append(problems, "it's in a list of passwords leaked in data breaches, so it's one of the first to be tried")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return &mux.HttpError{
+		Body:   fmt.Sprintf("the password is not good enough: %s", strings.Join(problems, "; ")),
+		Status: http.StatusUnprocessableEntity,
+	}
+}
+
+// PasswordEntropy estimates the bits of entropy of the password in the style
+// of zxcvbn. The password is split into patterns an attacker would try first,
+// repeated characters, sequences, rows of the keyboard and common passwords,
+// each worth much less than the random characters they replace.
+func PasswordEntropy(password string) float64 {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	bits := math.Log2(float64(charset(runes)))
+
+	var entropy float64
+	for i := 0; i < len(runes); {
+		if n := commonAt(lower, i); n > 0 {
+			entropy += math.Log2(float64(len(common_passwords)))
+			i += n
+			continue
+		}
+
+		if n := patternAt(lower, i); n >= 3 {
+			// guessing the start and the length of the pattern
+			entropy += bits + math.Log2(float64(n))
+			i += n
+			continue
+		}
+
+		entropy += bits
+		i++
+	}
+
+	return entropy
+}
+
+// charset is how many characters the password could have been made from,
+// judging by the classes of the ones it has.
+func charset(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, i := range []struct {
+		has  bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if i.has {
+			size += i.size
+		}
+	}
+
+	if size < 2 {
+		return 2
+	}
+
+	return size
+}
+
+// commonAt returns the length of the longest common password at i.
+func commonAt(runes []rune, i int) int {
+	rest := string(runes[i:])
+
+	longest := 0
+	for _, c := range common_passwords {
+		if n := len([]rune(c)); n >= 4 && n > longest && strings.HasPrefix(rest, c) {
+			longest = n
+		}
+	}
+
+	return longest
+}
+
+// patternAt returns the length of the repetition, sequence or keyboard row
+// that starts at i.
+func patternAt(runes []rune, i int) int {
+	longest := 1
+
+	for _, step := range []func(a, b rune) bool{
+		func(a, b rune) bool { return a == b },
+		func(a, b rune) bool { return b == a+1 },
+		func(a, b rune) bool { return b == a-1 },
+		adjacentOnKeyboard,
+	} {
+		n := 1
+		for j := i + 1; j < len(runes) && step(runes[j-1], runes[j]); j++ {
+			n++
+		}
+
+		if n > longest {
+			longest = n
+		}
+	}
+
+	return longest
+}
+
+func adjacentOnKeyboard(a, b rune) bool {
+	for _, row := range keyboard_rows {
+		if k := strings.IndexRune(row, a); k >= 0 && k+1 < len(row) && rune(row[k+1]) == b {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsBreached looks for the SHA-1 of the password in the hash prefix buckets
+// at `auth.password.breached`, a directory with a `PREFIX.txt` file for each
+// of the first 5 characters of the hashes, with the `SUFFIX:COUNT` lines of
+// the rest of them like the ranges of Have I Been Pwned. Only the bucket of
+// the password is read, from a local copy, so the passwords, or even their
+// hash prefixes, never leave the service. Without the directory nothing is
+// breached.
+func IsBreached(password string) (bool, error) {
+	dir := conf.MustGetConfOrDefault("", "auth", "password", "breached")
+	if dir == "" {
+		return false, nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:BREACHED_PREFIX_LEN], hash[BREACHED_PREFIX_LEN:]
+
+	// a bucket can be missing when there are no hashes with its prefix, the
+	// directory can't
+	if info, err := os.Stat(dir); err != nil {
+		return false, err
+	} else if !info.IsDir() {
+		return false, fmt.Errorf("`auth.password.breached` has to be a directory, `%s` isn't", dir)
+	}
+
+	f, err := os.Open(filepath.Join(dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return inBucket(f, suffix)
+}
+
+// inBucket is if the suffix is in the `SUFFIX:COUNT` lines of the bucket.
+func inBucket(r io.Reader, suffix string) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if strings.ToUpper(strings.TrimSpace(strings.SplitN(scanner.Text(), ":", 2)[0])) == suffix {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/testconf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// withConf runs the rest of the test with the configuration in content, and
+// then with testConf again.
+func withConf(t *testing.T, content string) {
+	t.Helper()
+
+	cleanup, err := testconf.Compile(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		cleanup()
+		if _, err := testconf.Compile(testConf); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		// the estimate is between min and max
+		min, max float64
+	}{
+		{"aaaaaaaaaaaa", 0, 12},
+		{"abcdefghij", 0, 12},
+		{"zyxwvu", 0, 12},
+		{"qwertyuiop", 0, 12},
+		{"password", 0, 12},
+		{"password1", 0, 12},
+		{"hunter2hunter2", 12, 36},
+		{"Tr0ub4dor&3", 60, 80},
+		{"q7$Vz!pL2#xR", 70, 90},
+		{"correct horse battery staple", 100, 200},
+	}
+
+	for _, tt := range tests {
+		if got := PasswordEntropy(tt.password); got < tt.min || got > tt.max {
+			t.Errorf("PasswordEntropy(%q) = %.1f, want it between %.0f and %.0f", tt.password, got, tt.min, tt.max)
+		}
+	}
+
+	// the patterns are worth less than the random characters they replace
+	if PasswordEntropy("xk2abcdefgh") >= PasswordEntropy("xk2qmzrwjtn") {
+		t.Errorf("a sequence is worth as much as random characters")
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	withConf(t, `{
+		"auth": {
+			"webauthn": {"rp_id": "example.com"},
+			"password": {"min": 10, "banned": ["bloqs", ""]}
+		}
+	}`)
+
+	tests := []struct {
+		name       string
+		password   string
+		identifier string
+		// the problems the error has to mention, none when it's nil
+		problems []string
+	}{
+		{
+			name:       "good",
+			password:   "blue-cactus-river-42",
+			identifier: "someone@example.com",
+		},
+		{
+			name:       "too short",
+			password:   "q7$Vz!pL2",
+			identifier: "someone@example.com",
+			problems:   []string{"at least 10 characters, it has 9"},
+		},
+		{
+			name:       "banned",
+			password:   "my-BLOQS-river-42",
+			identifier: "someone@example.com",
+			problems:   []string{"can't contain `bloqs`"},
+		},
+		{
+			name:       "local part of the email",
+			password:   "Someone-cactus-42",
+			identifier: "someone@example.com",
+			problems:   []string{"can't contain `someone`"},
+		},
+		{
+			name:       "short local part",
+			password:   "blue-cactus-jo-42",
+			identifier: "jo@example.com",
+		},
+		{
+			name:       "everything banned at once",
+			password:   "bloqs-someone",
+			identifier: "someone@example.com",
+			problems:   []string{"can't contain `bloqs`", "can't contain `someone`"},
+		},
+		{
+			name:       "guessable",
+			password:   "aaaaaaaaaaaaaaaa",
+			identifier: "someone@example.com",
+			problems:   []string{"too easy to guess"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPassword(tt.password, tt.identifier)
+			if tt.problems == nil {
+				if err != nil {
+					t.Errorf("CheckPassword() error = %v", err)
+				}
+				return
+			}
+
+			var e *mux.HttpError
+			if !errors.As(err, &e) || e.Status != http.StatusUnprocessableEntity {
+				t.Fatalf("CheckPassword() error = %v, want a 422", err)
+			}
+			for _, i := range tt.problems {
+				if !strings.Contains(e.Body, i) {
+					t.Errorf("CheckPassword() = %q, want it to mention %q", e.Body, i)
+				}
+			}
+		})
+	}
+}
+
+// breachedBuckets makes the buckets of the breached passwords in a temporary
+// directory.
+func breachedBuckets(t *testing.T, buckets map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for prefix, lines := range buckets {
+		if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(lines), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestIsBreached(t *testing.T) {
+	// the SHA-1 of `password` is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	dir := breachedBuckets(t, map[string]string{
+		"5BAA6": "003D68EB55068C33ACE09247EE4C639306B:3\r\n1e4c9b93f3f0682250b6cf8331b7ee68fd8:9545824\r\n",
+		// the SHA-1 of `hunter2` is F3BBBD66A63D4BF1747940578EC3D0103530E21D
+		"F3BBB": "0000000000000000000000000000000000A:1\n",
+	})
+	withConf(t, fmt.Sprintf(`{"auth": {"password": {"breached": %q}}}`, dir))
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"hunter2", false},
+		// its bucket is missing
+		{"blue-cactus-river-42", false},
+	}
+
+	for _, tt := range tests {
+		got, err := IsBreached(tt.password)
+		if err != nil {
+			t.Fatalf("IsBreached(%q) error = %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("IsBreached(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+
+	if err := CheckPassword("password", "someone@example.com"); err == nil || !strings.Contains(err.Error(), "leaked") {
+		t.Errorf("CheckPassword() error = %v, want it to say the password leaked", err)
+	}
+}
+
+func TestIsBreachedUnreadable(t *testing.T) {
+	withConf(t, fmt.Sprintf(`{"auth": {"password": {"breached": %q}}}`, filepath.Join(t.TempDir(), "missing")))
+
+	if _, err := IsBreached("blue-cactus-river-42"); err == nil {
+		t.Errorf("IsBreached() didn't fail without the buckets")
+	}
+
+	// the passwords aren't let through when they can't be checked
+	var e *mux.HttpError
+	if err := CheckPassword("blue-cactus-river-42", "someone@example.com"); !errors.As(err, &e) || e.Status != http.StatusInternalServerError {
+		t.Errorf("CheckPassword() error = %v, want a 500", err)
+	}
+}
@@ -1,87 +1,321 @@
 package auth
 
 import (
+	"encoding/json"
 	"fmt"
-	"strconv"
+	"math/bits"
+	"strings"
 )
 
-const (
-	CREATE_PREFERENCE Permission = 1 << (NEEDLE_FOR_NEXT_PERMISSION + iota)
-	UPDATE_PREFERENCE
-	DELETE_PREFERENCE
+// PERMISSION_WORDS is how many uint64 a Permission has, so how many scopes
+// the registry can have is 64 times it.
+const PERMISSION_WORDS = 4
 
-	CREATE_PROFILE
-	READ_PROFILE
-	UPDATE_PROFILE
-	DELETE_PROFILE
+// Scope is a permission in the registry, named `<resource>:<action>`.
+type Scope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Super scopes are only given to super credentials.
+	Super bool `json:"super,omitempty"`
+	// Alias is the name it had before the registry, still accepted when asking
+	// for permissions.
+	Alias string `json:"alias,omitempty"`
 
-	CREATE_BLOQ
-	UPDATE_BLOQ
-	DELETE_BLOQ
+	// legacy is its bit in the uint64 masks the tokens had before the
+	// registry, 0 for the scopes that are newer.
+	legacy uint64
+	index  int
+}
+
+// Permission is a set of scopes, a bitset of their positions in the registry.
+// In the tokens it's the list of their names, so the positions can change.
+type Permission [PERMISSION_WORDS]uint64
+
+var (
+	registry = []*Scope{}
+	scopes   = map[string]*Scope{}
+)
+
+// register adds the scope to the registry and returns the permission with
+// only it.
+func register(s Scope) Permission {
+	if _, ok := scopes[s.Name]; ok {
+		panic(fmt.Sprintf("the scope `%s` is registered twice", s.Name))
+	}
+
+	if len(registry) >= PERMISSION_WORDS*64 {
+		panic(fmt.Sprintf("there's no room for the scope `%s`, increase PERMISSION_WORDS", s.Name))
+	}
+
+	s.index = len(registry)
+	registry = append(registry, &s)
+	scopes[s.Name] = &s
+
+	var p Permission
+	p[s.index/64] |= 1 << (s.index % 64)
+	return p
+}
+
+var (
+	NIL Permission
+
+	SIGN_OUT     = register(Scope{Name: "token:sign_out", Description: "Sign out the credentials of the token.", legacy: 1 << 1})
+	GRANT_SUPER  = register(Scope{Name: "super:grant", Description: "Make credentials super.", Super: true, legacy: 1 << 2})
+	REVOKE_SUPER = register(Scope{Name: "super:revoke", Description: "Make credentials not super.", Super: true, legacy: 1 << 3})
+
+	CREATE_PREFERENCE = register(Scope{Name: "preference:create", Description: "Create preferences.", Super: true, Alias: "create_preference", legacy: 1 << 4})
+	UPDATE_PREFERENCE = register(Scope{Name: "preference:update", Description: "Update preferences.", Super: true, Alias: "update_preference", legacy: 1 << 5})
+	DELETE_PREFERENCE = register(Scope{Name: "preference:delete", Description: "Delete preferences.", Super: true, Alias: "delete_preference", legacy: 1 << 6})
+
+	CREATE_PROFILE = register(Scope{Name: "profile:create", Description: "Create profiles.", Alias: "create_profile", legacy: 1 << 7})
+	READ_PROFILE   = register(Scope{Name: "profile:read", Description: "Read the private parts of your profiles.", Alias: "read_profile", legacy: 1 << 8})
+	UPDATE_PROFILE = register(Scope{Name: "profile:update", Description: "Update your profiles.", Alias: "update_profile", legacy: 1 << 9})
+	DELETE_PROFILE = register(Scope{Name: "profile:delete", Description: "Delete your profiles.", Alias: "delete_profile", legacy: 1 << 10})
+
+	CREATE_BLOQ = register(Scope{Name: "bloq:create", Description: "Create bloqs.", Alias: "create_bloq", legacy: 1 << 11})
+	UPDATE_BLOQ = register(Scope{Name: "bloq:update", Description: "Update your bloqs.", Alias: "update_bloq", legacy: 1 << 12})
+	DELETE_BLOQ = register(Scope{Name: "bloq:delete", Description: "Delete your bloqs.", Alias: "delete_bloq", legacy: 1 << 13})
+
+	CREATE_OFFER = register(Scope{Name: "offer:create", Description: "Create offers.", Alias: "create_offer", legacy: 1 << 14})
+	UPDATE_OFFER = register(Scope{Name: "offer:update", Description: "Update your offers.", Alias: "update_offer", legacy: 1 << 15})
+	DELETE_OFFER = register(Scope{Name: "offer:delete", Description: "Delete your offers.", Alias: "delete_offer", legacy: 1 << 16})
+
+	CREATE_ORDER = register(Scope{Name: "order:create", Description: "Order offers.", Alias: "create_order", legacy: 1 << 17})
+	DELETE_ORDER = register(Scope{Name: "order:delete", Description: "Read and cancel your orders.", Alias: "delete_order", legacy: 1 << 18})
+
+	CREATE_REVIEW = register(Scope{Name: "review:create", Description: "Review bloqs.", Alias: "create_review", legacy: 1 << 19})
+	UPDATE_REVIEW = register(Scope{Name: "review:update", Description: "Update your reviews.", Alias: "update_review", legacy: 1 << 20})
+	DELETE_REVIEW = register(Scope{Name: "review:delete", Description: "Delete your reviews.", Alias: "delete_review", legacy: 1 << 21})
+
+	CREATE_ORG = register(Scope{Name: "org:create", Description: "Create organizations.", Alias: "create_org", legacy: 1 << 22})
+	UPDATE_ORG = register(Scope{Name: "org:update", Description: "Manage the organizations you're in.", Alias: "update_org", legacy: 1 << 23})
+	DELETE_ORG = register(Scope{Name: "org:delete", Description: "Delete the organizations you own.", Alias: "delete_org", legacy: 1 << 24})
 
-	CREATE_OFFER
-	UPDATE_OFFER
-	DELETE_OFFER
+	FOLLOW_PROFILE = register(Scope{Name: "profile:follow", Description: "Follow profiles as one of yours.", Alias: "follow_profile", legacy: 1 << 25})
 
-	CREATE_ORDER
-	DELETE_ORDER
+	MODERATE_BLOQ   = register(Scope{Name: "bloq:moderate", Description: "Update and delete the bloqs of anyone."})
+	MODERATE_REVIEW = register(Scope{Name: "review:moderate", Description: "Update and delete the reviews of anyone."})
 
-	PREFERENCE_MANAGER = CREATE_PREFERENCE | UPDATE_PREFERENCE | DELETE_PREFERENCE
+	CREATE_ORG_RATING = register(Scope{Name: "org_rating:create", Description: "Rate organizations."})
+	UPDATE_ORG_RATING = register(Scope{Name: "org_rating:update", Description: "Update your ratings of organizations."})
+	DELETE_ORG_RATING = register(Scope{Name: "org_rating:delete", Description: "Delete your ratings of organizations."})
 
-	DEFAULT_PERMISSIONS = CREATE_PROFILE |
-		READ_PROFILE |
-		CREATE_BLOQ |
-		UPDATE_BLOQ |
-		CREATE_OFFER |
-		CREATE_ORDER |
-		DELETE_ORDER
+	PREFERENCE_MANAGER = Union(CREATE_PREFERENCE, UPDATE_PREFERENCE, DELETE_PREFERENCE)
+
+	CREATE_PERMISSIONS = Union(
+		CREATE_PREFERENCE,
+		CREATE_PROFILE,
+		CREATE_BLOQ,
+		CREATE_OFFER,
+		CREATE_ORDER,
+		CREATE_REVIEW,
+		CREATE_ORG,
+		CREATE_ORG_RATING,
+	)
+
+	DEFAULT_PERMISSIONS = Union(
+		CREATE_PROFILE,
+		READ_PROFILE,
+		CREATE_BLOQ,
+		UPDATE_BLOQ,
+		CREATE_OFFER,
+		CREATE_ORDER,
+		DELETE_ORDER,
+		CREATE_REVIEW,
+		UPDATE_REVIEW,
+		DELETE_REVIEW,
+		CREATE_ORG,
+		UPDATE_ORG,
+		DELETE_ORG,
+		CREATE_ORG_RATING,
+		UPDATE_ORG_RATING,
+		DELETE_ORG_RATING,
+		FOLLOW_PROFILE,
+	)
+
+	// SUPER_PERMISSIONS are the scopes only super credentials get.
+	SUPER_PERMISSIONS = func() (p Permission) {
+		for _, i := range registry {
+			if i.Super {
+				p[i.index/64] |= 1 << (i.index % 64)
+			}
+		}
+		return
+	}()
 )
 
-var Permissions = map[string]Permission{
-	"create_bloq":    CREATE_BLOQ,
-	"update_bloq":    UPDATE_BLOQ,
-	"delete_bloq":    DELETE_BLOQ,
-	"create_offer":   CREATE_OFFER,
-	"update_offer":   UPDATE_OFFER,
-	"delete_offer":   DELETE_OFFER,
-	"create_order":   CREATE_ORDER,
-	"delete_order":   DELETE_ORDER,
-	"create_profile": CREATE_PROFILE,
-	"read_profile":   CREATE_PROFILE,
-	"update_profile": UPDATE_PROFILE,
-	"delete_profile": DELETE_PROFILE,
-	"default":        DEFAULT_PERMISSIONS,
+func Union(ps ...Permission) (p Permission) {
+	for _, i := range ps {
+		for w := range p {
+			p[w] |= i[w]
+		}
+	}
+	return
 }
 
-var SuperPermissions = map[string]Permission{
-	"create_preference": CREATE_PREFERENCE,
-	"update_preference": UPDATE_PREFERENCE,
-	"delete_preference": DELETE_PREFERENCE,
+// Has is if p has all the scopes of q.
+func (p Permission) Has(q Permission) bool {
+	for w := range p {
+		if p[w]&q[w] != q[w] {
+			return false
+		}
+	}
+	return true
 }
 
-func GetPermissionsList(super bool) map[string]Permission {
-	list := make(map[string]Permission, len(Permissions))
-	for k, v := range Permissions {
-		list[k] = v
+// Any is if p has any of the scopes of q.
+func (p Permission) Any(q Permission) bool {
+	for w := range p {
+		if p[w]&q[w] != 0 {
+			return true
+		}
 	}
-	if super {
-		for k, v := range SuperPermissions {
-			list[k] = v
+	return false
+}
+
+// Intersect is p with only the scopes q has too.
+func (p Permission) Intersect(q Permission) Permission {
+	for w := range p {
+		p[w] &= q[w]
+	}
+	return p
+}
+
+// Without is p without the scopes of q.
+func (p Permission) Without(q Permission) Permission {
+	for w := range p {
+		p[w] &^= q[w]
+	}
+	return p
+}
+
+// Scopes are the names of the scopes of p, in the order of the registry.
+func (p Permission) Scopes() []string {
+	names := []string{}
+	for w, word := range p {
+		for word != 0 {
+			i := bits.TrailingZeros64(word)
+			word &^= 1 << i
+
+			if k := w*64 + i; k < len(registry) {
+				names = append(names, registry[k].Name)
+			}
 		}
 	}
-	return list
+	return names
 }
 
-func GetPermissionsHash(p Permission) string {
+func (p Permission) String() string {
+	return strings.Join(p.Scopes(), " ")
+}
+
+// Legacy is p as the uint64 mask the tokens had before the registry, without
+// the scopes that are newer.
+func (p Permission) Legacy() (mask uint64) {
+	for _, i := range registry {
+		if p[i.index/64]&(1<<(i.index%64)) != 0 {
+			mask |= i.legacy
+		}
+	}
+	return
+}
+
+// FromLegacy reads an uint64 mask from before the registry.
+func FromLegacy(mask uint64) (p Permission) {
+	for _, i := range registry {
+		if i.legacy != 0 && mask&i.legacy == i.legacy {
+			p[i.index/64] |= 1 << (i.index % 64)
+		}
+	}
+	return
+}
+
+// ParseScopes reads the names of scopes, the aliases they had before the
+// registry and `default`.
+func ParseScopes(names []string) (p Permission, err error) {
 	list := GetPermissionsList(true)
+	for _, i := range names {
+		q, ok := list[i]
+		if !ok {
+			return NIL, fmt.Errorf("`%s` is not a permission", i)
+		}
+		p = Union(p, q)
+	}
+	return
+}
 
-	for k, v := range list {
-		if v == p {
-			return k
+// FromProto is the permission of the scopes and legacy mask of a message.
+// Scopes it doesn't know are from newer versions, so they're ignored.
+func FromProto(names []string, legacy uint64) Permission {
+	p := FromLegacy(legacy)
+	for _, i := range names {
+		if s, ok := scopes[i]; ok {
+			p[s.index/64] |= 1 << (s.index % 64)
 		}
 	}
+	return p
+}
+
+// MarshalJSON writes the list of scopes.
+func (p Permission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Scopes())
+}
+
+// UnmarshalJSON reads the list of scopes, or the uint64 mask of the tokens
+// from before the registry.
+func (p *Permission) UnmarshalJSON(data []byte) error {
+	var mask uint64
+	if err := json.Unmarshal(data, &mask); err == nil {
+		*p = FromLegacy(mask)
+		return nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("the permissions have to be a list of scopes:\t%s", err)
+	}
+
+	*p = FromProto(names, 0)
+	return nil
+}
+
+// Registry is the catalogue of the scopes, in order.
+func Registry() []Scope {
+	list := make([]Scope, 0, len(registry))
+	for _, i := range registry {
+		list = append(list, *i)
+	}
+	return list
+}
+
+// GetPermissionsList maps the names and aliases of the scopes, and `default`,
+// to their permissions. The super scopes are only in it if super.
+func GetPermissionsList(super bool) map[string]Permission {
+	list := make(map[string]Permission, len(registry)*2+1)
+	for _, i := range registry {
+		if i.Super && !super {
+			continue
+		}
+
+		var p Permission
+		p[i.index/64] |= 1 << (i.index % 64)
+
+		list[i.Name] = p
+		if i.Alias != "" {
+			list[i.Alias] = p
+		}
+	}
+	list["default"] = DEFAULT_PERMISSIONS
+	return list
+}
+
+// GetPermissionsHash names the permission by its scopes.
+func GetPermissionsHash(p Permission) string {
+	if p == NIL {
+		return "nil"
+	}
 
-	return strconv.Itoa(int(p))
+	return p.String()
 }
 
 type NoPermissionsError struct {
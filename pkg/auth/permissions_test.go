@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func allScopes() (p Permission) {
+	for _, i := range registry {
+		p[i.index/64] |= 1 << (i.index % 64)
+	}
+	return
+}
+
+func TestPermissionRoundTrips(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Permission
+	}{
+		{"nil", NIL},
+		{"one scope", SIGN_OUT},
+		{"newer scopes", Union(MODERATE_BLOQ, DELETE_ORG_RATING)},
+		{"default", DEFAULT_PERMISSIONS},
+		{"super", SUPER_PERMISSIONS},
+		{"all", allScopes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := tt.p.Scopes()
+
+			if got := FromProto(names, 0); got != tt.p {
+				t.Errorf("FromProto(%v) = %v, want %v", names, got, tt.p)
+			}
+
+			if got, err := ParseScopes(names); err != nil || got != tt.p {
+				t.Errorf("ParseScopes(%v) = (%v, %v), want %v", names, got, err, tt.p)
+			}
+
+			buf, err := json.Marshal(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got Permission
+			if err := json.Unmarshal(buf, &got); err != nil || got != tt.p {
+				t.Errorf("json.Unmarshal(%s) = (%v, %v), want %v", buf, got, err, tt.p)
+			}
+
+			// only the scopes from before the registry fit in the masks
+			var legacy Permission
+			for _, i := range registry {
+				if i.legacy != 0 {
+					legacy[i.index/64] |= 1 << (i.index % 64)
+				}
+			}
+			if got := FromLegacy(tt.p.Legacy()); got != tt.p.Intersect(legacy) {
+				t.Errorf("FromLegacy(%#x) = %v, want %v", tt.p.Legacy(), got, tt.p.Intersect(legacy))
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	seen := map[string]bool{}
+
+	for i, s := range Registry() {
+		var p Permission
+		p[i/64] |= 1 << (i % 64)
+
+		if got := p.Scopes(); !reflect.DeepEqual(got, []string{s.Name}) {
+			t.Errorf("the scope %d is %v, want `%s`", i, got, s.Name)
+		}
+
+		for _, name := range []string{s.Name, s.Alias} {
+			if name == "" {
+				continue
+			}
+			if seen[name] {
+				t.Errorf("`%s` names two scopes", name)
+			}
+			seen[name] = true
+
+			if got, err := ParseScopes([]string{name}); err != nil || got != p {
+				t.Errorf("ParseScopes(%q) = (%v, %v), want %v", name, got, err, p)
+			}
+		}
+
+		if _, ok := GetPermissionsList(false)[s.Name]; ok == s.Super {
+			t.Errorf("GetPermissionsList(false) has `%s` %v, want the opposite", s.Name, ok)
+		}
+	}
+}
+
+func TestLegacyMasks(t *testing.T) {
+	// the tokens signed before the registry have these bits, so they can't
+	// change
+	tests := []struct {
+		p    Permission
+		mask uint64
+	}{
+		{SIGN_OUT, 1 << 1},
+		{GRANT_SUPER, 1 << 2},
+		{REVOKE_SUPER, 1 << 3},
+		{CREATE_PREFERENCE, 1 << 4},
+		{DELETE_PREFERENCE, 1 << 6},
+		{CREATE_PROFILE, 1 << 7},
+		{READ_PROFILE, 1 << 8},
+		{DELETE_PROFILE, 1 << 10},
+		{CREATE_BLOQ, 1 << 11},
+		{DELETE_BLOQ, 1 << 13},
+		{CREATE_OFFER, 1 << 14},
+		{DELETE_OFFER, 1 << 16},
+		{CREATE_ORDER, 1 << 17},
+		{DELETE_ORDER, 1 << 18},
+		{CREATE_REVIEW, 1 << 19},
+		{DELETE_REVIEW, 1 << 21},
+		{CREATE_ORG, 1 << 22},
+		{DELETE_ORG, 1 << 24},
+		{FOLLOW_PROFILE, 1 << 25},
+		{MODERATE_BLOQ, 0},
+		{CREATE_ORG_RATING, 0},
+		{Union(CREATE_BLOQ, UPDATE_BLOQ, MODERATE_REVIEW), 1<<11 | 1<<12},
+	}
+
+	for _, tt := range tests {
+		if got := tt.p.Legacy(); got != tt.mask {
+			t.Errorf("%v.Legacy() = %#x, want %#x", tt.p, got, tt.mask)
+		}
+	}
+}
+
+func TestUnmarshalPermission(t *testing.T) {
+	tests := []struct {
+		json string
+		want Permission
+		err  bool
+	}{
+		{`[]`, NIL, false},
+		{`["bloq:create", "review:moderate"]`, Union(CREATE_BLOQ, MODERATE_REVIEW), false},
+		{`["bloq:create", "from:a_newer_version"]`, CREATE_BLOQ, false},
+		{`6144`, Union(CREATE_BLOQ, UPDATE_BLOQ), false},
+		{`0`, NIL, false},
+		{`"bloq:create"`, NIL, true},
+		{`{}`, NIL, true},
+	}
+
+	for _, tt := range tests {
+		var got Permission
+		err := json.Unmarshal([]byte(tt.json), &got)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("json.Unmarshal(%s) = (%v, %v), want %v", tt.json, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  Permission
+		err   bool
+	}{
+		{nil, NIL, false},
+		{[]string{"create_bloq", "bloq:update"}, Union(CREATE_BLOQ, UPDATE_BLOQ), false},
+		{[]string{"read_profile"}, READ_PROFILE, false},
+		{[]string{"default"}, DEFAULT_PERMISSIONS, false},
+		{[]string{"default", "bloq:moderate"}, Union(DEFAULT_PERMISSIONS, MODERATE_BLOQ), false},
+		{[]string{"super:grant"}, GRANT_SUPER, false},
+		{[]string{"bloq:create", "bloq:fly"}, NIL, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseScopes(tt.names)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseScopes(%v) = (%v, %v), want %v", tt.names, got, err, tt.want)
+		}
+	}
+}
+
+func TestPermissionSets(t *testing.T) {
+	bloq := Union(CREATE_BLOQ, UPDATE_BLOQ, DELETE_BLOQ)
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"has all", bloq.Has(Union(CREATE_BLOQ, DELETE_BLOQ)), true},
+		{"has some", bloq.Has(Union(CREATE_BLOQ, CREATE_OFFER)), false},
+		{"has nil", NIL.Has(NIL), true},
+		{"any", bloq.Any(Union(CREATE_BLOQ, CREATE_OFFER)), true},
+		{"any none", bloq.Any(CREATE_OFFER), false},
+		{"intersect", bloq.Intersect(Union(CREATE_BLOQ, CREATE_OFFER)), CREATE_BLOQ},
+		{"without", bloq.Without(Union(CREATE_BLOQ, CREATE_OFFER)), Union(UPDATE_BLOQ, DELETE_BLOQ)},
+		{"string", Union(DELETE_BLOQ, CREATE_BLOQ).String(), "bloq:create bloq:delete"},
+		{"hash of nil", GetPermissionsHash(NIL), "nil"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+const (
+	ORG_OWNER  = "owner"
+	ORG_ADMIN  = "admin"
+	ORG_MEMBER = "member"
+)
+
+// OrgRoleRank orders the roles in an organization, the ones that aren't
+// roles are 0.
+func OrgRoleRank(role string) int {
+	switch role {
+	case ORG_OWNER:
+		return 3
+	case ORG_ADMIN:
+		return 2
+	case ORG_MEMBER:
+		return 1
+	}
+
+	return 0
+}
+
+// OrgRoleFor is the least role in the organization of a resource that allows
+// the action on it. Members act as the organization, admins manage what it
+// has and only owners delete it.
+func OrgRoleFor(action Permission) string {
+	switch {
+	case action.Has(DELETE_ORG):
+		return ORG_OWNER
+	case action.Any(Union(UPDATE_PROFILE, DELETE_PROFILE, UPDATE_BLOQ, DELETE_BLOQ, UPDATE_OFFER, DELETE_OFFER, UPDATE_REVIEW, DELETE_REVIEW, UPDATE_ORG)):
+		return ORG_ADMIN
+	default:
+		return ORG_MEMBER
+	}
+}
+
+// moderation are the scopes that allow actions on resources of anyone.
+var moderation = []struct {
+	scope   Permission
+	actions Permission
+}{
+	{MODERATE_BLOQ, Union(UPDATE_BLOQ, DELETE_BLOQ)},
+	{MODERATE_REVIEW, Union(UPDATE_REVIEW, DELETE_REVIEW)},
+}
+
+// Relations are what Authorize needs to know about who owns what, kept by
+// the service with the resources.
+type Relations interface {
+	// Profiles are the profiles of the account.
+	Profiles(ctx context.Context, account string) ([]int64, error)
+	// OrgRole is the role of the profile in the organization, empty if it's
+	// not a member.
+	OrgRole(ctx context.Context, org, profile int64) (string, error)
+}
+
+type relationsKey struct{}
+
+func WithRelations(ctx context.Context, r Relations) context.Context {
+	return context.WithValue(ctx, relationsKey{}, r)
+}
+
+// Resource is what an action is done to. Owner is the profile it belongs to
+// and Org the organization, 0 if none. A resource that belongs to no one only
+// needs the permission.
+type Resource struct {
+	Kind  string
+	ID    int64
+	Owner int64
+	Org   int64
+}
+
+// Authorize checks if the subject of the claims can do the action on the
+// resource. The token needs the action as a permission, and the resource has
+// to be of one of its profiles or of an organization where one of them has
+// at least the OrgRoleFor the action. Super users can do it on anything, and
+// moderators on the resources they moderate.
+func Authorize(ctx context.Context, claims *Claims, action Permission, resource Resource) error {
+	if claims == nil {
+		return &mux.HttpError{
+			Body:   "the request needs a token to be authorized",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	if !claims.Permissions.Has(action) {
+		return &mux.HttpError{
+			Body:   NoPermissionsError{Permission: action}.Error(),
+			Status: http.StatusForbidden,
+		}
+	}
+
+	if claims.Super || (resource.Owner == 0 && resource.Org == 0) {
+		return nil
+	}
+
+	for _, i := range moderation {
+		if action.Any(i.actions) && claims.Permissions.Has(i.scope) {
+			return nil
+		}
+	}
+
+	rel, ok := ctx.Value(relationsKey{}).(Relations)
+	if !ok {
+		return &mux.HttpError{
+			Body:   "there are no relations to authorize with",
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	profiles, err := rel.Profiles(ctx, claims.AccountID())
+	if err != nil {
+		return &mux.HttpError{
+			Body:   err.Error(),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	for _, i := range profiles {
+		if resource.Owner != 0 && i == resource.Owner {
+			return nil
+		}
+	}
+
+	if resource.Org != 0 {
+		needed := OrgRoleFor(action)
+
+		for _, i := range profiles {
+			role, err := rel.OrgRole(ctx, resource.Org, i)
+			if err != nil {
+				return &mux.HttpError{
+					Body:   err.Error(),
+					Status: http.StatusInternalServerError,
+				}
+			}
+
+			if OrgRoleRank(role) >= OrgRoleRank(needed) {
+				return nil
+			}
+		}
+
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("you need to be at least `%s` of the organization `%d` to do that to the %s `%d`", needed, resource.Org, resource.Kind, resource.ID),
+			Status: http.StatusForbidden,
+		}
+	}
+
+	return &mux.HttpError{
+		Body:   fmt.Sprintf("the %s `%d` is not yours", resource.Kind, resource.ID),
+		Status: http.StatusForbidden,
+	}
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+// relations are the profiles of each account and the roles of each profile
+// in each organization.
+type relations struct {
+	profiles map[string][]int64
+	roles    map[int64]map[int64]string
+	err      error
+}
+
+func (r *relations) Profiles(ctx context.Context, account string) ([]int64, error) {
+	return r.profiles[account], r.err
+}
+
+func (r *relations) OrgRole(ctx context.Context, org, profile int64) (string, error) {
+	return r.roles[org][profile], r.err
+}
+
+func TestOrgRoleFor(t *testing.T) {
+	tests := []struct {
+		action Permission
+		want   string
+	}{
+		{DELETE_ORG, ORG_OWNER},
+		{UPDATE_ORG, ORG_ADMIN},
+		{UPDATE_PROFILE, ORG_ADMIN},
+		{DELETE_PROFILE, ORG_ADMIN},
+		{UPDATE_BLOQ, ORG_ADMIN},
+		{DELETE_BLOQ, ORG_ADMIN},
+		{UPDATE_OFFER, ORG_ADMIN},
+		{DELETE_OFFER, ORG_ADMIN},
+		{UPDATE_REVIEW, ORG_ADMIN},
+		{DELETE_REVIEW, ORG_ADMIN},
+		{CREATE_BLOQ, ORG_MEMBER},
+		{CREATE_OFFER, ORG_MEMBER},
+		{CREATE_REVIEW, ORG_MEMBER},
+		{Union(CREATE_BLOQ, DELETE_ORG), ORG_OWNER},
+	}
+
+	for _, tt := range tests {
+		if got := OrgRoleFor(tt.action); got != tt.want {
+			t.Errorf("OrgRoleFor(%v) = %q, want %q", tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	const org = 50
+
+	ctx := WithRelations(context.Background(), &relations{
+		profiles: map[string][]int64{
+			"owner":    {1, 2},
+			"orgOwner": {10},
+			"admin":    {11},
+			"member":   {12},
+			"stranger": {20},
+		},
+		roles: map[int64]map[int64]string{
+			org: {10: ORG_OWNER, 11: ORG_ADMIN, 12: ORG_MEMBER},
+		},
+	})
+
+	everything := Union(UPDATE_BLOQ, DELETE_BLOQ, UPDATE_REVIEW, DELETE_REVIEW, DELETE_ORG, CREATE_OFFER)
+	claims := func(account string, super bool, scopes ...Permission) *Claims {
+		return &Claims{Payload: Payload{
+			Account:     account,
+			Super:       super,
+			Permissions: Union(append(scopes, everything)...),
+		}}
+	}
+
+	bloq := Resource{Kind: "bloq", ID: 7, Owner: 2}
+	orgBloq := Resource{Kind: "bloq", ID: 8, Owner: 3, Org: org}
+	review := Resource{Kind: "review", ID: 9, Owner: 2}
+	orgItself := Resource{Kind: "organization", ID: org, Org: org}
+	nobodys := Resource{Kind: "bloq", ID: 10}
+
+	tests := []struct {
+		name     string
+		claims   *Claims
+		action   Permission
+		resource Resource
+		status   uint16
+	}{
+		// the owner of the resource
+		{"owner updates", claims("owner", false), UPDATE_BLOQ, bloq, 0},
+		{"owner deletes", claims("owner", false), DELETE_BLOQ, bloq, 0},
+		{"owner without the permission", &Claims{Payload: Payload{Account: "owner", Permissions: UPDATE_BLOQ}}, DELETE_BLOQ, bloq, http.StatusForbidden},
+
+		// the roles in the organization
+		{"org owner deletes the org", claims("orgOwner", false), DELETE_ORG, orgItself, 0},
+		{"org admin deletes the org", claims("admin", false), DELETE_ORG, orgItself, http.StatusForbidden},
+		{"org admin updates a bloq", claims("admin", false), UPDATE_BLOQ, orgBloq, 0},
+		{"org admin deletes a bloq", claims("admin", false), DELETE_BLOQ, orgBloq, 0},
+		{"org member creates an offer", claims("member", false), CREATE_OFFER, orgBloq, 0},
+		{"org member updates a bloq", claims("member", false), UPDATE_BLOQ, orgBloq, http.StatusForbidden},
+		{"org member deletes the org", claims("member", false), DELETE_ORG, orgItself, http.StatusForbidden},
+
+		// moderators only moderate what their scope covers
+		{"bloq moderator deletes a bloq", claims("stranger", false, MODERATE_BLOQ), DELETE_BLOQ, bloq, 0},
+		{"bloq moderator deletes an org bloq", claims("stranger", false, MODERATE_BLOQ), DELETE_BLOQ, orgBloq, 0},
+		{"bloq moderator deletes a review", claims("stranger", false, MODERATE_BLOQ), DELETE_REVIEW, review, http.StatusForbidden},
+		{"review moderator deletes a review", claims("stranger", false, MODERATE_REVIEW), DELETE_REVIEW, review, 0},
+		{"review moderator deletes the org", claims("stranger", false, MODERATE_REVIEW), DELETE_ORG, orgItself, http.StatusForbidden},
+
+		// super users do anything they have the permission for
+		{"super deletes a bloq", claims("stranger", true), DELETE_BLOQ, bloq, 0},
+		{"super deletes the org", claims("stranger", true), DELETE_ORG, orgItself, 0},
+		{"super without the permission", &Claims{Payload: Payload{Super: true, Permissions: UPDATE_BLOQ}}, DELETE_BLOQ, bloq, http.StatusForbidden},
+
+		// strangers
+		{"stranger updates a bloq", claims("stranger", false), UPDATE_BLOQ, bloq, http.StatusForbidden},
+		{"stranger updates an org bloq", claims("stranger", false), UPDATE_BLOQ, orgBloq, http.StatusForbidden},
+		{"stranger deletes a review", claims("stranger", false), DELETE_REVIEW, review, http.StatusForbidden},
+		{"stranger deletes the org", claims("stranger", false), DELETE_ORG, orgItself, http.StatusForbidden},
+		{"stranger updates a bloq of no one", claims("stranger", false), UPDATE_BLOQ, nobodys, 0},
+		{"no token", nil, UPDATE_BLOQ, bloq, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Authorize(ctx, tt.claims, tt.action, tt.resource)
+			if tt.status == 0 {
+				if err != nil {
+					t.Errorf("Authorize() error = %v", err)
+				}
+				return
+			}
+
+			var e *mux.HttpError
+			if !errors.As(err, &e) || e.Status != tt.status {
+				t.Errorf("Authorize() error = %v, want a %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRelations(t *testing.T) {
+	claims := &Claims{Payload: Payload{Account: "owner", Permissions: UPDATE_BLOQ}}
+	bloq := Resource{Kind: "bloq", ID: 7, Owner: 2}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"none", context.Background()},
+		{"failing", WithRelations(context.Background(), &relations{err: errors.New("the database is down")})},
+	}
+
+	for _, tt := range tests {
+		var e *mux.HttpError
+		if err := Authorize(tt.ctx, claims, UPDATE_BLOQ, bloq); !errors.As(err, &e) || e.Status != http.StatusInternalServerError {
+			t.Errorf("%s: Authorize() error = %v, want a 500", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+)
+
+const (
+	// ROLE_USER is the role of every credentials, it doesn't have to be
+	// assigned.
+	ROLE_USER               = "user"
+	ROLE_SELLER             = "seller"
+	ROLE_MODERATOR          = "moderator"
+	ROLE_PREFERENCE_MANAGER = "preference_manager"
+)
+
+var default_roles = map[string]Permission{
+	ROLE_USER:               Union(DEFAULT_PERMISSIONS, SIGN_OUT, UPDATE_PROFILE, DELETE_PROFILE, DELETE_BLOQ),
+	ROLE_SELLER:             Union(CREATE_OFFER, UPDATE_OFFER, DELETE_OFFER),
+	ROLE_MODERATOR:          Union(MODERATE_BLOQ, MODERATE_REVIEW, UPDATE_BLOQ, DELETE_BLOQ, UPDATE_REVIEW, DELETE_REVIEW),
+	ROLE_PREFERENCE_MANAGER: PREFERENCE_MANAGER,
+}
+
+// Roles are the roles and the scopes they bundle. The scopes of a role, and
+// new roles, are defined with lists of scopes at `auth.roles.<role>`.
+func Roles() map[string]Permission {
+	roles := make(map[string]Permission, len(default_roles))
+	for k, v := range default_roles {
+		roles[k] = v
+	}
+
+	for k, v := range conf.MustGetConfOrDefault(map[string]any{}, "auth", "roles") {
+		list, ok := v.([]any)
+		if !ok {
+			fmt.Printf("%v\n", fmt.Errorf("the role `%s` has to be a list of scopes", k))
+			continue
+		}
+
+		names := make([]string, 0, len(list))
+		for _, i := range list {
+			if name, ok := i.(string); ok {
+				names = append(names, name)
+			}
+		}
+
+		p, err := ParseScopes(names)
+		if err != nil {
+			fmt.Printf("%v\n", fmt.Errorf("the role `%s` has an invalid scope:\t%s", k, err))
+			continue
+		}
+		roles[k] = p
+	}
+
+	return roles
+}
+
+func IsRole(role string) bool {
+	_, ok := Roles()[role]
+	return ok
+}
+
+// RoleNames are the roles in order, without ROLE_USER that is implicit.
+func RoleNames() []string {
+	names := []string{}
+	for k := range Roles() {
+		if k != ROLE_USER {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CapPermissions is what's left of p for credentials with the roles. Super
+// credentials aren't capped, and unverified ones don't get the `CREATE_*`
+// permissions when the verification is required.
+func CapPermissions(p Permission, super bool, verified bool, roles []string) Permission {
+	if !super {
+		p = p.Intersect(RolesPermissions(roles))
+	}
+
+	if IsVerificationRequired() && !verified {
+		p = p.Without(CREATE_PERMISSIONS)
+	}
+
+	return p
+}
+
+// RolesPermissions is the most a token of credentials with the roles can
+// have, with ROLE_USER always included.
+func RolesPermissions(roles []string) Permission {
+	defined := Roles()
+
+	p := defined[ROLE_USER]
+	for _, i := range roles {
+		p = Union(p, defined[i])
+	}
+	return p
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoles(t *testing.T) {
+	if got := Roles(); !reflect.DeepEqual(got, default_roles) {
+		t.Errorf("Roles() without the conf = %v, want the default ones", got)
+	}
+
+	withConf(t, `{"auth": {"roles": {
+		"seller": ["offer:create"],
+		"reviewer": ["review:moderate", "update_review"],
+		"broken": "review:moderate",
+		"unknown": ["review:moderate", "nothing:at_all"]
+	}}}`)
+
+	roles := Roles()
+	if roles[ROLE_SELLER] != CREATE_OFFER {
+		t.Errorf("Roles() seller = %v, want the one in the conf", roles[ROLE_SELLER])
+	}
+	if roles["reviewer"] != Union(MODERATE_REVIEW, UPDATE_REVIEW) {
+		t.Errorf("Roles() reviewer = %v, want it with the scopes in the conf", roles["reviewer"])
+	}
+	if roles[ROLE_MODERATOR] != default_roles[ROLE_MODERATOR] {
+		t.Errorf("Roles() moderator = %v, want the default one", roles[ROLE_MODERATOR])
+	}
+	for _, i := range []string{"broken", "unknown"} {
+		if IsRole(i) {
+			t.Errorf("IsRole(%q) = true for an invalid role", i)
+		}
+	}
+
+	// ROLE_USER is a role, but it isn't one that's assigned
+	if !IsRole(ROLE_USER) {
+		t.Errorf("IsRole(%q) = false", ROLE_USER)
+	}
+	if want := []string{ROLE_MODERATOR, ROLE_PREFERENCE_MANAGER, "reviewer", ROLE_SELLER}; !reflect.DeepEqual(RoleNames(), want) {
+		t.Errorf("RoleNames() = %v, want %v", RoleNames(), want)
+	}
+}
+
+func TestCapPermissions(t *testing.T) {
+	user := default_roles[ROLE_USER]
+	asked := Union(CREATE_BLOQ, CREATE_OFFER, MODERATE_BLOQ, GRANT_SUPER, READ_PROFILE)
+
+	tests := []struct {
+		name     string
+		super    bool
+		verified bool
+		roles    []string
+		want     Permission
+	}{
+		{"user", false, true, nil, asked.Intersect(user)},
+		{"seller", false, true, []string{ROLE_SELLER}, Union(CREATE_BLOQ, CREATE_OFFER, READ_PROFILE)},
+		{"seller and moderator", false, true, []string{ROLE_SELLER, ROLE_MODERATOR}, Union(CREATE_BLOQ, CREATE_OFFER, MODERATE_BLOQ, READ_PROFILE)},
+		{"not a role", false, true, []string{"nothing"}, asked.Intersect(user)},
+		{"super", true, true, nil, asked},
+		// the verification isn't required by the conf
+		{"unverified", false, false, []string{ROLE_SELLER}, Union(CREATE_BLOQ, CREATE_OFFER, READ_PROFILE)},
+	}
+
+	for _, tt := range tests {
+		if got := CapPermissions(asked, tt.super, tt.verified, tt.roles); got != tt.want {
+			t.Errorf("CapPermissions() %s = %v, want %v", tt.name, got.Scopes(), tt.want.Scopes())
+		}
+	}
+
+	if got := RolesPermissions(nil); got != user {
+		t.Errorf("RolesPermissions() without roles = %v, want the ones of %q", got.Scopes(), ROLE_USER)
+	}
+
+	withConf(t, `{"auth": {"email": {"verify": {"required": true}}}}`)
+
+	// without a verified email nothing can be created, not even by super users
+	if got := CapPermissions(asked, false, false, []string{ROLE_SELLER}); got != READ_PROFILE {
+		t.Errorf("CapPermissions() unverified = %v, want %v", got.Scopes(), READ_PROFILE.Scopes())
+	}
+	if got := CapPermissions(asked, true, false, nil); got != Union(MODERATE_BLOQ, GRANT_SUPER, READ_PROFILE) {
+		t.Errorf("CapPermissions() unverified super = %v", got.Scopes())
+	}
+	if got := CapPermissions(asked, false, true, []string{ROLE_SELLER}); got != Union(CREATE_BLOQ, CREATE_OFFER, READ_PROFILE) {
+		t.Errorf("CapPermissions() verified = %v", got.Scopes())
+	}
+}
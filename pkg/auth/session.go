@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Session is a log in, the tokens refreshed from it all share its ID.
+type Session struct {
+	ID string `json:"id"`
+	// TokenID is the `jti` of the last access token issued for it.
+	TokenID   string    `json:"jti"`
+	IssuedAt  time.Time `json:"issued_at"`
+	LastUsed  time.Time `json:"last_used"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Type      AuthType  `json:"type"`
+}
+
+type userAgentKey struct{}
+
+// WithUserAgent remembers the device that is logging in, so it can be told
+// apart from the others in the sessions.
+func WithUserAgent(ctx context.Context, ua string) context.Context {
+	return context.WithValue(ctx, userAgentKey{}, ua)
+}
+
+// UserAgent is the one set with WithUserAgent or, for gRPC calls, the one in
+// the metadata.
+func UserAgent(ctx context.Context) string {
+	if ua, ok := ctx.Value(userAgentKey{}).(string); ok {
+		return ua
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			return ua[0]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+)
+
+// The TOTP codes follow RFC 6238 with the parameters every authenticator app
+// supports: HMAC-SHA1, 6 digits and 30 seconds steps.
+const (
+	totpDigits = 6
+	totpPeriod = 30
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base32NoPadding.EncodeToString(buf), nil
+}
+
+// TOTPURI is the `otpauth://` URI authenticator apps enrol with, usually
+// shown as a QR code. The issuer is `auth.totp.issuer`.
+func TOTPURI(account, secret string) string {
+	issuer := conf.MustGetConfOrDefault("Bloqs", "auth", "totp", "issuer")
+
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+func totpCode(key []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, code%1000000)
+}
+
+// VerifyTOTP checks code against the steps around t, as many as
+// `auth.totp.drift` to each side to tolerate clocks that are off. Steps up to
+// last were already used and are refused, so a code can't be replayed. It
+// returns the step the code is from.
+func VerifyTOTP(secret, code string, t time.Time, last int64) (int64, bool) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	drift := int64(conf.MustGetConfOrDefault[float64](1, "auth", "totp", "drift"))
+	now := t.Unix() / totpPeriod
+
+	for step := now - drift; step <= now+drift; step++ {
+		if step <= last {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns `auth.totp.recovery` one-time codes to use
+// when the authenticator is lost. Only their hashes are stored and, as they
+// are random enough, SHA-256 is all that's needed.
+func GenerateRecoveryCodes() ([]string, error) {
+	n := int(conf.MustGetConfOrDefault[float64](10, "auth", "totp", "recovery"))
+
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(base32NoPadding.EncodeToString(buf))
+		codes = append(codes, code[:8]+"-"+code[8:])
+	}
+
+	return codes, nil
+}
+
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// the SHA-1 secret of the test vectors of RFC 6238, `12345678901234567890`
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// the codes of RFC 6238 are 8 digits, these are their last 6
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	key, err := base32NoPadding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range rfc6238Vectors {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode(%d) = %q, want %q", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		last   int64
+		step   int64
+		ok     bool
+	}{
+		{"current step", rfc6238Secret, "050471", now, 0, step, true},
+		{"lowercase padded secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq====", "050471", now, 0, step, true},
+		{"previous step within drift", rfc6238Secret, "050471", now.Add(totpPeriod * time.Second), 0, step, true},
+		{"next step within drift", rfc6238Secret, "050471", now.Add(-totpPeriod * time.Second), 0, step, true},
+		{"beyond drift", rfc6238Secret, "050471", now.Add(2 * totpPeriod * time.Second), 0, 0, false},
+		{"replayed step", rfc6238Secret, "050471", now, step, 0, false},
+		{"wrong code", rfc6238Secret, "050472", now, 0, 0, false},
+		{"too short", rfc6238Secret, "50471", now, 0, 0, false},
+		{"malformed secret", "not base32!", "050471", now, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTOTP(tt.secret, tt.code, tt.at, tt.last)
+			if ok != tt.ok || step != tt.step {
+				t.Errorf("VerifyTOTP() = (%d, %v), want (%d, %v)", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("abcdefgh-ijklmnop")
+
+	for _, code := range []string{"abcdefghijklmnop", " ABCDEFGH-IJKLMNOP ", "abcd-efgh-ijkl-mnop"} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("HashRecoveryCode(%q) = %q, want %q", code, got, want)
+		}
+	}
+
+	if HashRecoveryCode("abcdefgh-ijklmnoq") == want {
+		t.Error("different codes have the same hash")
+	}
+}
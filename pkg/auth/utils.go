@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"time"
+
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
 	"github.com/bloqs-sites/bloqsenjin/proto"
 )
 
@@ -22,14 +25,29 @@ func Invalid(msg string, status *uint32) *proto.Validation {
 }
 
 func ErrorToValidation(err error, status *uint32) *proto.Validation {
-	return Invalid(err.Error(), status)
+	v := Invalid(err.Error(), status)
+	if err, ok := err.(*mux.HttpError); ok && err.RetryAfter > 0 {
+		v.RetryAfter = &err.RetryAfter
+	}
+
+	return v
+}
+
+// ErrorStatus is the HTTP status of the error when it's a `*mux.HttpError`
+// and def when it isn't.
+func ErrorStatus(err error, def uint32) uint32 {
+	if err, ok := err.(*mux.HttpError); ok {
+		return uint32(err.Status)
+	}
+
+	return def
 }
 
 func CredentialsToID(c *proto.Credentials) *string {
 	switch x := c.Credentials.(type) {
 	case *proto.Credentials_Basic:
 		return &x.Basic.Email
-	case nil:
+	case *proto.Credentials_Oidc, *proto.Credentials_Webauthn, nil: // only known after they are checked
 		return nil
 	default:
 		id := c.String()
@@ -37,7 +55,55 @@ func CredentialsToID(c *proto.Credentials) *string {
 	}
 }
 
+// AccessTokenExp is for how long the access tokens are valid. It's defined in
+// milliseconds at `auth.token.exp`.
+func AccessTokenExp() time.Duration {
+	return time.Duration(conf.MustGetConfOrDefault[float64](900000, "auth", "token", "exp")) * time.Millisecond
+}
+
+// RefreshTokenExp is for how long a session can go without being refreshed.
+// It's defined in milliseconds at `auth.token.refresh`.
+func RefreshTokenExp() time.Duration {
+	return time.Duration(conf.MustGetConfOrDefault[float64](2592000000, "auth", "token", "refresh")) * time.Millisecond
+}
+
+// WebAuthnTimeout is for how long a WebAuthn ceremony can take. It's defined
+// in milliseconds at `auth.webauthn.timeout`.
+func WebAuthnTimeout() time.Duration {
+	return time.Duration(conf.MustGetConfOrDefault[float64](300000, "auth", "webauthn", "timeout")) * time.Millisecond
+}
+
+// AuthTypes are the values of the `type` query parameter to log in with each
+// of the supported types of credentials.
+var AuthTypes = []string{"basic", "oidc", "webauthn"}
+
 func IsAuthMethodSupported(s string) bool {
 	supported, ok := conf.MustGetConfOrDefault(map[string]any{}, "auth", "supported")[s].(bool)
 	return ok && supported
 }
+
+// EmailPurpose is what a token sent by email can be used for.
+type EmailPurpose string
+
+const (
+	VERIFY_EMAIL   EmailPurpose = "verify"
+	RESET_PASSWORD EmailPurpose = "reset"
+	CHANGE_EMAIL   EmailPurpose = "change"
+)
+
+// Exp is for how long the tokens are valid. It's defined in milliseconds at
+// `auth.email.<purpose>.exp`, an hour to reset and a day for the others.
+func (p EmailPurpose) Exp() time.Duration {
+	def := 86400000.
+	if p == RESET_PASSWORD {
+		def = 3600000
+	}
+
+	return time.Duration(conf.MustGetConfOrDefault(def, "auth", "email", string(p), "exp")) * time.Millisecond
+}
+
+// IsVerificationRequired is if `auth.email.verify.required` is set, then
+// credentials need a verified email to be granted the `CREATE_*` permissions.
+func IsVerificationRequired() bool {
+	return conf.MustGetConfOrDefault(false, "auth", "email", "verify", "required")
+}
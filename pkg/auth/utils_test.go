@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmailPurposeExp(t *testing.T) {
+	tests := []struct {
+		purpose EmailPurpose
+		want    time.Duration
+	}{
+		{VERIFY_EMAIL, 24 * time.Hour},
+		{RESET_PASSWORD, time.Hour},
+		{CHANGE_EMAIL, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := tt.purpose.Exp(); got != tt.want {
+			t.Errorf("%s.Exp() = %v, want %v", tt.purpose, got, tt.want)
+		}
+	}
+
+	withConf(t, `{"auth": {"email": {"reset": {"exp": 600000}}}}`)
+	if got := RESET_PASSWORD.Exp(); got != 10*time.Minute {
+		t.Errorf("%s.Exp() = %v, want the configured %v", RESET_PASSWORD, got, 10*time.Minute)
+	}
+}
+
+func TestIsVerificationRequired(t *testing.T) {
+	if IsVerificationRequired() {
+		t.Errorf("IsVerificationRequired() without the conf = true")
+	}
+
+	withConf(t, `{"auth": {"email": {"verify": {"required": true}}}}`)
+	if !IsVerificationRequired() {
+		t.Errorf("IsVerificationRequired() with `auth.email.verify.required` = false")
+	}
+}
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+const (
+	WEBAUTHN_CREATE = "webauthn.create"
+	WEBAUTHN_GET    = "webauthn.get"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+
+	coseES256 = -7
+	coseEdDSA = -8
+	coseRS256 = -257
+)
+
+// WebAuthnRP is the relying party the authenticators are registered to. It's
+// configured at `auth.webauthn`, the id defaults to the host of
+// `auth.domain` and the origins the ceremonies can be made from to it over
+// HTTPS.
+func WebAuthnRP() (id string, name string, origins []string) {
+	id = conf.MustGetConfOrDefault("", "auth", "webauthn", "rp_id")
+	if id == "" {
+		if u, err := url.Parse(conf.MustGetConfOrDefault("", "auth", "domain")); err == nil {
+			id = u.Hostname()
+		}
+	}
+
+	name = conf.MustGetConfOrDefault("Bloqs", "auth", "webauthn", "rp_name")
+
+	for _, i := range conf.MustGetConfOrDefault([]any{}, "auth", "webauthn", "origins") {
+		if origin, ok := i.(string); ok {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		origins = []string{"https://" + id}
+	}
+
+	return
+}
+
+func webAuthnError(msg string) error {
+	return &mux.HttpError{
+		Body:   msg,
+		Status: http.StatusUnauthorized,
+	}
+}
+
+type ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// ParseClientData checks that the client data was made for the ceremony typ
+// from one of the origins allowed.
+func ParseClientData(raw []byte, typ string) (*ClientData, error) {
+	c := new(ClientData)
+	if err := json.Unmarshal(raw, c); err != nil {
+		return nil, webAuthnError(fmt.Sprintf("the client data is malformed:\t%s", err))
+	}
+
+	if c.Type != typ {
+		return nil, webAuthnError(fmt.Sprintf("the client data is for a `%s` ceremony and not for a `%s` one", c.Type, typ))
+	}
+
+	_, _, origins := WebAuthnRP()
+	for _, i := range origins {
+		if c.Origin == i {
+			return c, nil
+		}
+	}
+
+	return nil, webAuthnError(fmt.Sprintf("the origin `%s` is not allowed", c.Origin))
+}
+
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    crypto.PublicKey
+}
+
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, webAuthnError("the authenticator data is too short")
+	}
+
+	d := &AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if d.Flags&flagAttestedData == 0 {
+		return d, nil
+	}
+
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, webAuthnError("the attested credential data is too short")
+	}
+
+	// the AAGUID of the authenticator isn't used
+	l := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < l {
+		return nil, webAuthnError("the credential id is truncated")
+	}
+	d.CredentialID = rest[:l]
+
+	key, _, err := decodeCBOR(rest[l:])
+	if err != nil {
+		return nil, webAuthnError(fmt.Sprintf("the credential public key is malformed:\t%s", err))
+	}
+
+	if d.PublicKey, err = parseCOSEKey(key); err != nil {
+		return nil, webAuthnError(err.Error())
+	}
+
+	return d, nil
+}
+
+// ParseAttestationObject gets the authenticator data of a registration. The
+// attestation statement isn't verified, the options ask for none.
+func ParseAttestationObject(raw []byte) (*AuthenticatorData, error) {
+	obj, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, webAuthnError(fmt.Sprintf("the attestation object is malformed:\t%s", err))
+	}
+
+	m, ok := obj.(map[any]any)
+	if !ok {
+		return nil, webAuthnError("the attestation object is malformed")
+	}
+
+	data, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, webAuthnError("the attestation object has no authenticator data")
+	}
+
+	d, err := ParseAuthenticatorData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.PublicKey == nil {
+		return nil, webAuthnError("the attestation object has no credential")
+	}
+
+	return d, nil
+}
+
+// Check checks that the data is for this relying party and that the user was
+// present.
+func (d *AuthenticatorData) Check() error {
+	id, _, _ := WebAuthnRP()
+	hash := sha256.Sum256([]byte(id))
+	if !bytes.Equal(d.RPIDHash, hash[:]) {
+		return webAuthnError("the authenticator data is for another relying party")
+	}
+
+	if d.Flags&flagUserPresent == 0 {
+		return webAuthnError("the user was not present")
+	}
+
+	if conf.MustGetConfOrDefault(false, "auth", "webauthn", "user_verification") && d.Flags&flagUserVerified == 0 {
+		return webAuthnError("the user was not verified")
+	}
+
+	return nil
+}
+
+// VerifyAssertion verifies the signature the authenticator made over its
+// data and the hash of the client data.
+func VerifyAssertion(pub crypto.PublicKey, authenticator_data, client_data, signature []byte) error {
+	hash := sha256.Sum256(client_data)
+	signed := append(append([]byte(nil), authenticator_data...), hash[:]...)
+	digest := sha256.Sum256(signed)
+
+	valid := false
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(k, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, signed, signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !valid {
+		return webAuthnError("invalid signature")
+	}
+
+	return nil
+}
+
+func parseCOSEKey(v any) (crypto.PublicKey, error) {
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, errors.New("the credential public key is not a COSE key")
+	}
+
+	alg, _ := m[int64(3)].(int64)
+	switch alg {
+	case coseES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 credential public key")
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("invalid P-256 credential public key")
+		}
+
+		return pub, nil
+	case coseEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 credential public key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	case coseRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA credential public key")
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported credential public key algorithm %d", alg)
+	}
+}
+
+// MarshalWebAuthnKey and ParseWebAuthnKey convert the credential public keys
+// to and from how they are stored.
+func MarshalWebAuthnKey(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+func ParseWebAuthnKey(s string) (crypto.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParsePKIXPublicKey(der)
+}
+
+// CreationOptions and RequestOptions are the `publicKey` options of the
+// `navigator.credentials.create` and `navigator.credentials.get` calls, with
+// the binary values encoded as base64url.
+func CreationOptions(challenge, identifier string, exclude []string) map[string]any {
+	id, name, _ := WebAuthnRP()
+	handle := sha256.Sum256([]byte(identifier))
+
+	excluded := make([]map[string]any, 0, len(exclude))
+	for _, i := range exclude {
+		excluded = append(excluded, map[string]any{"type": "public-key", "id": i})
+	}
+
+	return map[string]any{
+		"challenge": challenge,
+		"rp":        map[string]any{"id": id, "name": name},
+		"user": map[string]any{
+			"id":          base64.RawURLEncoding.EncodeToString(handle[:16]),
+			"name":        identifier,
+			"displayName": identifier,
+		},
+		"pubKeyCredParams": []map[string]any{
+			{"type": "public-key", "alg": coseES256},
+			{"type": "public-key", "alg": coseEdDSA},
+			{"type": "public-key", "alg": coseRS256},
+		},
+		"excludeCredentials": excluded,
+		"timeout":            int64(WebAuthnTimeout().Milliseconds()),
+		"attestation":        "none",
+		"authenticatorSelection": map[string]any{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+	}
+}
+
+func RequestOptions(challenge string, allow []string) map[string]any {
+	id, _, _ := WebAuthnRP()
+
+	allowed := make([]map[string]any, 0, len(allow))
+	for _, i := range allow {
+		allowed = append(allowed, map[string]any{"type": "public-key", "id": i})
+	}
+
+	return map[string]any{
+		"challenge":        challenge,
+		"rpId":             id,
+		"allowCredentials": allowed,
+		"timeout":          int64(WebAuthnTimeout().Milliseconds()),
+		"userVerification": "preferred",
+	}
+}
@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+)
+
+// cborHead is the first bytes of a CBOR item, with its major type and
+// argument.
+func cborHead(major byte, arg int) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg < 1<<8:
+		return []byte{major<<5 | 24, byte(arg)}
+	default:
+		return []byte{major<<5 | 25, byte(arg >> 8), byte(arg)}
+	}
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, len(s)), s...)
+}
+
+func coseKey(t *testing.T, pub crypto.PublicKey) []byte {
+	t.Helper()
+
+	var key []byte
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		k.X.FillBytes(x)
+		k.Y.FillBytes(y)
+
+		key = append(key, cborHead(5, 5)...)
+		key = append(key, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21)
+		key = append(key, cborBytes(x)...)
+		key = append(key, 0x22)
+		key = append(key, cborBytes(y)...)
+	case ed25519.PublicKey:
+		key = append(key, cborHead(5, 4)...)
+		key = append(key, 0x01, 0x01, 0x03, 0x27, 0x20, 0x06, 0x21)
+		key = append(key, cborBytes(k)...)
+	case *rsa.PublicKey:
+		e := make([]byte, 4)
+		binary.BigEndian.PutUint32(e, uint32(k.E))
+
+		key = append(key, cborHead(5, 4)...)
+		key = append(key, 0x01, 0x03, 0x03, 0x39, 0x01, 0x00, 0x20)
+		key = append(key, cborBytes(k.N.Bytes())...)
+		key = append(key, 0x21)
+		key = append(key, cborBytes(e[1:])...)
+	default:
+		t.Fatalf("no COSE key for %T", pub)
+	}
+
+	return key
+}
+
+// authenticatorData is the authenticator data for the relying party rp, with
+// the attested credential data when cose isn't nil.
+func authenticatorData(rp string, flags byte, count uint32, id, cose []byte) []byte {
+	hash := sha256.Sum256([]byte(rp))
+
+	data := append([]byte(nil), hash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, count)
+
+	if cose != nil {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(id)))
+		data = append(data, id...)
+		data = append(data, cose...)
+	}
+
+	return data
+}
+
+func attestationObject(data []byte) []byte {
+	obj := cborHead(5, 3)
+	obj = append(obj, cborText("fmt")...)
+	obj = append(obj, cborText("none")...)
+	obj = append(obj, cborText("attStmt")...)
+	obj = append(obj, cborHead(5, 0)...)
+	obj = append(obj, cborText("authData")...)
+
+	return append(obj, cborBytes(data)...)
+}
+
+type testKey struct {
+	name string
+	pub  crypto.PublicKey
+	sign func(signed []byte) []byte
+}
+
+func testKeys(t *testing.T) []testKey {
+	t.Helper()
+
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ed_pub, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []testKey{
+		{"ES256", &ec.PublicKey, func(signed []byte) []byte {
+			digest := sha256.Sum256(signed)
+			sig, _ := ecdsa.SignASN1(rand.Reader, ec, digest[:])
+			return sig
+		}},
+		{"EdDSA", ed_pub, func(signed []byte) []byte {
+			return ed25519.Sign(ed, signed)
+		}},
+		{"RS256", &rs.PublicKey, func(signed []byte) []byte {
+			digest := sha256.Sum256(signed)
+			sig, _ := rsa.SignPKCS1v15(rand.Reader, rs, crypto.SHA256, digest[:])
+			return sig
+		}},
+	}
+}
+
+func TestParseClientData(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		typ  string
+		ok   bool
+	}{
+		{"create", `{"type":"webauthn.create","challenge":"abc","origin":"https://example.com"}`, WEBAUTHN_CREATE, true},
+		{"get", `{"type":"webauthn.get","challenge":"abc","origin":"https://example.com"}`, WEBAUTHN_GET, true},
+		{"other ceremony", `{"type":"webauthn.get","challenge":"abc","origin":"https://example.com"}`, WEBAUTHN_CREATE, false},
+		{"other origin", `{"type":"webauthn.get","challenge":"abc","origin":"https://example.org"}`, WEBAUTHN_GET, false},
+		{"insecure origin", `{"type":"webauthn.get","challenge":"abc","origin":"http://example.com"}`, WEBAUTHN_GET, false},
+		{"malformed", `{"type":`, WEBAUTHN_GET, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseClientData([]byte(tt.raw), tt.typ)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseClientData() error = %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && c.Challenge != "abc" {
+				t.Errorf("ParseClientData().Challenge = %q, want %q", c.Challenge, "abc")
+			}
+		})
+	}
+}
+
+func TestParseAttestationObject(t *testing.T) {
+	id := []byte("credential-id")
+
+	for _, k := range testKeys(t) {
+		t.Run(k.name, func(t *testing.T) {
+			data := authenticatorData("example.com", flagUserPresent|flagAttestedData, 7, id, coseKey(t, k.pub))
+
+			d, err := ParseAttestationObject(attestationObject(data))
+			if err != nil {
+				t.Fatalf("ParseAttestationObject() error = %v", err)
+			}
+
+			if string(d.CredentialID) != string(id) || d.SignCount != 7 {
+				t.Errorf("ParseAttestationObject() = %+v, want the credential %q counted 7", d, id)
+			}
+			if !d.PublicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(k.pub) {
+				t.Errorf("ParseAttestationObject() has another public key")
+			}
+			if err := d.Check(); err != nil {
+				t.Errorf("Check() error = %v", err)
+			}
+		})
+	}
+
+	ed_pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	cose := coseKey(t, ed_pub)
+
+	tests := []struct {
+		name string
+		obj  []byte
+	}{
+		{"not cbor", []byte{0xff}},
+		{"not a map", cborText("authData")},
+		{"no authenticator data", append(cborHead(5, 1), append(cborText("fmt"), cborText("none")...)...)},
+		{"too short", attestationObject(make([]byte, 36))},
+		{"no credential", attestationObject(authenticatorData("example.com", flagUserPresent, 0, nil, nil))},
+		{"truncated credential id", attestationObject(authenticatorData("example.com", flagAttestedData, 0, id, []byte{})[:60])},
+		{"malformed key", attestationObject(authenticatorData("example.com", flagAttestedData, 0, id, cose[:len(cose)-1]))},
+		{"unsupported algorithm", attestationObject(authenticatorData("example.com", flagAttestedData, 0, id, []byte{0xa1, 0x03, 0x38, 0x24}))},
+		{"key not on the curve", attestationObject(authenticatorData("example.com", flagAttestedData, 0, id,
+			append(append(append([]byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21}, cborBytes(make([]byte, 32))...), 0x22), cborBytes(make([]byte, 32))...)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAttestationObject(tt.obj); err == nil {
+				t.Error("ParseAttestationObject() didn't fail")
+			}
+		})
+	}
+}
+
+func TestAuthenticatorDataCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"present", authenticatorData("example.com", flagUserPresent, 0, nil, nil), true},
+		{"present and verified", authenticatorData("example.com", flagUserPresent|flagUserVerified, 0, nil, nil), true},
+		{"not present", authenticatorData("example.com", flagUserVerified, 0, nil, nil), false},
+		{"other relying party", authenticatorData("example.org", flagUserPresent, 0, nil, nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseAuthenticatorData(tt.data)
+			if err != nil {
+				t.Fatalf("ParseAuthenticatorData() error = %v", err)
+			}
+
+			if err := d.Check(); (err == nil) != tt.ok {
+				t.Errorf("Check() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	data := authenticatorData("example.com", flagUserPresent, 8, nil, nil)
+	client := []byte(`{"type":"webauthn.get","challenge":"abc","origin":"https://example.com"}`)
+
+	keys := testKeys(t)
+	for i, k := range keys {
+		hash := sha256.Sum256(client)
+		signature := k.sign(append(append([]byte(nil), data...), hash[:]...))
+
+		other := keys[(i+1)%len(keys)].pub
+
+		tests := []struct {
+			name      string
+			pub       crypto.PublicKey
+			data      []byte
+			client    []byte
+			signature []byte
+			ok        bool
+		}{
+			{"valid", k.pub, data, client, signature, true},
+			{"other authenticator data", k.pub, authenticatorData("example.com", flagUserPresent, 9, nil, nil), client, signature, false},
+			{"other client data", k.pub, data, []byte(`{}`), signature, false},
+			{"other key", other, data, client, signature, false},
+			{"no signature", k.pub, data, client, nil, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(k.name+" "+tt.name, func(t *testing.T) {
+				if err := VerifyAssertion(tt.pub, tt.data, tt.client, tt.signature); (err == nil) != tt.ok {
+					t.Errorf("VerifyAssertion() error = %v, want ok %v", err, tt.ok)
+				}
+			})
+		}
+	}
+}
+
+func TestWebAuthnKey(t *testing.T) {
+	for _, k := range testKeys(t) {
+		t.Run(k.name, func(t *testing.T) {
+			s, err := MarshalWebAuthnKey(k.pub)
+			if err != nil {
+				t.Fatalf("MarshalWebAuthnKey() error = %v", err)
+			}
+
+			pub, err := ParseWebAuthnKey(s)
+			if err != nil {
+				t.Fatalf("ParseWebAuthnKey() error = %v", err)
+			}
+
+			if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(k.pub) {
+				t.Error("ParseWebAuthnKey() has another public key")
+			}
+		})
+	}
+}
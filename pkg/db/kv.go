@@ -5,6 +5,12 @@ import (
 	"time"
 )
 
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Score  float64
+	Member []byte
+}
+
 type KVDBer interface {
 	Get(ctx context.Context, key ...string) (map[string][]byte, error)
 	Put(ctx context.Context, entries map[string][]byte, ttl time.Duration) error
@@ -12,6 +18,21 @@ type KVDBer interface {
 	List(ctx context.Context, prefix *string, limit *uint) ([]string, uint64, error)
 	DeleteAll(ctx context.Context) error
 	Head(ctx context.Context, key ...string) (bool, error)
+	// PutIfAbsent only puts the entry if the key doesn't exist, atomically,
+	// and is if it was put.
+	PutIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Incr adds one to the counter at key, atomically, and returns it. The
+	// counter is made with the ttl when it doesn't exist.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// ZAdd adds the member to the sorted sets at the keys, which then only
+	// keep the keep members with the highest scores, all of them when it's 0,
+	// for ttl.
+	ZAdd(ctx context.Context, keys []string, m ZMember, keep int64, ttl time.Duration) error
+	// ZRevRange is up to limit members of the sorted set at key, from the
+	// highest score, with a score below before when it isn't nil.
+	ZRevRange(ctx context.Context, key string, before *float64, limit int64) ([]ZMember, error)
+	// ZRem removes the members from the sorted set at key.
+	ZRem(ctx context.Context, key string, members ...[]byte) error
 
 	Close() error
 }
@@ -1,6 +1,13 @@
 package db
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrDuplicate is wrapped by the errors of the inserts of rows that break a
+// unique key.
+var ErrDuplicate = errors.New("the row already exists")
 
 type Operator = uint8
 
@@ -11,6 +18,8 @@ const (
 	GT
 	LE
 	LT
+	// IN matches the values of a `[]any`, none of them when it's empty.
+	IN
 )
 
 type Table struct {
@@ -29,28 +38,56 @@ type View struct {
 	Select string
 }
 
+// Migration changes the tables made by an older version into how they are
+// now. Each one runs once, in order, and the statements that fail because the
+// change is already there, like in a table that was just made, are skipped.
+type Migration struct {
+	ID         string
+	Statements []string
+}
+
 type Mapper interface {
 	CreateTable() []Table
 	CreateIndexes() []Index
 	CreateViews() []View
 }
 
+// Migrater is implemented by the Mappers whose tables changed since they were
+// first made.
+type Migrater interface {
+	Migrations() []Migration
+}
+
 type Condition struct {
 	Column string
 	Op     Operator
 	Value  any
 }
 
+// Page orders the rows of a selection by a column and keeps the first Limit
+// of them, all of them when it's 0.
+type Page struct {
+	OrderBy string
+	Desc    bool
+	Limit   uint
+}
+
 type DataManipulater interface {
 	Select(ctx context.Context, table string, columns func() map[string]any, where []Condition) (Result, error)
+	SelectPage(ctx context.Context, table string, columns func() map[string]any, where []Condition, page Page) (Result, error)
 	Insert(ctx context.Context, table string, rows []map[string]any) (Result, error)
 	Update(ctx context.Context, table string, assignments map[string]any, conditions map[string]any) error
 	Delete(ctx context.Context, table string, conditions map[string]any) error
+	// Increment adds the deltas to the columns of the row with the keys, in
+	// one statement so the ones at the same time all count. The row is made
+	// with the deltas if there isn't one, the keys have to be unique.
+	Increment(ctx context.Context, table string, keys map[string]any, deltas map[string]int64) error
 
 	CreateTables(context.Context, []Table) error
 	CreateIndexes(context.Context, []Index) error
 	CreateViews(context.Context, []View) error
 	DropTables(context.Context, []Table) error
+	Migrate(context.Context, []Migration) error
 
 	Close() error
 }
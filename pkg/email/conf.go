@@ -0,0 +1,68 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+)
+
+// NewSenderFromConf makes the Sender of the transport at `email.transport`,
+// `resend`, `smtp` or `file`. Without one it's Resend if
+// `BLOQS_EMAIL_RESEND_KEY` is set, otherwise there's no Sender and nil is
+// returned.
+func NewSenderFromConf() (Sender, error) {
+	key, has_key := os.LookupEnv("BLOQS_EMAIL_RESEND_KEY")
+
+	def := ""
+	if has_key {
+		def = "resend"
+	}
+
+	switch transport := conf.MustGetConfOrDefault(def, "email", "transport"); transport {
+	case "resend":
+		if !has_key {
+			return nil, errors.New("`BLOQS_EMAIL_RESEND_KEY` needs to be set to send emails with Resend")
+		}
+		return NewResendSender(key), nil
+	case "smtp":
+		host := conf.MustGetConfOrDefault("", "email", "smtp", "host")
+		if host == "" {
+			return nil, errors.New("`email.smtp.host` needs to be set to send emails with SMTP")
+		}
+
+		return NewSMTPSender(
+			host,
+			fmt.Sprint(conf.MustGetConfOrDefault[float64](587, "email", "smtp", "port")),
+			conf.MustGetConfOrDefault("", "email", "smtp", "username"),
+			os.Getenv("BLOQS_EMAIL_SMTP_PASSWORD"),
+			conf.MustGetConfOrDefault(true, "email", "smtp", "starttls"),
+		), nil
+	case "file":
+		return NewFileSender(conf.MustGetConfOrDefault("mail", "email", "file", "dir")), nil
+	case "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported email transport `%s`", transport)
+	}
+}
+
+// NewQueueFromConf makes a Queue for the Sender of the conf with the options
+// at `email.queue`. The sender is `email.from`.
+func NewQueueFromConf() (*Queue, error) {
+	sender, err := NewSenderFromConf()
+	if err != nil || sender == nil {
+		return nil, err
+	}
+
+	return NewQueue(
+		sender,
+		conf.MustGetConfOrDefault("Bloqs <onboarding@resend.dev>", "email", "from"),
+		int(conf.MustGetConfOrDefault[float64](100, "email", "queue", "size")),
+		int(conf.MustGetConfOrDefault[float64](2, "email", "queue", "workers")),
+		int(conf.MustGetConfOrDefault[float64](3, "email", "queue", "retries")),
+		time.Duration(conf.MustGetConfOrDefault[float64](1000, "email", "queue", "backoff"))*time.Millisecond,
+	), nil
+}
@@ -0,0 +1,86 @@
+package email
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/bloqs-sites/bloqsenjin/internal/testconf"
+)
+
+func TestNewSenderFromConf(t *testing.T) {
+	tests := []struct {
+		name string
+		conf string
+		key  string
+		want Sender
+		err  bool
+	}{
+		{"nothing", `{}`, "", nil, false},
+		{"Resend by the key", `{}`, "re_key", &ResendSender{}, false},
+		{"Resend without the key", `{"email": {"transport": "resend"}}`, "", nil, true},
+		{"SMTP", `{"email": {"transport": "smtp", "smtp": {"host": "smtp.example.com"}}}`, "", &SMTPSender{}, false},
+		{"SMTP without the host", `{"email": {"transport": "smtp"}}`, "", nil, true},
+		{"file", `{"email": {"transport": "file"}}`, "re_key", &FileSender{}, false},
+		{"unsupported", `{"email": {"transport": "pigeon"}}`, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BLOQS_EMAIL_RESEND_KEY", tt.key)
+			if tt.key == "" {
+				os.Unsetenv("BLOQS_EMAIL_RESEND_KEY")
+			}
+
+			cleanup, err := testconf.Compile(tt.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			got, err := NewSenderFromConf()
+			if (err != nil) != tt.err {
+				t.Fatalf("NewSenderFromConf() error = %v", err)
+			}
+
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("NewSenderFromConf() = %T, want none", got)
+				}
+			} else if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+				t.Errorf("NewSenderFromConf() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewQueueFromConf(t *testing.T) {
+	t.Setenv("BLOQS_EMAIL_RESEND_KEY", "")
+	os.Unsetenv("BLOQS_EMAIL_RESEND_KEY")
+
+	cleanup, err := testconf.Compile(`{}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	// there's no queue without a transport
+	if q, err := NewQueueFromConf(); q != nil || err != nil {
+		t.Errorf("NewQueueFromConf() = (%v, %v), want none", q, err)
+	}
+
+	cleanup, err = testconf.Compile(`{"email": {"transport": "file", "from": "no-reply@example.com", "queue": {"workers": 0}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	q, err := NewQueueFromConf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if q.from != "no-reply@example.com" || q.jobs != nil || q.retries != 3 {
+		t.Errorf("NewQueueFromConf() = %+v", q)
+	}
+}
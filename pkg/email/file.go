@@ -0,0 +1,42 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSender writes the messages to a maildir, so they can be read with a
+// mail client while developing without sending anything.
+type FileSender struct {
+	dir string
+}
+
+func NewFileSender(dir string) *FileSender {
+	return &FileSender{dir}
+}
+
+func (s *FileSender) Send(ctx context.Context, m *Message) error {
+	msg, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+
+	for _, i := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(s.dir, i), 0o700); err != nil {
+			return err
+		}
+	}
+
+	// written to `tmp` and then moved to `new`, so readers never see half a
+	// message
+	name := fmt.Sprintf("%d.%d_%d.%s.eml", time.Now().Unix(), os.Getpid(), time.Now().UnixNano(), hostname)
+	tmp := filepath.Join(s.dir, "tmp", name)
+	if err := os.WriteFile(tmp, msg, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(s.dir, "new", name))
+}
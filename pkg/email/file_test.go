@@ -0,0 +1,47 @@
+package email
+
+import (
+	"context"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	s := NewFileSender(dir)
+
+	for _, subject := range []string{"one", "two"} {
+		if err := s.Send(context.Background(), &Message{From: "no-reply@example.com", To: []string{"user@example.com"}, Subject: subject, Text: subject}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the messages are in `new` and nothing is left in `tmp`
+	if tmp, err := os.ReadDir(filepath.Join(dir, "tmp")); err != nil || len(tmp) != 0 {
+		t.Errorf("tmp has %v (%v), want it empty", tmp, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("new has %d messages, want 2", len(entries))
+	}
+
+	f, err := os.Open(filepath.Join(dir, "new", entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("To") != "user@example.com" {
+		t.Errorf("the message is to %q", msg.Header.Get("To"))
+	}
+}
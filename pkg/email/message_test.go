@@ -0,0 +1,77 @@
+package email
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+)
+
+func TestMessageBytes(t *testing.T) {
+	m := &Message{
+		From:    "Bloqs <no-reply@example.com>",
+		To:      []string{"a@example.com", "b@example.com"},
+		Subject: "Verifica o teu email",
+		Text:    "Olá, segue a ligação",
+		HTML:    "<p>Olá</p>",
+	}
+
+	buf, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := msg.Header.Get("To"); got != "a@example.com, b@example.com" {
+		t.Errorf("To: %q", got)
+	}
+	if got, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || got != m.Subject {
+		t.Errorf("Subject: %q (%v), want %q", got, err, m.Subject)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+
+	typ, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || typ != "multipart/alternative" {
+		t.Fatalf("Content-Type: %q (%v)", typ, err)
+	}
+
+	// multipart decodes the quoted-printable parts
+	parts := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[part.Header.Get("Content-Type")] = string(body)
+	}
+
+	if len(parts) != 2 || parts["text/plain; charset=utf-8"] != m.Text || parts["text/html; charset=utf-8"] != m.HTML {
+		t.Errorf("the parts are %q", parts)
+	}
+
+	// a message without HTML only has the text
+	m.HTML = ""
+	if buf, err = m.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf, []byte("text/html")) {
+		t.Errorf("Bytes() without HTML has an HTML part")
+	}
+}
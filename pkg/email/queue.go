@@ -0,0 +1,118 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type acceptLanguageKey struct{}
+
+// WithAcceptLanguage remembers the `Accept-Language` of the request, so the
+// emails sent because of it are in a language the user understands.
+func WithAcceptLanguage(ctx context.Context, accept string) context.Context {
+	return context.WithValue(ctx, acceptLanguageKey{}, accept)
+}
+
+func acceptLanguage(ctx context.Context) string {
+	accept, _ := ctx.Value(acceptLanguageKey{}).(string)
+	return accept
+}
+
+// Queue sends the messages in the background, retrying the ones that fail, so
+// a slow provider doesn't make who is waiting for the response wait too.
+// Without workers it sends them right away.
+type Queue struct {
+	sender  Sender
+	from    string
+	jobs    chan *Message
+	retries int
+	backoff time.Duration
+	wg      sync.WaitGroup
+}
+
+func NewQueue(sender Sender, from string, size, workers, retries int, backoff time.Duration) *Queue {
+	q := &Queue{
+		sender:  sender,
+		from:    from,
+		jobs:    make(chan *Message, size),
+		retries: retries,
+		backoff: backoff,
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	if workers <= 0 {
+		q.jobs = nil
+	}
+
+	return q
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for m := range q.jobs {
+		wait := q.backoff
+		for attempt := 0; ; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			err := q.sender.Send(ctx, m)
+			cancel()
+
+			if err == nil {
+				break
+			}
+
+			if attempt >= q.retries {
+				fmt.Printf("could not send the email `%s` to %v:\t%v\n", m.Subject, m.To, err)
+				break
+			}
+
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+}
+
+func (q *Queue) Send(ctx context.Context, m *Message) error {
+	if m.From == "" {
+		m.From = q.from
+	}
+
+	if q.jobs == nil {
+		return q.sender.Send(ctx, m)
+	}
+
+	select {
+	case q.jobs <- m:
+		return nil
+	default:
+		return errors.New("the queue of emails to send is full")
+	}
+}
+
+// Mail renders the template in the language of the context and sends it.
+func (q *Queue) Mail(ctx context.Context, to string, template string, data map[string]any) error {
+	m, err := Render(template, acceptLanguage(ctx), data)
+	if err != nil {
+		return err
+	}
+
+	m.To = []string{to}
+
+	return q.Send(ctx, m)
+}
+
+// Close waits for the messages in the queue to be sent.
+func (q *Queue) Close() {
+	if q.jobs != nil {
+		close(q.jobs)
+	}
+
+	q.wg.Wait()
+}
@@ -0,0 +1,135 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flaky fails the first fails sends of each subject and records the ones
+// that go through. It waits for release, when it's set, before sending.
+type flaky struct {
+	mu       sync.Mutex
+	fails    int
+	attempts map[string]int
+	sent     []*Message
+	release  chan struct{}
+}
+
+func (s *flaky) Send(ctx context.Context, m *Message) error {
+	if s.release != nil {
+		<-s.release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts[m.Subject]++
+	if s.attempts[m.Subject] <= s.fails {
+		return errors.New("the provider is down")
+	}
+
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func TestQueueRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		fails    int
+		attempts int
+		sent     int
+	}{
+		{"at once", 0, 1, 1},
+		{"after retrying", 2, 3, 1},
+		{"never", 5, 4, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &flaky{fails: tt.fails, attempts: map[string]int{}}
+			q := NewQueue(s, "Bloqs <no-reply@example.com>", 10, 1, 3, time.Millisecond)
+
+			if err := q.Send(context.Background(), &Message{To: []string{"user@example.com"}, Subject: "hi"}); err != nil {
+				t.Fatal(err)
+			}
+			q.Close()
+
+			if s.attempts["hi"] != tt.attempts || len(s.sent) != tt.sent {
+				t.Errorf("it was tried %d times and sent %d, want %d and %d", s.attempts["hi"], len(s.sent), tt.attempts, tt.sent)
+			}
+		})
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	s := &flaky{attempts: map[string]int{}, release: make(chan struct{})}
+	q := NewQueue(s, "", 1, 1, 0, 0)
+
+	ctx := context.Background()
+	// the worker takes the first and waits, the second waits in the queue
+	if err := q.Send(ctx, &Message{Subject: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); len(q.jobs) != 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if err := q.Send(ctx, &Message{Subject: "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Send(ctx, &Message{Subject: "3"}); err == nil {
+		t.Errorf("Send() to a full queue didn't fail")
+	}
+
+	close(s.release)
+	q.Close()
+
+	if len(s.sent) != 2 {
+		t.Errorf("%d messages were sent, want 2", len(s.sent))
+	}
+}
+
+func TestQueueWithoutWorkers(t *testing.T) {
+	s := &flaky{fails: 1, attempts: map[string]int{}}
+	q := NewQueue(s, "Bloqs <no-reply@example.com>", 10, 0, 3, time.Millisecond)
+	defer q.Close()
+
+	// it's sent right away, so the error is returned and not retried
+	ctx := context.Background()
+	if err := q.Send(ctx, &Message{Subject: "hi"}); err == nil {
+		t.Errorf("Send() without workers didn't return the error")
+	}
+	if err := q.Send(ctx, &Message{Subject: "hi", From: "other@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.attempts["hi"] != 2 || len(s.sent) != 1 || s.sent[0].From != "other@example.com" {
+		t.Errorf("it was tried %d times and sent %+v", s.attempts["hi"], s.sent)
+	}
+}
+
+func TestQueueMail(t *testing.T) {
+	s := &flaky{attempts: map[string]int{}}
+	q := NewQueue(s, "Bloqs <no-reply@example.com>", 10, 0, 0, 0)
+	defer q.Close()
+
+	ctx := WithAcceptLanguage(context.Background(), "pt-PT,pt;q=0.9")
+	if err := q.Mail(ctx, "user@example.com", "verify", map[string]any{"Link": "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.sent) != 1 {
+		t.Fatalf("%d messages were sent, want 1", len(s.sent))
+	}
+	m := s.sent[0]
+	if m.From != "Bloqs <no-reply@example.com>" || !reflect.DeepEqual(m.To, []string{"user@example.com"}) || m.Subject != "Verifica o teu email da Bloqs" {
+		t.Errorf("Mail() sent %+v", m)
+	}
+
+	if err := q.Mail(context.Background(), "user@example.com", "welcome", nil); err == nil {
+		t.Errorf("Mail() of a template without translations didn't fail")
+	}
+}
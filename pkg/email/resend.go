@@ -0,0 +1,27 @@
+package email
+
+import (
+	"context"
+
+	"github.com/resendlabs/resend-go"
+)
+
+type ResendSender struct {
+	client *resend.Client
+}
+
+func NewResendSender(key string) *ResendSender {
+	return &ResendSender{resend.NewClient(key)}
+}
+
+func (s *ResendSender) Send(ctx context.Context, m *Message) error {
+	_, err := s.client.Emails.Send(&resend.SendEmailRequest{
+		From:    m.From,
+		To:      m.To,
+		Subject: m.Subject,
+		Text:    m.Text,
+		Html:    m.HTML,
+	})
+
+	return err
+}
@@ -0,0 +1,129 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	html_template "html/template"
+	"strings"
+	text_template "text/template"
+)
+
+const default_lang = "en"
+
+var (
+	//go:embed templates
+	templates embed.FS
+	//go:embed locales
+	locales embed.FS
+)
+
+// translations returns the strings of lang, falling back to the default
+// language for the ones it doesn't have.
+func translations(lang string) (map[string]string, error) {
+	strs := map[string]string{}
+
+	for _, i := range []string{default_lang, lang} {
+		buf, err := locales.ReadFile("locales/" + i + ".json")
+		if err != nil {
+			if i == default_lang {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := json.Unmarshal(buf, &strs); err != nil {
+			return nil, err
+		}
+	}
+
+	return strs, nil
+}
+
+// Language picks from an `Accept-Language` header the first language there
+// are translations for.
+func Language(accept string) string {
+	for _, i := range strings.Split(accept, ",") {
+		lang := strings.ToLower(strings.TrimSpace(strings.SplitN(i, ";", 2)[0]))
+		lang = strings.SplitN(lang, "-", 2)[0]
+
+		if lang == "" {
+			continue
+		}
+
+		if _, err := locales.Open("locales/" + lang + ".json"); err == nil {
+			return lang
+		}
+	}
+
+	return default_lang
+}
+
+// Render makes the message of the template name, `verify`, `reset` or
+// `change`, in the
+// language that fits accept best. The strings of the template are the ones
+// prefixed with its name in the locales and can use data too.
+func Render(name, accept string, data map[string]any) (*Message, error) {
+	lang := Language(accept)
+
+	strs, err := translations(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{"Lang": lang}
+	for k, v := range data {
+		values[k] = v
+	}
+
+	t := func(key string) (string, error) {
+		str, ok := strs[name+"."+key]
+		if !ok {
+			if str, ok = strs[key]; !ok {
+				return "", fmt.Errorf("no translation for `%s` of the template `%s`", key, name)
+			}
+		}
+
+		tmpl, err := text_template.New(key).Parse(str)
+		if err != nil {
+			return "", err
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, values); err != nil {
+			return "", err
+		}
+
+		return buf.String(), nil
+	}
+
+	subject, err := t("subject")
+	if err != nil {
+		return nil, err
+	}
+
+	var text, html bytes.Buffer
+
+	txt_tmpl, err := text_template.New(name).Funcs(text_template.FuncMap{"t": t}).ParseFS(templates, "templates/layout.txt")
+	if err != nil {
+		return nil, err
+	}
+	if err := txt_tmpl.ExecuteTemplate(&text, "layout", values); err != nil {
+		return nil, err
+	}
+
+	html_tmpl, err := html_template.New(name).Funcs(html_template.FuncMap{"t": t}).ParseFS(templates, "templates/layout.html")
+	if err != nil {
+		return nil, err
+	}
+	if err := html_tmpl.ExecuteTemplate(&html, "layout", values); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
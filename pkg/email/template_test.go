@@ -0,0 +1,87 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "en"},
+		{"pt", "pt"},
+		{"pt-PT,pt;q=0.9,en;q=0.8", "pt"},
+		{"PT-br", "pt"},
+		{"fr-FR, pt;q=0.5", "pt"},
+		{"fr-FR,de", "en"},
+		{"*", "en"},
+		{"../en", "en"},
+	}
+
+	for _, tt := range tests {
+		if got := Language(tt.accept); got != tt.want {
+			t.Errorf("Language(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	link := "https://auth.example.com/email/reset?token=a&b"
+
+	m, err := Render("reset", "pt-PT", map[string]any{"Link": link, "Minutes": 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Subject != "Repõe a tua palavra-passe da Bloqs" {
+		t.Errorf("Render() subject = %q", m.Subject)
+	}
+	for _, i := range []string{"Olá,", "durante 60 minutos", link} {
+		if !strings.Contains(m.Text, i) {
+			t.Errorf("Render() text = %q, want it with %q", m.Text, i)
+		}
+	}
+	// the link is escaped in the HTML
+	for _, i := range []string{`<html lang="pt">`, `href="https://auth.example.com/email/reset?token=a&amp;b"`, "Repor palavra-passe"} {
+		if !strings.Contains(m.HTML, i) {
+			t.Errorf("Render() HTML = %q, want it with %q", m.HTML, i)
+		}
+	}
+	if m.From != "" || len(m.To) != 0 {
+		t.Errorf("Render() addressed the message to %v from %q", m.To, m.From)
+	}
+
+	if _, err := Render("welcome", "", nil); err == nil {
+		t.Errorf("Render() of a template without translations didn't fail")
+	}
+}
+
+// TestRenderLocales renders every template in every language, so none of
+// them misses a string.
+func TestRenderLocales(t *testing.T) {
+	entries, err := locales.ReadDir("locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range entries {
+		lang := strings.TrimSuffix(i.Name(), ".json")
+		strs, err := translations(lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{"verify", "reset", "change"} {
+			m, err := Render(name, lang, map[string]any{"Link": "https://example.com", "Minutes": 60, "From": "old@example.com"})
+			if err != nil {
+				t.Errorf("Render(%q, %q) error = %v", name, lang, err)
+				continue
+			}
+			if m.Subject != strs[name+".subject"] || strings.Contains(m.Text, "<no value>") {
+				t.Errorf("Render(%q, %q) = %+v", name, lang, m)
+			}
+		}
+	}
+}
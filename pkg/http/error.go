@@ -3,6 +3,8 @@ package http
 type HttpError struct {
 	Body   string
 	Status uint16
+	// RetryAfter is in seconds, for when the request can be tried again.
+	RetryAfter uint32
 }
 
 func (e *HttpError) Error() string {
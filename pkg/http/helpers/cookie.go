@@ -12,7 +12,8 @@ import (
 
 const (
 	//JWT_COOKIE = "__Host-bloqs-auth"
-	JWT_COOKIE = "_Secure-bloqs-auth"
+	JWT_COOKIE     = "_Secure-bloqs-auth"
+	REFRESH_COOKIE = "_Secure-bloqs-refresh"
 )
 
 func SetToken(w http.ResponseWriter, r *http.Request, jwt string) error {
@@ -21,7 +22,7 @@ func SetToken(w http.ResponseWriter, r *http.Request, jwt string) error {
 	http.SetCookie(w, &http.Cookie{
 		Name:     JWT_COOKIE,
 		Value:    jwt,
-		Expires:  time.Now().Add(time.Duration(exp)),
+		Expires:  time.Now().Add(time.Duration(exp) * time.Millisecond),
 		Path:     "/",
 		Domain:   r.URL.Host,
 		Secure:   true,
@@ -32,6 +33,40 @@ func SetToken(w http.ResponseWriter, r *http.Request, jwt string) error {
 	return nil
 }
 
+func SetRefreshToken(w http.ResponseWriter, r *http.Request, refresh string) error {
+	exp := conf.MustGetConfOrDefault[float64](2592000000, "auth", "token", "refresh")
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     REFRESH_COOKIE,
+		Value:    refresh,
+		Expires:  time.Now().Add(time.Duration(exp) * time.Millisecond),
+		Path:     "/",
+		Domain:   r.URL.Host,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	return nil
+}
+
+// ExtractRefreshToken gets the refresh token from its HTTP Cookie or from the
+// `refresh_token` body field.
+func ExtractRefreshToken(r *http.Request) ([]byte, error) {
+	if cookie, err := r.Cookie(REFRESH_COOKIE); err == nil && cookie.Value != "" {
+		return []byte(cookie.Value), nil
+	}
+
+	if v := r.FormValue("refresh_token"); v != "" {
+		return []byte(v), nil
+	}
+
+	return nil, &mux.HttpError{
+		Body:   fmt.Sprintf("HTTP Cookie `%s` and/or `refresh_token` body field is missing", REFRESH_COOKIE),
+		Status: http.StatusUnauthorized,
+	}
+}
+
 func ExtractToken(w http.ResponseWriter, r *http.Request) (jwt []byte, err error) {
 	var cookie *http.Cookie
 	cookie, err = r.Cookie(JWT_COOKIE)
@@ -48,9 +83,9 @@ func ExtractToken(w http.ResponseWriter, r *http.Request) (jwt []byte, err error
 
 		bearerToken := strings.Split(header, " ")
 
-		if len(bearerToken) != 2 || bearerToken[0] != BEARER_PREFIX {
+		if len(bearerToken) != 2 || (bearerToken[0] != BEARER_PREFIX && bearerToken[0] != API_KEY_PREFIX) {
 			return nil, &mux.HttpError{
-				Body:   "`Authorization` HTTP Header does not have a Bearer token",
+				Body:   "`Authorization` HTTP Header does not have a Bearer token or an API key",
 				Status: http.StatusUnauthorized,
 			}
 		}
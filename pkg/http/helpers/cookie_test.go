@@ -0,0 +1,50 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
+func TestExtractTokenHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"bearer", "Bearer eyJhbGciOiJFZERTQSJ9.e30.c2ln", "eyJhbGciOiJFZERTQSJ9.e30.c2ln"},
+		{"API key", "ApiKey bloqs_abcdefgh_c2VjcmV0", "bloqs_abcdefgh_c2VjcmV0"},
+		{"other scheme", "Basic dXNlcjpwYXNz", ""},
+		{"no scheme", "bloqs_abcdefgh_c2VjcmV0", ""},
+		{"too many parts", "ApiKey bloqs_abcdefgh_c2VjcmV0 more", ""},
+		{"missing", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			got, err := ExtractToken(httptest.NewRecorder(), r)
+			if tt.want == "" {
+				var e *mux.HttpError
+				if !errors.As(err, &e) || e.Status != http.StatusUnauthorized {
+					t.Errorf("ExtractToken() error = %v, want a 401", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ExtractToken() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ExtractToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,13 @@
 package helpers
 
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	mux "github.com/bloqs-sites/bloqsenjin/pkg/http"
+)
+
 func FormValueTrue(v string) bool {
 	if v == "yes" || v == "on" || v == "1" || v == "true" {
 		return true
@@ -7,3 +15,34 @@ func FormValueTrue(v string) bool {
 
 	return false
 }
+
+// ParseFormBody parses a url encoded or a multipart body, the other media
+// types aren't accepted.
+func ParseFormBody(w http.ResponseWriter, r *http.Request) error {
+	ct := r.Header.Get("Content-Type")
+	if strings.HasPrefix(ct, X_WWW_FORM_URLENCODED) {
+		if err := r.ParseForm(); err != nil {
+			return &mux.HttpError{
+				Body:   fmt.Sprintf("the HTTP request body could not be parsed as `%s`:\t%s", X_WWW_FORM_URLENCODED, err),
+				Status: http.StatusBadRequest,
+			}
+		}
+	} else if strings.HasPrefix(ct, FORM_DATA) {
+		if err := r.ParseMultipartForm(0x400); err != nil {
+			return &mux.HttpError{
+				Body:   fmt.Sprintf("the HTTP request body could not be parsed as `%s`:\t%s", FORM_DATA, err),
+				Status: http.StatusBadRequest,
+			}
+		}
+	} else {
+		h := w.Header()
+		Append(&h, "Accept", X_WWW_FORM_URLENCODED)
+		Append(&h, "Accept", FORM_DATA)
+		return &mux.HttpError{
+			Body:   fmt.Sprintf("request has the usupported media type `%s`", ct),
+			Status: http.StatusUnsupportedMediaType,
+		}
+	}
+
+	return nil
+}
@@ -1,9 +1,16 @@
 package helpers
 
-import "net/http"
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
+)
 
 const (
-	BEARER_PREFIX = "Bearer"
+	BEARER_PREFIX  = "Bearer"
+	API_KEY_PREFIX = "ApiKey"
 )
 
 func Append(h *http.Header, name, value string) {
@@ -13,3 +20,22 @@ func Append(h *http.Header, name, value string) {
 		h.Set(name, value)
 	}
 }
+
+// ClientIP is the address of who made the request. `X-Forwarded-For` is only
+// trusted when `proxied` is set in the conf, otherwise anyone could pick
+// their own address, and only the address the proxy added to it is used.
+func ClientIP(r *http.Request) string {
+	if conf.MustGetConfOrDefault(false, "proxied") {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type Created struct {
-	LastID  *int64 `json:"id"`
-	Status  uint16 `json:"status"`
-	Message string `json:"message"`
+	LastID   *int64 `json:"id"`
+	Status   uint16 `json:"status"`
+	Message  string `json:"message"`
+	Location string `json:"-"`
 }
 
 type Resource struct {
@@ -11,6 +11,7 @@ import (
 	"github.com/bloqs-sites/bloqsenjin/internal/models"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
 	"github.com/bloqs-sites/bloqsenjin/pkg/rest"
+	"github.com/redis/go-redis/v9"
 )
 
 func Server(ctx context.Context, endpoint string) http.HandlerFunc {
@@ -25,11 +26,28 @@ func Server(ctx context.Context, endpoint string) http.HandlerFunc {
 
 	s := rest.NewRESTServer(endpoint, dbh)
 
+	// the KV store is optional, without it features like the activity feed are
+	// disabled
+	if dsn := strings.TrimSpace(os.Getenv("BLOQS_REST_REDIS_DSN")); dsn != "" {
+		opt, err := redis.ParseURL(dsn)
+		if err != nil {
+			panic(fmt.Errorf("could not parse the `BLOQS_REST_REDIS_DSN` to create the credentials to connect to the DB:\t%s", err))
+		}
+
+		kv, err := db.NewKeyDB(ctx, opt)
+		if err != nil {
+			panic(fmt.Errorf("error creating DB instance of type `%T`:\t%s", kv, err))
+		}
+
+		s.KV = kv
+	}
+
 	s.AttachHandler(context.Background(), "/preference", new(models.Preference))
 	s.AttachHandler(context.Background(), "/profile", new(models.Profile))
 	s.AttachHandler(context.Background(), "/bloq", new(models.Bloq))
 	s.AttachHandler(context.Background(), "/offer", new(models.Offer))
 	s.AttachHandler(context.Background(), "/order", new(models.Order))
+	s.AttachHandler(context.Background(), "/org", new(models.Org))
 
 	return s.Serve()
 }
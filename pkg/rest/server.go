@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/bloqs-sites/bloqsenjin/internal/helpers"
 	"github.com/bloqs-sites/bloqsenjin/pkg/conf"
@@ -17,6 +18,7 @@ import (
 type RESTServer struct {
 	mux      *mux.Router
 	DBH      db.DataManipulater
+	KV       db.KVDBer
 	segments []string
 }
 
@@ -31,6 +33,11 @@ func (s *RESTServer) AttachHandler(ctx context.Context, route string, h Handler)
 	if err := s.DBH.CreateTables(ctx, h.CreateTable()); err != nil {
 		fmt.Printf("%v\n", err)
 	}
+	if m, ok := h.(db.Migrater); ok {
+		if err := s.DBH.Migrate(ctx, m.Migrations()); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	}
 	if err := s.DBH.CreateIndexes(ctx, h.CreateIndexes()); err != nil {
 		fmt.Printf("%v\n", err)
 	}
@@ -197,7 +204,9 @@ func (s *RESTServer) AttachHandler(ctx context.Context, route string, h Handler)
 				id = &id_str
 			}
 
-			if id != nil {
+			if created.Location != "" {
+				w.Header().Set("Location", fmt.Sprintf("%s/%s", domain, strings.TrimPrefix(created.Location, "/")))
+			} else if id != nil {
 				w.Header().Set("Location", fmt.Sprintf("%s/%s/%s", domain, h.Table(), *id))
 			}
 			if w.Header().Get("Content-Type") == "" {
@@ -205,12 +214,40 @@ func (s *RESTServer) AttachHandler(ctx context.Context, route string, h Handler)
 			}
 			w.WriteHeader(int(created.Status))
 			w.Write([]byte(created.Message))
+		case http.MethodPut:
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				break
+			}
+
+			var resource *Resource
+			resource, err = h.Update(w, r, *s)
+
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				break
+			}
+
+			if resource == nil {
+				status = http.StatusMethodNotAllowed
+				err = &mux.HttpError{
+					Status: status,
+				}
+				break
+			}
+
+			err = writeResource(w, resource)
 		case http.MethodDelete:
-			_, err = h.Delete(w, r, *s)
+			var resource *Resource
+			resource, err = h.Delete(w, r, *s)
 			if err != nil {
 				fmt.Printf("%v\n", err)
 				break
 			}
+
+			if resource != nil {
+				err = writeResource(w, resource)
+			}
 		case http.MethodOptions:
 			http_helpers.Append(&headers, "Access-Control-Allow-Methods", http.MethodHead)
 			http_helpers.Append(&headers, "Access-Control-Allow-Methods", http.MethodGet)
@@ -249,6 +286,31 @@ func (s *RESTServer) AttachHandler(ctx context.Context, route string, h Handler)
 	})
 }
 
+func writeResource(w http.ResponseWriter, resource *Resource) error {
+	status := resource.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if len(resource.Models) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(status))
+		return json.NewEncoder(w).Encode(resource.Models)
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/plain")
+	}
+	w.WriteHeader(int(status))
+
+	if status != http.StatusNoContent && resource.Message != "" {
+		_, err := w.Write([]byte(resource.Message))
+		return err
+	}
+
+	return nil
+}
+
 func (s *RESTServer) Serve() http.HandlerFunc {
 	return s.mux.ServeHTTP
 }
@@ -26,10 +26,32 @@ type AuthClient interface {
 	SignOut(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Validation, error)
 	LogIn(ctx context.Context, in *AskPermissions, opts ...grpc.CallOption) (*TokenValidation, error)
 	LogOut(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Validation, error)
+	LogOutEverywhere(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Validation, error)
 	IsSuper(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*Validation, error)
 	GrantSuper(ctx context.Context, in *CredentialsWithToken, opts ...grpc.CallOption) (*Validation, error)
 	RevokeSuper(ctx context.Context, in *CredentialsWithToken, opts ...grpc.CallOption) (*Validation, error)
 	Validate(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Validation, error)
+	Refresh(ctx context.Context, in *Token, opts ...grpc.CallOption) (*TokenValidation, error)
+	EnrolTOTP(ctx context.Context, in *Token, opts ...grpc.CallOption) (*TOTPEnrolment, error)
+	ConfirmTOTP(ctx context.Context, in *TOTPCode, opts ...grpc.CallOption) (*RecoveryCodes, error)
+	DisableTOTP(ctx context.Context, in *TOTPCode, opts ...grpc.CallOption) (*Validation, error)
+	SendVerification(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Validation, error)
+	VerifyEmail(ctx context.Context, in *EmailToken, opts ...grpc.CallOption) (*Validation, error)
+	RequestPasswordReset(ctx context.Context, in *Email, opts ...grpc.CallOption) (*Validation, error)
+	ResetPassword(ctx context.Context, in *EmailToken, opts ...grpc.CallOption) (*Validation, error)
+	ChangePassword(ctx context.Context, in *PasswordChange, opts ...grpc.CallOption) (*Validation, error)
+	ChangeEmail(ctx context.Context, in *EmailChange, opts ...grpc.CallOption) (*Validation, error)
+	ConfirmEmailChange(ctx context.Context, in *EmailToken, opts ...grpc.CallOption) (*Validation, error)
+	GetRoles(ctx context.Context, in *RoleAssignment, opts ...grpc.CallOption) (*Roles, error)
+	GrantRole(ctx context.Context, in *RoleAssignment, opts ...grpc.CallOption) (*Validation, error)
+	RevokeRole(ctx context.Context, in *RoleAssignment, opts ...grpc.CallOption) (*Validation, error)
+	Introspect(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Introspection, error)
+	CreateAPIKey(ctx context.Context, in *APIKeyRequest, opts ...grpc.CallOption) (*APIKeyValidation, error)
+	ListAPIKeys(ctx context.Context, in *Token, opts ...grpc.CallOption) (*APIKeys, error)
+	RevokeAPIKey(ctx context.Context, in *APIKeyRevocation, opts ...grpc.CallOption) (*Validation, error)
+	ListSessions(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Sessions, error)
+	RevokeSession(ctx context.Context, in *SessionRevocation, opts ...grpc.CallOption) (*Validation, error)
+	Audit(ctx context.Context, in *AuditQuery, opts ...grpc.CallOption) (*AuditEvents, error)
 }
 
 type authClient struct {
@@ -76,6 +98,15 @@ func (c *authClient) LogOut(ctx context.Context, in *Token, opts ...grpc.CallOpt
 	return out, nil
 }
 
+func (c *authClient) LogOutEverywhere(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/LogOutEverywhere", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *authClient) IsSuper(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*Validation, error) {
 	out := new(Validation)
 	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/IsSuper", in, out, opts...)
@@ -112,6 +143,195 @@ func (c *authClient) Validate(ctx context.Context, in *Token, opts ...grpc.CallO
 	return out, nil
 }
 
+func (c *authClient) Refresh(ctx context.Context, in *Token, opts ...grpc.CallOption) (*TokenValidation, error) {
+	out := new(TokenValidation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/Refresh", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) EnrolTOTP(ctx context.Context, in *Token, opts ...grpc.CallOption) (*TOTPEnrolment, error) {
+	out := new(TOTPEnrolment)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/EnrolTOTP", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ConfirmTOTP(ctx context.Context, in *TOTPCode, opts ...grpc.CallOption) (*RecoveryCodes, error) {
+	out := new(RecoveryCodes)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/ConfirmTOTP", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) DisableTOTP(ctx context.Context, in *TOTPCode, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/DisableTOTP", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) SendVerification(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/SendVerification", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) VerifyEmail(ctx context.Context, in *EmailToken, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/VerifyEmail", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) RequestPasswordReset(ctx context.Context, in *Email, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/RequestPasswordReset", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ResetPassword(ctx context.Context, in *EmailToken, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/ResetPassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ChangePassword(ctx context.Context, in *PasswordChange, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/ChangePassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ChangeEmail(ctx context.Context, in *EmailChange, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/ChangeEmail", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ConfirmEmailChange(ctx context.Context, in *EmailToken, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/ConfirmEmailChange", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GetRoles(ctx context.Context, in *RoleAssignment, opts ...grpc.CallOption) (*Roles, error) {
+	out := new(Roles)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/GetRoles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GrantRole(ctx context.Context, in *RoleAssignment, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/GrantRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) RevokeRole(ctx context.Context, in *RoleAssignment, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/RevokeRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) Introspect(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Introspection, error) {
+	out := new(Introspection)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/Introspect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) CreateAPIKey(ctx context.Context, in *APIKeyRequest, opts ...grpc.CallOption) (*APIKeyValidation, error) {
+	out := new(APIKeyValidation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/CreateAPIKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ListAPIKeys(ctx context.Context, in *Token, opts ...grpc.CallOption) (*APIKeys, error) {
+	out := new(APIKeys)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/ListAPIKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) RevokeAPIKey(ctx context.Context, in *APIKeyRevocation, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/RevokeAPIKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ListSessions(ctx context.Context, in *Token, opts ...grpc.CallOption) (*Sessions, error) {
+	out := new(Sessions)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/ListSessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) RevokeSession(ctx context.Context, in *SessionRevocation, opts ...grpc.CallOption) (*Validation, error) {
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/RevokeSession", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) Audit(ctx context.Context, in *AuditQuery, opts ...grpc.CallOption) (*AuditEvents, error) {
+	out := new(AuditEvents)
+	err := c.cc.Invoke(ctx, "/bloqs.auth.Auth/Audit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServer is the server API for Auth service.
 // All implementations must embed UnimplementedAuthServer
 // for forward compatibility
@@ -120,10 +340,32 @@ type AuthServer interface {
 	SignOut(context.Context, *Token) (*Validation, error)
 	LogIn(context.Context, *AskPermissions) (*TokenValidation, error)
 	LogOut(context.Context, *Token) (*Validation, error)
+	LogOutEverywhere(context.Context, *Token) (*Validation, error)
 	IsSuper(context.Context, *Credentials) (*Validation, error)
 	GrantSuper(context.Context, *CredentialsWithToken) (*Validation, error)
 	RevokeSuper(context.Context, *CredentialsWithToken) (*Validation, error)
 	Validate(context.Context, *Token) (*Validation, error)
+	Refresh(context.Context, *Token) (*TokenValidation, error)
+	EnrolTOTP(context.Context, *Token) (*TOTPEnrolment, error)
+	ConfirmTOTP(context.Context, *TOTPCode) (*RecoveryCodes, error)
+	DisableTOTP(context.Context, *TOTPCode) (*Validation, error)
+	SendVerification(context.Context, *Token) (*Validation, error)
+	VerifyEmail(context.Context, *EmailToken) (*Validation, error)
+	RequestPasswordReset(context.Context, *Email) (*Validation, error)
+	ResetPassword(context.Context, *EmailToken) (*Validation, error)
+	ChangePassword(context.Context, *PasswordChange) (*Validation, error)
+	ChangeEmail(context.Context, *EmailChange) (*Validation, error)
+	ConfirmEmailChange(context.Context, *EmailToken) (*Validation, error)
+	GetRoles(context.Context, *RoleAssignment) (*Roles, error)
+	GrantRole(context.Context, *RoleAssignment) (*Validation, error)
+	RevokeRole(context.Context, *RoleAssignment) (*Validation, error)
+	Introspect(context.Context, *Token) (*Introspection, error)
+	CreateAPIKey(context.Context, *APIKeyRequest) (*APIKeyValidation, error)
+	ListAPIKeys(context.Context, *Token) (*APIKeys, error)
+	RevokeAPIKey(context.Context, *APIKeyRevocation) (*Validation, error)
+	ListSessions(context.Context, *Token) (*Sessions, error)
+	RevokeSession(context.Context, *SessionRevocation) (*Validation, error)
+	Audit(context.Context, *AuditQuery) (*AuditEvents, error)
 	mustEmbedUnimplementedAuthServer()
 }
 
@@ -143,6 +385,9 @@ func (UnimplementedAuthServer) LogIn(context.Context, *AskPermissions) (*TokenVa
 func (UnimplementedAuthServer) LogOut(context.Context, *Token) (*Validation, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LogOut not implemented")
 }
+func (UnimplementedAuthServer) LogOutEverywhere(context.Context, *Token) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LogOutEverywhere not implemented")
+}
 func (UnimplementedAuthServer) IsSuper(context.Context, *Credentials) (*Validation, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method IsSuper not implemented")
 }
@@ -155,6 +400,69 @@ func (UnimplementedAuthServer) RevokeSuper(context.Context, *CredentialsWithToke
 func (UnimplementedAuthServer) Validate(context.Context, *Token) (*Validation, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
 }
+func (UnimplementedAuthServer) Refresh(context.Context, *Token) (*TokenValidation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Refresh not implemented")
+}
+func (UnimplementedAuthServer) EnrolTOTP(context.Context, *Token) (*TOTPEnrolment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnrolTOTP not implemented")
+}
+func (UnimplementedAuthServer) ConfirmTOTP(context.Context, *TOTPCode) (*RecoveryCodes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmTOTP not implemented")
+}
+func (UnimplementedAuthServer) DisableTOTP(context.Context, *TOTPCode) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableTOTP not implemented")
+}
+func (UnimplementedAuthServer) SendVerification(context.Context, *Token) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendVerification not implemented")
+}
+func (UnimplementedAuthServer) VerifyEmail(context.Context, *EmailToken) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyEmail not implemented")
+}
+func (UnimplementedAuthServer) RequestPasswordReset(context.Context, *Email) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestPasswordReset not implemented")
+}
+func (UnimplementedAuthServer) ResetPassword(context.Context, *EmailToken) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetPassword not implemented")
+}
+func (UnimplementedAuthServer) ChangePassword(context.Context, *PasswordChange) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangePassword not implemented")
+}
+func (UnimplementedAuthServer) ChangeEmail(context.Context, *EmailChange) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangeEmail not implemented")
+}
+func (UnimplementedAuthServer) ConfirmEmailChange(context.Context, *EmailToken) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmEmailChange not implemented")
+}
+func (UnimplementedAuthServer) GetRoles(context.Context, *RoleAssignment) (*Roles, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoles not implemented")
+}
+func (UnimplementedAuthServer) GrantRole(context.Context, *RoleAssignment) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GrantRole not implemented")
+}
+func (UnimplementedAuthServer) RevokeRole(context.Context, *RoleAssignment) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeRole not implemented")
+}
+func (UnimplementedAuthServer) Introspect(context.Context, *Token) (*Introspection, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Introspect not implemented")
+}
+func (UnimplementedAuthServer) CreateAPIKey(context.Context, *APIKeyRequest) (*APIKeyValidation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAPIKey not implemented")
+}
+func (UnimplementedAuthServer) ListAPIKeys(context.Context, *Token) (*APIKeys, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAPIKeys not implemented")
+}
+func (UnimplementedAuthServer) RevokeAPIKey(context.Context, *APIKeyRevocation) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAPIKey not implemented")
+}
+func (UnimplementedAuthServer) ListSessions(context.Context, *Token) (*Sessions, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSessions not implemented")
+}
+func (UnimplementedAuthServer) RevokeSession(context.Context, *SessionRevocation) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSession not implemented")
+}
+func (UnimplementedAuthServer) Audit(context.Context, *AuditQuery) (*AuditEvents, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Audit not implemented")
+}
 func (UnimplementedAuthServer) mustEmbedUnimplementedAuthServer() {}
 
 // UnsafeAuthServer may be embedded to opt out of forward compatibility for this service.
@@ -240,6 +548,24 @@ func _Auth_LogOut_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Auth_LogOutEverywhere_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Token)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).LogOutEverywhere(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/LogOutEverywhere",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).LogOutEverywhere(ctx, req.(*Token))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Auth_IsSuper_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Credentials)
 	if err := dec(in); err != nil {
@@ -312,6 +638,384 @@ func _Auth_Validate_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Auth_Refresh_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Token)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Refresh(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/Refresh",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Refresh(ctx, req.(*Token))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_EnrolTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Token)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).EnrolTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/EnrolTOTP",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).EnrolTOTP(ctx, req.(*Token))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ConfirmTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCode)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ConfirmTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/ConfirmTOTP",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ConfirmTOTP(ctx, req.(*TOTPCode))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_DisableTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCode)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).DisableTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/DisableTOTP",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).DisableTOTP(ctx, req.(*TOTPCode))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_SendVerification_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Token)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).SendVerification(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/SendVerification",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).SendVerification(ctx, req.(*Token))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_VerifyEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmailToken)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).VerifyEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/VerifyEmail",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).VerifyEmail(ctx, req.(*EmailToken))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_RequestPasswordReset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Email)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).RequestPasswordReset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/RequestPasswordReset",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).RequestPasswordReset(ctx, req.(*Email))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ResetPassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmailToken)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ResetPassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/ResetPassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ResetPassword(ctx, req.(*EmailToken))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ChangePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PasswordChange)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ChangePassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/ChangePassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ChangePassword(ctx, req.(*PasswordChange))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ChangeEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmailChange)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ChangeEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/ChangeEmail",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ChangeEmail(ctx, req.(*EmailChange))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ConfirmEmailChange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmailToken)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ConfirmEmailChange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/ConfirmEmailChange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ConfirmEmailChange(ctx, req.(*EmailToken))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GetRoles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoleAssignment)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetRoles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/GetRoles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetRoles(ctx, req.(*RoleAssignment))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GrantRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoleAssignment)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GrantRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/GrantRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GrantRole(ctx, req.(*RoleAssignment))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_RevokeRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoleAssignment)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).RevokeRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/RevokeRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).RevokeRole(ctx, req.(*RoleAssignment))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_Introspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Token)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Introspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/Introspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Introspect(ctx, req.(*Token))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_CreateAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(APIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).CreateAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/CreateAPIKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).CreateAPIKey(ctx, req.(*APIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ListAPIKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Token)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ListAPIKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/ListAPIKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ListAPIKeys(ctx, req.(*Token))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_RevokeAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(APIKeyRevocation)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).RevokeAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/RevokeAPIKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).RevokeAPIKey(ctx, req.(*APIKeyRevocation))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Token)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/ListSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ListSessions(ctx, req.(*Token))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_RevokeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionRevocation)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).RevokeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/RevokeSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).RevokeSession(ctx, req.(*SessionRevocation))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_Audit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Audit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloqs.auth.Auth/Audit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Audit(ctx, req.(*AuditQuery))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Auth_ServiceDesc is the grpc.ServiceDesc for Auth service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -335,6 +1039,10 @@ var Auth_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "LogOut",
 			Handler:    _Auth_LogOut_Handler,
 		},
+		{
+			MethodName: "LogOutEverywhere",
+			Handler:    _Auth_LogOutEverywhere_Handler,
+		},
 		{
 			MethodName: "IsSuper",
 			Handler:    _Auth_IsSuper_Handler,
@@ -351,6 +1059,90 @@ var Auth_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Validate",
 			Handler:    _Auth_Validate_Handler,
 		},
+		{
+			MethodName: "Refresh",
+			Handler:    _Auth_Refresh_Handler,
+		},
+		{
+			MethodName: "EnrolTOTP",
+			Handler:    _Auth_EnrolTOTP_Handler,
+		},
+		{
+			MethodName: "ConfirmTOTP",
+			Handler:    _Auth_ConfirmTOTP_Handler,
+		},
+		{
+			MethodName: "DisableTOTP",
+			Handler:    _Auth_DisableTOTP_Handler,
+		},
+		{
+			MethodName: "SendVerification",
+			Handler:    _Auth_SendVerification_Handler,
+		},
+		{
+			MethodName: "VerifyEmail",
+			Handler:    _Auth_VerifyEmail_Handler,
+		},
+		{
+			MethodName: "RequestPasswordReset",
+			Handler:    _Auth_RequestPasswordReset_Handler,
+		},
+		{
+			MethodName: "ResetPassword",
+			Handler:    _Auth_ResetPassword_Handler,
+		},
+		{
+			MethodName: "ChangePassword",
+			Handler:    _Auth_ChangePassword_Handler,
+		},
+		{
+			MethodName: "ChangeEmail",
+			Handler:    _Auth_ChangeEmail_Handler,
+		},
+		{
+			MethodName: "ConfirmEmailChange",
+			Handler:    _Auth_ConfirmEmailChange_Handler,
+		},
+		{
+			MethodName: "GetRoles",
+			Handler:    _Auth_GetRoles_Handler,
+		},
+		{
+			MethodName: "GrantRole",
+			Handler:    _Auth_GrantRole_Handler,
+		},
+		{
+			MethodName: "RevokeRole",
+			Handler:    _Auth_RevokeRole_Handler,
+		},
+		{
+			MethodName: "Introspect",
+			Handler:    _Auth_Introspect_Handler,
+		},
+		{
+			MethodName: "CreateAPIKey",
+			Handler:    _Auth_CreateAPIKey_Handler,
+		},
+		{
+			MethodName: "ListAPIKeys",
+			Handler:    _Auth_ListAPIKeys_Handler,
+		},
+		{
+			MethodName: "RevokeAPIKey",
+			Handler:    _Auth_RevokeAPIKey_Handler,
+		},
+		{
+			MethodName: "ListSessions",
+			Handler:    _Auth_ListSessions_Handler,
+		},
+		{
+			MethodName: "RevokeSession",
+			Handler:    _Auth_RevokeSession_Handler,
+		},
+		{
+			MethodName: "Audit",
+			Handler:    _Auth_Audit_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/auth.proto",